@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"github.com/ilhamtubagus/shortenurl/server"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"log"
+	"net"
+	"time"
+)
+
+// defaultDatabase is the database used when MONGODB_DATABASE_NAME is not set
+const defaultDatabase = "shorten"
+
+// The collections of the database. Links keep the name of the collection they had before there
+// were others.
+const (
+	linksCollection       = "shorten"
+	clicksCollection      = "clicks"
+	usersCollection       = "users"
+	membershipsCollection = "memberships"
+	workspacesCollection  = "workspaces"
+	revisionsCollection   = "revisions"
+	auditCollection       = "audit"
+	importsCollection     = "imports"
+	apiKeysCollection     = "api_keys"
+	domainsCollection     = "domains"
+	migrationsCollection  = "migrations"
+)
+
+// app holds the connections, repositories and services commands working on the database share
+type app struct {
+	config      *config.Config
+	mongoClient *mongo.Client
+	redisClient *redis.Client
+	db          *mongo.Database
+
+	linkCache            repository.Cache[entity.ShortenedURL]
+	shortenedRepository  repository.ShortenedRepository
+	clickRepository      repository.ClickRepository
+	userRepository       repository.UserRepository
+	membershipRepository repository.MembershipRepository
+	revisionRepository   repository.RevisionRepository
+	auditRepository      repository.AuditRepository
+	apiKeyRepository     repository.APIKeyRepository
+	domainRepository     repository.DomainRepository
+	importRepository     repository.ImportRepository
+	migrationRepository  repository.MigrationRepository
+
+	domainVerifier   *services.DomainVerifier
+	domainService    services.DomainService
+	workspaceService services.WorkspaceService
+	auditService     services.AuditService
+	shortenedService services.ShortenedService
+	importService    services.ImportService
+	exportService    services.ExportService
+	userService      services.UserService
+	apiKeyService    services.APIKeyService
+}
+
+// defaultDomain is the domain links are created on when none is given
+func defaultDomain(cfg *config.Config) string {
+	if cfg.DefaultDomain != "" {
+		return cfg.DefaultDomain
+	}
+
+	return fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+}
+
+// databaseName is the database of MONGODB_DATABASE_NAME, defaultDatabase when it is not set
+func databaseName(cfg *config.Config) string {
+	if cfg.Mongo.Database != "" {
+		return cfg.Mongo.Database
+	}
+
+	return defaultDatabase
+}
+
+// workspaceClaimRole returns the role access tokens have in the workspaces of their workspace
+// claim, none when no claim is configured.
+func workspaceClaimRole(cfg *config.Config) (string, error) {
+	if cfg.OIDC.WorkspaceClaim == "" {
+		return "", nil
+	}
+
+	if !entity.IsRole(cfg.OIDC.WorkspaceRole) {
+		return "", fmt.Errorf("unknown OIDC_WORKSPACE_ROLE %q", cfg.OIDC.WorkspaceRole)
+	}
+
+	return cfg.OIDC.WorkspaceRole, nil
+}
+
+// newApp connects to MongoDB and Redis and creates the repositories and services, Close
+// disconnects again.
+func newApp(cfg *config.Config) (*app, error) {
+	claimRole, err := workspaceClaimRole(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoClient, err := server.ConnectMongoClient(cfg.Mongo)
+	if err != nil {
+		return nil, err
+	}
+
+	redisClient, err := server.ConnectRedisClient(cfg.Redis)
+	if err != nil {
+		_ = mongoClient.Disconnect(context.Background())
+		return nil, err
+	}
+
+	a := &app{config: cfg, mongoClient: mongoClient, redisClient: redisClient, db: mongoClient.Database(databaseName(cfg))}
+
+	a.linkCache = repository.NewRedisCache[entity.ShortenedURL](redisClient)
+	shortenCollection := a.db.Collection(linksCollection)
+	a.shortenedRepository = repository.NewShortenedRepository(a.linkCache, shortenCollection, *cfg)
+	a.clickRepository = repository.NewClickRepository(a.db.Collection(clicksCollection), shortenCollection)
+
+	metadataFetcher := services.NewHTTPMetadataFetcher(
+		time.Duration(cfg.Metadata.Timeout)*time.Second,
+		cfg.Metadata.MaxBytes)
+
+	a.userRepository = repository.NewUserRepository(a.db.Collection(usersCollection))
+	a.membershipRepository = repository.NewMembershipRepository(a.db.Collection(membershipsCollection))
+	workspaceRepository := repository.NewWorkspaceRepository(a.db.Collection(workspacesCollection))
+	a.workspaceService = services.NewWorkspaceService(workspaceRepository, a.membershipRepository, a.userRepository,
+		a.shortenedRepository, claimRole)
+
+	a.revisionRepository = repository.NewRevisionRepository(a.db.Collection(revisionsCollection))
+	a.auditRepository = repository.NewAuditRepository(a.db.Collection(auditCollection))
+	a.auditService = services.NewAuditService(a.auditRepository, a.workspaceService)
+
+	a.domainRepository = repository.NewDomainRepository(a.db.Collection(domainsCollection))
+	a.domainVerifier = services.NewDomainVerifier(a.domainRepository, net.DefaultResolver,
+		time.Duration(cfg.Verification.Interval)*time.Second,
+		time.Duration(cfg.Verification.Timeout)*time.Second,
+		time.Duration(cfg.Verification.PendingTTL)*time.Second,
+		cfg.Verification.MaxFailedChecks)
+	a.domainService = services.NewDomainService(a.domainRepository, a.domainVerifier, a.workspaceService, defaultDomain(cfg))
+
+	a.shortenedService = services.NewShortenedService(a.shortenedRepository, a.clickRepository, a.revisionRepository, metadataFetcher,
+		a.workspaceService, a.domainService, a.auditService, cfg.Auth.AllowAnonymousShorten)
+
+	a.importRepository = repository.NewImportRepository(a.db.Collection(importsCollection))
+	a.importService = services.NewImportService(a.importRepository, a.shortenedService, a.workspaceService)
+	a.exportService = services.NewExportService(a.shortenedRepository, a.clickRepository, a.workspaceService)
+
+	sessionRepository := repository.NewSessionRepository(repository.NewRedisCache[entity.Session](redisClient))
+	a.userService = services.NewUserService(a.userRepository, sessionRepository, a.sessionTTL(), cfg.Auth.TOTPIssuer)
+
+	a.apiKeyRepository = repository.NewAPIKeyRepository(a.db.Collection(apiKeysCollection))
+	a.apiKeyService = services.NewAPIKeyService(a.apiKeyRepository, a.userRepository)
+
+	a.migrationRepository = repository.NewMigrationRepository(a.db, a.db.Collection(migrationsCollection))
+
+	return a, nil
+}
+
+func (a *app) sessionTTL() time.Duration {
+	return time.Duration(a.config.Auth.SessionTTL) * time.Second
+}
+
+// Close disconnects from MongoDB and Redis.
+func (a *app) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := a.mongoClient.Disconnect(ctx)
+	if err != nil {
+		log.Printf("error disconnecting from MongoDB %v\n", err)
+	}
+
+	err = a.redisClient.Close()
+	if err != nil {
+		log.Printf("error disconnecting from Redis %v\n", err)
+	}
+}
+
+// newAccessTokenService trusts access tokens of the configured identity provider, it returns
+// nil when none is configured.
+func (a *app) newAccessTokenService(ctx context.Context) (services.AccessTokenService, error) {
+	oidc := a.config.OIDC
+	if oidc.JWKS == "" {
+		return nil, nil
+	}
+
+	if oidc.Issuer == "" || oidc.Audience == "" {
+		return nil, fmt.Errorf("OIDC_ISSUER and OIDC_AUDIENCE are required when OIDC_JWKS is set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	verifier := services.NewTokenVerifier(oidc.JWKS, oidc.Issuer, oidc.Audience, time.Duration(oidc.Leeway)*time.Second)
+
+	err := verifier.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NewAccessTokenService(verifier, a.userRepository, oidc.WorkspaceClaim), nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/client"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/routes"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// backend is where the links, imports and exports of commands are, the database or a running
+// server. Both check the permissions of the user they act for the same way.
+type backend interface {
+	CreateLink(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error)
+	GetLink(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error)
+	ListLinks(ctx context.Context, query url.Values) (*entity.LinkPage, error)
+	UpdateLink(ctx context.Context, domain string, shortCode string, update entity.LinkUpdate) (*entity.ShortenedURL, error)
+	DeleteLink(ctx context.Context, domain string, shortCode string) error
+	RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error)
+	CreateImport(ctx context.Context, domain string, format string, name string, export io.Reader) (*entity.ImportJob, error)
+	ResumeImport(ctx context.Context, id string, export io.Reader) (*entity.ImportJob, error)
+	GetImport(ctx context.Context, id string) (*entity.ImportJob, error)
+	ExportLinks(ctx context.Context, format string, query url.Values, w io.Writer) error
+	ExportClicks(ctx context.Context, format string, query url.Values, w io.Writer) error
+}
+
+// target holds the flags choosing the backend of a command and who it acts as
+type target struct {
+	api       string
+	token     string
+	user      string
+	workspace string
+}
+
+func addTargetFlags(flags *flag.FlagSet) *target {
+	t := &target{}
+	flags.StringVar(&t.api, "api", os.Getenv("SHORTENURL_API"),
+		"URL of a running server to call instead of using the database, $SHORTENURL_API by default")
+	flags.StringVar(&t.token, "token", os.Getenv("SHORTENURL_TOKEN"),
+		"API key or access token sent to -api, $SHORTENURL_TOKEN by default")
+	flags.StringVar(&t.user, "user", os.Getenv("SHORTENURL_USER"),
+		"email of the user to act as on the database, $SHORTENURL_USER by default")
+	flags.StringVar(&t.workspace, "workspace", "", "workspace to work in, the personal workspace by default")
+
+	return t
+}
+
+// open returns the backend of the flags and a function releasing it. Without -api commands
+// connect to the database themselves and act as -user.
+func (t *target) open(ctx context.Context, cfg *config.Config) (backend, func(), error) {
+	if t.api != "" {
+		return client.NewClient(t.api, t.token, t.workspace), func() {}, nil
+	}
+
+	if t.user == "" {
+		return nil, nil, fmt.Errorf("either -api or -user is required")
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := a.userRepository.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(t.user)))
+	if err != nil {
+		a.Close()
+		return nil, nil, fmt.Errorf("user %s: %w", t.user, err)
+	}
+
+	// operators on the database are trusted like a second factor, so workspaces requiring one let them in
+	principal := &entity.Principal{UserID: user.ID, Email: user.Email, TwoFactor: true}
+
+	return &databaseBackend{app: a, principal: principal, workspace: t.workspace}, a.Close, nil
+}
+
+// withBackend opens the backend chosen by the target flags and calls fn with it
+func withBackend(cfg *config.Config, t *target, fn func(ctx context.Context, b backend) error) error {
+	ctx := context.Background()
+
+	b, release, err := t.open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn(ctx, b)
+}
+
+// databaseBackend calls the services directly, acting for principal in workspace
+type databaseBackend struct {
+	app       *app
+	principal *entity.Principal
+	workspace string
+}
+
+func (d *databaseBackend) context(ctx context.Context) context.Context {
+	ctx = services.WithPrincipal(ctx, d.principal)
+	if d.workspace != "" {
+		ctx = services.WithWorkspace(ctx, d.workspace)
+	}
+
+	return ctx
+}
+
+// domain returns the registered domain of a request, the default domain when it is empty
+func (d *databaseBackend) domain(ctx context.Context, domain string) (string, error) {
+	if domain == "" {
+		return d.app.domainService.DefaultDomain(), nil
+	}
+
+	domain = entity.NormalizeHost(domain)
+	_, err := d.app.domainService.GetDomain(ctx, domain)
+
+	return domain, err
+}
+
+func (d *databaseBackend) CreateLink(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error) {
+	domain, err := d.domain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	err = routes.CheckURL("original URL", originalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.app.shortenedService.ShortenURL(d.context(ctx), domain, originalURL)
+}
+
+func (d *databaseBackend) GetLink(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error) {
+	domain, err := d.domain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.app.shortenedService.GetLink(d.context(ctx), domain, shortCode)
+}
+
+func (d *databaseBackend) ListLinks(ctx context.Context, values url.Values) (*entity.LinkPage, error) {
+	query, err := routes.ParseLinkQuery(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.app.shortenedService.ListShortenedURLs(d.context(ctx), query)
+}
+
+func (d *databaseBackend) UpdateLink(ctx context.Context, domain string, shortCode string, update entity.LinkUpdate) (*entity.ShortenedURL, error) {
+	if update.OriginalURL == "" && update.FallbackURL == nil {
+		return nil, fmt.Errorf("%w: nothing to update", constants.ErrorInvalidRequest)
+	}
+
+	var err error
+	if update.OriginalURL != "" {
+		err = routes.CheckURL("original URL", update.OriginalURL)
+	}
+	if err == nil && update.FallbackURL != nil && *update.FallbackURL != "" {
+		err = routes.CheckURL("fallback URL", *update.FallbackURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err = d.domain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = d.context(ctx)
+
+	var shortened *entity.ShortenedURL
+	if update.OriginalURL != "" {
+		shortened, err = d.app.shortenedService.UpdateShortenedURL(ctx, domain, shortCode, update.OriginalURL)
+	}
+	if err == nil && update.FallbackURL != nil {
+		shortened, err = d.app.shortenedService.UpdateFallbackURL(ctx, domain, shortCode, *update.FallbackURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+func (d *databaseBackend) DeleteLink(ctx context.Context, domain string, shortCode string) error {
+	domain, err := d.domain(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	return d.app.shortenedService.DeleteShortenedURL(d.context(ctx), domain, shortCode)
+}
+
+func (d *databaseBackend) RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error) {
+	return d.app.shortenedService.RewriteDestinations(d.context(ctx), rewrite)
+}
+
+// CreateImport runs the import before returning, unlike the API which runs it in the background
+func (d *databaseBackend) CreateImport(ctx context.Context, domain string, format string, name string, export io.Reader) (*entity.ImportJob, error) {
+	domain, err := d.domain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(export)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = d.context(ctx)
+
+	job, links, err := d.app.importService.CreateImport(ctx, domain, format, name, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.app.importService.RunImport(ctx, job, links)
+}
+
+func (d *databaseBackend) ResumeImport(ctx context.Context, id string, export io.Reader) (*entity.ImportJob, error) {
+	body, err := io.ReadAll(export)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = d.context(ctx)
+
+	job, links, err := d.app.importService.ResumeImport(ctx, id, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.app.importService.RunImport(ctx, job, links)
+}
+
+func (d *databaseBackend) GetImport(ctx context.Context, id string) (*entity.ImportJob, error) {
+	return d.app.importService.GetImport(d.context(ctx), id)
+}
+
+func (d *databaseBackend) ExportLinks(ctx context.Context, format string, values url.Values, w io.Writer) error {
+	query, err := routes.ParseLinkQuery(values)
+	if err != nil {
+		return err
+	}
+
+	return d.app.exportService.ExportLinks(d.context(ctx), format, query, w)
+}
+
+func (d *databaseBackend) ExportClicks(ctx context.Context, format string, values url.Values, w io.Writer) error {
+	query, err := routes.ParseClickQuery(values)
+	if err != nil {
+		return err
+	}
+
+	return d.app.exportService.ExportClicks(d.context(ctx), format, query, w)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockShortenedService is a mock type for services.ShortenedService, only the methods the
+// database backend calls are implemented
+type MockShortenedService struct {
+	services.ShortenedService
+	mock.Mock
+}
+
+func (m *MockShortenedService) ShortenURL(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, originalURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) GetLink(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) ListShortenedURLs(ctx context.Context, query entity.LinkQuery) (*entity.LinkPage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*entity.LinkPage), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateShortenedURL(ctx context.Context, domain string, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, originalURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateFallbackURL(ctx context.Context, domain string, shortcode string, fallbackURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, fallbackURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) DeleteShortenedURL(ctx context.Context, domain string, shortcode string) error {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Error(0)
+}
+
+func (m *MockShortenedService) RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error) {
+	args := m.Called(ctx, rewrite)
+	return args.Get(0).(*entity.DestinationRewriteResult), args.Error(1)
+}
+
+// MockDomainService is a mock type for services.DomainService, only the methods the database
+// backend calls are implemented
+type MockDomainService struct {
+	services.DomainService
+	mock.Mock
+}
+
+func (m *MockDomainService) DefaultDomain() string {
+	return "short.url"
+}
+
+func (m *MockDomainService) GetDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+// MockImportService is a mock type for services.ImportService
+type MockImportService struct {
+	mock.Mock
+}
+
+func (m *MockImportService) CreateImport(ctx context.Context, domain string, format string, name string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	args := m.Called(ctx, domain, format, name, body)
+	return args.Get(0).(*entity.ImportJob), args.Get(1).([]entity.ImportLink), args.Error(2)
+}
+
+func (m *MockImportService) ResumeImport(ctx context.Context, id string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	args := m.Called(ctx, id, body)
+	return args.Get(0).(*entity.ImportJob), args.Get(1).([]entity.ImportLink), args.Error(2)
+}
+
+func (m *MockImportService) RunImport(ctx context.Context, job *entity.ImportJob, links []entity.ImportLink) (*entity.ImportJob, error) {
+	args := m.Called(ctx, job, links)
+	return args.Get(0).(*entity.ImportJob), args.Error(1)
+}
+
+func (m *MockImportService) GetImport(ctx context.Context, id string) (*entity.ImportJob, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.ImportJob), args.Error(1)
+}
+
+// MockExportService is a mock type for services.ExportService
+type MockExportService struct {
+	mock.Mock
+}
+
+func (m *MockExportService) ExportLinks(ctx context.Context, format string, query entity.LinkQuery, w io.Writer) error {
+	args := m.Called(ctx, format, query, w)
+	return args.Error(0)
+}
+
+func (m *MockExportService) ExportClicks(ctx context.Context, format string, query entity.ClickQuery, w io.Writer) error {
+	args := m.Called(ctx, format, query, w)
+	return args.Error(0)
+}
+
+func TestDatabaseBackend(t *testing.T) {
+	ctx := context.Background()
+	principal := &entity.Principal{UserID: "user-1", Email: "ada@acme.com", TwoFactor: true}
+	link := &entity.ShortenedURL{Domain: "go.acme.link", ShortCode: "abc123", OriginalURL: "https://example.com"}
+	job := &entity.ImportJob{ID: "job1", Status: entity.ImportStatusRunning}
+	done := &entity.ImportJob{ID: "job1", Status: entity.ImportStatusCompleted}
+	rows := []entity.ImportLink{{ShortCode: "abc123", OriginalURL: "https://example.com"}}
+	fallbackURL := ""
+
+	// the services are called for the principal, in the workspace of the backend
+	acting := mock.MatchedBy(func(ctx context.Context) bool {
+		actor, ok := services.PrincipalFromContext(ctx)
+		return ok && actor == principal && services.WorkspaceFromContext(ctx) == "acme"
+	})
+
+	type mocks struct {
+		links   *MockShortenedService
+		domains *MockDomainService
+		imports *MockImportService
+		exports *MockExportService
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(m mocks)
+		call    func(b backend) (any, error)
+		want    any
+		wantErr error
+	}{
+		{
+			name: "CreateLinkOnDefaultDomain",
+			setup: func(m mocks) {
+				m.links.On("ShortenURL", acting, "short.url", "https://example.com").Return(link, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.CreateLink(ctx, "", "https://example.com")
+			},
+			want: link,
+		},
+		{
+			name: "CreateLinkOnDomain",
+			setup: func(m mocks) {
+				m.domains.On("GetDomain", ctx, "go.acme.link").Return(&entity.Domain{Name: "go.acme.link"}, nil)
+				m.links.On("ShortenURL", acting, "go.acme.link", "https://example.com").Return(link, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.CreateLink(ctx, "Go.Acme.Link", "https://example.com")
+			},
+			want: link,
+		},
+		{
+			name: "CreateLinkOnUnknownDomain",
+			setup: func(m mocks) {
+				m.domains.On("GetDomain", ctx, "go.other.link").Return((*entity.Domain)(nil), constants.ErrorNotFound)
+			},
+			call: func(b backend) (any, error) {
+				return b.CreateLink(ctx, "go.other.link", "https://example.com")
+			},
+			wantErr: constants.ErrorNotFound,
+		},
+		{
+			name: "CreateLinkInvalidURL",
+			call: func(b backend) (any, error) {
+				return b.CreateLink(ctx, "", "ftp://example.com")
+			},
+			wantErr: constants.ErrorInvalidRequest,
+		},
+		{
+			name: "GetLink",
+			setup: func(m mocks) {
+				m.links.On("GetLink", acting, "short.url", "abc123").Return(link, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.GetLink(ctx, "", "abc123")
+			},
+			want: link,
+		},
+		{
+			name: "ListLinks",
+			setup: func(m mocks) {
+				m.links.On("ListShortenedURLs", acting, mock.MatchedBy(func(query entity.LinkQuery) bool {
+					return query.Tag == "sales" && query.Sort == "-clicks" && query.Limit == 10
+				})).Return(&entity.LinkPage{NextCursor: "next"}, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.ListLinks(ctx, url.Values{"tag": {"sales"}, "sort": {"-clicks"}, "limit": {"10"}})
+			},
+			want: &entity.LinkPage{NextCursor: "next"},
+		},
+		{
+			name: "ListLinksInvalidQuery",
+			call: func(b backend) (any, error) {
+				return b.ListLinks(ctx, url.Values{"limit": {"many"}})
+			},
+			wantErr: constants.ErrorInvalidRequest,
+		},
+		{
+			name: "UpdateLink",
+			setup: func(m mocks) {
+				m.links.On("UpdateShortenedURL", acting, "short.url", "abc123", "https://example.com/new").Return(link, nil)
+				m.links.On("UpdateFallbackURL", acting, "short.url", "abc123", "").Return(link, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.UpdateLink(ctx, "", "abc123", entity.LinkUpdate{OriginalURL: "https://example.com/new", FallbackURL: &fallbackURL})
+			},
+			want: link,
+		},
+		{
+			name: "UpdateLinkWithoutChange",
+			call: func(b backend) (any, error) {
+				return b.UpdateLink(ctx, "", "abc123", entity.LinkUpdate{})
+			},
+			wantErr: constants.ErrorInvalidRequest,
+		},
+		{
+			name: "DeleteLink",
+			setup: func(m mocks) {
+				m.links.On("DeleteShortenedURL", acting, "short.url", "abc123").Return(nil)
+			},
+			call: func(b backend) (any, error) {
+				return nil, b.DeleteLink(ctx, "", "abc123")
+			},
+		},
+		{
+			name: "RewriteDestinations",
+			setup: func(m mocks) {
+				m.links.On("RewriteDestinations", acting, entity.DestinationRewrite{From: "docs.old.com", To: "docs.new.com", DryRun: true}).
+					Return(&entity.DestinationRewriteResult{DryRun: true, Matched: 2}, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.RewriteDestinations(ctx, entity.DestinationRewrite{From: "docs.old.com", To: "docs.new.com", DryRun: true})
+			},
+			want: &entity.DestinationRewriteResult{DryRun: true, Matched: 2},
+		},
+		{
+			name: "CreateImport",
+			setup: func(m mocks) {
+				m.imports.On("CreateImport", acting, "short.url", entity.ImportFormatBitly, "links.csv", []byte("rows")).Return(job, rows, nil)
+				m.imports.On("RunImport", acting, job, rows).Return(done, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.CreateImport(ctx, "", entity.ImportFormatBitly, "links.csv", strings.NewReader("rows"))
+			},
+			want: done,
+		},
+		{
+			name: "ResumeImport",
+			setup: func(m mocks) {
+				m.imports.On("ResumeImport", acting, "job1", []byte("rows")).Return(job, rows, nil)
+				m.imports.On("RunImport", acting, job, rows).Return(done, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.ResumeImport(ctx, "job1", strings.NewReader("rows"))
+			},
+			want: done,
+		},
+		{
+			name: "GetImport",
+			setup: func(m mocks) {
+				m.imports.On("GetImport", acting, "job1").Return(done, nil)
+			},
+			call: func(b backend) (any, error) {
+				return b.GetImport(ctx, "job1")
+			},
+			want: done,
+		},
+		{
+			name: "ExportLinks",
+			setup: func(m mocks) {
+				m.exports.On("ExportLinks", acting, entity.ExportFormatJSONLines, mock.MatchedBy(func(query entity.LinkQuery) bool {
+					return query.Tag == "sales" && query.Status == entity.LinkStatusActive
+				}), mock.Anything).Return(nil)
+			},
+			call: func(b backend) (any, error) {
+				return nil, b.ExportLinks(ctx, entity.ExportFormatJSONLines, url.Values{"tag": {"sales"}, "status": {"active"}}, &bytes.Buffer{})
+			},
+		},
+		{
+			name: "ExportClicks",
+			setup: func(m mocks) {
+				from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+				m.exports.On("ExportClicks", acting, entity.ExportFormatCSV, mock.MatchedBy(func(query entity.ClickQuery) bool {
+					return query.Domain == "go.acme.link" && query.From.Equal(from)
+				}), mock.Anything).Return(nil)
+			},
+			call: func(b backend) (any, error) {
+				return nil, b.ExportClicks(ctx, entity.ExportFormatCSV, url.Values{"domain": {"go.acme.link"}, "from": {"2026-03-01"}}, &bytes.Buffer{})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mocks{new(MockShortenedService), new(MockDomainService), new(MockImportService), new(MockExportService)}
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			b := &databaseBackend{
+				app:       &app{shortenedService: m.links, domainService: m.domains, importService: m.imports, exportService: m.exports},
+				principal: principal,
+				workspace: "acme",
+			}
+
+			got, err := tt.call(b)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else if assert.NoError(t, err) && tt.want != nil {
+				assert.Equal(t, tt.want, got)
+			}
+			m.links.AssertExpectations(t)
+			m.domains.AssertExpectations(t)
+			m.imports.AssertExpectations(t)
+			m.exports.AssertExpectations(t)
+		})
+	}
+}
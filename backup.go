@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"io"
+	"log"
+	"os"
+)
+
+// backupCollections are the collections backups hold besides the daily click counts. Audit
+// entries, revisions, imports and raw clicks are left out.
+var backupCollections = []string{domainsCollection, linksCollection, usersCollection, apiKeysCollection, workspacesCollection, membershipsCollection}
+
+func (a *app) newBackupService() services.BackupService {
+	backupRepository := repository.NewBackupRepository(a.db, a.db.Collection(linksCollection), a.db.Collection(clicksCollection), a.linkCache)
+
+	return services.NewBackupService(backupRepository, backupCollections)
+}
+
+// backupCommand writes a backup archive to the file given with -o, or to standard output.
+// The file only appears once the backup is complete.
+func backupCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("backup", "backup [-o file] [-allow-inconsistent]")
+	output := flags.String("o", "", "file to write the archive to, standard output when empty")
+	allowInconsistent := flags.Bool("allow-inconsistent", false,
+		"back up servers without snapshot reads, reading the collections one after the other")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var manifest *entity.BackupManifest
+	err = writeOutput(*output, func(w io.Writer) error {
+		var err error
+		manifest, err = a.newBackupService().Backup(context.Background(), w, *allowInconsistent)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range manifest.Collections {
+		log.Printf("backed up %d documents of %s\n", collection.Documents, collection.Name)
+	}
+	if !manifest.Consistent {
+		log.Println("the backup was not read from a snapshot, collections may be inconsistent with each other")
+	}
+
+	return nil
+}
+
+// restoreCommand loads a backup archive into the database, existing documents are handled
+// with the conflict mode given with -mode.
+func restoreCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("restore", "restore [-mode skip|overwrite|fail] archive.tar.gz")
+	mode := flags.String("mode", entity.RestoreFail,
+		fmt.Sprintf("what to do with documents that exist already: %s, %s or %s", entity.RestoreSkip, entity.RestoreOverwrite, entity.RestoreFail))
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	archive, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	restored, err := a.newBackupService().Restore(context.Background(), archive, *mode)
+	if restored != nil {
+		if printErr := printJSON(restored); err == nil {
+			err = printErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"github.com/ilhamtubagus/shortenurl/server"
+	"log"
+	"os"
+)
+
+const cacheUsage = "cache flush|inspect [flags] [arguments]"
+
+// cacheCommand works on the redirects cached in Redis, it leaves sessions alone.
+func cacheCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: shortenurl %s\n", cacheUsage)
+		return errUsage
+	}
+
+	switch args[0] {
+	case "flush":
+		return cacheFlush(cfg, args[1:])
+	case "inspect":
+		return cacheInspect(cfg, args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown cache command %q\nusage: shortenurl %s\n", args[0], cacheUsage)
+
+	return errUsage
+}
+
+func newLinkCache(cfg *config.Config) (repository.LinkCache, func(), error) {
+	redisClient, err := server.ConnectRedisClient(cfg.Redis)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release := func() {
+		_ = redisClient.Close()
+	}
+
+	return repository.NewLinkCache(repository.NewRedisCache[entity.ShortenedURL](redisClient)), release, nil
+}
+
+// cacheFlush removes the cached redirects of a domain or of every domain, redirects then read
+// links from the database again
+func cacheFlush(cfg *config.Config, args []string) error {
+	flags := newFlagSet("cache flush", "cache flush [-domain name]")
+	domain := flags.String("domain", "", "only flush the links of the domain")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	linkCache, release, err := newLinkCache(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	flushed, err := linkCache.Flush(context.Background(), entity.NormalizeHost(*domain))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("flushed %d cached links\n", flushed)
+
+	return nil
+}
+
+// cacheInspect prints the cached redirect of a link as JSON
+func cacheInspect(cfg *config.Config, args []string) error {
+	flags := newFlagSet("cache inspect", "cache inspect [-domain name] short-code")
+	domain := flags.String("domain", "", "domain of the link, the default domain when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	if *domain == "" {
+		*domain = defaultDomain(cfg)
+	}
+
+	linkCache, release, err := newLinkCache(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	shortened, err := linkCache.Get(context.Background(), entity.NormalizeHost(*domain), flags.Arg(0))
+	if errors.Is(err, constants.ErrorCacheNotFound) {
+		return fmt.Errorf("%s/%s is not cached", entity.NormalizeHost(*domain), flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	return printJSON(shortened)
+}
@@ -0,0 +1,308 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the JSON API of a running server under /api/v1. It acts for the API key or
+// access token it is given, in the given workspace or the selected one when it is empty.
+type Client struct {
+	baseURL    string
+	token      string
+	workspace  string
+	httpClient *http.Client
+}
+
+// NewClient creates a client of the server at baseURL, such as http://localhost:8080.
+func NewClient(baseURL string, token string, workspace string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		workspace:  workspace,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// apiError is an error answered by the server, it wraps the constants error of its status so
+// callers tell them apart like errors of the services.
+type apiError struct {
+	message string
+	err     error
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// statusErrors are the constants errors the server answers with each status
+var statusErrors = map[int]error{
+	http.StatusNotFound:     constants.ErrorNotFound,
+	http.StatusBadRequest:   constants.ErrorInvalidRequest,
+	http.StatusConflict:     constants.ErrorAlreadyExists,
+	http.StatusUnauthorized: constants.ErrorUnauthorized,
+	http.StatusForbidden:    constants.ErrorForbidden,
+}
+
+func responseError(response *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(response.Body).Decode(&body)
+
+	if body.Error == "" {
+		body.Error = response.Status
+	}
+
+	err, ok := statusErrors[response.StatusCode]
+	if !ok {
+		return fmt.Errorf("server answered %s: %s", response.Status, body.Error)
+	}
+
+	return &apiError{message: body.Error, err: err}
+}
+
+// send makes a request of the API and returns the response of a successful one, the caller
+// closes its body.
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	values := url.Values{}
+	for key, value := range query {
+		values[key] = value
+	}
+	if c.workspace != "" {
+		values.Set("workspace", c.workspace)
+	}
+
+	target := c.baseURL + "/api/v1" + path
+	if len(values) > 0 {
+		target += "?" + values.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+
+		return nil, responseError(response)
+	}
+
+	return response, nil
+}
+
+// call sends body as JSON, unless it is nil, and decodes the data of the response into data,
+// unless it is nil.
+func (c *Client) call(ctx context.Context, method string, path string, query url.Values, body any, data any) error {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	response, err := c.send(ctx, method, path, query, reader, contentType)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if data == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(&struct {
+		Data any `json:"data"`
+	}{Data: data})
+}
+
+func domainQuery(domain string) url.Values {
+	query := url.Values{}
+	if domain != "" {
+		query.Set("domain", domain)
+	}
+
+	return query
+}
+
+func linkPath(shortCode string) string {
+	return "/links/" + url.PathEscape(shortCode)
+}
+
+// CreateLink shortens originalURL on domain, the default domain when it is empty.
+func (c *Client) CreateLink(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error) {
+	request := map[string]string{"originalURL": originalURL, "domain": domain}
+
+	var shortened entity.ShortenedURL
+	err := c.call(ctx, http.MethodPost, "/links", nil, request, &shortened)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortened, nil
+}
+
+func (c *Client) GetLink(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error) {
+	var shortened entity.ShortenedURL
+	err := c.call(ctx, http.MethodGet, linkPath(shortCode), domainQuery(domain), nil, &shortened)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortened, nil
+}
+
+// ListLinks returns a page of links, query holds the parameters of GET /api/v1/links.
+func (c *Client) ListLinks(ctx context.Context, query url.Values) (*entity.LinkPage, error) {
+	response, err := c.send(ctx, http.MethodGet, "/links", query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var page struct {
+		Data       []entity.ShortenedURL `json:"data"`
+		NextCursor string                `json:"nextCursor"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.LinkPage{Links: page.Data, NextCursor: page.NextCursor}, nil
+}
+
+func (c *Client) UpdateLink(ctx context.Context, domain string, shortCode string, update entity.LinkUpdate) (*entity.ShortenedURL, error) {
+	var shortened entity.ShortenedURL
+	err := c.call(ctx, http.MethodPut, linkPath(shortCode), domainQuery(domain), update, &shortened)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortened, nil
+}
+
+// DeleteLink moves a link to the trash.
+func (c *Client) DeleteLink(ctx context.Context, domain string, shortCode string) error {
+	return c.call(ctx, http.MethodDelete, linkPath(shortCode), domainQuery(domain), nil, nil)
+}
+
+func (c *Client) RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error) {
+	request := map[string]any{"from": rewrite.From, "to": rewrite.To, "dryRun": rewrite.DryRun}
+
+	var result entity.DestinationRewriteResult
+	err := c.call(ctx, http.MethodPost, "/bulk/destinations", nil, request, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CreateImport starts importing an export of another shortener in the given entity.ImportFormat
+// format, name tells the export apart in the job. The server runs the import in the background.
+func (c *Client) CreateImport(ctx context.Context, domain string, format string, name string, export io.Reader) (*entity.ImportJob, error) {
+	query := domainQuery(domain)
+	query.Set("format", format)
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	return c.sendImport(ctx, "/imports", query, export)
+}
+
+// ResumeImport carries on with a failed or interrupted import, given the same export again.
+func (c *Client) ResumeImport(ctx context.Context, id string, export io.Reader) (*entity.ImportJob, error) {
+	return c.sendImport(ctx, "/imports/"+url.PathEscape(id)+"/resume", nil, export)
+}
+
+func (c *Client) sendImport(ctx context.Context, path string, query url.Values, export io.Reader) (*entity.ImportJob, error) {
+	response, err := c.send(ctx, http.MethodPost, path, query, export, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var job struct {
+		Data entity.ImportJob `json:"data"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job.Data, nil
+}
+
+func (c *Client) GetImport(ctx context.Context, id string) (*entity.ImportJob, error) {
+	var job entity.ImportJob
+	err := c.call(ctx, http.MethodGet, "/imports/"+url.PathEscape(id), nil, nil, &job)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ExportLinks writes the links matching query, the parameters of link listings, to w in the
+// given entity.ExportFormat format.
+func (c *Client) ExportLinks(ctx context.Context, format string, query url.Values, w io.Writer) error {
+	return c.export(ctx, "/exports/links", format, query, w)
+}
+
+// ExportClicks writes the clicks matching query, with the tag, domain, from and to parameters,
+// to w in the given entity.ExportFormat format.
+func (c *Client) ExportClicks(ctx context.Context, format string, query url.Values, w io.Writer) error {
+	return c.export(ctx, "/exports/clicks", format, query, w)
+}
+
+// export copies an export to w as it is streamed. The server cuts off exports that fail midway,
+// which fail here with io.ErrUnexpectedEOF.
+func (c *Client) export(ctx context.Context, path string, format string, query url.Values, w io.Writer) error {
+	values := url.Values{"format": {format}}
+	for key, value := range query {
+		if key != "format" {
+			values[key] = value
+		}
+	}
+
+	response, err := c.send(ctx, http.MethodGet, path, values, nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+
+	return err
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/links", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"data":{"domain":"short.url","shortCode":"abc123","originalURL":"https://example.com"}}`)
+	})
+	mux.HandleFunc("GET /api/v1/links", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":[{"shortCode":"abc123"},{"shortCode":"def456"}],"nextCursor":"next"}`)
+	})
+	mux.HandleFunc("GET /api/v1/links/{shortCode}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"error not found: short.url/missing"}`)
+	})
+	mux.HandleFunc("PUT /api/v1/links/{shortCode}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"shortCode":"abc123","originalURL":"https://example.com/new"}}`)
+	})
+	mux.HandleFunc("DELETE /api/v1/links/{shortCode}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/v1/exports/links", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "domain,shortCode\nshort.url,abc123\n")
+	})
+	mux.HandleFunc("GET /api/v1/exports/clicks", func(w http.ResponseWriter, r *http.Request) {
+		// a failing export is cut off after its first bytes
+		io.WriteString(w, "createdAt,domain\n")
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := NewClient(server.URL+"/", "key", "acme")
+
+	t.Run("CreateLink", func(t *testing.T) {
+		shortened, err := c.CreateLink(ctx, "", "https://example.com")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "abc123", shortened.ShortCode)
+
+		request := requests[len(requests)-1]
+		assert.Equal(t, "Bearer key", request.Header.Get("Authorization"))
+		assert.Equal(t, "acme", request.URL.Query().Get("workspace"))
+		assert.JSONEq(t, `{"originalURL":"https://example.com","domain":""}`, bodies[len(bodies)-1])
+	})
+
+	t.Run("GetLinkNotFound", func(t *testing.T) {
+		_, err := c.GetLink(ctx, "short.url", "missing")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+		assert.Equal(t, "error not found: short.url/missing", err.Error())
+		assert.Equal(t, "short.url", requests[len(requests)-1].URL.Query().Get("domain"))
+	})
+
+	t.Run("ListLinks", func(t *testing.T) {
+		query := url.Values{"tag": {"sales"}}
+
+		page, err := c.ListLinks(ctx, query)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, page.Links, 2)
+		assert.Equal(t, "next", page.NextCursor)
+		assert.Equal(t, "sales", requests[len(requests)-1].URL.Query().Get("tag"))
+		assert.Equal(t, url.Values{"tag": {"sales"}}, query)
+	})
+
+	t.Run("UpdateLink", func(t *testing.T) {
+		fallbackURL := ""
+
+		shortened, err := c.UpdateLink(ctx, "", "abc123", entity.LinkUpdate{OriginalURL: "https://example.com/new", FallbackURL: &fallbackURL})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "https://example.com/new", shortened.OriginalURL)
+
+		var update map[string]any
+		json.Unmarshal([]byte(bodies[len(bodies)-1]), &update)
+		assert.Equal(t, map[string]any{"originalURL": "https://example.com/new", "fallbackURL": ""}, update)
+	})
+
+	t.Run("DeleteLink", func(t *testing.T) {
+		err := c.DeleteLink(ctx, "", "abc123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodDelete, requests[len(requests)-1].Method)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		var export bytes.Buffer
+
+		err := c.ExportLinks(ctx, entity.ExportFormatCSV, url.Values{"format": {"parquet"}, "tag": {"sales"}}, &export)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "domain,shortCode\nshort.url,abc123\n", export.String())
+		assert.Equal(t, url.Values{"format": {"csv"}, "tag": {"sales"}, "workspace": {"acme"}}, requests[len(requests)-1].URL.Query())
+
+		err = c.ExportClicks(ctx, entity.ExportFormatCSV, nil, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
@@ -15,10 +15,58 @@ type MongoConfig struct {
 	Options  string `env:"MONGODB_OPTIONS"`
 }
 
+type HealthCheckConfig struct {
+	Interval int `env:"HEALTH_CHECK_INTERVAL" defaultEnv:"60"`
+	Timeout  int `env:"HEALTH_CHECK_TIMEOUT" defaultEnv:"5"`
+}
+
+type MetadataConfig struct {
+	Timeout  int   `env:"METADATA_FETCH_TIMEOUT" defaultEnv:"5"`
+	MaxBytes int64 `env:"METADATA_FETCH_MAX_BYTES" defaultEnv:"1048576"`
+}
+
+type DomainVerificationConfig struct {
+	Interval        int `env:"DOMAIN_VERIFICATION_INTERVAL" defaultEnv:"3600"`
+	Timeout         int `env:"DOMAIN_VERIFICATION_TIMEOUT" defaultEnv:"5"`
+	PendingTTL      int `env:"DOMAIN_VERIFICATION_PENDING_TTL" defaultEnv:"604800"`
+	MaxFailedChecks int `env:"DOMAIN_VERIFICATION_MAX_FAILURES" defaultEnv:"3"`
+}
+
+// TrashConfig keeps deleted links restorable for Retention seconds, the purge runs every PurgeInterval seconds.
+type TrashConfig struct {
+	Retention     int `env:"TRASH_RETENTION" defaultEnv:"2592000"`
+	PurgeInterval int `env:"TRASH_PURGE_INTERVAL" defaultEnv:"3600"`
+}
+
+type AuthConfig struct {
+	AllowAnonymousShorten bool `env:"AUTH_ALLOW_ANONYMOUS_SHORTEN"`
+	SessionTTL            int  `env:"AUTH_SESSION_TTL" defaultEnv:"604800"`
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string `env:"AUTH_TOTP_ISSUER" defaultEnv:"Shorten URL"`
+}
+
+// OIDCConfig enables access tokens of an identity provider when JWKS is set. JWKS is a
+// file path or an http(s) URL.
+type OIDCConfig struct {
+	JWKS           string `env:"OIDC_JWKS"`
+	Issuer         string `env:"OIDC_ISSUER"`
+	Audience       string `env:"OIDC_AUDIENCE"`
+	WorkspaceClaim string `env:"OIDC_WORKSPACE_CLAIM"`
+	WorkspaceRole  string `env:"OIDC_WORKSPACE_ROLE" defaultEnv:"viewer"`
+	Leeway         int    `env:"OIDC_LEEWAY" defaultEnv:"60"`
+}
+
 type Config struct {
-	Host     string `env:"SERVICE_HOST"`
-	Port     string `env:"SERVICE_PORT"`
-	Protocol string `env:"SERVICE_PROTOCOL" default:"http"`
-	Redis    RedisConfig
-	Mongo    MongoConfig
+	Host          string `env:"SERVICE_HOST"`
+	Port          string `env:"SERVICE_PORT"`
+	Protocol      string `env:"SERVICE_PROTOCOL" default:"http"`
+	DefaultDomain string `env:"SERVICE_DEFAULT_DOMAIN"`
+	Redis         RedisConfig
+	Mongo         MongoConfig
+	Health        HealthCheckConfig
+	Metadata      MetadataConfig
+	Verification  DomainVerificationConfig
+	Trash         TrashConfig
+	Auth          AuthConfig
+	OIDC          OIDCConfig
 }
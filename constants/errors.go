@@ -4,3 +4,10 @@ import "fmt"
 
 var ErrorCacheNotFound = fmt.Errorf("error cache not found")
 var ErrorNotFound = fmt.Errorf("error not found")
+var ErrorInvalidRequest = fmt.Errorf("error invalid request")
+var ErrorAlreadyExists = fmt.Errorf("error already exists")
+var ErrorUnauthorized = fmt.Errorf("error unauthorized")
+var ErrorForbidden = fmt.Errorf("error forbidden")
+var ErrorSnapshotUnsupported = fmt.Errorf("error snapshot reads unsupported")
+var ErrorMigrationLocked = fmt.Errorf("error migrations locked")
+var ErrorPrivateAddress = fmt.Errorf("error private address")
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// Audited changes of links.
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+	AuditSettings = "settings"
+)
+
+// AuditEntry records one change of a link, it is never changed or removed afterwards.
+// Before is empty for created links and After for deleted ones.
+type AuditEntry struct {
+	ID        string `json:"id" bson:"_id"`
+	Workspace string `json:"workspace,omitempty" bson:"workspace,omitempty"`
+	Domain    string `json:"domain" bson:"domain"`
+	ShortCode string `json:"shortCode" bson:"shortCode"`
+	Action    string `json:"action" bson:"action"`
+	// ActorID is the user who made the change, empty for anonymous links
+	ActorID    string        `json:"actorID,omitempty" bson:"actorID,omitempty"`
+	ActorEmail string        `json:"actorEmail,omitempty" bson:"actorEmail,omitempty"`
+	APIKeyID   string        `json:"apiKeyID,omitempty" bson:"apiKeyID,omitempty"`
+	IP         string        `json:"ip,omitempty" bson:"ip,omitempty"`
+	Before     *ShortenedURL `json:"before,omitempty" bson:"before,omitempty"`
+	After      *ShortenedURL `json:"after,omitempty" bson:"after,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt" bson:"createdAt"`
+}
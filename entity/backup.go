@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// BackupFormatVersion is the version of the backup archives written, restores refuse newer ones
+const BackupFormatVersion = 1
+
+// BackupClickRollups names the click counts of links by day in backups, which keep them
+// instead of every click
+const BackupClickRollups = "click_rollups"
+
+// Conflict modes of restores, for documents whose ID is already in the database. Skip keeps the
+// existing document, overwrite replaces it and fail refuses to restore anything.
+const (
+	RestoreSkip      = "skip"
+	RestoreOverwrite = "overwrite"
+	RestoreFail      = "fail"
+)
+
+// IsRestoreMode reports whether mode is one of the Restore conflict modes
+func IsRestoreMode(mode string) bool {
+	return mode == RestoreSkip || mode == RestoreOverwrite || mode == RestoreFail
+}
+
+// BackupCollection is a collection of a backup archive, its documents are stored one after the
+// other in File, which SHA256 is the hex encoded checksum of.
+type BackupCollection struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Documents int64  `json:"documents"`
+	SHA256    string `json:"sha256"`
+}
+
+// BackupManifest describes a backup archive. Consistent backups were read from a single
+// snapshot of the database.
+type BackupManifest struct {
+	Version     int                `json:"version"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	Consistent  bool               `json:"consistent"`
+	Collections []BackupCollection `json:"collections"`
+}
+
+// RestoredCollection counts the documents of a collection a restore inserted, replaced and
+// skipped.
+type RestoredCollection struct {
+	Name     string `json:"name"`
+	Inserted int64  `json:"inserted"`
+	Replaced int64  `json:"replaced"`
+	Skipped  int64  `json:"skipped"`
+}
@@ -0,0 +1,142 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+// BulkLink is a row of a bulk link creation. Alias is the short code to use instead of a
+// generated one, ExpiresAt a day or an RFC 3339 time.
+type BulkLink struct {
+	OriginalURL string   `json:"originalURL"`
+	Alias       string   `json:"alias,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ExpiresAt   string   `json:"expiresAt,omitempty"`
+}
+
+// BulkResult is the outcome of a row of a bulk operation, rows are numbered from 1. Either Link
+// or Error is set.
+type BulkResult struct {
+	Row   int           `json:"row"`
+	Link  *ShortenedURL `json:"link,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// Actions of bulk operations
+const (
+	BulkActionDelete     = "delete"
+	BulkActionDisable    = "disable"
+	BulkActionAddTags    = "add-tags"
+	BulkActionRemoveTags = "remove-tags"
+	BulkActionSetExpiry  = "set-expiry"
+)
+
+// LinkKey identifies a link, short codes are only unique within their domain.
+type LinkKey struct {
+	Domain    string `json:"domain"`
+	ShortCode string `json:"shortCode"`
+}
+
+// BulkOperation applies Action to the links listed in Links, or to the links matching Filter
+// when none are listed; the sort and page of Filter are ignored. Tags are the tags added or
+// removed and ExpiresAt the expiry set, like the one of BulkLink, empty to remove it. A dry run
+// only counts the links the operation would change.
+type BulkOperation struct {
+	Action    string
+	Links     []LinkKey
+	Filter    *LinkQuery
+	Tags      []string
+	ExpiresAt string
+	DryRun    bool
+}
+
+// BulkOperationResult counts the links a bulk operation matched and those it changed, or would
+// change on a dry run.
+type BulkOperationResult struct {
+	Action  string `json:"action"`
+	DryRun  bool   `json:"dryRun"`
+	Matched int    `json:"matched"`
+	Changed int    `json:"changed"`
+}
+
+// LinkChange is a change made to many links at once, its zero fields leave links as they are.
+type LinkChange struct {
+	Status      string
+	AddTags     []string
+	RemoveTags  []string
+	ExpiresAt   *time.Time
+	ClearExpiry bool
+}
+
+// Apply returns link with the change made, it reports false when the change leaves it as it is.
+func (c LinkChange) Apply(link ShortenedURL) (ShortenedURL, bool) {
+	changed := false
+
+	if c.Status != "" && link.LinkStatus() != c.Status {
+		link.Status = c.Status
+		changed = true
+	}
+
+	if len(c.AddTags) > 0 || len(c.RemoveTags) > 0 {
+		tags := make([]string, 0, len(link.Tags)+len(c.AddTags))
+		for _, tag := range link.Tags {
+			if slices.Contains(c.RemoveTags, tag) {
+				changed = true
+				continue
+			}
+			tags = append(tags, tag)
+		}
+
+		for _, tag := range c.AddTags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+				changed = true
+			}
+		}
+
+		link.Tags = tags
+		if len(tags) == 0 {
+			link.Tags = nil
+		}
+	}
+
+	if c.ExpiresAt != nil && (link.ExpiresAt == nil || !link.ExpiresAt.Equal(*c.ExpiresAt)) {
+		link.ExpiresAt = c.ExpiresAt
+		changed = true
+	}
+
+	if c.ClearExpiry && link.ExpiresAt != nil {
+		link.ExpiresAt = nil
+		changed = true
+	}
+
+	return link, changed
+}
+
+// DestinationRewrite moves the destinations starting with From to To, keeping the rest of their
+// path, their query and their fragment. Both are a host, like docs.old.com, or a URL prefix,
+// like https://docs.old.com/v1; a From without a scheme matches http and https. A dry run only
+// lists the links it would change.
+type DestinationRewrite struct {
+	From   string
+	To     string
+	DryRun bool
+}
+
+// DestinationChange is the destination of a link before and after a rewrite
+type DestinationChange struct {
+	Domain    string `json:"domain"`
+	ShortCode string `json:"shortCode"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DestinationRewriteResult lists the links a rewrite changed, or would change on a dry run.
+// Changed does not count the links whose change failed, they have their error set.
+type DestinationRewriteResult struct {
+	DryRun  bool                `json:"dryRun"`
+	Matched int                 `json:"matched"`
+	Changed int                 `json:"changed"`
+	Links   []DestinationChange `json:"links"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type Click struct {
+	Domain    string    `json:"domain" bson:"domain"`
+	ShortCode string    `json:"shortCode" bson:"shortCode"`
+	Fallback  bool      `json:"fallback" bson:"fallback"`
+	Referrer  string    `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// ClickQuery narrows down the clicks on the links of a workspace. From is inclusive and To
+// exclusive, zero times leave the range open; Tag matches the tags of the links.
+type ClickQuery struct {
+	Domain string
+	Tag    string
+	From   time.Time
+	To     time.Time
+}
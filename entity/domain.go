@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	DomainStatusPending  = "pending"
+	DomainStatusVerified = "verified"
+)
+
+// DomainVerification tracks the proof that whoever registered a domain controls it.
+type DomainVerification struct {
+	Status       string     `json:"status" bson:"status"`
+	Token        string     `json:"token,omitempty" bson:"token,omitempty"`
+	VerifiedAt   *time.Time `json:"verifiedAt,omitempty" bson:"verifiedAt,omitempty"`
+	CheckedAt    *time.Time `json:"checkedAt,omitempty" bson:"checkedAt,omitempty"`
+	FailedChecks int        `json:"failedChecks" bson:"failedChecks"`
+}
+
+// Domain is a branded host short links are served from. Workspace is the workspace that
+// registered it, whose admins manage it; the default domain and domains registered before
+// workspaces owned them have none.
+type Domain struct {
+	Name      string `json:"name" bson:"name"`
+	Workspace string `json:"workspace,omitempty" bson:"workspace,omitempty"`
+	// RootRedirectURL is where visitors of the bare domain are sent, the home page is shown when empty
+	RootRedirectURL string `json:"rootRedirectURL,omitempty" bson:"rootRedirectURL,omitempty"`
+	// NotFoundTemplate names the template rendered for unknown short codes instead of 404.html
+	NotFoundTemplate string `json:"notFoundTemplate,omitempty" bson:"notFoundTemplate,omitempty"`
+	// UnavailableTemplate names the template rendered for disabled and blocked links instead of unavailable.html
+	UnavailableTemplate string             `json:"unavailableTemplate,omitempty" bson:"unavailableTemplate,omitempty"`
+	Verification        DomainVerification `json:"verification" bson:"verification"`
+	CreatedAt           time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+func (d *Domain) IsVerified() bool {
+	return d.Verification.Status == DomainStatusVerified
+}
+
+// TXTRecordName is the DNS name that has to carry the verification TXT record.
+func (d *Domain) TXTRecordName() string {
+	host := d.Name
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return "_shortenurl-challenge." + host
+}
+
+// TXTRecordValue is the expected content of the verification TXT record and well-known file.
+func (d *Domain) TXTRecordValue() string {
+	return "shortenurl-verification=" + d.Verification.Token
+}
+
+// NormalizeHost turns a request Host into a domain name: lower case, without a trailing dot
+// and without the default http/https port.
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	if h, port, err := net.SplitHostPort(host); err == nil && (port == "80" || port == "443") {
+		host = h
+	}
+
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,14 @@
+package entity
+
+// Formats of link and click exports
+const (
+	ExportFormatCSV       = "csv"
+	ExportFormatJSONLines = "jsonl"
+	ExportFormatParquet   = "parquet"
+	DefaultExportFormat   = ExportFormatCSV
+)
+
+// IsExportFormat reports whether format is one of the ExportFormat formats
+func IsExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatJSONLines || format == ExportFormatParquet
+}
@@ -0,0 +1,77 @@
+package entity
+
+import "time"
+
+// Formats of link imports
+const (
+	ImportFormatBitly  = "bitly"
+	ImportFormatYOURLS = "yourls"
+	ImportFormatCSV    = "csv"
+)
+
+// IsImportFormat reports whether format is one of the ImportFormat formats
+func IsImportFormat(format string) bool {
+	return format == ImportFormatBitly || format == ImportFormatYOURLS || format == ImportFormatCSV
+}
+
+// Statuses of import jobs. An interrupted job stays running until it is resumed.
+const (
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// Outcomes of imported links. Existing links were made by an earlier import of the same link,
+// conflicting ones have a short code already used by another link.
+const (
+	ImportCreated  = "created"
+	ImportExists   = "exists"
+	ImportConflict = "conflict"
+	ImportFailed   = "failed"
+)
+
+// ImportLink is a link exported by another shortener. Source identifies it across imports, like
+// bitly:bit.ly/3xYz12, and ShortCode is the code it had there, kept when it is free.
+type ImportLink struct {
+	Source      string
+	ShortCode   string
+	OriginalURL string
+	Title       string
+	Tags        []string
+	CreatedAt   *time.Time
+	Clicks      int64
+}
+
+// ImportResult is the outcome of a row of an import, rows are numbered from 1.
+type ImportResult struct {
+	Row       int    `json:"row" bson:"row"`
+	Source    string `json:"source,omitempty" bson:"source,omitempty"`
+	ShortCode string `json:"shortCode,omitempty" bson:"shortCode,omitempty"`
+	Status    string `json:"status" bson:"status"`
+	Error     string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// ImportJob imports the links of an export file into a workspace a batch at a time. Position is
+// the number of rows processed, a resumed job starts there with the same file, which Checksum
+// identifies. Problems are the results of the rows that conflicted or failed, the first
+// thousand of them.
+type ImportJob struct {
+	ID        string         `json:"id" bson:"_id"`
+	Workspace string         `json:"workspace" bson:"workspace"`
+	Domain    string         `json:"domain" bson:"domain"`
+	Format    string         `json:"format" bson:"format"`
+	Name      string         `json:"name,omitempty" bson:"name,omitempty"`
+	Checksum  string         `json:"checksum" bson:"checksum"`
+	CreatedBy string         `json:"createdBy" bson:"createdBy"`
+	Status    string         `json:"status" bson:"status"`
+	Error     string         `json:"error,omitempty" bson:"error,omitempty"`
+	Total     int            `json:"total" bson:"total"`
+	Position  int            `json:"position" bson:"position"`
+	Created   int            `json:"created" bson:"created"`
+	Existing  int            `json:"existing" bson:"existing"`
+	Conflicts int            `json:"conflicts" bson:"conflicts"`
+	Failed    int            `json:"failed" bson:"failed"`
+	Problems  []ImportResult `json:"problems" bson:"problems"`
+	CreatedAt time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt" bson:"updatedAt"`
+}
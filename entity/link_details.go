@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// LinkDetails organize links, they are only shown to members of the workspace.
+type LinkDetails struct {
+	Title string   `json:"title"`
+	Notes string   `json:"notes"`
+	Tags  []string `json:"tags"`
+}
+
+// Sort orders of link listings, a leading minus sorts descending. Ties are broken by creation
+// order so pages stay stable.
+const (
+	LinkSortCreated = "created"
+	LinkSortClicks  = "clicks"
+	LinkSortCode    = "code"
+	// DefaultLinkSort lists the newest links first
+	DefaultLinkSort = "-" + LinkSortCreated
+)
+
+// IsLinkSort reports whether sort is a known link sort order.
+func IsLinkSort(sort string) bool {
+	switch strings.TrimPrefix(sort, "-") {
+	case LinkSortCreated, LinkSortClicks, LinkSortCode:
+		return true
+	}
+
+	return false
+}
+
+// LinkQuery narrows down, orders and pages the links of a workspace, the zero value returns
+// the first page of all of them, newest first.
+type LinkQuery struct {
+	// Search matches words of the destination, title, notes and tags, or the start of short codes
+	Search string
+	Domain string
+	Tag    string
+	Status string
+	// CreatedFrom is inclusive and CreatedTo exclusive, zero times leave the range open
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Sort        string
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// LinkPage is a page of a link listing, NextCursor is empty on the last page.
+type LinkPage struct {
+	Links      []ShortenedURL `json:"links"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// Metadata describes the destination page of a shortened URL.
+type Metadata struct {
+	Title       string    `json:"title,omitempty" bson:"title,omitempty"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	FaviconURL  string    `json:"faviconURL,omitempty" bson:"faviconURL,omitempty"`
+	ImageURL    string    `json:"imageURL,omitempty" bson:"imageURL,omitempty"`
+	FetchedAt   time.Time `json:"fetchedAt" bson:"fetchedAt"`
+}
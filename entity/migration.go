@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// Migration is a change of the database, identified by its Version, which migrations are applied
+// in the order of. AppliedAt is nil until it is applied.
+type Migration struct {
+	Version   int        `json:"version" bson:"_id"`
+	Name      string     `json:"name" bson:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty" bson:"appliedAt"`
+}
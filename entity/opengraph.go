@@ -0,0 +1,12 @@
+package entity
+
+// OpenGraph overrides how a shortened URL unfurls when shared on social platforms.
+type OpenGraph struct {
+	Title       string `json:"title,omitempty" bson:"title,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	ImageURL    string `json:"imageURL,omitempty" bson:"imageURL,omitempty"`
+}
+
+func (o OpenGraph) IsEmpty() bool {
+	return o.Title == "" && o.Description == "" && o.ImageURL == ""
+}
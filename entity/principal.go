@@ -0,0 +1,34 @@
+package entity
+
+import "slices"
+
+// Principal is who a request acts for, a signed-in user, one of their API keys or an
+// access token issued by the identity provider.
+type Principal struct {
+	UserID   string
+	Email    string
+	APIKeyID string
+	// Subject is the identity provider subject of an access token
+	Subject string
+	Scopes  []string
+	// Workspaces are the workspaces the identity provider places an access token in
+	Workspaces []string
+	// TwoFactor is set when the user signs in with a second factor
+	TwoFactor bool
+}
+
+// Restricted reports whether the principal is limited to its Scopes. API keys always
+// are, access tokens only when they carry scopes of this service.
+func (p *Principal) Restricted() bool {
+	return p.APIKeyID != "" || p.Scopes != nil
+}
+
+// HasScope reports whether the principal may act within scope. Sessions and unrestricted
+// access tokens act with every permission of their user.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.Restricted() {
+		return true
+	}
+
+	return slices.Contains(p.Scopes, scope)
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// Revision is a destination a link had, a new one is kept every time the destination changes.
+type Revision struct {
+	ID          string `json:"id" bson:"_id"`
+	Domain      string `json:"domain" bson:"domain"`
+	ShortCode   string `json:"shortCode" bson:"shortCode"`
+	OriginalURL string `json:"originalURL" bson:"originalURL"`
+	// CreatedBy is the user who set the destination, empty for anonymous links
+	CreatedBy string `json:"createdBy,omitempty" bson:"createdBy,omitempty"`
+	// RestoredFrom is the revision a rollback restored
+	RestoredFrom string    `json:"restoredFrom,omitempty" bson:"restoredFrom,omitempty"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+}
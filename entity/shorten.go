@@ -8,16 +8,58 @@ import (
 	"html/template"
 	"math/big"
 	"os"
+	"time"
 )
 
 var (
 	encodeBase62 = util.EncodeBase62
 )
 
+// Statuses of links, only active links redirect. Links without a status are active.
+const (
+	LinkStatusActive   = "active"
+	LinkStatusDisabled = "disabled"
+	// LinkStatusBlocked is set by workspace admins, editors cannot change it
+	LinkStatusBlocked = "blocked-by-admin"
+)
+
+// IsLinkStatus reports whether status is a known link status.
+func IsLinkStatus(status string) bool {
+	return status == LinkStatusActive || status == LinkStatusDisabled || status == LinkStatusBlocked
+}
+
 type ShortenedURL struct {
-	ShortCode    string `json:"shortCode" bson:"shortCode"`
-	OriginalURL  string `json:"originalURL" bson:"originalURL"`
-	ShortenedURL string `json:"shortenedURL,omitempty" bson:",omitempty"`
+	Domain       string     `json:"domain" bson:"domain"`
+	ShortCode    string     `json:"shortCode" bson:"shortCode"`
+	OriginalURL  string     `json:"originalURL" bson:"originalURL"`
+	Owner        string     `json:"owner,omitempty" bson:"owner,omitempty"`
+	Workspace    string     `json:"workspace,omitempty" bson:"workspace,omitempty"`
+	FallbackURL  string     `json:"fallbackURL,omitempty" bson:"fallbackURL,omitempty"`
+	PrimaryDown  bool       `json:"primaryDown" bson:"primaryDown"`
+	Status       string     `json:"status,omitempty" bson:"status,omitempty"`
+	Title        string     `json:"title,omitempty" bson:"title,omitempty"`
+	Notes        string     `json:"notes,omitempty" bson:"notes,omitempty"`
+	Tags         []string   `json:"tags,omitempty" bson:"tags,omitempty"`
+	Clicks       int64      `json:"clicks" bson:"clicks"`
+	Metadata     *Metadata  `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	OpenGraph    *OpenGraph `json:"openGraph,omitempty" bson:"openGraph,omitempty"`
+	ShortenedURL string     `json:"shortenedURL,omitempty" bson:",omitempty"`
+	// CreatedAt and UpdatedAt are missing on links created before they were recorded
+	CreatedAt *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+	// DeletedAt is set while the link is in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// ExpiresAt is when the link stops redirecting, links without it never expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	// ImportedFrom is the ImportLink.Source of imported links, imports skip the links they already made
+	ImportedFrom string `json:"importedFrom,omitempty" bson:"importedFrom,omitempty"`
+}
+
+// LinkUpdate changes the destination and fallback URL of a link. An empty OriginalURL keeps
+// the destination, a nil FallbackURL keeps the fallback URL and an empty one removes it.
+type LinkUpdate struct {
+	OriginalURL string  `json:"originalURL,omitempty"`
+	FallbackURL *string `json:"fallbackURL,omitempty"`
 }
 
 func (s *ShortenedURL) GenerateShortCode(salt ...string) string {
@@ -48,8 +90,13 @@ func (s *ShortenedURL) GenerateShortenedURL() error {
 		return fmt.Errorf("short code not specified")
 	}
 
-	host := fmt.Sprintf("%s://%s:%s", os.Getenv("SERVICE_PROTOCOL"), os.Getenv("SERVICE_HOST"), os.Getenv("SERVICE_PORT"))
-	s.ShortenedURL = fmt.Sprintf("%s/s/%s", host, s.ShortCode)
+	// links are served from their own domain, legacy links without one from the service address
+	domain := s.Domain
+	if domain == "" {
+		domain = fmt.Sprintf("%s:%s", os.Getenv("SERVICE_HOST"), os.Getenv("SERVICE_PORT"))
+	}
+
+	s.ShortenedURL = fmt.Sprintf("%s://%s/s/%s", os.Getenv("SERVICE_PROTOCOL"), domain, s.ShortCode)
 
 	return nil
 }
@@ -57,3 +104,31 @@ func (s *ShortenedURL) GenerateShortenedURL() error {
 func (s *ShortenedURL) SafeShortenedURL() template.URL {
 	return template.URL(s.ShortenedURL)
 }
+
+// LinkStatus returns the status of the link, active for links without one.
+func (s *ShortenedURL) LinkStatus() string {
+	if s.Status == "" {
+		return LinkStatusActive
+	}
+
+	return s.Status
+}
+
+func (s *ShortenedURL) IsActive() bool {
+	return s.LinkStatus() == LinkStatusActive
+}
+
+func (s *ShortenedURL) IsExpired() bool {
+	return s.ExpiresAt != nil && !time.Now().Before(*s.ExpiresAt)
+}
+
+// Destination returns the URL visitors should be redirected to, falling back to
+// FallbackURL while the health checker has the primary marked as down.
+// The second return value reports whether the fallback was chosen.
+func (s *ShortenedURL) Destination() (string, bool) {
+	if s.PrimaryDown && s.FallbackURL != "" {
+		return s.FallbackURL, true
+	}
+
+	return s.OriginalURL, false
+}
@@ -0,0 +1,8 @@
+package entity
+
+type LinkStats struct {
+	Domain         string `json:"domain"`
+	ShortCode      string `json:"shortCode"`
+	Clicks         int64  `json:"clicks"`
+	FallbackClicks int64  `json:"fallbackClicks"`
+}
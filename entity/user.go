@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+type User struct {
+	ID           string `json:"id" bson:"_id"`
+	Email        string `json:"email" bson:"email"`
+	PasswordHash string `json:"-" bson:"passwordHash"`
+	// Subject links the user to the identity provider, it is empty for password-only users
+	Subject   string     `json:"-" bson:"subject,omitempty"`
+	TwoFactor *TwoFactor `json:"-" bson:"twoFactor,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+// TwoFactorEnabled reports whether signing in needs a code of an authenticator app.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// TwoFactor is the TOTP enrollment of a user.
+type TwoFactor struct {
+	Secret string `bson:"secret"`
+	// Enabled is false until a first code confirms the enrollment
+	Enabled bool `bson:"enabled"`
+	// RecoveryCodes are hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recoveryCodes,omitempty"`
+	// LastStep is the time step of the last accepted code, a code is never accepted twice
+	LastStep  int64      `bson:"lastStep"`
+	EnabledAt *time.Time `bson:"enabledAt,omitempty"`
+}
+
+// TwoFactorSetup is what an authenticator app needs to generate codes, the URI is usually
+// scanned as a QR code.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Session is a signed-in browser. Only a hash of the cookie token is stored.
+type Session struct {
+	UserID string `json:"userID"`
+	// TwoFactorPending is set while the password is checked but the code is not yet
+	TwoFactorPending bool      `json:"twoFactorPending,omitempty"`
+	FailedAttempts   int       `json:"failedAttempts,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+// Workspace roles, from the most to the least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Roles lists every role, from the most to the least privileged.
+var Roles = []string{RoleOwner, RoleAdmin, RoleEditor, RoleViewer}
+
+// PersonalWorkspacePrefix starts the ID of the workspace every user gets for themselves
+const PersonalWorkspacePrefix = "personal-"
+
+// Workspace is a team that owns links. Its ID is a slug, used in URLs and in identity provider claims.
+type Workspace struct {
+	ID   string `json:"id" bson:"_id"`
+	Name string `json:"name" bson:"name"`
+	// RequireTwoFactor keeps members without two-factor authentication out of the workspace
+	RequireTwoFactor bool      `json:"requireTwoFactor" bson:"requireTwoFactor,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Membership gives a user a role in a workspace.
+type Membership struct {
+	WorkspaceID string    `json:"workspaceID" bson:"workspaceID"`
+	UserID      string    `json:"userID" bson:"userID"`
+	Email       string    `json:"email,omitempty" bson:"-"`
+	Role        string    `json:"role" bson:"role"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// WorkspaceMembership is a workspace as seen by one of its members.
+type WorkspaceMembership struct {
+	Workspace
+	Role string `json:"role"`
+}
+
+// IsRole reports whether role is a known role.
+func IsRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// RoleAtLeast reports whether role grants every permission of required.
+func RoleAtLeast(role string, required string) bool {
+	rank := slices.Index(Roles, role)
+
+	return rank != -1 && rank <= slices.Index(Roles, required)
+}
+
+// PersonalWorkspaceID returns the ID of the personal workspace of a user.
+func PersonalWorkspaceID(userID string) string {
+	return PersonalWorkspacePrefix + userID
+}
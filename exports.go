@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"io"
+)
+
+// exportCommand writes the links or clicks of a workspace to a file, or to standard output.
+// The file only appears once the export is complete.
+func exportCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("export", "export [-format csv|jsonl|parquet] [-o file] [filters] links|clicks")
+	t := addTargetFlags(flags)
+	format := flags.String("format", entity.DefaultExportFormat,
+		fmt.Sprintf("format of the file: %s, %s or %s", entity.ExportFormatCSV, entity.ExportFormatJSONLines, entity.ExportFormatParquet))
+	output := flags.String("o", "", "file to write the export to, standard output when empty")
+	query := addLinkFilterFlags(flags, "q", "domain", "tag", "status", "from", "to")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	var export func(ctx context.Context, b backend, w io.Writer) error
+	switch flags.Arg(0) {
+	case "links":
+		export = func(ctx context.Context, b backend, w io.Writer) error {
+			return b.ExportLinks(ctx, *format, query, w)
+		}
+	case "clicks":
+		// clicks are filtered by the tag and domain of their link and the day they were recorded on
+		if query.Has("q") || query.Has("status") {
+			fmt.Fprintln(flags.Output(), "clicks are only filtered by -domain, -tag, -from and -to")
+			return usageError(flags)
+		}
+
+		export = func(ctx context.Context, b backend, w io.Writer) error {
+			return b.ExportClicks(ctx, *format, query, w)
+		}
+	default:
+		return usageError(flags)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		return writeOutput(*output, func(w io.Writer) error {
+			return export(ctx, b, w)
+		})
+	})
+}
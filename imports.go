@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// importPollInterval is how often the progress of imports run by a server is checked
+const importPollInterval = 2 * time.Second
+
+// importCommand imports an export of another shortener, or resumes an import with -resume, and
+// prints the job once it is done. Servers run imports in the background, the command follows
+// their progress unless -no-wait is given.
+func importCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("import", "import [-format bitly|yourls|csv] [-domain name] [-resume id] [-no-wait] file")
+	t := addTargetFlags(flags)
+	format := flags.String("format", entity.ImportFormatCSV,
+		fmt.Sprintf("format of the export: %s, %s or %s", entity.ImportFormatBitly, entity.ImportFormatYOURLS, entity.ImportFormatCSV))
+	domain := flags.String("domain", "", "domain of the links, the default domain when empty")
+	name := flags.String("name", "", "name of the import, the name of the file by default")
+	resume := flags.String("resume", "", "ID of a failed or interrupted import to resume with the same file")
+	noWait := flags.Bool("no-wait", false, "return once a server started the import")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	export := io.Reader(os.Stdin)
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		export = file
+		if *name == "" {
+			*name = filepath.Base(path)
+		}
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		var job *entity.ImportJob
+		var err error
+		if *resume != "" {
+			job, err = b.ResumeImport(ctx, *resume, export)
+		} else {
+			job, err = b.CreateImport(ctx, *domain, *format, *name, export)
+		}
+
+		if err == nil && !*noWait {
+			job, err = waitImport(ctx, b, job)
+		}
+		if job != nil {
+			if printErr := printJSON(job); err == nil {
+				err = printErr
+			}
+		}
+		if err == nil && job.Status == entity.ImportStatusFailed {
+			err = fmt.Errorf("import %s failed: %s", job.ID, job.Error)
+		}
+
+		return err
+	})
+}
+
+// waitImport follows the progress of an import until it stops running
+func waitImport(ctx context.Context, b backend, job *entity.ImportJob) (*entity.ImportJob, error) {
+	for job.Status == entity.ImportStatusRunning {
+		log.Printf("import %s: %d of %d rows\n", job.ID, job.Position, job.Total)
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(importPollInterval):
+		}
+
+		current, err := b.GetImport(ctx, job.ID)
+		if err != nil {
+			return job, err
+		}
+
+		job = current
+	}
+
+	return job, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"net/url"
+	"os"
+)
+
+const linksUsage = "links create|get|list|update|delete|rewrite [flags] [arguments]"
+
+// linksCommand manages the links of a workspace, on the database or through a running server.
+// Every subcommand prints the links it returns as JSON.
+func linksCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: shortenurl %s\n", linksUsage)
+		return errUsage
+	}
+
+	subcommands := map[string]func(cfg *config.Config, args []string) error{
+		"create":  linksCreate,
+		"get":     linksGet,
+		"list":    linksList,
+		"update":  linksUpdate,
+		"delete":  linksDelete,
+		"rewrite": linksRewrite,
+	}
+
+	subcommand, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown links command %q\nusage: shortenurl %s\n", args[0], linksUsage)
+		return errUsage
+	}
+
+	return subcommand(cfg, args[1:])
+}
+
+func linksCreate(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links create", "links create [-domain name] original-url")
+	t := addTargetFlags(flags)
+	domain := flags.String("domain", "", "domain of the link, the default domain when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		shortened, err := b.CreateLink(ctx, *domain, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		return printJSON(shortened)
+	})
+}
+
+func linksGet(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links get", "links get [-domain name] short-code")
+	t := addTargetFlags(flags)
+	domain := flags.String("domain", "", "domain of the link, the default domain when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		shortened, err := b.GetLink(ctx, *domain, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		return printJSON(shortened)
+	})
+}
+
+// addLinkFilterFlags adds the filters of link listings to flags, they are the values of the
+// parameters of the same name
+func addLinkFilterFlags(flags *flag.FlagSet, names ...string) url.Values {
+	usages := map[string]string{
+		"q":      "search the destination, title, notes and tags for words and short codes for a prefix",
+		"domain": "only links on the domain",
+		"tag":    "only links with the tag",
+		"status": "only links with the status: active, disabled or blocked-by-admin",
+		"from":   "only links created, or clicks recorded, from the day or RFC 3339 time",
+		"to":     "only links created, or clicks recorded, up to the day, included, or RFC 3339 time",
+	}
+
+	values := url.Values{}
+	for _, name := range names {
+		flags.Func(name, usages[name], func(value string) error {
+			values.Set(name, value)
+			return nil
+		})
+	}
+
+	return values
+}
+
+func linksList(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links list", "links list [filters] [-sort order] [-limit n] [-cursor cursor]")
+	t := addTargetFlags(flags)
+	query := addLinkFilterFlags(flags, "q", "domain", "tag", "status", "from", "to")
+	sort := flags.String("sort", entity.DefaultLinkSort, "created, clicks or code, prefixed with - for descending order")
+	limit := flags.Int("limit", 0, "links per page, 50 when 0 and at most 200")
+	cursor := flags.String("cursor", "", "the nextCursor of the previous page")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	query.Set("sort", *sort)
+	if *limit > 0 {
+		query.Set("limit", fmt.Sprint(*limit))
+	}
+	if *cursor != "" {
+		query.Set("cursor", *cursor)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		page, err := b.ListLinks(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(page)
+	})
+}
+
+func linksUpdate(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links update", "links update [-domain name] [-url original-url] [-fallback fallback-url] short-code")
+	t := addTargetFlags(flags)
+	domain := flags.String("domain", "", "domain of the link, the default domain when empty")
+	originalURL := flags.String("url", "", "new destination of the link")
+
+	var update entity.LinkUpdate
+	flags.Func("fallback", "new fallback URL of the link, empty to remove it", func(value string) error {
+		update.FallbackURL = &value
+		return nil
+	})
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	update.OriginalURL = *originalURL
+	if update.OriginalURL == "" && update.FallbackURL == nil {
+		fmt.Fprintln(flags.Output(), "-url or -fallback is required")
+		return usageError(flags)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		shortened, err := b.UpdateLink(ctx, *domain, flags.Arg(0), update)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(shortened)
+	})
+}
+
+func linksDelete(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links delete", "links delete [-domain name] short-code")
+	t := addTargetFlags(flags)
+	domain := flags.String("domain", "", "domain of the link, the default domain when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		return b.DeleteLink(ctx, *domain, flags.Arg(0))
+	})
+}
+
+// linksRewrite moves the destinations of a workspace from one host or URL prefix to another
+func linksRewrite(cfg *config.Config, args []string) error {
+	flags := newFlagSet("links rewrite", "links rewrite [-dry-run] from to")
+	t := addTargetFlags(flags)
+	dryRun := flags.Bool("dry-run", false, "only list the links that would change")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return usageError(flags)
+	}
+
+	rewrite := entity.DestinationRewrite{From: flags.Arg(0), To: flags.Arg(1), DryRun: *dryRun}
+
+	return withBackend(cfg, t, func(ctx context.Context, b backend) error {
+		result, err := b.RewriteDestinations(ctx, rewrite)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(result)
+	})
+}
@@ -1,70 +1,155 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/ilhamtubagus/goenv"
 	"github.com/ilhamtubagus/shortenurl/config"
-	"github.com/ilhamtubagus/shortenurl/entity"
-	"github.com/ilhamtubagus/shortenurl/repository"
-	"github.com/ilhamtubagus/shortenurl/routes"
-	"github.com/ilhamtubagus/shortenurl/server"
-	"github.com/ilhamtubagus/shortenurl/services"
 	"github.com/joho/godotenv"
-	"github.com/julienschmidt/httprouter"
-	"html/template"
+	"io"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
 )
 
-var appConfig = &config.Config{}
+// command is a subcommand of the binary, run with the arguments after its name
+type command struct {
+	name    string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
 
-func init() {
-	if os.Getenv("ENV") == "development" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("error loading .env file")
-		}
+var commands = []command{
+	{"serve", "run the web server, the command when none is given", serveCommand},
+	{"migrate", "prepare the database for this version and exit", migrateCommand},
+	{"links", "create, get, list, update, delete and rewrite links", linksCommand},
+	{"import", "import an export of Bitly, YOURLS or a CSV of links", importCommand},
+	{"export", "write the links or clicks of a workspace to a file", exportCommand},
+	{"cache", "inspect and flush the cached redirects", cacheCommand},
+	{"users", "create and look up users, turn off their two-factor authentication", usersCommand},
+	{"backup", "write the database to a checksummed archive", backupCommand},
+	{"restore", "load a backup archive into the database", restoreCommand},
+}
 
-		log.Println("successfully loaded development environment variables")
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: shortenurl <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The commands are:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "shortenurl <command> -h" for the flags of a command.`)
+}
 
-	err := goenv.Unmarshal(appConfig)
-	if err != nil {
-		log.Fatal(err)
+// errUsage is returned by commands given the wrong arguments, once they printed their usage
+var errUsage = errors.New("invalid arguments")
+
+// newFlagSet creates the flags of a command, synopsis follows the name of the binary in its usage
+func newFlagSet(name string, synopsis string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: shortenurl %s\n", synopsis)
+		flags.PrintDefaults()
 	}
+
+	return flags
 }
 
-func main() {
-	tmpl := template.Must(template.New("").ParseGlob("./templates/*"))
+// usageError prints the usage of a command and returns errUsage
+func usageError(flags *flag.FlagSet) error {
+	flags.Usage()
 
-	redisClient := server.ConnectRedisClient(appConfig.Redis)
-	mongoClient := server.ConnectMongoClient(appConfig.Mongo)
+	return errUsage
+}
+
+// stdout is where commands write their results, standard output unless tests capture them
+var stdout io.Writer = os.Stdout
 
-	shortenRedisCache := repository.NewRedisCache[entity.ShortenedURL](redisClient)
-	shortenCollection := mongoClient.Database("shorten").Collection("shorten")
-	shortenRepository := repository.NewShortenedRepository(shortenRedisCache, shortenCollection, *appConfig)
+// printJSON writes the result of a command to standard output
+func printJSON(v any) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
 
-	shortenService := services.NewShortenedService(shortenRepository)
+	return encoder.Encode(v)
+}
 
-	router := httprouter.New()
-	routesDefs := routes.NewRoutes(tmpl, shortenService)
+// writeOutput calls write with the file at output, or standard output when it is empty. The file
+// is written through a temporary file next to it, which is only renamed once write succeeds.
+func writeOutput(output string, write func(w io.Writer) error) error {
+	if output == "" {
+		return write(stdout)
+	}
 
-	router.NotFound = http.HandlerFunc(routesDefs.NotFound())
+	file, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*.partial")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	router.GET("/", routesDefs.Index())
-	router.POST("/shorten-url", routesDefs.ShortenURL())
-	router.GET("/shorten-url", routesDefs.ListShortenedURLs())
-	router.DELETE("/:shortCode", routesDefs.DeleteShortenedURL())
-	router.PATCH("/:shortCode", routesDefs.UpdateShortenedURL())
-	router.GET("/s/:shortCode", routesDefs.RedirectURL())
+	err = write(file)
+	if err != nil {
+		return err
+	}
 
-	host := fmt.Sprintf("%s:%s", os.Getenv("SERVICE_HOST"), os.Getenv("SERVICE_PORT"))
+	if err := file.Close(); err != nil {
+		return err
+	}
 
-	log.Printf("server running on %s\n", host)
+	return os.Rename(file.Name(), output)
+}
 
-	err := http.ListenAndServe(host, router)
-	if err != nil {
-		log.Fatal(err)
+// loadConfig reads the configuration from the environment, loading .env first in development.
+func loadConfig() (*config.Config, error) {
+	if os.Getenv("ENV") == "development" {
+		err := godotenv.Load()
+		if err != nil {
+			return nil, fmt.Errorf("error loading .env file: %w", err)
+		}
+
+		log.Println("successfully loaded development environment variables")
+	}
+
+	cfg := &config.Config{}
+
+	return cfg, goenv.Unmarshal(cfg)
+}
+
+func main() {
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
 	}
+
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage(os.Stdout)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		cfg, err := loadConfig()
+		if err == nil {
+			err = cmd.run(cfg, args)
+		}
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "shortenurl %s: %v\n", name, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "shortenurl: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
 }
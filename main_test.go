@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unsetenv removes the variables for the rest of the test, they are restored once it ends
+func unsetenv(t *testing.T, keys ...string) {
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+// chdir makes dir the working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		dotenv     string
+		wantHost   string
+		wantPort   string
+		wantTTL    int
+		wantAnon   bool
+		wantIssuer string
+		wantErr    bool
+	}{
+		{
+			name:       "Defaults",
+			wantPort:   "6379",
+			wantTTL:    604800,
+			wantIssuer: "Shorten URL",
+		},
+		{
+			name: "Environment",
+			env: map[string]string{
+				"SERVICE_HOST":                    "0.0.0.0",
+				"REDIS_PORT":                      "6380",
+				"DOMAIN_VERIFICATION_PENDING_TTL": "3600",
+				"AUTH_ALLOW_ANONYMOUS_SHORTEN":    "true",
+				"AUTH_TOTP_ISSUER":                "Acme Links",
+			},
+			wantHost:   "0.0.0.0",
+			wantPort:   "6380",
+			wantTTL:    3600,
+			wantAnon:   true,
+			wantIssuer: "Acme Links",
+		},
+		{
+			name:       "DotEnvInDevelopment",
+			env:        map[string]string{"ENV": "development", "REDIS_PORT": "6390"},
+			dotenv:     "SERVICE_HOST=127.0.0.1\nREDIS_PORT=6381\n",
+			wantHost:   "127.0.0.1",
+			wantPort:   "6390",
+			wantTTL:    604800,
+			wantIssuer: "Shorten URL",
+		},
+		{
+			name:    "MissingDotEnvInDevelopment",
+			env:     map[string]string{"ENV": "development"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidNumber",
+			env:     map[string]string{"DOMAIN_VERIFICATION_PENDING_TTL": "a week"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "ENV", "SERVICE_HOST", "REDIS_PORT", "DOMAIN_VERIFICATION_PENDING_TTL",
+				"AUTH_ALLOW_ANONYMOUS_SHORTEN", "AUTH_TOTP_ISSUER")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			dir := t.TempDir()
+			if tt.dotenv != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(tt.dotenv), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			chdir(t, dir)
+
+			cfg, err := loadConfig()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantHost, cfg.Host)
+			assert.Equal(t, tt.wantPort, cfg.Redis.Port)
+			assert.Equal(t, tt.wantTTL, cfg.Verification.PendingTTL)
+			assert.Equal(t, tt.wantAnon, cfg.Auth.AllowAnonymousShorten)
+			assert.Equal(t, tt.wantIssuer, cfg.Auth.TOTPIssuer)
+		})
+	}
+}
+
+// apiRequest is a request a command made of the fake server
+type apiRequest struct {
+	method string
+	path   string
+	query  url.Values
+	body   string
+}
+
+func TestCommands(t *testing.T) {
+	unsetenv(t, "SHORTENURL_API", "SHORTENURL_TOKEN", "SHORTENURL_USER")
+
+	var requests []apiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, apiRequest{r.Method, r.URL.Path, r.URL.Query(), string(body)})
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/links":
+			io.WriteString(w, `{"data":[]}`)
+		case r.Method == http.MethodGet && filepath.Dir(r.URL.Path) == "/api/v1/exports":
+			io.WriteString(w, "domain,shortCode\n")
+		default:
+			io.WriteString(w, `{"data":{}}`)
+		}
+	}))
+	defer server.Close()
+
+	export := filepath.Join(t.TempDir(), "links.csv")
+	if err := os.WriteFile(export, []byte("Bitlink,Long URL\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	api := func(args ...string) []string {
+		return append([]string{"-api", server.URL, "-token", "key"}, args...)
+	}
+	links := func(subcommand string, args ...string) []string {
+		return append([]string{subcommand}, api(args...)...)
+	}
+
+	tests := []struct {
+		name       string
+		run        func(cfg *config.Config, args []string) error
+		args       []string
+		want       *apiRequest
+		wantStdout string
+		wantErr    error
+	}{
+		{
+			name: "LinksCreate",
+			run:  linksCommand,
+			args: links("create", "-domain", "go.acme.link", "https://example.com"),
+			want: &apiRequest{http.MethodPost, "/api/v1/links", url.Values{},
+				`{"originalURL":"https://example.com","domain":"go.acme.link"}`},
+		},
+		{
+			name: "LinksGet",
+			run:  linksCommand,
+			args: links("get", "-domain", "go.acme.link", "abc123"),
+			want: &apiRequest{http.MethodGet, "/api/v1/links/abc123", url.Values{"domain": {"go.acme.link"}}, ""},
+		},
+		{
+			name: "LinksList",
+			run:  linksCommand,
+			args: links("list", "-tag", "sales", "-status", "active", "-sort", "-clicks", "-limit", "10"),
+			want: &apiRequest{http.MethodGet, "/api/v1/links",
+				url.Values{"tag": {"sales"}, "status": {"active"}, "sort": {"-clicks"}, "limit": {"10"}}, ""},
+		},
+		{
+			name: "LinksListInWorkspace",
+			run:  linksCommand,
+			args: links("list", "-workspace", "acme"),
+			want: &apiRequest{http.MethodGet, "/api/v1/links",
+				url.Values{"sort": {entity.DefaultLinkSort}, "workspace": {"acme"}}, ""},
+		},
+		{
+			name: "LinksUpdate",
+			run:  linksCommand,
+			args: links("update", "-url", "https://example.com/new", "abc123"),
+			want: &apiRequest{http.MethodPut, "/api/v1/links/abc123", url.Values{}, `{"originalURL":"https://example.com/new"}`},
+		},
+		{
+			name: "LinksUpdateRemoveFallback",
+			run:  linksCommand,
+			args: links("update", "-fallback=", "abc123"),
+			want: &apiRequest{http.MethodPut, "/api/v1/links/abc123", url.Values{}, `{"fallbackURL":""}`},
+		},
+		{
+			name: "LinksDelete",
+			run:  linksCommand,
+			args: links("delete", "-domain", "go.acme.link", "abc123"),
+			want: &apiRequest{http.MethodDelete, "/api/v1/links/abc123", url.Values{"domain": {"go.acme.link"}}, ""},
+		},
+		{
+			name: "LinksRewrite",
+			run:  linksCommand,
+			args: links("rewrite", "-dry-run", "docs.old.com", "docs.new.com"),
+			want: &apiRequest{http.MethodPost, "/api/v1/bulk/destinations", url.Values{},
+				`{"from":"docs.old.com","to":"docs.new.com","dryRun":true}`},
+		},
+		{
+			name: "Import",
+			run:  importCommand,
+			args: api("-format", "bitly", "-domain", "go.acme.link", export),
+			want: &apiRequest{http.MethodPost, "/api/v1/imports",
+				url.Values{"format": {"bitly"}, "domain": {"go.acme.link"}, "name": {"links.csv"}}, "Bitlink,Long URL\n"},
+		},
+		{
+			name: "ImportResume",
+			run:  importCommand,
+			args: api("-resume", "job1", "-no-wait", export),
+			want: &apiRequest{http.MethodPost, "/api/v1/imports/job1/resume", url.Values{}, "Bitlink,Long URL\n"},
+		},
+		{
+			name:       "ExportLinks",
+			run:        exportCommand,
+			args:       api("-format", "jsonl", "-tag", "sales", "links"),
+			want:       &apiRequest{http.MethodGet, "/api/v1/exports/links", url.Values{"format": {"jsonl"}, "tag": {"sales"}}, ""},
+			wantStdout: "domain,shortCode\n",
+		},
+		{
+			name: "ExportClicks",
+			run:  exportCommand,
+			args: api("-from", "2026-03-01", "clicks"),
+			want: &apiRequest{http.MethodGet, "/api/v1/exports/clicks",
+				url.Values{"format": {entity.DefaultExportFormat}, "from": {"2026-03-01"}}, ""},
+			wantStdout: "domain,shortCode\n",
+		},
+		{name: "LinksWithoutSubcommand", run: linksCommand, args: nil, wantErr: errUsage},
+		{name: "LinksUnknownSubcommand", run: linksCommand, args: []string{"rename"}, wantErr: errUsage},
+		{name: "LinksCreateWithoutURL", run: linksCommand, args: links("create"), wantErr: errUsage},
+		{name: "LinksUpdateWithoutChange", run: linksCommand, args: links("update", "abc123"), wantErr: errUsage},
+		{name: "LinksRewriteWithoutTo", run: linksCommand, args: links("rewrite", "docs.old.com"), wantErr: errUsage},
+		{name: "ImportWithoutFile", run: importCommand, args: api(), wantErr: errUsage},
+		{name: "ExportWithoutKind", run: exportCommand, args: api(), wantErr: errUsage},
+		{name: "ExportUnknownKind", run: exportCommand, args: api("visits"), wantErr: errUsage},
+		{name: "ExportClicksBySearch", run: exportCommand, args: api("-q", "sales", "clicks"), wantErr: errUsage},
+		{name: "CacheWithoutSubcommand", run: cacheCommand, args: nil, wantErr: errUsage},
+		{name: "CacheUnknownSubcommand", run: cacheCommand, args: []string{"warm"}, wantErr: errUsage},
+		{name: "CacheInspectWithoutCode", run: cacheCommand, args: []string{"inspect"}, wantErr: errUsage},
+		{name: "UsersWithoutSubcommand", run: usersCommand, args: nil, wantErr: errUsage},
+		{name: "UsersUnknownSubcommand", run: usersCommand, args: []string{"delete", "ada@acme.com"}, wantErr: errUsage},
+		{name: "UsersGetWithoutEmail", run: usersCommand, args: []string{"get"}, wantErr: errUsage},
+		{name: "MigrateWithArguments", run: migrateCommand, args: []string{"now"}, wantErr: errUsage},
+		{name: "BackupWithArguments", run: backupCommand, args: []string{"backup.tar.gz"}, wantErr: errUsage},
+		{name: "RestoreWithoutArchive", run: restoreCommand, args: nil, wantErr: errUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			stdout = &output
+			defer func() { stdout = os.Stdout }()
+			requests = nil
+
+			err := tt.run(&config.Config{}, tt.args)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, requests)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, requests, 1) {
+				request := requests[0]
+				assert.Equal(t, tt.want.method, request.method)
+				assert.Equal(t, tt.want.path, request.path)
+				assert.Equal(t, tt.want.query, request.query)
+				if len(tt.want.body) > 0 && tt.want.body[0] == '{' {
+					assert.JSONEq(t, tt.want.body, request.body)
+				} else {
+					assert.Equal(t, tt.want.body, request.body)
+				}
+			}
+			if tt.wantStdout != "" {
+				assert.Equal(t, tt.wantStdout, output.String())
+			}
+		})
+	}
+
+	t.Run("WithoutTarget", func(t *testing.T) {
+		err := linksCommand(&config.Config{}, []string{"get", "abc123"})
+
+		assert.EqualError(t, err, "either -api or -user is required")
+	})
+}
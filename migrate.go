@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"log"
+	"time"
+)
+
+// migrationRetryInterval is how often migrations try the lock while another instance migrates
+const migrationRetryInterval = 2 * time.Second
+
+// migrateCommand applies the migrations the database has not had yet without starting the
+// server, serve does the same on startup. -status lists the migrations instead.
+func migrateCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("migrate", "migrate [-status]")
+	status := flags.Bool("status", false, "list the migrations and when they were applied, without applying any")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx := context.Background()
+
+	if *status {
+		migrations, err := a.newMigrator().Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(migrations)
+	}
+
+	err = a.migrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Println("the database is up to date")
+
+	return nil
+}
+
+func (a *app) newMigrator() services.Migrator {
+	return services.NewMigrator(a.migrationRepository, a.migrations(), migrationRetryInterval)
+}
+
+// migrate applies the pending migrations, then registers the default domain, which changes
+// with the configuration rather than the version.
+func (a *app) migrate(ctx context.Context) error {
+	migrated, err := a.newMigrator().Migrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(migrated) > 0 {
+		log.Printf("applied %d migrations\n", len(migrated))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return a.domainService.EnsureDefaultDomain(ctx)
+}
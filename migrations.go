@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"strings"
+)
+
+// migrations bring databases of earlier versions up to date, they are applied once each in
+// order. Released migrations are never changed, changes of the database are appended with the
+// next version. The indexes and schemas of a migration are written out in it rather than taken
+// from the repositories, so a database migrated today ends up as one migrated back then.
+func (a *app) migrations() []services.Migration {
+	return []services.Migration{
+		{Version: 1, Name: "domain indexes", Up: a.createIndexes(collectionIndexes{domainsCollection, []mongo.IndexModel{
+			{Keys: bson.D{{"name", 1}}, Options: options.Index().SetUnique(true)},
+		}})},
+		{Version: 2, Name: "assign links to the default domain", Up: a.assignDefaultDomain},
+		{Version: 3, Name: "link indexes", Up: a.createLinkIndexes},
+		{Version: 4, Name: "account indexes", Up: a.createIndexes(
+			collectionIndexes{usersCollection, []mongo.IndexModel{
+				{Keys: bson.D{{"email", 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{"subject", 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+			}},
+			collectionIndexes{apiKeysCollection, []mongo.IndexModel{
+				{Keys: bson.D{{"keyHash", 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{"userID", 1}}},
+			}},
+			collectionIndexes{membershipsCollection, []mongo.IndexModel{
+				{Keys: bson.D{{"workspaceID", 1}, {"userID", 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{"userID", 1}}},
+			}},
+		)},
+		{Version: 5, Name: "history indexes", Up: a.createIndexes(
+			collectionIndexes{auditCollection, []mongo.IndexModel{
+				{Keys: bson.D{{"domain", 1}, {"shortCode", 1}, {"createdAt", -1}}},
+				{Keys: bson.D{{"workspace", 1}, {"createdAt", -1}}},
+			}},
+			collectionIndexes{revisionsCollection, []mongo.IndexModel{
+				{Keys: bson.D{{"domain", 1}, {"shortCode", 1}, {"createdAt", -1}}},
+			}},
+		)},
+		// completed import jobs are removed 30 days after they were last updated, failed and
+		// interrupted ones are kept until they are resumed
+		{Version: 6, Name: "import job expiry", Up: a.createIndexes(collectionIndexes{importsCollection, []mongo.IndexModel{
+			{Keys: bson.D{{"updatedAt", 1}}, Options: options.Index().
+				SetExpireAfterSeconds(30 * 24 * 60 * 60).
+				SetPartialFilterExpression(bson.D{{"status", "completed"}})},
+		}})},
+		{Version: 7, Name: "link creation times", Up: a.shortenedRepository.BackfillCreatedAt},
+		{Version: 8, Name: "schema validators", Up: a.setValidators},
+		{Version: 9, Name: "link creation time index", Up: a.createIndexes(collectionIndexes{linksCollection, []mongo.IndexModel{
+			{Keys: bson.D{{"workspace", 1}, {"createdAt", 1}, {"_id", 1}}},
+		}})},
+	}
+}
+
+// collectionIndexes are indexes to create on a collection
+type collectionIndexes struct {
+	collection string
+	indexes    []mongo.IndexModel
+}
+
+// createIndexes returns a migration creating the indexes of each collection in turn
+func (a *app) createIndexes(specs ...collectionIndexes) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, spec := range specs {
+			err := a.migrationRepository.CreateIndexes(ctx, spec.collection, spec.indexes)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// assignDefaultDomain moves links created before domains existed onto the default domain.
+func (a *app) assignDefaultDomain(ctx context.Context) error {
+	err := a.shortenedRepository.AssignDomain(ctx, a.domainService.DefaultDomain())
+	if err != nil {
+		return err
+	}
+
+	// the clicks of links are counted by domain and short code, so only after they have one
+	return a.clickRepository.BackfillLinkClicks(ctx)
+}
+
+// createLinkIndexes refuses to build the unique index of short codes while links share one,
+// listing them so they can be renamed or deleted before migrating again. The other indexes serve
+// workspace listings, their sort orders, search, tag filters and the trash purge.
+func (a *app) createLinkIndexes(ctx context.Context) error {
+	duplicates, err := a.shortenedRepository.FindDuplicateCodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(duplicates) > 0 {
+		codes := make([]string, len(duplicates))
+		for index, duplicate := range duplicates {
+			codes[index] = duplicate.Domain + "/" + duplicate.ShortCode
+		}
+
+		return fmt.Errorf("%w: %d short codes are used by several links, rename or delete all but one of each: %s",
+			constants.ErrorAlreadyExists, len(codes), strings.Join(codes, ", "))
+	}
+
+	return a.createIndexes(collectionIndexes{linksCollection, []mongo.IndexModel{
+		{Keys: bson.D{{"domain", 1}, {"shortCode", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"workspace", 1}}},
+		{Keys: bson.D{{"workspace", 1}, {"tags", 1}}},
+		{Keys: bson.D{{"workspace", 1}, {"clicks", 1}, {"_id", 1}}},
+		{Keys: bson.D{{"workspace", 1}, {"shortCode", 1}, {"_id", 1}}},
+		{Keys: bson.D{{"shortCode", 1}}},
+		{
+			Keys: bson.D{{"originalURL", "text"}, {"title", "text"}, {"notes", "text"}, {"tags", "text"}},
+			Options: options.Index().SetName("search").
+				SetWeights(bson.D{{"title", 10}, {"tags", 5}, {"notes", 2}, {"originalURL", 1}}),
+		},
+		{Keys: bson.D{{"deletedAt", 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{"workspace", 1}, {"importedFrom", 1}}, Options: options.Index().SetSparse(true)},
+	}})(ctx)
+}
+
+// setValidators makes the collections whose fields the services rely on validate the documents
+// written to them. The schemas only check those fields so documents can gain new ones without
+// changing them.
+func (a *app) setValidators(ctx context.Context) error {
+	nonEmptyString := bson.D{{"bsonType", "string"}, {"minLength", 1}}
+	date := bson.D{{"bsonType", "date"}}
+
+	validators := []struct {
+		collection string
+		schema     bson.D
+	}{
+		{linksCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"domain", "shortCode", "originalURL"}},
+			{"properties", bson.D{
+				{"domain", nonEmptyString},
+				{"shortCode", nonEmptyString},
+				{"originalURL", nonEmptyString},
+				{"status", bson.D{{"enum", bson.A{"active", "disabled", "blocked-by-admin"}}}},
+				{"tags", bson.D{{"bsonType", "array"}, {"items", bson.D{{"bsonType", "string"}}}}},
+				{"clicks", bson.D{{"bsonType", "number"}, {"minimum", 0}}},
+				{"createdAt", date},
+				{"updatedAt", date},
+				{"deletedAt", date},
+				{"expiresAt", date},
+			}},
+		}},
+		{domainsCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"name", "verification", "createdAt"}},
+			{"properties", bson.D{
+				{"name", nonEmptyString},
+				{"verification", bson.D{
+					{"bsonType", "object"},
+					{"required", bson.A{"status"}},
+					{"properties", bson.D{
+						{"status", bson.D{{"enum", bson.A{"pending", "verified"}}}},
+					}},
+				}},
+				{"createdAt", date},
+			}},
+		}},
+		{usersCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"email", "passwordHash", "createdAt"}},
+			{"properties", bson.D{
+				{"email", nonEmptyString},
+				{"passwordHash", bson.D{{"bsonType", "string"}}},
+				{"subject", nonEmptyString},
+				{"createdAt", date},
+			}},
+		}},
+		{apiKeysCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"userID", "keyHash", "createdAt"}},
+			{"properties", bson.D{
+				{"userID", nonEmptyString},
+				{"keyHash", nonEmptyString},
+				{"scopes", bson.D{
+					{"bsonType", bson.A{"array", "null"}},
+					{"items", bson.D{{"enum", bson.A{"links:read", "links:write", "stats:read"}}}},
+				}},
+				{"createdAt", date},
+				{"lastUsedAt", date},
+				{"revokedAt", date},
+			}},
+		}},
+		{workspacesCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"name", "createdAt"}},
+			{"properties", bson.D{
+				{"name", nonEmptyString},
+				{"requireTwoFactor", bson.D{{"bsonType", "bool"}}},
+				{"createdAt", date},
+			}},
+		}},
+		{membershipsCollection, bson.D{
+			{"bsonType", "object"},
+			{"required", bson.A{"workspaceID", "userID", "role", "createdAt"}},
+			{"properties", bson.D{
+				{"workspaceID", nonEmptyString},
+				{"userID", nonEmptyString},
+				{"role", bson.D{{"enum", bson.A{"owner", "admin", "editor", "viewer"}}}},
+				{"createdAt", date},
+			}},
+		}},
+	}
+
+	for _, validator := range validators {
+		err := a.migrationRepository.SetValidator(ctx, validator.collection, validator.schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
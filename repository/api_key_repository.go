@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"time"
+)
+
+type APIKeyRepository interface {
+	Insert(ctx context.Context, key entity.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	GetByUser(ctx context.Context, userID string) (*[]entity.APIKey, error)
+	Revoke(ctx context.Context, userID string, id string) (*entity.APIKey, error)
+	UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error
+}
+
+type APIKeyRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewAPIKeyRepository(col *mongo.Collection) *APIKeyRepositoryIml {
+	return &APIKeyRepositoryIml{col: col}
+}
+
+func (i *APIKeyRepositoryIml) Insert(ctx context.Context, key entity.APIKey) error {
+	_, err := i.col.InsertOne(ctx, key)
+
+	return err
+}
+
+func (i *APIKeyRepositoryIml) GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+
+	err := i.col.FindOne(ctx, bson.D{{"keyHash", keyHash}}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (i *APIKeyRepositoryIml) GetByUser(ctx context.Context, userID string) (*[]entity.APIKey, error) {
+	keys := []entity.APIKey{}
+	opts := options.Find().SetSort(bson.D{{"createdAt", -1}})
+
+	cursor, err := i.col.Find(ctx, bson.D{{"userID", userID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return &keys, nil
+}
+
+// Revoke marks a key of the user as revoked, the key is kept so its history stays visible.
+func (i *APIKeyRepositoryIml) Revoke(ctx context.Context, userID string, id string) (*entity.APIKey, error) {
+	filter := bson.D{{"_id", id}, {"userID", userID}}
+	update := bson.D{{"$set", bson.D{{"revokedAt", time.Now()}}}}
+	var key entity.APIKey
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (i *APIKeyRepositoryIml) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	update := bson.D{{"$set", bson.D{{"lastUsedAt", lastUsedAt}}}}
+	_, err := i.col.UpdateOne(ctx, bson.D{{"_id", id}}, update)
+
+	return err
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AuditRepository stores the audit log, entries are only ever appended.
+type AuditRepository interface {
+	Insert(ctx context.Context, entry entity.AuditEntry) error
+	GetByLink(ctx context.Context, domain string, shortCode string, limit int64) (*[]entity.AuditEntry, error)
+	GetByWorkspace(ctx context.Context, workspace string, limit int64) (*[]entity.AuditEntry, error)
+}
+
+type AuditRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewAuditRepository(col *mongo.Collection) *AuditRepositoryIml {
+	return &AuditRepositoryIml{col: col}
+}
+
+func (i *AuditRepositoryIml) Insert(ctx context.Context, entry entity.AuditEntry) error {
+	_, err := i.col.InsertOne(ctx, entry)
+
+	return err
+}
+
+func (i *AuditRepositoryIml) GetByLink(ctx context.Context, domain string, shortCode string, limit int64) (*[]entity.AuditEntry, error) {
+	return i.find(ctx, bson.D{{"domain", domain}, {"shortCode", shortCode}}, limit)
+}
+
+func (i *AuditRepositoryIml) GetByWorkspace(ctx context.Context, workspace string, limit int64) (*[]entity.AuditEntry, error) {
+	return i.find(ctx, bson.D{{"workspace", workspace}}, limit)
+}
+
+// find returns the newest entries matching filter first
+func (i *AuditRepositoryIml) find(ctx context.Context, filter bson.D, limit int64) (*[]entity.AuditEntry, error) {
+	entries := []entity.AuditEntry{}
+	opts := options.Find().SetSort(bson.D{{"createdAt", -1}, {"_id", -1}}).SetLimit(limit)
+
+	cursor, err := i.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return &entries, nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log"
+)
+
+// BackupRepository reads and writes whole collections as raw documents, keeping every field
+// as stored, for backups and restores.
+type BackupRepository interface {
+	Snapshot(ctx context.Context, fn func(ctx context.Context) error) error
+	StreamCollection(ctx context.Context, collection string, fn func(document bson.Raw) error) error
+	StreamClickRollups(ctx context.Context, fn func(document bson.Raw) error) error
+	CountExisting(ctx context.Context, collection string, ids []bson.RawValue) (int64, error)
+	RestoreDocuments(ctx context.Context, collection string, documents []bson.Raw, mode string) (*entity.RestoredCollection, error)
+}
+
+// BackupRepositoryIml backs up the collections of db. Clicks are backed up as daily counts
+// computed from clickCol, which are restored to their own collection; restoring links
+// removes the cache entries of the links it replaced.
+type BackupRepositoryIml struct {
+	db       *mongo.Database
+	linkCol  *mongo.Collection
+	clickCol *mongo.Collection
+	cache    Cache[entity.ShortenedURL]
+}
+
+func NewBackupRepository(db *mongo.Database, linkCol *mongo.Collection, clickCol *mongo.Collection, cache Cache[entity.ShortenedURL]) *BackupRepositoryIml {
+	return &BackupRepositoryIml{db: db, linkCol: linkCol, clickCol: clickCol, cache: cache}
+}
+
+// Snapshot calls fn with a context whose reads all see the same snapshot of the database. It
+// returns constants.ErrorSnapshotUnsupported on servers without snapshot reads, which need a
+// replica set or sharded cluster of MongoDB 5.0 or later.
+func (i *BackupRepositoryIml) Snapshot(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := i.db.Client().StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	ctx = mongo.NewSessionContext(ctx, session)
+
+	// standalone servers only refuse snapshot reads once one is made
+	err = i.linkCol.FindOne(ctx, bson.D{}).Err()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("%w: %v", constants.ErrorSnapshotUnsupported, err)
+	}
+
+	return fn(ctx)
+}
+
+func stream(ctx context.Context, cursor *mongo.Cursor, fn func(document bson.Raw) error) error {
+	defer cursor.Close(context.WithoutCancel(ctx))
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// StreamCollection calls fn with every document of a collection in the order of their IDs.
+// The document is only valid until fn returns.
+func (i *BackupRepositoryIml) StreamCollection(ctx context.Context, collection string, fn func(document bson.Raw) error) error {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetBatchSize(streamBatchSize)
+
+	cursor, err := i.db.Collection(collection).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+
+	return stream(ctx, cursor, fn)
+}
+
+// StreamClickRollups calls fn with the clicks of every link by day, including the daily
+// counts restored from earlier backups. Their ID is the domain, short code and day.
+func (i *BackupRepositoryIml) StreamClickRollups(ctx context.Context, fn func(document bson.Raw) error) error {
+	pipeline := mongo.Pipeline{
+		{{"$group", bson.D{
+			{"_id", bson.D{
+				{"domain", "$domain"},
+				{"shortCode", "$shortCode"},
+				{"day", bson.D{{"$dateTrunc", bson.D{{"date", "$createdAt"}, {"unit", "day"}}}}},
+			}},
+			{"clicks", bson.D{{"$sum", 1}}},
+			{"fallbackClicks", bson.D{{"$sum", bson.D{{"$cond", bson.A{"$fallback", 1, 0}}}}}},
+		}}},
+		{{"$unionWith", bson.D{{"coll", entity.BackupClickRollups}}}},
+		{{"$group", bson.D{
+			{"_id", "$_id"},
+			{"clicks", bson.D{{"$sum", "$clicks"}}},
+			{"fallbackClicks", bson.D{{"$sum", "$fallbackClicks"}}},
+		}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+	}
+
+	opts := options.Aggregate().SetAllowDiskUse(true).SetBatchSize(streamBatchSize)
+
+	cursor, err := i.clickCol.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+
+	return stream(ctx, cursor, fn)
+}
+
+// CountExisting returns how many of the given IDs a collection has documents for.
+func (i *BackupRepositoryIml) CountExisting(ctx context.Context, collection string, ids []bson.RawValue) (int64, error) {
+	return i.db.Collection(collection).CountDocuments(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}})
+}
+
+// RestoreDocuments writes documents to a collection, resolving those whose ID exists already
+// with mode. In fail mode the first of them stops the restore with constants.ErrorAlreadyExists.
+func (i *BackupRepositoryIml) RestoreDocuments(ctx context.Context, collection string, documents []bson.Raw, mode string) (*entity.RestoredCollection, error) {
+	restored := &entity.RestoredCollection{Name: collection}
+	if len(documents) == 0 {
+		return restored, nil
+	}
+
+	col := i.db.Collection(collection)
+
+	if mode == entity.RestoreOverwrite {
+		models := make([]mongo.WriteModel, 0, len(documents))
+		for _, document := range documents {
+			models = append(models, mongo.NewReplaceOneModel().
+				SetFilter(bson.D{{"_id", document.Lookup("_id")}}).
+				SetReplacement(document).
+				SetUpsert(true))
+		}
+
+		result, err := col.BulkWrite(ctx, models)
+		if mongo.IsDuplicateKeyError(err) {
+			// another document has the same short code, email or other unique key
+			return nil, fmt.Errorf("%w: %v of %s", constants.ErrorAlreadyExists, err, collection)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		restored.Inserted = result.UpsertedCount
+		restored.Replaced = result.MatchedCount
+
+		if collection == i.linkCol.Name() {
+			i.uncache(ctx, documents)
+		}
+
+		return restored, nil
+	}
+
+	// without ordering, inserts carry on past documents that exist already
+	opts := options.InsertMany().SetOrdered(mode == entity.RestoreFail)
+
+	_, err := col.InsertMany(ctx, documents, opts)
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr.WriteError) {
+				return nil, err
+			}
+
+			if mode == entity.RestoreFail {
+				return nil, fmt.Errorf("%w: %s of %s", constants.ErrorAlreadyExists, writeErr.Message, collection)
+			}
+		}
+
+		restored.Skipped = int64(len(bulkErr.WriteErrors))
+	} else if err != nil {
+		return nil, err
+	}
+
+	restored.Inserted = int64(len(documents)) - restored.Skipped
+
+	return restored, nil
+}
+
+// uncache removes the cache entries of restored links, redirects read them again
+func (i *BackupRepositoryIml) uncache(ctx context.Context, documents []bson.Raw) {
+	keys := make([]string, 0, len(documents))
+	for _, document := range documents {
+		domain, _ := document.Lookup("domain").StringValueOK()
+		shortCode, _ := document.Lookup("shortCode").StringValueOK()
+		keys = append(keys, cacheKey(domain, shortCode))
+	}
+
+	err := i.cache.Delete(ctx, keys...)
+	if err != nil {
+		log.Printf("error removing restored links from the cache %v\n", err)
+	}
+}
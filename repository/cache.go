@@ -15,7 +15,8 @@ type Cache[T any] interface {
 	Get(ctx context.Context, key string) (T, error)
 	Put(ctx context.Context, key string, val T, ttl uint64) error
 	IsExist(ctx context.Context, key string) (bool, error)
-	Delete(ctx context.Context, key string) error
+	Delete(ctx context.Context, keys ...string) error
+	Keys(ctx context.Context, pattern string) ([]string, error)
 	Flush(ctx context.Context) error
 }
 
@@ -70,8 +71,21 @@ func (rc *RedisCache[T]) IsExist(ctx context.Context, key string) (bool, error)
 	return r > 0, nil
 }
 
-func (rc *RedisCache[T]) Delete(ctx context.Context, key string) error {
-	return rc.client.Del(ctx, key).Err()
+func (rc *RedisCache[T]) Delete(ctx context.Context, keys ...string) error {
+	return rc.client.Del(ctx, keys...).Err()
+}
+
+// Keys returns the keys matching a glob-style pattern. They are scanned a batch at a time, so
+// the server keeps serving others meanwhile.
+func (rc *RedisCache[T]) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+
+	iter := rc.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
 }
 
 func (rc *RedisCache[T]) Flush(ctx context.Context) error {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -168,3 +169,52 @@ func TestRedisCache_PutWithTTL(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
+
+func TestRedisCache_Keys(t *testing.T) {
+	cache, mr, err := setupRedisCache()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	mr.Set("short.url/abc", "{}")
+	mr.Set("short.url/def", "{}")
+	mr.Set("session:abc", "{}")
+
+	keys, err := cache.Keys(context.Background(), "short.url/*")
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"short.url/abc", "short.url/def"}, keys)
+}
+
+func TestLinkCache(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	ctx := context.Background()
+	linkCache := NewLinkCache(NewRedisCache[entity.ShortenedURL](redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+
+	mr.Set("short.url/abc", `{"shortCode":"abc","originalURL":"https://example.com"}`)
+	mr.Set("go.acme.com/abc", "{}")
+	mr.Set("session:abc", "{}")
+
+	shortened, err := linkCache.Get(ctx, "short.url", "abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", shortened.OriginalURL)
+
+	_, err = linkCache.Get(ctx, "short.url", "def")
+
+	assert.ErrorIs(t, err, constants.ErrorCacheNotFound)
+
+	flushed, err := linkCache.Flush(ctx, "short.url")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	assert.ElementsMatch(t, []string{"go.acme.com/abc", "session:abc"}, mr.Keys())
+
+	flushed, err = linkCache.Flush(ctx, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, []string{"session:abc"}, mr.Keys())
+}
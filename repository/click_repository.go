@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log"
+	"time"
+)
+
+type ClickRepository interface {
+	Insert(ctx context.Context, click entity.Click) error
+	GetStats(ctx context.Context, domain string, shortCode string) (*entity.LinkStats, error)
+	BackfillLinkClicks(ctx context.Context) error
+	StreamClicks(ctx context.Context, workspace string, query entity.ClickQuery, fn func(click entity.Click) error) error
+	DeleteByLink(ctx context.Context, domain string, shortCode string) error
+}
+
+// ClickRepositoryIml records clicks in col and counts them on the links in linkCol, which
+// link listings sort by.
+type ClickRepositoryIml struct {
+	col        *mongo.Collection
+	linkCol    *mongo.Collection
+	clickTasks chan entity.Click
+}
+
+func NewClickRepository(col *mongo.Collection, linkCol *mongo.Collection) *ClickRepositoryIml {
+	repo := &ClickRepositoryIml{
+		col:        col,
+		linkCol:    linkCol,
+		clickTasks: make(chan entity.Click, 1000),
+	}
+
+	// clicks are written in the background so redirects never wait on mongodb
+	for i := 0; i < 5; i++ {
+		go repo.clickWorker()
+	}
+
+	return repo
+}
+
+func (i *ClickRepositoryIml) clickWorker() {
+	for click := range i.clickTasks {
+		i.insertClick(click)
+	}
+}
+
+func (i *ClickRepositoryIml) insertClick(click entity.Click) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := i.col.InsertOne(ctx, click)
+	if err != nil {
+		log.Printf("error inserting click %v: %v\n", click.ShortCode, err)
+		return
+	}
+
+	update := bson.D{{"$inc", bson.D{{"clicks", 1}}}}
+
+	_, err = i.linkCol.UpdateOne(ctx, linkFilter(click.Domain, click.ShortCode), update)
+	if err != nil {
+		log.Printf("error counting click %v: %v\n", click.ShortCode, err)
+	}
+}
+
+func (i *ClickRepositoryIml) Insert(ctx context.Context, click entity.Click) error {
+	select {
+	case i.clickTasks <- click:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *ClickRepositoryIml) GetStats(ctx context.Context, domain string, shortCode string) (*entity.LinkStats, error) {
+	filter := bson.D{{"domain", domain}, {"shortCode", shortCode}}
+
+	clicks, err := i.col.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackClicks, err := i.col.CountDocuments(ctx, append(filter, bson.E{Key: "fallback", Value: true}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.LinkStats{
+		Domain:         domain,
+		ShortCode:      shortCode,
+		Clicks:         clicks,
+		FallbackClicks: fallbackClicks,
+	}, nil
+}
+
+// StreamClicks calls fn with every click on the links of a workspace matching query, in the
+// order they were recorded, reading them from a cursor a batch at a time. Clicks do not record
+// the workspace, it is looked up on their link. An error of fn stops the stream.
+func (i *ClickRepositoryIml) StreamClicks(ctx context.Context, workspace string, query entity.ClickQuery, fn func(click entity.Click) error) error {
+	match := bson.D{}
+	if query.Domain != "" {
+		match = append(match, bson.E{Key: "domain", Value: query.Domain})
+	}
+
+	created := bson.D{}
+	if !query.From.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: query.To})
+	}
+	if len(created) > 0 {
+		match = append(match, bson.E{Key: "createdAt", Value: created})
+	}
+
+	link := bson.D{
+		{"$expr", bson.D{{"$and", bson.A{
+			bson.D{{"$eq", bson.A{"$domain", "$$domain"}}},
+			bson.D{{"$eq", bson.A{"$shortCode", "$$shortCode"}}},
+		}}}},
+		{"workspace", workspace},
+	}
+	if query.Tag != "" {
+		link = append(link, bson.E{Key: "tags", Value: query.Tag})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$lookup", bson.D{
+			{"from", i.linkCol.Name()},
+			{"let", bson.D{{"domain", "$domain"}, {"shortCode", "$shortCode"}}},
+			{"pipeline", mongo.Pipeline{{{"$match", link}}, {{"$limit", 1}}, {{"$project", bson.D{{"_id", 1}}}}}},
+			{"as", "link"},
+		}}},
+		{{"$match", bson.D{{"link", bson.D{{"$ne", bson.A{}}}}}}},
+		{{"$project", bson.D{{"link", 0}}}},
+	}
+
+	opts := options.Aggregate().SetBatchSize(streamBatchSize)
+
+	cursor, err := i.col.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.WithoutCancel(ctx))
+
+	for cursor.Next(ctx) {
+		var click entity.Click
+		if err := cursor.Decode(&click); err != nil {
+			return err
+		}
+
+		if err := fn(click); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// DeleteByLink deletes the clicks of a link, along with the daily counts restored from backups.
+func (i *ClickRepositoryIml) DeleteByLink(ctx context.Context, domain string, shortCode string) error {
+	_, err := i.col.DeleteMany(ctx, linkFilter(domain, shortCode))
+	if err != nil {
+		return err
+	}
+
+	rollups := i.col.Database().Collection(entity.BackupClickRollups)
+	_, err = rollups.DeleteMany(ctx, bson.D{{"_id.domain", domain}, {"_id.shortCode", shortCode}})
+
+	return err
+}
+
+// BackfillLinkClicks counts the recorded clicks of links created before the counter existed.
+func (i *ClickRepositoryIml) BackfillLinkClicks(ctx context.Context) error {
+	uncounted := bson.D{{"clicks", bson.D{{"$exists", false}}}}
+
+	count, err := i.linkCol.CountDocuments(ctx, uncounted)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$group", bson.D{{"_id", bson.D{{"domain", "$domain"}, {"shortCode", "$shortCode"}}}, {"clicks", bson.D{{"$sum", 1}}}}}},
+	}
+
+	cursor, err := i.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var totals []struct {
+		ID struct {
+			Domain    string `bson:"domain"`
+			ShortCode string `bson:"shortCode"`
+		} `bson:"_id"`
+		Clicks int64 `bson:"clicks"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return err
+	}
+
+	for _, total := range totals {
+		filter := append(linkFilter(total.ID.Domain, total.ID.ShortCode), uncounted...)
+
+		_, err := i.linkCol.UpdateOne(ctx, filter, bson.D{{"$set", bson.D{{"clicks", total.Clicks}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	// the remaining links were never clicked
+	_, err = i.linkCol.UpdateMany(ctx, uncounted, bson.D{{"$set", bson.D{{"clicks", 0}}}})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("counted the clicks of %d shortened URLs\n", count)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type DomainRepository interface {
+	GetByName(ctx context.Context, name string) (*entity.Domain, error)
+	Insert(ctx context.Context, domain entity.Domain) error
+	GetDomains(ctx context.Context) (*[]entity.Domain, error)
+	UpdateByName(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error)
+	UpdateVerification(ctx context.Context, name string, verification entity.DomainVerification) (*entity.Domain, error)
+	DeletePending(ctx context.Context, name string) error
+}
+
+type DomainRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewDomainRepository(col *mongo.Collection) *DomainRepositoryIml {
+	return &DomainRepositoryIml{col: col}
+}
+
+func (i *DomainRepositoryIml) GetByName(ctx context.Context, name string) (*entity.Domain, error) {
+	filter := bson.D{{"name", name}}
+	var domain entity.Domain
+
+	err := i.col.FindOne(ctx, filter).Decode(&domain)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+func (i *DomainRepositoryIml) Insert(ctx context.Context, domain entity.Domain) error {
+	_, err := i.col.InsertOne(ctx, domain)
+
+	return err
+}
+
+func (i *DomainRepositoryIml) GetDomains(ctx context.Context) (*[]entity.Domain, error) {
+	var domains []entity.Domain
+	opts := options.Find().SetSort(bson.D{{"name", 1}})
+
+	cursor, err := i.col.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+
+	return &domains, nil
+}
+
+func (i *DomainRepositoryIml) UpdateByName(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error) {
+	update := bson.D{{"$set", bson.D{
+		{"rootRedirectURL", rootRedirectURL},
+		{"notFoundTemplate", notFoundTemplate},
+		{"unavailableTemplate", unavailableTemplate},
+	}}}
+
+	return i.updateByName(ctx, name, update)
+}
+
+func (i *DomainRepositoryIml) UpdateVerification(ctx context.Context, name string, verification entity.DomainVerification) (*entity.Domain, error) {
+	update := bson.D{{"$set", bson.D{{"verification", verification}}}}
+
+	return i.updateByName(ctx, name, update)
+}
+
+// DeletePending removes a domain that is still pending and was never verified
+func (i *DomainRepositoryIml) DeletePending(ctx context.Context, name string) error {
+	filter := bson.D{
+		{"name", name},
+		{"verification.status", entity.DomainStatusPending},
+		{"verification.verifiedAt", bson.D{{"$exists", false}}},
+	}
+
+	_, err := i.col.DeleteOne(ctx, filter)
+
+	return err
+}
+
+func (i *DomainRepositoryIml) updateByName(ctx context.Context, name string, update bson.D) (*entity.Domain, error) {
+	filter := bson.D{{"name", name}}
+	var domain entity.Domain
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&domain)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &domain, nil
+}
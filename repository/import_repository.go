@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ImportRepository stores link import jobs and their progress.
+type ImportRepository interface {
+	Insert(ctx context.Context, job entity.ImportJob) error
+	GetByID(ctx context.Context, id string) (*entity.ImportJob, error)
+	Update(ctx context.Context, job entity.ImportJob) error
+}
+
+type ImportRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewImportRepository(col *mongo.Collection) *ImportRepositoryIml {
+	return &ImportRepositoryIml{col: col}
+}
+
+func (i *ImportRepositoryIml) Insert(ctx context.Context, job entity.ImportJob) error {
+	_, err := i.col.InsertOne(ctx, job)
+
+	return err
+}
+
+func (i *ImportRepositoryIml) GetByID(ctx context.Context, id string) (*entity.ImportJob, error) {
+	var job entity.ImportJob
+
+	err := i.col.FindOne(ctx, bson.D{{"_id", id}}).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Update saves the progress of a job
+func (i *ImportRepositoryIml) Update(ctx context.Context, job entity.ImportJob) error {
+	result, err := i.col.ReplaceOne(ctx, bson.D{{"_id", job.ID}}, job)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return constants.ErrorNotFound
+	}
+
+	return nil
+}
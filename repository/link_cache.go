@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"strings"
+)
+
+// LinkCache inspects and clears the redirects ShortenedRepository caches. Sessions share the
+// cache and are left alone.
+type LinkCache interface {
+	Get(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error)
+	Flush(ctx context.Context, domain string) (int, error)
+}
+
+type LinkCacheIml struct {
+	cache Cache[entity.ShortenedURL]
+}
+
+func NewLinkCache(cache Cache[entity.ShortenedURL]) *LinkCacheIml {
+	return &LinkCacheIml{cache: cache}
+}
+
+// Get returns the cached link, constants.ErrorCacheNotFound when it is not cached.
+func (i *LinkCacheIml) Get(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error) {
+	shortened, err := i.cache.Get(ctx, cacheKey(domain, shortCode))
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortened, nil
+}
+
+// Flush removes the cached links of a domain, or of every domain when it is empty, and returns
+// how many there were. Redirects read them from the database again.
+func (i *LinkCacheIml) Flush(ctx context.Context, domain string) (int, error) {
+	pattern := "*/*"
+	if domain != "" {
+		pattern = escapeGlob(domain) + "/*"
+	}
+
+	keys, err := i.cache.Keys(ctx, pattern)
+	if err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(keys); start += streamBatchSize {
+		err := i.cache.Delete(ctx, keys[start:min(start+streamBatchSize, len(keys))]...)
+		if err != nil {
+			return start, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// escapeGlob matches value literally in a pattern of Cache.Keys
+func escapeGlob(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(value)
+}
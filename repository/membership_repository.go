@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MembershipRepository stores the roles users have in workspaces, one per workspace and user.
+type MembershipRepository interface {
+	Get(ctx context.Context, workspaceID string, userID string) (*entity.Membership, error)
+	GetByUser(ctx context.Context, userID string) (*[]entity.Membership, error)
+	GetByWorkspace(ctx context.Context, workspaceID string) (*[]entity.Membership, error)
+	Insert(ctx context.Context, membership entity.Membership) error
+	UpdateRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error)
+	Delete(ctx context.Context, workspaceID string, userID string) error
+	CountByRole(ctx context.Context, workspaceID string, role string) (int64, error)
+}
+
+type MembershipRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewMembershipRepository(col *mongo.Collection) *MembershipRepositoryIml {
+	return &MembershipRepositoryIml{col: col}
+}
+
+func membershipFilter(workspaceID string, userID string) bson.D {
+	return bson.D{{"workspaceID", workspaceID}, {"userID", userID}}
+}
+
+func (i *MembershipRepositoryIml) Get(ctx context.Context, workspaceID string, userID string) (*entity.Membership, error) {
+	var membership entity.Membership
+
+	err := i.col.FindOne(ctx, membershipFilter(workspaceID, userID)).Decode(&membership)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func (i *MembershipRepositoryIml) GetByUser(ctx context.Context, userID string) (*[]entity.Membership, error) {
+	return i.find(ctx, bson.D{{"userID", userID}})
+}
+
+func (i *MembershipRepositoryIml) GetByWorkspace(ctx context.Context, workspaceID string) (*[]entity.Membership, error) {
+	return i.find(ctx, bson.D{{"workspaceID", workspaceID}})
+}
+
+func (i *MembershipRepositoryIml) find(ctx context.Context, filter bson.D) (*[]entity.Membership, error) {
+	memberships := []entity.Membership{}
+	opts := options.Find().SetSort(bson.D{{"createdAt", 1}})
+
+	cursor, err := i.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+
+	return &memberships, nil
+}
+
+func (i *MembershipRepositoryIml) Insert(ctx context.Context, membership entity.Membership) error {
+	_, err := i.col.InsertOne(ctx, membership)
+
+	return err
+}
+
+func (i *MembershipRepositoryIml) UpdateRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error) {
+	update := bson.D{{"$set", bson.D{{"role", role}}}}
+	var membership entity.Membership
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, membershipFilter(workspaceID, userID), update, opts).Decode(&membership)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func (i *MembershipRepositoryIml) Delete(ctx context.Context, workspaceID string, userID string) error {
+	result, err := i.col.DeleteOne(ctx, membershipFilter(workspaceID, userID))
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return constants.ErrorNotFound
+	}
+
+	return nil
+}
+
+func (i *MembershipRepositoryIml) CountByRole(ctx context.Context, workspaceID string, role string) (int64, error) {
+	return i.col.CountDocuments(ctx, bson.D{{"workspaceID", workspaceID}, {"role", role}})
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"time"
+)
+
+// migrationLockID is the ID of the document holding the migration lock, next to the applied
+// migrations whose IDs are their versions
+const migrationLockID = "lock"
+
+// namespaceNotFound is the code of the error collMod fails with on missing collections
+const namespaceNotFound = 26
+
+// MigrationRepository records the migrations applied to the database and holds the lock
+// keeping instances starting together from applying them twice.
+type MigrationRepository interface {
+	Lock(ctx context.Context, owner string, ttl time.Duration) error
+	Unlock(ctx context.Context, owner string) error
+	ListApplied(ctx context.Context) ([]entity.Migration, error)
+	Record(ctx context.Context, migration entity.Migration) error
+	SetValidator(ctx context.Context, collection string, schema bson.D) error
+	CreateIndexes(ctx context.Context, collection string, indexes []mongo.IndexModel) error
+}
+
+// MigrationRepositoryIml records migrations in col, sets the validators and creates the indexes
+// of the collections of db.
+type MigrationRepositoryIml struct {
+	db  *mongo.Database
+	col *mongo.Collection
+}
+
+func NewMigrationRepository(db *mongo.Database, col *mongo.Collection) *MigrationRepositoryIml {
+	return &MigrationRepositoryIml{db: db, col: col}
+}
+
+// Lock takes the migration lock for owner until ttl has passed, or extends it when owner holds it
+// already. It returns constants.ErrorMigrationLocked while another owner holds it, a lock
+// left by an instance that stopped can be taken once it expires.
+func (i *MigrationRepositoryIml) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	now := time.Now()
+	filter := bson.D{{"_id", migrationLockID}, {"$or", bson.A{
+		bson.D{{"owner", owner}},
+		bson.D{{"expiresAt", bson.D{{"$lte", now}}}},
+	}}}
+	update := bson.D{{"$set", bson.D{{"owner", owner}, {"expiresAt", now.Add(ttl)}}}}
+
+	// when the lock is held by another owner the filter misses and the upsert hits its ID
+	_, err := i.col.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return constants.ErrorMigrationLocked
+	}
+
+	return err
+}
+
+// Unlock releases the lock if owner holds it
+func (i *MigrationRepositoryIml) Unlock(ctx context.Context, owner string) error {
+	_, err := i.col.DeleteOne(ctx, bson.D{{"_id", migrationLockID}, {"owner", owner}})
+
+	return err
+}
+
+// ListApplied returns the applied migrations by version
+func (i *MigrationRepositoryIml) ListApplied(ctx context.Context) ([]entity.Migration, error) {
+	filter := bson.D{{"_id", bson.D{{"$type", "number"}}}}
+
+	cursor, err := i.col.Find(ctx, filter, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]entity.Migration, 0)
+	err = cursor.All(ctx, &migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrations, nil
+}
+
+func (i *MigrationRepositoryIml) Record(ctx context.Context, migration entity.Migration) error {
+	_, err := i.col.InsertOne(ctx, migration)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: migration %d", constants.ErrorAlreadyExists, migration.Version)
+	}
+
+	return err
+}
+
+// SetValidator makes collection validate the documents written to it against a JSON schema,
+// creating the collection when it does not exist yet. Documents that were invalid before can
+// still be updated, the validation level is moderate.
+func (i *MigrationRepositoryIml) SetValidator(ctx context.Context, collection string, schema bson.D) error {
+	validator := bson.D{{"$jsonSchema", schema}}
+
+	err := i.db.RunCommand(ctx, bson.D{
+		{"collMod", collection},
+		{"validator", validator},
+		{"validationLevel", "moderate"},
+	}).Err()
+
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.HasErrorCode(namespaceNotFound) {
+		opts := options.CreateCollection().SetValidator(validator).SetValidationLevel("moderate")
+
+		return i.db.CreateCollection(ctx, collection, opts)
+	}
+
+	return err
+}
+
+// CreateIndexes creates the indexes of collection, indexes that exist already with the same
+// options are left as they are.
+func (i *MigrationRepositoryIml) CreateIndexes(ctx context.Context, collection string, indexes []mongo.IndexModel) error {
+	_, err := i.db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+
+	return err
+}
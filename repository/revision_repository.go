@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// RevisionRepository stores the destinations links had.
+type RevisionRepository interface {
+	Insert(ctx context.Context, revision entity.Revision) error
+	GetByID(ctx context.Context, id string) (*entity.Revision, error)
+	GetByLink(ctx context.Context, domain string, shortCode string) (*[]entity.Revision, error)
+	DeleteByLink(ctx context.Context, domain string, shortCode string) error
+}
+
+type RevisionRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewRevisionRepository(col *mongo.Collection) *RevisionRepositoryIml {
+	return &RevisionRepositoryIml{col: col}
+}
+
+func (i *RevisionRepositoryIml) Insert(ctx context.Context, revision entity.Revision) error {
+	_, err := i.col.InsertOne(ctx, revision)
+
+	return err
+}
+
+func (i *RevisionRepositoryIml) GetByID(ctx context.Context, id string) (*entity.Revision, error) {
+	var revision entity.Revision
+
+	err := i.col.FindOne(ctx, bson.D{{"_id", id}}).Decode(&revision)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// GetByLink returns the revisions of a link, the current destination first.
+func (i *RevisionRepositoryIml) GetByLink(ctx context.Context, domain string, shortCode string) (*[]entity.Revision, error) {
+	revisions := []entity.Revision{}
+	opts := options.Find().SetSort(bson.D{{"createdAt", -1}, {"_id", -1}})
+
+	cursor, err := i.col.Find(ctx, linkFilter(domain, shortCode), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return &revisions, nil
+}
+
+func (i *RevisionRepositoryIml) DeleteByLink(ctx context.Context, domain string, shortCode string) error {
+	_, err := i.col.DeleteMany(ctx, linkFilter(domain, shortCode))
+
+	return err
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"time"
+)
+
+// SessionRepository keeps sessions in the cache, they expire together with their entry.
+type SessionRepository interface {
+	Insert(ctx context.Context, tokenHash string, session entity.Session) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entity.Session, error)
+	Delete(ctx context.Context, tokenHash string) error
+}
+
+type SessionRepositoryIml struct {
+	cache Cache[entity.Session]
+}
+
+func NewSessionRepository(cache Cache[entity.Session]) *SessionRepositoryIml {
+	return &SessionRepositoryIml{cache: cache}
+}
+
+func sessionKey(tokenHash string) string {
+	return "session:" + tokenHash
+}
+
+func (i *SessionRepositoryIml) Insert(ctx context.Context, tokenHash string, session entity.Session) error {
+	ttl := time.Until(session.ExpiresAt).Seconds()
+	if ttl < 1 {
+		return nil
+	}
+
+	return i.cache.Put(ctx, sessionKey(tokenHash), session, uint64(ttl))
+}
+
+func (i *SessionRepositoryIml) GetByTokenHash(ctx context.Context, tokenHash string) (*entity.Session, error) {
+	session, err := i.cache.Get(ctx, sessionKey(tokenHash))
+	if err != nil {
+		if errors.Is(err, constants.ErrorCacheNotFound) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (i *SessionRepositoryIml) Delete(ctx context.Context, tokenHash string) error {
+	return i.cache.Delete(ctx, sessionKey(tokenHash))
+}
@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ilhamtubagus/shortenurl/config"
 	"github.com/ilhamtubagus/shortenurl/constants"
 	"github.com/ilhamtubagus/shortenurl/entity"
@@ -10,15 +14,43 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// ShortenedRepository stores shortened URLs. Short codes are only unique within a domain,
+// so every lookup and mutation is keyed by both. Deleted links stay in the trash, keeping their
+// short code, until they are purged; only the trash methods see them.
 type ShortenedRepository interface {
-	GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error)
+	GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error)
 	Insert(ctx context.Context, payload entity.ShortenedURL) error
-	GetShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error)
-	DeleteByShortCode(ctx context.Context, shortCode string) error
-	UpdateByShortCode(ctx context.Context, shortCode string, newOriginalURL string) (*entity.ShortenedURL, error)
+	InsertMany(ctx context.Context, payloads []entity.ShortenedURL) ([]error, error)
+	GetShortenedURLs(ctx context.Context, workspace string, query entity.LinkQuery) (*entity.LinkPage, error)
+	SelectLinks(ctx context.Context, workspace string, keys []entity.LinkKey, query *entity.LinkQuery, limit int) (*[]entity.ShortenedURL, error)
+	UpdateLinks(ctx context.Context, keys []entity.LinkKey, change entity.LinkChange) (int64, error)
+	DeleteLinks(ctx context.Context, keys []entity.LinkKey) (int64, error)
+	GetByDestinationHost(ctx context.Context, workspace string, host string) (*[]entity.ShortenedURL, error)
+	RewriteDestination(ctx context.Context, domain string, shortCode string, from string, to string) (*entity.ShortenedURL, error)
+	GetImported(ctx context.Context, workspace string, sources []string) (*[]entity.ShortenedURL, error)
+	StreamLinks(ctx context.Context, workspace string, query entity.LinkQuery, fn func(link entity.ShortenedURL) error) error
+	DeleteByShortCode(ctx context.Context, domain string, shortCode string) error
+	GetDeletedByShortCode(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error)
+	GetDeleted(ctx context.Context, workspace string) (*[]entity.ShortenedURL, error)
+	RestoreByShortCode(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (*[]entity.ShortenedURL, error)
+	UpdateByShortCode(ctx context.Context, domain string, shortCode string, newOriginalURL string) (*entity.ShortenedURL, error)
+	UpdateFallbackByShortCode(ctx context.Context, domain string, shortCode string, fallbackURL string) (*entity.ShortenedURL, error)
+	GetWithFallback(ctx context.Context) (*[]entity.ShortenedURL, error)
+	UpdatePrimaryDown(ctx context.Context, domain string, shortCode string, down bool) error
+	UpdateMetadataByShortCode(ctx context.Context, domain string, shortCode string, metadata entity.Metadata) (*entity.ShortenedURL, error)
+	UpdateOpenGraphByShortCode(ctx context.Context, domain string, shortCode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error)
+	UpdateStatusByShortCode(ctx context.Context, domain string, shortCode string, status string) (*entity.ShortenedURL, error)
+	UpdateDetailsByShortCode(ctx context.Context, domain string, shortCode string, details entity.LinkDetails) (*entity.ShortenedURL, error)
+	AssignDomain(ctx context.Context, domain string) error
+	AssignWorkspace(ctx context.Context, owner string, workspace string) error
+	BackfillCreatedAt(ctx context.Context) error
+	FindDuplicateCodes(ctx context.Context) ([]entity.LinkKey, error)
 }
 
 type ShortenedRepositoryIml struct {
@@ -51,28 +83,83 @@ func (i *ShortenedRepositoryIml) cacheWorker() {
 }
 
 func (i *ShortenedRepositoryIml) insertCache(shortenedURL entity.ShortenedURL) {
-	log.Printf("inserting cache %v", shortenedURL.ShortCode)
+	key := cacheKey(shortenedURL.Domain, shortenedURL.ShortCode)
+	log.Printf("inserting cache %v", key)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := i.cache.Delete(ctx, shortenedURL.ShortCode)
+	err := i.cache.Delete(ctx, key)
 	if err != nil {
 		log.Printf("error deleting cache %v\n", err)
 	}
 
-	err = i.cache.Put(ctx, shortenedURL.ShortCode, shortenedURL, uint64(i.config.Redis.TTL))
+	err = i.cache.Put(ctx, key, shortenedURL, uint64(i.config.Redis.TTL))
 	if err != nil {
 		log.Printf("error inserting cache %v\n", err)
 	}
 
-	log.Printf("insert cache %v success \n", shortenedURL.ShortCode)
+	log.Printf("insert cache %v success \n", key)
 }
 
-func (i *ShortenedRepositoryIml) GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error) {
-	log.Printf("getting from cache %v\n", shortcode)
+func cacheKey(domain string, shortCode string) string {
+	return domain + "/" + shortCode
+}
+
+func linkFilter(domain string, shortCode string) bson.D {
+	return bson.D{{"domain", domain}, {"shortCode", shortCode}}
+}
+
+// notDeleted leaves the links in the trash out of filter
+func notDeleted(filter bson.D) bson.D {
+	return append(filter, bson.E{Key: "deletedAt", Value: bson.D{{"$exists", false}}})
+}
+
+// deleted only matches the links in the trash with filter
+func deleted(filter bson.D) bson.D {
+	return append(filter, bson.E{Key: "deletedAt", Value: bson.D{{"$exists", true}}})
+}
+
+func (i *ShortenedRepositoryIml) find(ctx context.Context, filter bson.D, opts ...options.Lister[options.FindOptions]) (*[]entity.ShortenedURL, error) {
+	shortenedURLs := []entity.ShortenedURL{}
+
+	cursor, err := i.col.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &shortenedURLs); err != nil {
+		return nil, err
+	}
+
+	return &shortenedURLs, nil
+}
 
-	shortenedURL, err := i.cache.Get(ctx, shortcode)
+// updateByShortCode applies update to a single link and refreshes its cache entry
+func (i *ShortenedRepositoryIml) updateByShortCode(ctx context.Context, domain string, shortCode string, update bson.D) (*entity.ShortenedURL, error) {
+	var shortened entity.ShortenedURL
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, notDeleted(linkFilter(domain, shortCode)), update, opts).Decode(&shortened)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	i.cacheTasks <- shortened
+
+	return &shortened, nil
+}
+
+func (i *ShortenedRepositoryIml) GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	key := cacheKey(domain, shortcode)
+	log.Printf("getting from cache %v\n", key)
+
+	shortenedURL, err := i.cache.Get(ctx, key)
 
 	if err != nil {
 		if !errors.Is(err, constants.ErrorCacheNotFound) {
@@ -80,9 +167,9 @@ func (i *ShortenedRepositoryIml) GetByShortCode(ctx context.Context, shortcode s
 		}
 
 		if errors.Is(err, constants.ErrorCacheNotFound) {
-			log.Printf("getting from mongodb %v\n", shortcode)
+			log.Printf("getting from mongodb %v\n", key)
 
-			filter := bson.D{{"shortCode", shortcode}}
+			filter := notDeleted(linkFilter(domain, shortcode))
 			var shortened entity.ShortenedURL
 			err := i.col.FindOne(ctx, filter).Decode(&shortened)
 
@@ -118,32 +205,411 @@ func (i *ShortenedRepositoryIml) Insert(ctx context.Context, shortenedURL entity
 	return nil
 }
 
-func (i *ShortenedRepositoryIml) GetShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error) {
-	log.Println("getting all shortened URLs from mongodb")
+// InsertMany inserts links in one unordered batch, so a failing link does not stop the others.
+// The returned errors line up with links and are nil for the inserted ones, taken short codes
+// are reported as duplicate key errors.
+func (i *ShortenedRepositoryIml) InsertMany(ctx context.Context, shortenedURLs []entity.ShortenedURL) ([]error, error) {
+	errs := make([]error, len(shortenedURLs))
+	if len(shortenedURLs) == 0 {
+		return errs, nil
+	}
+
+	documents := make([]any, len(shortenedURLs))
+	for index, shortenedURL := range shortenedURLs {
+		documents[index] = shortenedURL
+	}
+
+	_, err := i.col.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			errs[writeErr.Index] = writeErr.WriteError
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	inserted := 0
+	for index, shortenedURL := range shortenedURLs {
+		if errs[index] == nil {
+			inserted++
+			i.cacheTasks <- shortenedURL
+		}
+	}
+
+	log.Printf("success insert of %d shortened URLs into database\n", inserted)
+
+	return errs, nil
+}
+
+// linkDocument exposes the _id of a link, listings page by it
+type linkDocument struct {
+	ID                  bson.ObjectID `bson:"_id"`
+	entity.ShortenedURL `bson:",inline"`
+}
+
+// linkCursor is the position after the last link of a page, Value is its sort key
+type linkCursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v,omitempty"`
+	ID    string `json:"id"`
+}
+
+// linkSortFields maps the sort orders of listings to the fields they sort by, links sharing a
+// value are ordered by _id
+var linkSortFields = map[string]string{
+	entity.LinkSortCreated: "createdAt",
+	entity.LinkSortClicks:  "clicks",
+	entity.LinkSortCode:    "shortCode",
+}
+
+func encodeLinkCursor(sort string, link linkDocument) string {
+	cursor := linkCursor{Sort: sort, ID: link.ID.Hex()}
+
+	switch strings.TrimPrefix(sort, "-") {
+	case entity.LinkSortCreated:
+		cursor.Value = link.CreatedAt
+	case entity.LinkSortClicks:
+		cursor.Value = link.Clicks
+	case entity.LinkSortCode:
+		cursor.Value = link.ShortCode
+	}
+
+	encoded, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeLinkCursor(sort string, encoded string) (*linkCursor, bson.ObjectID, error) {
+	var cursor linkCursor
+
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(decoded))
+		decoder.UseNumber()
+		err = decoder.Decode(&cursor)
+	}
+	if err != nil || cursor.Sort != sort {
+		return nil, bson.ObjectID{}, fmt.Errorf("%w: invalid cursor", constants.ErrorInvalidRequest)
+	}
+
+	id, err := bson.ObjectIDFromHex(cursor.ID)
+	if err != nil {
+		return nil, bson.ObjectID{}, fmt.Errorf("%w: invalid cursor", constants.ErrorInvalidRequest)
+	}
+
+	// creation times are compared as dates, JSON carries them as RFC 3339 strings
+	if strings.TrimPrefix(sort, "-") == entity.LinkSortCreated {
+		value, _ := cursor.Value.(string)
+		created, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, bson.ObjectID{}, fmt.Errorf("%w: invalid cursor", constants.ErrorInvalidRequest)
+		}
+
+		cursor.Value = created
+	}
+
+	// clicks are compared as numbers, mongodb matches them across integer types
+	if number, ok := cursor.Value.(json.Number); ok {
+		cursor.Value, err = number.Int64()
+		if err != nil {
+			return nil, bson.ObjectID{}, fmt.Errorf("%w: invalid cursor", constants.ErrorInvalidRequest)
+		}
+	}
+
+	return &cursor, id, nil
+}
+
+// linkQueryFilter matches the links of a workspace that query filters, leaving its sort and
+// page aside
+func linkQueryFilter(workspace string, query entity.LinkQuery) bson.D {
+	filter := notDeleted(bson.D{{"workspace", workspace}})
+	if query.Search != "" {
+		// both clauses are indexed, which mongodb requires to combine $text with $or
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{"$text", bson.D{{"$search", query.Search}}}},
+			bson.D{{"shortCode", bson.D{{"$regex", "^" + regexp.QuoteMeta(query.Search)}}}},
+		}})
+	}
+	if query.Domain != "" {
+		filter = append(filter, bson.E{Key: "domain", Value: query.Domain})
+	}
+	if query.Tag != "" {
+		filter = append(filter, bson.E{Key: "tags", Value: query.Tag})
+	}
+	if query.Status == entity.LinkStatusActive {
+		filter = append(filter, bson.E{Key: "status", Value: bson.D{{"$in", bson.A{nil, entity.LinkStatusActive}}}})
+	} else if query.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: query.Status})
+	}
+
+	created := bson.D{}
+	if !query.CreatedFrom.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: query.CreatedFrom})
+	}
+	if !query.CreatedTo.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: query.CreatedTo})
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "createdAt", Value: created})
+	}
+
+	return filter
+}
+
+// GetShortenedURLs returns a page of the links of a workspace matching query. The service
+// validates the query; the sort, if any, must be one of the entity.LinkSort orders.
+func (i *ShortenedRepositoryIml) GetShortenedURLs(ctx context.Context, workspace string, query entity.LinkQuery) (*entity.LinkPage, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = entity.DefaultLinkSort
+	}
+
+	field, ok := linkSortFields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %s", constants.ErrorInvalidRequest, sort)
+	}
+
+	direction, after := 1, "$gt"
+	if strings.HasPrefix(sort, "-") {
+		direction, after = -1, "$lt"
+	}
+
+	filter := linkQueryFilter(workspace, query)
+
+	order := bson.D{{field, direction}, {"_id", direction}}
+
+	if query.Cursor != "" {
+		cursor, id, err := decodeLinkCursor(sort, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		next := bson.A{bson.D{{"$or", bson.A{
+			bson.D{{field, bson.D{{after, cursor.Value}}}},
+			bson.D{{field, cursor.Value}, {"_id", bson.D{{after, id}}}},
+		}}}}
 
-	var shortenedURLs []entity.ShortenedURL
-	filter := bson.D{}
-	cursor, err := i.col.Find(ctx, filter)
+		filter = append(filter, bson.E{Key: "$and", Value: next})
+	}
+
+	// one more link than the page holds tells whether there is a next page
+	opts := options.Find().SetSort(order).SetLimit(int64(query.Limit) + 1)
+
+	cursor, err := i.col.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cursor.All(ctx, &shortenedURLs); err != nil {
-		panic(err)
+	documents := []linkDocument{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
 	}
 
-	log.Println("success get all shortened URLs from mongodb")
+	page := entity.LinkPage{Links: []entity.ShortenedURL{}}
+	if len(documents) > query.Limit {
+		documents = documents[:query.Limit]
+		page.NextCursor = encodeLinkCursor(sort, documents[len(documents)-1])
+	}
 
-	return &shortenedURLs, nil
+	for _, document := range documents {
+		page.Links = append(page.Links, document.ShortenedURL)
+	}
+
+	return &page, nil
 }
 
-func (i *ShortenedRepositoryIml) DeleteByShortCode(ctx context.Context, shortCode string) error {
-	filter := bson.D{{"shortCode", shortCode}}
-	if _, err := i.col.DeleteOne(ctx, filter); err != nil {
+// keysFilter matches the links with the given keys
+func keysFilter(keys []entity.LinkKey) bson.D {
+	links := bson.A{}
+	for _, key := range keys {
+		links = append(links, linkFilter(key.Domain, key.ShortCode))
+	}
+
+	return bson.D{{"$or", links}}
+}
+
+func cacheKeys(keys []entity.LinkKey) []string {
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, cacheKey(key.Domain, key.ShortCode))
+	}
+
+	return cacheKeys
+}
+
+// SelectLinks returns up to limit links of a workspace, those with the given keys when there
+// are any, matching query when it is set, oldest first.
+func (i *ShortenedRepositoryIml) SelectLinks(ctx context.Context, workspace string, keys []entity.LinkKey, query *entity.LinkQuery, limit int) (*[]entity.ShortenedURL, error) {
+	filter := notDeleted(bson.D{{"workspace", workspace}})
+	if query != nil {
+		filter = linkQueryFilter(workspace, *query)
+	}
+
+	if len(keys) > 0 {
+		filter = append(filter, bson.E{Key: "$and", Value: bson.A{keysFilter(keys)}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetLimit(int64(limit))
+
+	return i.find(ctx, filter, opts)
+}
+
+// UpdateLinks makes change to the links with the given keys that are not in the trash and
+// returns how many it changed. Their cache entries are removed, redirects read them again.
+func (i *ShortenedRepositoryIml) UpdateLinks(ctx context.Context, keys []entity.LinkKey, change entity.LinkChange) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	set := bson.D{}
+	if change.Status != "" {
+		set = append(set, bson.E{Key: "status", Value: change.Status})
+	}
+	if change.ExpiresAt != nil {
+		set = append(set, bson.E{Key: "expiresAt", Value: *change.ExpiresAt})
+	}
+
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if change.ClearExpiry {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{{"expiresAt", ""}}})
+	}
+	if len(change.AddTags) > 0 {
+		update = append(update, bson.E{Key: "$addToSet", Value: bson.D{{"tags", bson.D{{"$each", change.AddTags}}}}})
+	}
+	if len(change.RemoveTags) > 0 {
+		update = append(update, bson.E{Key: "$pull", Value: bson.D{{"tags", bson.D{{"$in", change.RemoveTags}}}}})
+	}
+
+	result, err := i.col.UpdateMany(ctx, notDeleted(keysFilter(keys)), touched(update))
+	if err != nil {
+		return 0, err
+	}
+
+	err = i.cache.Delete(ctx, cacheKeys(keys)...)
+	if err != nil {
+		return result.ModifiedCount, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// DeleteLinks moves the links with the given keys to the trash and returns how many it moved.
+func (i *ShortenedRepositoryIml) DeleteLinks(ctx context.Context, keys []entity.LinkKey) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	update := bson.D{{"$currentDate", bson.D{{"deletedAt", true}}}}
+
+	result, err := i.col.UpdateMany(ctx, notDeleted(keysFilter(keys)), update)
+	if err != nil {
+		return 0, err
+	}
+
+	err = i.cache.Delete(ctx, cacheKeys(keys)...)
+	if err != nil {
+		return result.ModifiedCount, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// GetByDestinationHost returns the links of a workspace whose destination is on host, on any
+// port, oldest first.
+func (i *ShortenedRepositoryIml) GetByDestinationHost(ctx context.Context, workspace string, host string) (*[]entity.ShortenedURL, error) {
+	pattern := "^https?://" + regexp.QuoteMeta(host) + "(:[0-9]+)?([/?#]|$)"
+	filter := notDeleted(bson.D{
+		{"workspace", workspace},
+		{"originalURL", bson.D{{"$regex", pattern}, {"$options", "i"}}},
+	})
+
+	return i.find(ctx, filter, options.Find().SetSort(bson.D{{"_id", 1}}))
+}
+
+// RewriteDestination sets the destination of a link to to, provided it is still from, and
+// removes its cache entry. It returns constants.ErrorNotFound when the link or its destination
+// changed in the meantime.
+func (i *ShortenedRepositoryIml) RewriteDestination(ctx context.Context, domain string, shortCode string, from string, to string) (*entity.ShortenedURL, error) {
+	var shortened entity.ShortenedURL
+
+	filter := notDeleted(append(linkFilter(domain, shortCode), bson.E{Key: "originalURL", Value: from}))
+	update := bson.D{{"$set", bson.D{{"originalURL", to}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, filter, touched(update), opts).Decode(&shortened)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	// the destination changed either way, a stale cache entry only lives until its TTL
+	err = i.cache.Delete(ctx, cacheKey(domain, shortCode))
+	if err != nil {
+		log.Printf("error deleting cache %v\n", err)
+	}
+
+	return &shortened, nil
+}
+
+// GetImported returns the links of a workspace imported from the given sources, including
+// those in the trash, which imports do not bring back.
+func (i *ShortenedRepositoryIml) GetImported(ctx context.Context, workspace string, sources []string) (*[]entity.ShortenedURL, error) {
+	filter := bson.D{{"workspace", workspace}, {"importedFrom", bson.D{{"$in", sources}}}}
+
+	return i.find(ctx, filter)
+}
+
+// streamBatchSize is how many documents streams read from mongodb at a time
+const streamBatchSize = 1000
+
+// StreamLinks calls fn with every link of a workspace matching query, oldest first, reading
+// them from a cursor a batch at a time so exports of any size hold a single batch in memory.
+// The sort, cursor and limit of query are ignored, an error of fn stops the stream.
+func (i *ShortenedRepositoryIml) StreamLinks(ctx context.Context, workspace string, query entity.LinkQuery, fn func(link entity.ShortenedURL) error) error {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetBatchSize(streamBatchSize)
+
+	cursor, err := i.col.Find(ctx, linkQueryFilter(workspace, query), opts)
+	if err != nil {
 		return err
 	}
+	defer cursor.Close(context.WithoutCancel(ctx))
 
-	err := i.cache.Delete(ctx, shortCode)
+	for cursor.Next(ctx) {
+		var link entity.ShortenedURL
+		if err := cursor.Decode(&link); err != nil {
+			return err
+		}
+
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// DeleteByShortCode moves a link to the trash, it stops resolving right away.
+func (i *ShortenedRepositoryIml) DeleteByShortCode(ctx context.Context, domain string, shortCode string) error {
+	update := bson.D{{"$currentDate", bson.D{{"deletedAt", true}}}}
+
+	result, err := i.col.UpdateOne(ctx, notDeleted(linkFilter(domain, shortCode)), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return constants.ErrorNotFound
+	}
+
+	err = i.cache.Delete(ctx, cacheKey(domain, shortCode))
 	if err != nil {
 		return err
 	}
@@ -151,15 +617,41 @@ func (i *ShortenedRepositoryIml) DeleteByShortCode(ctx context.Context, shortCod
 	return nil
 }
 
-func (i *ShortenedRepositoryIml) UpdateByShortCode(ctx context.Context, shortCode string, newOriginalURL string) (*entity.ShortenedURL, error) {
-	filter := bson.D{{"shortCode", shortCode}}
-	update := bson.D{{"$set", bson.D{{"originalURL", newOriginalURL}}}}
+func (i *ShortenedRepositoryIml) GetDeletedByShortCode(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error) {
+	var shortened entity.ShortenedURL
+
+	err := i.col.FindOne(ctx, deleted(linkFilter(domain, shortCode))).Decode(&shortened)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &shortened, nil
+}
+
+// GetDeleted returns the trash of a workspace, the most recently deleted links first.
+func (i *ShortenedRepositoryIml) GetDeleted(ctx context.Context, workspace string) (*[]entity.ShortenedURL, error) {
+	opts := options.Find().SetSort(bson.D{{"deletedAt", -1}})
+
+	return i.find(ctx, deleted(bson.D{{"workspace", workspace}}), opts)
+}
+
+// RestoreByShortCode takes a link out of the trash and caches it again.
+func (i *ShortenedRepositoryIml) RestoreByShortCode(ctx context.Context, domain string, shortCode string) (*entity.ShortenedURL, error) {
 	var shortened entity.ShortenedURL
 
+	update := touched(bson.D{{"$unset", bson.D{{"deletedAt", ""}}}})
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	err := i.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shortened)
+	err := i.col.FindOneAndUpdate(ctx, deleted(linkFilter(domain, shortCode)), update, opts).Decode(&shortened)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
 		return nil, err
 	}
 
@@ -167,3 +659,202 @@ func (i *ShortenedRepositoryIml) UpdateByShortCode(ctx context.Context, shortCod
 
 	return &shortened, nil
 }
+
+// PurgeDeleted permanently removes the links deleted before deletedBefore, freeing their short
+// codes, and returns them.
+func (i *ShortenedRepositoryIml) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (*[]entity.ShortenedURL, error) {
+	filter := bson.D{{"deletedAt", bson.D{{"$lt", deletedBefore}}}}
+
+	expired, err := i.find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := []entity.ShortenedURL{}
+	for _, shortened := range *expired {
+		// a link restored meanwhile is kept
+		result, err := i.col.DeleteOne(ctx, append(linkFilter(shortened.Domain, shortened.ShortCode), filter...))
+		if err != nil {
+			return &purged, err
+		}
+
+		if result.DeletedCount > 0 {
+			purged = append(purged, shortened)
+		}
+	}
+
+	return &purged, nil
+}
+
+// touched adds setting updatedAt to update, for changes made by users
+func touched(update bson.D) bson.D {
+	return append(update, bson.E{Key: "$currentDate", Value: bson.D{{"updatedAt", true}}})
+}
+
+func (i *ShortenedRepositoryIml) UpdateByShortCode(ctx context.Context, domain string, shortCode string, newOriginalURL string) (*entity.ShortenedURL, error) {
+	update := bson.D{{"$set", bson.D{{"originalURL", newOriginalURL}}}}
+
+	return i.updateByShortCode(ctx, domain, shortCode, touched(update))
+}
+
+func (i *ShortenedRepositoryIml) UpdateFallbackByShortCode(ctx context.Context, domain string, shortCode string, fallbackURL string) (*entity.ShortenedURL, error) {
+	update := bson.D{{"$set", bson.D{{"fallbackURL", fallbackURL}}}}
+	if fallbackURL == "" {
+		update = bson.D{{"$unset", bson.D{{"fallbackURL", ""}, {"primaryDown", ""}}}}
+	}
+
+	return i.updateByShortCode(ctx, domain, shortCode, touched(update))
+}
+
+func (i *ShortenedRepositoryIml) GetWithFallback(ctx context.Context) (*[]entity.ShortenedURL, error) {
+	return i.find(ctx, notDeleted(bson.D{{"fallbackURL", bson.D{{"$exists", true}}}}))
+}
+
+func (i *ShortenedRepositoryIml) UpdatePrimaryDown(ctx context.Context, domain string, shortCode string, down bool) error {
+	update := bson.D{{"$set", bson.D{{"primaryDown", down}}}}
+
+	// the redirect path reads from cache, so the new health state has to land there too
+	_, err := i.updateByShortCode(ctx, domain, shortCode, update)
+
+	return err
+}
+
+func (i *ShortenedRepositoryIml) UpdateMetadataByShortCode(ctx context.Context, domain string, shortCode string, metadata entity.Metadata) (*entity.ShortenedURL, error) {
+	update := bson.D{{"$set", bson.D{{"metadata", metadata}}}}
+
+	return i.updateByShortCode(ctx, domain, shortCode, update)
+}
+
+func (i *ShortenedRepositoryIml) UpdateOpenGraphByShortCode(ctx context.Context, domain string, shortCode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error) {
+	update := bson.D{{"$set", bson.D{{"openGraph", openGraph}}}}
+	if openGraph.IsEmpty() {
+		update = bson.D{{"$unset", bson.D{{"openGraph", ""}}}}
+	}
+
+	return i.updateByShortCode(ctx, domain, shortCode, touched(update))
+}
+
+func (i *ShortenedRepositoryIml) UpdateStatusByShortCode(ctx context.Context, domain string, shortCode string, status string) (*entity.ShortenedURL, error) {
+	update := bson.D{{"$set", bson.D{{"status", status}}}}
+
+	// redirects read the status from cache, updateByShortCode refreshes it there
+	return i.updateByShortCode(ctx, domain, shortCode, touched(update))
+}
+
+func (i *ShortenedRepositoryIml) UpdateDetailsByShortCode(ctx context.Context, domain string, shortCode string, details entity.LinkDetails) (*entity.ShortenedURL, error) {
+	set := bson.D{}
+	unset := bson.D{}
+
+	for _, field := range []struct {
+		name  string
+		value any
+		empty bool
+	}{
+		{"title", details.Title, details.Title == ""},
+		{"notes", details.Notes, details.Notes == ""},
+		{"tags", details.Tags, len(details.Tags) == 0},
+	} {
+		if field.empty {
+			unset = append(unset, bson.E{Key: field.name, Value: ""})
+		} else {
+			set = append(set, bson.E{Key: field.name, Value: field.value})
+		}
+	}
+
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	return i.updateByShortCode(ctx, domain, shortCode, touched(update))
+}
+
+// AssignDomain moves links created before domains existed onto the given domain.
+func (i *ShortenedRepositoryIml) AssignDomain(ctx context.Context, domain string) error {
+	filter := bson.D{{"domain", bson.D{{"$exists", false}}}}
+	update := bson.D{{"$set", bson.D{{"domain", domain}}}}
+
+	result, err := i.col.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("assigned %d shortened URLs to domain %s\n", result.ModifiedCount, domain)
+	}
+
+	return nil
+}
+
+// AssignWorkspace moves the links a user created before workspaces existed into workspace.
+func (i *ShortenedRepositoryIml) AssignWorkspace(ctx context.Context, owner string, workspace string) error {
+	filter := bson.D{{"owner", owner}, {"workspace", bson.D{{"$exists", false}}}}
+	update := bson.D{{"$set", bson.D{{"workspace", workspace}}}}
+
+	result, err := i.col.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("assigned %d shortened URLs of %s to workspace %s\n", result.ModifiedCount, owner, workspace)
+	}
+
+	return nil
+}
+
+// BackfillCreatedAt sets the creation and update times of links created before they were
+// recorded to the time of their ID.
+func (i *ShortenedRepositoryIml) BackfillCreatedAt(ctx context.Context) error {
+	filter := bson.D{{"createdAt", bson.D{{"$exists", false}}}, {"_id", bson.D{{"$type", "objectId"}}}}
+	update := bson.A{bson.D{{"$set", bson.D{
+		{"createdAt", bson.D{{"$toDate", "$_id"}}},
+		{"updatedAt", bson.D{{"$ifNull", bson.A{"$updatedAt", bson.D{{"$toDate", "$_id"}}}}}},
+	}}}}
+
+	result, err := i.col.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("set the creation time of %d shortened URLs\n", result.ModifiedCount)
+	}
+
+	return nil
+}
+
+// FindDuplicateCodes returns the short codes used by more than one link on a domain, deleted
+// links included, which keep the unique index of short codes from being built.
+func (i *ShortenedRepositoryIml) FindDuplicateCodes(ctx context.Context) ([]entity.LinkKey, error) {
+	pipeline := mongo.Pipeline{
+		{{"$group", bson.D{{"_id", bson.D{{"domain", "$domain"}, {"shortCode", "$shortCode"}}}, {"links", bson.D{{"$sum", 1}}}}}},
+		{{"$match", bson.D{{"links", bson.D{{"$gt", 1}}}}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+	}
+
+	cursor, err := i.col.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []struct {
+		ID struct {
+			Domain    string `bson:"domain"`
+			ShortCode string `bson:"shortCode"`
+		} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return nil, err
+	}
+
+	keys := make([]entity.LinkKey, len(duplicates))
+	for index, duplicate := range duplicates {
+		keys[index] = entity.LinkKey{Domain: duplicate.ID.Domain, ShortCode: duplicate.ID.ShortCode}
+	}
+
+	return keys, nil
+}
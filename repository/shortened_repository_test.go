@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"testing"
+	"time"
+)
+
+func TestLinkCursor(t *testing.T) {
+	created := time.Date(2026, 3, 1, 9, 30, 0, 123000000, time.UTC)
+	link := linkDocument{ID: bson.NewObjectID(), ShortenedURL: entity.ShortenedURL{ShortCode: "abc123", Clicks: 42, CreatedAt: &created}}
+
+	t.Run("Created", func(t *testing.T) {
+		cursor, id, err := decodeLinkCursor("-created", encodeLinkCursor("-created", link))
+
+		assert.NoError(t, err)
+		assert.Equal(t, link.ID, id)
+		assert.Equal(t, created, cursor.Value)
+	})
+
+	t.Run("Clicks", func(t *testing.T) {
+		cursor, id, err := decodeLinkCursor("-clicks", encodeLinkCursor("-clicks", link))
+
+		assert.NoError(t, err)
+		assert.Equal(t, link.ID, id)
+		assert.Equal(t, int64(42), cursor.Value)
+	})
+
+	t.Run("Code", func(t *testing.T) {
+		cursor, id, err := decodeLinkCursor("code", encodeLinkCursor("code", link))
+
+		assert.NoError(t, err)
+		assert.Equal(t, link.ID, id)
+		assert.Equal(t, "abc123", cursor.Value)
+	})
+
+	t.Run("OtherSort", func(t *testing.T) {
+		_, _, err := decodeLinkCursor("code", encodeLinkCursor("-created", link))
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, _, err := decodeLinkCursor("-created", "not a cursor")
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+}
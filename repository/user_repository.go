@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type UserRepository interface {
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	GetBySubject(ctx context.Context, subject string) (*entity.User, error)
+	LinkSubject(ctx context.Context, id string, subject string) (*entity.User, error)
+	Insert(ctx context.Context, user entity.User) error
+	SetTwoFactor(ctx context.Context, id string, twoFactor entity.TwoFactor) error
+	DeleteTwoFactor(ctx context.Context, id string) error
+	SetRecoveryCodes(ctx context.Context, id string, codeHashes []string) error
+	UseTOTPStep(ctx context.Context, id string, step int64) error
+	UseRecoveryCode(ctx context.Context, id string, codeHash string) error
+}
+
+type UserRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewUserRepository(col *mongo.Collection) *UserRepositoryIml {
+	return &UserRepositoryIml{col: col}
+}
+
+func (i *UserRepositoryIml) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	return i.findOne(ctx, bson.D{{"_id", id}})
+}
+
+func (i *UserRepositoryIml) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return i.findOne(ctx, bson.D{{"email", email}})
+}
+
+func (i *UserRepositoryIml) GetBySubject(ctx context.Context, subject string) (*entity.User, error) {
+	return i.findOne(ctx, bson.D{{"subject", subject}})
+}
+
+// LinkSubject links a user that has no identity provider subject yet to subject.
+func (i *UserRepositoryIml) LinkSubject(ctx context.Context, id string, subject string) (*entity.User, error) {
+	filter := bson.D{{"_id", id}, {"subject", bson.D{{"$exists", false}}}}
+	update := bson.D{{"$set", bson.D{{"subject", subject}}}}
+	var user entity.User
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (i *UserRepositoryIml) findOne(ctx context.Context, filter bson.D) (*entity.User, error) {
+	var user entity.User
+
+	err := i.col.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (i *UserRepositoryIml) Insert(ctx context.Context, user entity.User) error {
+	_, err := i.col.InsertOne(ctx, user)
+
+	return err
+}
+
+func (i *UserRepositoryIml) SetTwoFactor(ctx context.Context, id string, twoFactor entity.TwoFactor) error {
+	return i.updateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$set", bson.D{{"twoFactor", twoFactor}}}})
+}
+
+func (i *UserRepositoryIml) DeleteTwoFactor(ctx context.Context, id string) error {
+	return i.updateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$unset", bson.D{{"twoFactor", ""}}}})
+}
+
+func (i *UserRepositoryIml) SetRecoveryCodes(ctx context.Context, id string, codeHashes []string) error {
+	filter := bson.D{{"_id", id}, {"twoFactor.enabled", true}}
+
+	return i.updateOne(ctx, filter, bson.D{{"$set", bson.D{{"twoFactor.recoveryCodes", codeHashes}}}})
+}
+
+// UseTOTPStep records step as the last accepted time step, it fails with ErrorNotFound when a
+// code of the same or a later step was already accepted.
+func (i *UserRepositoryIml) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	filter := bson.D{{"_id", id}, {"twoFactor.lastStep", bson.D{{"$lt", step}}}}
+
+	return i.updateOne(ctx, filter, bson.D{{"$set", bson.D{{"twoFactor.lastStep", step}}}})
+}
+
+// UseRecoveryCode removes the recovery code with codeHash, it fails with ErrorNotFound when
+// the code is unknown or already used.
+func (i *UserRepositoryIml) UseRecoveryCode(ctx context.Context, id string, codeHash string) error {
+	filter := bson.D{{"_id", id}, {"twoFactor.recoveryCodes", codeHash}}
+
+	return i.updateOne(ctx, filter, bson.D{{"$pull", bson.D{{"twoFactor.recoveryCodes", codeHash}}}})
+}
+
+func (i *UserRepositoryIml) updateOne(ctx context.Context, filter bson.D, update bson.D) error {
+	result, err := i.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return constants.ErrorNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type WorkspaceRepository interface {
+	GetByID(ctx context.Context, id string) (*entity.Workspace, error)
+	GetByIDs(ctx context.Context, ids []string) (*[]entity.Workspace, error)
+	Insert(ctx context.Context, workspace entity.Workspace) error
+	UpdateRequireTwoFactor(ctx context.Context, id string, required bool) (*entity.Workspace, error)
+}
+
+type WorkspaceRepositoryIml struct {
+	col *mongo.Collection
+}
+
+func NewWorkspaceRepository(col *mongo.Collection) *WorkspaceRepositoryIml {
+	return &WorkspaceRepositoryIml{col: col}
+}
+
+func (i *WorkspaceRepositoryIml) GetByID(ctx context.Context, id string) (*entity.Workspace, error) {
+	var workspace entity.Workspace
+
+	err := i.col.FindOne(ctx, bson.D{{"_id", id}}).Decode(&workspace)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+func (i *WorkspaceRepositoryIml) GetByIDs(ctx context.Context, ids []string) (*[]entity.Workspace, error) {
+	workspaces := []entity.Workspace{}
+	opts := options.Find().SetSort(bson.D{{"name", 1}})
+
+	cursor, err := i.col.Find(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &workspaces); err != nil {
+		return nil, err
+	}
+
+	return &workspaces, nil
+}
+
+func (i *WorkspaceRepositoryIml) Insert(ctx context.Context, workspace entity.Workspace) error {
+	_, err := i.col.InsertOne(ctx, workspace)
+
+	return err
+}
+
+func (i *WorkspaceRepositoryIml) UpdateRequireTwoFactor(ctx context.Context, id string, required bool) (*entity.Workspace, error) {
+	update := bson.D{{"$set", bson.D{{"requireTwoFactor", required}}}}
+	var workspace entity.Workspace
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := i.col.FindOneAndUpdate(ctx, bson.D{{"_id", id}}, update, opts).Decode(&workspace)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, constants.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	return &workspace, nil
+}
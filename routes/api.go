@@ -0,0 +1,370 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// APIRoutes serves the JSON API under /api/v1.
+type APIRoutes struct {
+	service          services.ShortenedService
+	domainService    services.DomainService
+	apiKeyService    services.APIKeyService
+	workspaceService services.WorkspaceService
+	auditService     services.AuditService
+}
+
+func NewAPIRoutes(s services.ShortenedService, ds services.DomainService, ks services.APIKeyService, ws services.WorkspaceService, as services.AuditService) *APIRoutes {
+	return &APIRoutes{service: s, domainService: ds, apiKeyService: ks, workspaceService: ws, auditService: as}
+}
+
+type dataResponse struct {
+	Data any `json:"data"`
+}
+
+// pageResponse is a page of a listing, the cursor of the next page is left out on the last page
+type pageResponse struct {
+	Data       any    `json:"data"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(dataResponse{Data: data})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// writePage writes a page of a listing, clients pass nextCursor as cursor to get the next page.
+func writePage(w http.ResponseWriter, data any, nextCursor string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err := json.NewEncoder(w).Encode(pageResponse{Data: data, NextCursor: nextCursor})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "internal server error"
+
+	if errors.Is(err, constants.ErrorNotFound) {
+		status = http.StatusNotFound
+		message = err.Error()
+	} else if errors.Is(err, constants.ErrorInvalidRequest) {
+		status = http.StatusBadRequest
+		message = err.Error()
+	} else if errors.Is(err, constants.ErrorAlreadyExists) {
+		status = http.StatusConflict
+		message = err.Error()
+	} else if errors.Is(err, constants.ErrorUnauthorized) {
+		status = http.StatusUnauthorized
+		message = err.Error()
+	} else if errors.Is(err, constants.ErrorForbidden) {
+		status = http.StatusForbidden
+		message = err.Error()
+	} else {
+		log.Print(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
+
+func (routes *APIRoutes) ListShortenedURLs() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query, err := requestLinkQuery(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		page, err := routes.service.ListShortenedURLs(r.Context(), query)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writePage(w, page.Links, page.NextCursor)
+	}
+}
+
+// CheckURL reports an invalid request unless value is an absolute http or https URL, name says
+// which URL it is in the error.
+func CheckURL(name string, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: invalid %s %q", constants.ErrorInvalidRequest, name, value)
+	}
+
+	return nil
+}
+
+type createShortenedURLRequest struct {
+	OriginalURL string `json:"originalURL"`
+	Domain      string `json:"domain"`
+}
+
+func (routes *APIRoutes) CreateShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request createShortenedURLRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		err = CheckURL("original URL", request.OriginalURL)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		domain := routes.domainService.DefaultDomain()
+		if request.Domain != "" {
+			domain = entity.NormalizeHost(request.Domain)
+		}
+
+		_, err = routes.domainService.GetDomain(r.Context(), domain)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		shortenedURL, err := routes.service.ShortenURL(r.Context(), domain, request.OriginalURL)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, shortenedURL)
+	}
+}
+
+func (routes *APIRoutes) GetShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		shortenedURL, err := routes.service.GetLink(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+// UpdateShortenedURL changes the destination of a link, its fallback URL or both.
+func (routes *APIRoutes) UpdateShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var update entity.LinkUpdate
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		if update.OriginalURL == "" && update.FallbackURL == nil {
+			writeError(w, fmt.Errorf("%w: nothing to update", constants.ErrorInvalidRequest))
+			return
+		}
+
+		if update.OriginalURL != "" {
+			err = CheckURL("original URL", update.OriginalURL)
+		}
+		if err == nil && update.FallbackURL != nil && *update.FallbackURL != "" {
+			err = CheckURL("fallback URL", *update.FallbackURL)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		domain := requestDomain(r, routes.domainService)
+		shortCode := p.ByName("shortCode")
+
+		var shortenedURL *entity.ShortenedURL
+		if update.OriginalURL != "" {
+			shortenedURL, err = routes.service.UpdateShortenedURL(r.Context(), domain, shortCode, update.OriginalURL)
+		}
+		if err == nil && update.FallbackURL != nil {
+			shortenedURL, err = routes.service.UpdateFallbackURL(r.Context(), domain, shortCode, *update.FallbackURL)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		_ = shortenedURL.GenerateShortenedURL()
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+// DeleteShortenedURL moves a link to the trash.
+func (routes *APIRoutes) DeleteShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		err := routes.service.DeleteShortenedURL(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (routes *APIRoutes) RefreshMetadata() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		shortenedURL, err := routes.service.RefreshMetadata(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+func (routes *APIRoutes) UpdateOpenGraph() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var openGraph entity.OpenGraph
+		err := json.NewDecoder(r.Body).Decode(&openGraph)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		shortenedURL, err := routes.service.UpdateOpenGraph(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"), openGraph)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+// linkStatusRequest is the body of UpdateStatus
+type linkStatusRequest struct {
+	Status string `json:"status"`
+}
+
+func (routes *APIRoutes) UpdateStatus() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request linkStatusRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		shortenedURL, err := routes.service.UpdateStatus(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"), request.Status)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+func (routes *APIRoutes) UpdateDetails() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var details entity.LinkDetails
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		shortenedURL, err := routes.service.UpdateDetails(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"), details)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortenedURL)
+	}
+}
+
+func (routes *APIRoutes) GetLinkStats() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		stats, err := routes.service.GetLinkStats(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, stats)
+	}
+}
+
+func (routes *APIRoutes) ListRevisions() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		revisions, err := routes.service.ListRevisions(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, revisions)
+	}
+}
+
+// RollbackShortenedURL restores the destination of a revision of the link.
+func (routes *APIRoutes) RollbackShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		shortened, err := routes.service.RollbackShortenedURL(r.Context(), requestDomain(r, routes.domainService),
+			p.ByName("shortCode"), p.ByName("revision"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortened)
+	}
+}
+
+// ListTrash returns the deleted links of the selected workspace.
+func (routes *APIRoutes) ListTrash() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		trash, err := routes.service.ListTrash(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, trash)
+	}
+}
+
+func (routes *APIRoutes) RestoreShortenedURL() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		shortened, err := routes.service.RestoreShortenedURL(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortened)
+	}
+}
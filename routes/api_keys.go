@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// createdAPIKey is the only response that ever contains the key itself
+type createdAPIKey struct {
+	*entity.APIKey
+	Key string `json:"key"`
+}
+
+func (routes *APIRoutes) ListAPIKeys() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		keys, err := routes.apiKeyService.ListAPIKeys(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, keys)
+	}
+}
+
+func (routes *APIRoutes) CreateAPIKey() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request createAPIKeyRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		key, raw, err := routes.apiKeyService.CreateAPIKey(r.Context(), request.Name, request.Scopes)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, createdAPIKey{APIKey: key, Key: raw})
+	}
+}
+
+func (routes *APIRoutes) RevokeAPIKey() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		key, err := routes.apiKeyService.RevokeAPIKey(r.Context(), p.ByName("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, key)
+	}
+}
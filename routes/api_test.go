@@ -0,0 +1,765 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIRoutes_ListShortenedURLs(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockURLs := []entity.ShortenedURL{
+		{OriginalURL: "https://example1.com", ShortCode: "abc123", Metadata: &entity.Metadata{Title: "Example"}},
+	}
+	mockService.On("ListShortenedURLs", mock.Anything, entity.LinkQuery{
+		Search:      "q3 deck",
+		Tag:         "launch",
+		Status:      entity.LinkStatusActive,
+		CreatedFrom: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:   time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		Sort:        "code",
+		Cursor:      "abc",
+		Limit:       20,
+	}).Return(&entity.LinkPage{Links: mockURLs, NextCursor: "def"}, nil)
+
+	req, _ := http.NewRequest("GET",
+		"/api/v1/links?q=q3+deck&tag=launch&status=active&from=2026-03-01&to=2026-03-31&sort=code&cursor=abc&limit=20", nil)
+	rr := httptest.NewRecorder()
+
+	router := httprouter.New()
+	router.GET("/api/v1/links", routes.ListShortenedURLs())
+	router.ServeHTTP(rr, req)
+
+	var body struct {
+		Data       []entity.ShortenedURL `json:"data"`
+		NextCursor string                `json:"nextCursor"`
+	}
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, mockURLs, body.Data)
+	assert.Equal(t, "def", body.NextCursor)
+
+	for _, query := range []string{"limit=ten", "from=yesterday"} {
+		req, _ = http.NewRequest("GET", "/api/v1/links?"+query, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestAPIRoutes_BulkCreate(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	rows := []entity.BulkLink{
+		{OriginalURL: "https://example.com/deck", Alias: "q3-deck", Tags: []string{"q3", "sales"}, ExpiresAt: "2026-12-31"},
+		{OriginalURL: "https://example.com/notes"},
+	}
+	mockService.On("BulkShorten", mock.Anything, "short.url", rows).Return(&[]entity.BulkResult{
+		{Row: 1, Link: &entity.ShortenedURL{ShortCode: "q3-deck"}},
+		{Row: 2, Error: "alias taken"},
+	}, nil)
+
+	router := httprouter.New()
+	router.POST("/api/v1/bulk/links", routes.BulkCreate())
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"JSON", `[{"originalURL":"https://example.com/deck","alias":"q3-deck","tags":["q3","sales"],"expiresAt":"2026-12-31"},
+			{"originalURL":"https://example.com/notes"}]`, http.StatusOK},
+		{"CSV", "Destination,Alias,Tags,Expiry\nhttps://example.com/deck,q3-deck,q3;sales,2026-12-31\nhttps://example.com/notes,,,\n", http.StatusOK},
+		{"NoDestination", "alias,tags\nq3-deck,q3\n", http.StatusBadRequest},
+		{"MalformedJSON", `[{"originalURL":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/bulk/links", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data bulkResponse `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, 1, body.Data.Created)
+			assert.Equal(t, 1, body.Data.Failed)
+		})
+	}
+}
+
+func TestAPIRoutes_BulkUpdate(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("BulkUpdate", mock.Anything, entity.BulkOperation{
+		Action: entity.BulkActionAddTags,
+		Links:  []entity.LinkKey{{Domain: "short.url", ShortCode: "abc123"}, {Domain: "go.acme.com", ShortCode: "deck"}},
+		Tags:   []string{"q3"},
+	}).Return(&entity.BulkOperationResult{Action: entity.BulkActionAddTags, Matched: 2, Changed: 1}, nil)
+	mockService.On("BulkUpdate", mock.Anything, entity.BulkOperation{
+		Action: entity.BulkActionDelete,
+		Filter: &entity.LinkQuery{Tag: "q3", CreatedTo: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)},
+		DryRun: true,
+	}).Return(&entity.BulkOperationResult{Action: entity.BulkActionDelete, DryRun: true, Matched: 12, Changed: 12}, nil)
+
+	router := httprouter.New()
+	router.POST("/api/v1/bulk/operations", routes.BulkUpdate())
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		changed int
+	}{
+		{"Links", `{"action":"add-tags","links":[{"shortCode":"abc123"},{"domain":"Go.Acme.com","shortCode":"deck"}],"tags":["q3"]}`, http.StatusOK, 1},
+		{"FilterDryRun", `{"action":"delete","filter":{"tag":"q3","to":"2025-09-30"},"dryRun":true}`, http.StatusOK, 12},
+		{"InvalidDate", `{"action":"delete","filter":{"from":"yesterday"}}`, http.StatusBadRequest, 0},
+		{"MalformedJSON", `{"action":`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/bulk/operations", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data entity.BulkOperationResult `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tt.changed, body.Data.Changed)
+		})
+	}
+}
+
+func TestAPIRoutes_RewriteDestinations(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("RewriteDestinations", mock.Anything, entity.DestinationRewrite{From: "docs.old.com", To: "docs.new.com", DryRun: true}).
+		Return(&entity.DestinationRewriteResult{DryRun: true, Matched: 1, Changed: 1, Links: []entity.DestinationChange{
+			{Domain: "short.url", ShortCode: "guide", Before: "https://docs.old.com/guide", After: "https://docs.new.com/guide"},
+		}}, nil)
+	mockService.On("RewriteDestinations", mock.Anything, entity.DestinationRewrite{From: "ftp://docs.old.com", To: "docs.new.com"}).
+		Return((*entity.DestinationRewriteResult)(nil), constants.ErrorInvalidRequest)
+
+	router := httprouter.New()
+	router.POST("/api/v1/bulk/destinations", routes.RewriteDestinations())
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"DryRun", `{"from":"docs.old.com","to":"docs.new.com","dryRun":true}`, http.StatusOK},
+		{"InvalidPrefix", `{"from":"ftp://docs.old.com","to":"docs.new.com"}`, http.StatusBadRequest},
+		{"MalformedJSON", `{"from":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/bulk/destinations", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data entity.DestinationRewriteResult `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, "https://docs.new.com/guide", body.Data.Links[0].After)
+		})
+	}
+}
+
+func TestImportRoutes(t *testing.T) {
+	mockService := new(MockImportService)
+	routes := NewImportRoutes(mockService, newMockDomainService())
+
+	body := "Title,Bitlink,Long URL\nDeck,bit.ly/deck,https://example.com/deck\n"
+	links := []entity.ImportLink{{Source: "bitly:bit.ly/deck", ShortCode: "deck", OriginalURL: "https://example.com/deck", Title: "Deck"}}
+	job := &entity.ImportJob{ID: "job-1", Domain: "short.url", Format: entity.ImportFormatBitly, Status: entity.ImportStatusRunning, Total: 1}
+
+	done := make(chan struct{})
+	mockService.On("CreateImport", mock.Anything, "short.url", entity.ImportFormatBitly, "bitly.csv", []byte(body)).Return(job, links, nil)
+	mockService.On("RunImport", mock.Anything, job, links).Return(job, nil).Run(func(args mock.Arguments) { close(done) })
+	mockService.On("ResumeImport", mock.Anything, "job-1", []byte("other")).Return((*entity.ImportJob)(nil), []entity.ImportLink(nil), constants.ErrorInvalidRequest)
+	mockService.On("GetImport", mock.Anything, "job-1").Return(job, nil)
+	mockService.On("GetImport", mock.Anything, "job-2").Return((*entity.ImportJob)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.POST("/api/v1/imports", routes.CreateImport())
+	router.GET("/api/v1/imports/:id", routes.GetImport())
+	router.POST("/api/v1/imports/:id/resume", routes.ResumeImport())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"Create", "POST", "/api/v1/imports?format=bitly&name=bitly.csv", body, http.StatusAccepted},
+		{"UnknownDomain", "POST", "/api/v1/imports?format=bitly&domain=go.acme.com", body, http.StatusNotFound},
+		{"ResumeOtherFile", "POST", "/api/v1/imports/job-1/resume", "other", http.StatusBadRequest},
+		{"Get", "GET", "/api/v1/imports/job-1", "", http.StatusOK},
+		{"GetMissing", "GET", "/api/v1/imports/job-2", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the import did not run")
+	}
+}
+
+func TestAPIRoutes_GetShortenedURL(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("GetLink", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "abc123",
+	}, nil)
+	mockService.On("GetLink", mock.Anything, "short.url", "missing").Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.GET("/api/v1/links/:shortCode", routes.GetShortenedURL())
+
+	req, _ := http.NewRequest("GET", "/api/v1/links/abc123", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"shortCode":"abc123"`)
+
+	req, _ = http.NewRequest("GET", "/api/v1/links/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"error":"error not found"}`, rr.Body.String())
+}
+
+func TestAPIRoutes_RefreshMetadata(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("RefreshMetadata", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "abc123",
+		Metadata:    &entity.Metadata{Title: "Example Domain"},
+	}, nil)
+
+	req, _ := http.NewRequest("POST", "/api/v1/links/abc123/metadata", nil)
+	rr := httptest.NewRecorder()
+
+	router := httprouter.New()
+	router.POST("/api/v1/links/:shortCode/metadata", routes.RefreshMetadata())
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"title":"Example Domain"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestAPIRoutes_UpdateOpenGraph(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	openGraph := entity.OpenGraph{Title: "Launch Week", Description: "Join us", ImageURL: "https://cdn.example.com/card.png"}
+	mockService.On("UpdateOpenGraph", mock.Anything, "short.url", "abc123", openGraph).Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "abc123",
+		OpenGraph:   &openGraph,
+	}, nil)
+
+	router := httprouter.New()
+	router.PUT("/api/v1/links/:shortCode/opengraph", routes.UpdateOpenGraph())
+
+	body := `{"title":"Launch Week","description":"Join us","imageURL":"https://cdn.example.com/card.png"}`
+	req, _ := http.NewRequest("PUT", "/api/v1/links/abc123/opengraph", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+
+	req, _ = http.NewRequest("PUT", "/api/v1/links/abc123/opengraph", bytes.NewBufferString("not json"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAPIRoutes_CreateDomain(t *testing.T) {
+	mockDomainService := new(MockDomainService)
+	routes := NewAPIRoutes(new(MockShortenedService), mockDomainService, nil, nil, nil)
+
+	mockDomainService.On("CreateDomain", mock.Anything, entity.Domain{Name: "acme.link"}).Return(&entity.Domain{Name: "acme.link"}, nil)
+	mockDomainService.On("CreateDomain", mock.Anything, entity.Domain{Name: "short.url"}).Return((*entity.Domain)(nil), constants.ErrorAlreadyExists)
+
+	router := httprouter.New()
+	router.POST("/api/v1/domains", routes.CreateDomain())
+
+	req, _ := http.NewRequest("POST", "/api/v1/domains", bytes.NewBufferString(`{"name":"acme.link"}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req, _ = http.NewRequest("POST", "/api/v1/domains", bytes.NewBufferString(`{"name":"short.url"}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestAPIRoutes_VerifyDomain(t *testing.T) {
+	mockDomainService := new(MockDomainService)
+	routes := NewAPIRoutes(new(MockShortenedService), mockDomainService, nil, nil, nil)
+
+	mockDomainService.On("VerifyDomain", mock.Anything, "acme.link").Return(&entity.Domain{
+		Name:         "acme.link",
+		Verification: entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"},
+	}, nil)
+	mockDomainService.On("VerifyDomain", mock.Anything, "missing.link").Return((*entity.Domain)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.POST("/api/v1/domains/:domain/verify", routes.VerifyDomain())
+
+	req, _ := http.NewRequest("POST", "/api/v1/domains/acme.link/verify", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"txtRecordName":"_shortenurl-challenge.acme.link"`)
+	assert.Contains(t, rr.Body.String(), `"txtRecordValue":"shortenurl-verification=abc"`)
+
+	req, _ = http.NewRequest("POST", "/api/v1/domains/missing.link/verify", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAPIRoutes_CreateShortenedURL(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("ShortenURL", mock.Anything, "short.url", "https://example.com").Return(&entity.ShortenedURL{
+		Domain:      "short.url",
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+	}, nil)
+
+	router := httprouter.New()
+	router.POST("/api/v1/links", routes.CreateShortenedURL())
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"Created", `{"originalURL":"https://example.com"}`, http.StatusCreated},
+		{"InvalidURL", `{"originalURL":"javascript:alert(1)"}`, http.StatusBadRequest},
+		{"UnknownDomain", `{"originalURL":"https://example.com","domain":"other.link"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/links", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestAPIRoutes_CreateAPIKey(t *testing.T) {
+	mockAPIKeyService := new(MockAPIKeyService)
+	routes := NewAPIRoutes(new(MockShortenedService), newMockDomainService(), mockAPIKeyService, nil, nil)
+
+	mockAPIKeyService.On("CreateAPIKey", mock.Anything, "ci", []string{"links:write"}).Return(&entity.APIKey{
+		ID:     "key-1",
+		Name:   "ci",
+		Prefix: "sk_abcdef",
+		Scopes: []string{"links:write"},
+	}, "sk_abcdef123", nil)
+	mockAPIKeyService.On("CreateAPIKey", mock.Anything, "ci", []string{"links:admin"}).Return((*entity.APIKey)(nil), "", constants.ErrorInvalidRequest)
+
+	router := httprouter.New()
+	router.POST("/api/v1/keys", routes.CreateAPIKey())
+
+	req, _ := http.NewRequest("POST", "/api/v1/keys", bytes.NewBufferString(`{"name":"ci","scopes":["links:write"]}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"key":"sk_abcdef123"`)
+	assert.NotContains(t, rr.Body.String(), "keyHash")
+
+	req, _ = http.NewRequest("POST", "/api/v1/keys", bytes.NewBufferString(`{"name":"ci","scopes":["links:admin"]}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAPIRoutes_Members(t *testing.T) {
+	mockWorkspaceService := new(MockWorkspaceService)
+	routes := NewAPIRoutes(new(MockShortenedService), newMockDomainService(), nil, mockWorkspaceService, nil)
+
+	mockWorkspaceService.On("AddMember", mock.Anything, "acme", "jane@acme.com", "editor").Return(&entity.Membership{
+		WorkspaceID: "acme",
+		UserID:      "user-2",
+		Email:       "jane@acme.com",
+		Role:        "editor",
+	}, nil)
+	mockWorkspaceService.On("AddMember", mock.Anything, "acme", "john@acme.com", "owner").Return((*entity.Membership)(nil), constants.ErrorForbidden)
+	mockWorkspaceService.On("RemoveMember", mock.Anything, "acme", "user-2").Return(nil)
+
+	router := httprouter.New()
+	router.POST("/api/v1/workspaces/:workspace/members", routes.AddMember())
+	router.POST("/api/v1/workspaces/:workspace/members/:userID/remove", routes.RemoveMember())
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"Add", "/api/v1/workspaces/acme/members", `{"email":"jane@acme.com","role":"editor"}`, http.StatusCreated},
+		{"AddOwnerAsAdmin", "/api/v1/workspaces/acme/members", `{"email":"john@acme.com","role":"owner"}`, http.StatusForbidden},
+		{"InvalidJSON", "/api/v1/workspaces/acme/members", `{`, http.StatusBadRequest},
+		{"Remove", "/api/v1/workspaces/acme/members/user-2/remove", ``, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestAPIRoutes_Audit(t *testing.T) {
+	mockAuditService := new(MockAuditService)
+	routes := NewAPIRoutes(new(MockShortenedService), newMockDomainService(), nil, nil, mockAuditService)
+
+	entries := &[]entity.AuditEntry{{Workspace: "acme", Domain: "short.url", ShortCode: "abc123", Action: entity.AuditCreate}}
+	mockAuditService.On("ListLinkAudit", mock.Anything, "short.url", "abc123", 20).Return(entries, nil)
+	mockAuditService.On("ListLinkAudit", mock.Anything, "short.url", "missing", 0).Return((*[]entity.AuditEntry)(nil), constants.ErrorNotFound)
+	mockAuditService.On("ListWorkspaceAudit", mock.Anything, "acme", 0).Return(entries, nil)
+	mockAuditService.On("ListWorkspaceAudit", mock.Anything, "globex", 0).Return((*[]entity.AuditEntry)(nil), constants.ErrorForbidden)
+
+	router := httprouter.New()
+	router.GET("/api/v1/links/:shortCode/audit", routes.ListLinkAudit())
+	router.GET("/api/v1/workspaces/:workspace/audit", routes.ListWorkspaceAudit())
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{"Link", "/api/v1/links/abc123/audit?limit=20", http.StatusOK},
+		{"UnknownLink", "/api/v1/links/missing/audit", http.StatusNotFound},
+		{"InvalidLimit", "/api/v1/links/abc123/audit?limit=many", http.StatusBadRequest},
+		{"Workspace", "/api/v1/workspaces/acme/audit", http.StatusOK},
+		{"NotAdmin", "/api/v1/workspaces/globex/audit", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestAPIRoutes_Revisions(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("ListRevisions", mock.Anything, "short.url", "abc123").Return(&[]entity.Revision{
+		{ID: "rev-2", ShortCode: "abc123", OriginalURL: "https://second.example.com"},
+		{ID: "rev-1", ShortCode: "abc123", OriginalURL: "https://first.example.com"},
+	}, nil)
+	mockService.On("RollbackShortenedURL", mock.Anything, "short.url", "abc123", "rev-1").Return(&entity.ShortenedURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://first.example.com",
+	}, nil)
+	mockService.On("RollbackShortenedURL", mock.Anything, "short.url", "abc123", "missing").Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.GET("/api/v1/links/:shortCode/revisions", routes.ListRevisions())
+	router.POST("/api/v1/links/:shortCode/revisions/:revision/rollback", routes.RollbackShortenedURL())
+
+	req, _ := http.NewRequest("GET", "/api/v1/links/abc123/revisions", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var body struct {
+		Data []entity.Revision `json:"data"`
+	}
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 2)
+
+	req, _ = http.NewRequest("POST", "/api/v1/links/abc123/revisions/rev-1/rollback", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"originalURL":"https://first.example.com"`)
+
+	req, _ = http.NewRequest("POST", "/api/v1/links/abc123/revisions/missing/rollback", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAPIRoutes_Trash(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("ListTrash", mock.Anything).Return(&[]entity.ShortenedURL{{ShortCode: "abc123"}}, nil)
+	mockService.On("RestoreShortenedURL", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{ShortCode: "abc123"}, nil)
+	mockService.On("RestoreShortenedURL", mock.Anything, "short.url", "purged").Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.GET("/api/v1/trash", routes.ListTrash())
+	router.POST("/api/v1/links/:shortCode/restore", routes.RestoreShortenedURL())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+	}{
+		{"List", "GET", "/api/v1/trash", http.StatusOK},
+		{"Restore", "POST", "/api/v1/links/abc123/restore", http.StatusOK},
+		{"Purged", "POST", "/api/v1/links/purged/restore", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestAPIRoutes_UpdateStatus(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("UpdateStatus", mock.Anything, "short.url", "abc123", entity.LinkStatusDisabled).Return(&entity.ShortenedURL{
+		ShortCode: "abc123",
+		Status:    entity.LinkStatusDisabled,
+	}, nil)
+	mockService.On("UpdateStatus", mock.Anything, "short.url", "abc123", "paused").Return((*entity.ShortenedURL)(nil), constants.ErrorInvalidRequest)
+
+	router := httprouter.New()
+	router.PUT("/api/v1/links/:shortCode/status", routes.UpdateStatus())
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"Disable", `{"status":"disabled"}`, http.StatusOK},
+		{"UnknownStatus", `{"status":"paused"}`, http.StatusBadRequest},
+		{"InvalidJSON", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", "/api/v1/links/abc123/status", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestAPIRoutes_UpdateDetails(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	details := entity.LinkDetails{Title: "Launch", Notes: "Spring campaign", Tags: []string{"marketing"}}
+	mockService.On("UpdateDetails", mock.Anything, "short.url", "abc123", details).Return(&entity.ShortenedURL{
+		ShortCode: "abc123",
+		Title:     "Launch",
+		Tags:      []string{"marketing"},
+	}, nil)
+
+	router := httprouter.New()
+	router.PUT("/api/v1/links/:shortCode/details", routes.UpdateDetails())
+
+	body := `{"title":"Launch","notes":"Spring campaign","tags":["marketing"]}`
+	req, _ := http.NewRequest("PUT", "/api/v1/links/abc123/details", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"tags":["marketing"]`)
+}
+
+func TestAPIRoutes_UpdateShortenedURL(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("UpdateShortenedURL", mock.Anything, "short.url", "abc123", "https://example.com/new").Return(&entity.ShortenedURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com/new",
+	}, nil)
+	mockService.On("UpdateFallbackURL", mock.Anything, "short.url", "abc123", "").Return(&entity.ShortenedURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com/new",
+	}, nil)
+	mockService.On("UpdateShortenedURL", mock.Anything, "short.url", "missing", mock.Anything).Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.PUT("/api/v1/links/:shortCode", routes.UpdateShortenedURL())
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"DestinationAndFallback", "/api/v1/links/abc123", `{"originalURL":"https://example.com/new","fallbackURL":""}`, http.StatusOK},
+		{"NotFound", "/api/v1/links/missing", `{"originalURL":"https://example.com/new"}`, http.StatusNotFound},
+		{"InvalidFallback", "/api/v1/links/abc123", `{"fallbackURL":"ftp://example.com"}`, http.StatusBadRequest},
+		{"NothingToUpdate", "/api/v1/links/abc123", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", tt.path, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+
+	mockService.AssertNumberOfCalls(t, "UpdateFallbackURL", 1)
+}
+
+func TestAPIRoutes_DeleteShortenedURL(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewAPIRoutes(mockService, newMockDomainService(), nil, nil, nil)
+
+	mockService.On("DeleteShortenedURL", mock.Anything, "short.url", "abc123").Return(nil)
+	mockService.On("DeleteShortenedURL", mock.Anything, "short.url", "missing").Return(constants.ErrorNotFound)
+
+	router := httprouter.New()
+	router.DELETE("/api/v1/links/:shortCode", routes.DeleteShortenedURL())
+
+	for path, code := range map[string]int{"/api/v1/links/abc123": http.StatusNoContent, "/api/v1/links/missing": http.StatusNotFound} {
+		req, _ := http.NewRequest("DELETE", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, code, rr.Code, path)
+	}
+}
+
+func TestExportRoutes(t *testing.T) {
+	mockService := new(MockExportService)
+	routes := NewExportRoutes(mockService)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mockService.On("ExportLinks", mock.Anything, entity.ExportFormatCSV, mock.MatchedBy(func(query entity.LinkQuery) bool {
+		return query.Tag == "sales" && query.CreatedFrom.Equal(from)
+	})).Return("domain,shortCode\nshort.url,deck\n", nil)
+	mockService.On("ExportLinks", mock.Anything, "xlsx", mock.Anything).Return("", constants.ErrorInvalidRequest)
+	mockService.On("ExportClicks", mock.Anything, entity.ExportFormatJSONLines, entity.ClickQuery{Tag: "sales", To: from.AddDate(0, 0, 1)}).
+		Return("", nil)
+
+	router := httprouter.New()
+	router.GET("/api/v1/exports/links", routes.ExportLinks())
+	router.GET("/api/v1/exports/clicks", routes.ExportClicks())
+
+	tests := []struct {
+		name        string
+		path        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"Links", "/api/v1/exports/links?tag=sales&from=2024-03-01", http.StatusOK, "text/csv; charset=utf-8", "domain,shortCode\nshort.url,deck\n"},
+		{"UnknownFormat", "/api/v1/exports/links?format=xlsx", http.StatusBadRequest, "application/json", ""},
+		{"InvalidDate", "/api/v1/exports/links?from=yesterday", http.StatusBadRequest, "application/json", ""},
+		{"EmptyClicks", "/api/v1/exports/clicks?format=jsonl&tag=sales&to=2024-03-01", http.StatusOK, "application/x-ndjson", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.body, rr.Body.String())
+				assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// auditPage shows the audit log of the selected workspace to its admins
+type auditPage struct {
+	Entries   *[]entity.AuditEntry
+	Workspace string
+	Error     string
+}
+
+// queryLimit returns the limit parameter, zero when it is missing.
+func queryLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%w: invalid limit %q", constants.ErrorInvalidRequest, value)
+	}
+
+	return limit, nil
+}
+
+func (routes *APIRoutes) ListLinkAudit() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		limit, err := queryLimit(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		entries, err := routes.auditService.ListLinkAudit(r.Context(), requestDomain(r, routes.domainService), p.ByName("shortCode"), limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+func (routes *APIRoutes) ListWorkspaceAudit() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		limit, err := queryLimit(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		entries, err := routes.auditService.ListWorkspaceAudit(r.Context(), p.ByName("workspace"), limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// AuditLog renders the audit log of the selected workspace.
+func (routes *Routes) AuditLog() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		limit, _ := queryLimit(r)
+		page := auditPage{Workspace: services.WorkspaceFromContext(r.Context())}
+
+		entries, err := routes.auditService.ListWorkspaceAudit(r.Context(), "", limit)
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if errors.Is(err, constants.ErrorForbidden) || errors.Is(err, constants.ErrorNotFound) {
+			w.WriteHeader(http.StatusForbidden)
+			page.Error = "Only admins of the workspace can read its audit log."
+		} else if err != nil {
+			log.Print(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			page.Error = "Something went wrong, please try again."
+		}
+
+		page.Entries = entries
+		if principal, ok := services.PrincipalFromContext(r.Context()); ok && page.Workspace == "" {
+			page.Workspace = entity.PersonalWorkspaceID(principal.UserID)
+		}
+
+		err = routes.template.ExecuteTemplate(w, "audit.html", page)
+
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookieName   = "session"
+	workspaceCookieName = "workspace"
+)
+
+type authPage struct {
+	Email string
+	Error string
+}
+
+// AuthRoutes serves registration, login and logout, and authenticates requests.
+type AuthRoutes struct {
+	template      *template.Template
+	userService   services.UserService
+	apiKeyService services.APIKeyService
+	// tokenService is nil when no identity provider is configured
+	tokenService services.AccessTokenService
+	sessionTTL   time.Duration
+	secureCookie bool
+}
+
+func NewAuthRoutes(t *template.Template, us services.UserService, ks services.APIKeyService, ts services.AccessTokenService, sessionTTL time.Duration, secureCookie bool) *AuthRoutes {
+	return &AuthRoutes{template: t, userService: us, apiKeyService: ks, tokenService: ts, sessionTTL: sessionTTL, secureCookie: secureCookie}
+}
+
+// bearerPrincipal authenticates an API key, or an access token of the identity provider.
+func (routes *AuthRoutes) bearerPrincipal(r *http.Request, credential string) (*entity.Principal, error) {
+	if routes.tokenService != nil && !strings.HasPrefix(credential, services.APIKeyPrefix) {
+		return routes.tokenService.Authenticate(r.Context(), credential)
+	}
+
+	return routes.apiKeyService.Authenticate(r.Context(), credential)
+}
+
+// selectWorkspace attaches the workspace chosen with the workspace parameter, or earlier with
+// the workspace switcher, to the request context.
+func selectWorkspace(r *http.Request) *http.Request {
+	workspace := r.URL.Query().Get("workspace")
+	if cookie, err := r.Cookie(workspaceCookieName); workspace == "" && err == nil {
+		workspace = cookie.Value
+	}
+
+	if workspace == "" {
+		return r
+	}
+
+	return r.WithContext(services.WithWorkspace(r.Context(), workspace))
+}
+
+// clientIP returns the address of the client connected to the server
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Authenticate attaches the principal of a bearer credential or of the session cookie, the
+// selected workspace and the client IP to the request context. Requests without credentials pass through
+// anonymously, the services decide what they may do, while invalid bearer credentials are
+// rejected right away.
+func (routes *AuthRoutes) Authenticate(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		r = selectWorkspace(r)
+		r = r.WithContext(services.WithClientIP(r.Context(), clientIP(r)))
+
+		if header := r.Header.Get("Authorization"); header != "" {
+			credential, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				writeError(w, fmt.Errorf("%w: expected a Bearer authorization", constants.ErrorUnauthorized))
+				return
+			}
+
+			principal, err := routes.bearerPrincipal(r, strings.TrimSpace(credential))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+
+			next(w, r.WithContext(services.WithPrincipal(r.Context(), principal)), p)
+
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookieName)
+		if err == nil && cookie.Value != "" {
+			user, err := routes.userService.Authenticate(r.Context(), cookie.Value)
+			if err == nil {
+				r = r.WithContext(services.WithPrincipal(r.Context(), &entity.Principal{UserID: user.ID, Email: user.Email, TwoFactor: user.TwoFactorEnabled()}))
+			} else if !errors.Is(err, constants.ErrorUnauthorized) {
+				log.Print(err)
+			}
+		}
+
+		next(w, r, p)
+	}
+}
+
+func (routes *AuthRoutes) render(w http.ResponseWriter, name string, page any) {
+	err := routes.template.ExecuteTemplate(w, name, page)
+
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func (routes *AuthRoutes) setSessionCookie(w http.ResponseWriter, token string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   routes.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (routes *AuthRoutes) LoginPage() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		routes.render(w, "login.html", authPage{})
+	}
+}
+
+func (routes *AuthRoutes) Login() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		email := r.FormValue("email")
+
+		token, user, err := routes.userService.Login(r.Context(), email, r.FormValue("password"))
+		if err != nil {
+			if !errors.Is(err, constants.ErrorUnauthorized) {
+				log.Print(err)
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+			routes.render(w, "login.html", authPage{Email: email, Error: "Invalid email or password."})
+
+			return
+		}
+
+		// the session only becomes valid with the code of the second factor
+		if user.TwoFactorEnabled() {
+			routes.setSessionCookie(w, token, int(services.TwoFactorLoginTTL.Seconds()))
+			http.Redirect(w, r, "/login/verify", http.StatusSeeOther)
+
+			return
+		}
+
+		routes.setSessionCookie(w, token, int(routes.sessionTTL.Seconds()))
+		http.Redirect(w, r, "/shorten-url", http.StatusSeeOther)
+	}
+}
+
+func (routes *AuthRoutes) RegisterPage() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		routes.render(w, "register.html", authPage{})
+	}
+}
+
+func (routes *AuthRoutes) Register() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		email := r.FormValue("email")
+		password := r.FormValue("password")
+
+		_, err := routes.userService.Register(r.Context(), email, password)
+		if err != nil {
+			message := "Registration failed, please try again."
+			if errors.Is(err, constants.ErrorInvalidRequest) {
+				w.WriteHeader(http.StatusBadRequest)
+				message = strings.TrimPrefix(err.Error(), constants.ErrorInvalidRequest.Error()+": ")
+			} else if errors.Is(err, constants.ErrorAlreadyExists) {
+				w.WriteHeader(http.StatusConflict)
+				message = "This email is already registered."
+			} else {
+				log.Print(err)
+			}
+
+			routes.render(w, "register.html", authPage{Email: email, Error: message})
+
+			return
+		}
+
+		token, _, err := routes.userService.Login(r.Context(), email, password)
+		if err != nil {
+			log.Print(err)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+
+			return
+		}
+
+		routes.setSessionCookie(w, token, int(routes.sessionTTL.Seconds()))
+		http.Redirect(w, r, "/shorten-url", http.StatusSeeOther)
+	}
+}
+
+func (routes *AuthRoutes) Logout() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err == nil && cookie.Value != "" {
+			err = routes.userService.Logout(r.Context(), cookie.Value)
+			if err != nil {
+				log.Print(err)
+			}
+		}
+
+		routes.setSessionCookie(w, "", -1)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
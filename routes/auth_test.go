@@ -0,0 +1,252 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockUserService is a mock of the UserService interface
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) Register(ctx context.Context, email string, password string) (*entity.User, error) {
+	args := m.Called(ctx, email, password)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserService) Login(ctx context.Context, email string, password string) (string, *entity.User, error) {
+	args := m.Called(ctx, email, password)
+	return args.String(0), args.Get(1).(*entity.User), args.Error(2)
+}
+
+func (m *MockUserService) Logout(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Authenticate(ctx context.Context, token string) (*entity.User, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserService) VerifyLogin(ctx context.Context, token string, code string) (string, *entity.User, error) {
+	args := m.Called(ctx, token, code)
+	return args.String(0), args.Get(1).(*entity.User), args.Error(2)
+}
+
+func (m *MockUserService) SetupTwoFactor(ctx context.Context) (*entity.TwoFactorSetup, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*entity.TwoFactorSetup), args.Error(1)
+}
+
+func (m *MockUserService) EnableTwoFactor(ctx context.Context, code string) ([]string, error) {
+	args := m.Called(ctx, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUserService) DisableTwoFactor(ctx context.Context, code string) error {
+	args := m.Called(ctx, code)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	args := m.Called(ctx, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockAPIKeyService is a mock of the APIKeyService interface
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*entity.APIKey, string, error) {
+	args := m.Called(ctx, name, scopes)
+	return args.Get(0).(*entity.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(ctx context.Context) (*[]entity.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*[]entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(ctx context.Context, id string) (*entity.APIKey, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, key string) (*entity.Principal, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*entity.Principal), args.Error(1)
+}
+
+// MockAccessTokenService is a mock of the AccessTokenService interface
+type MockAccessTokenService struct {
+	mock.Mock
+}
+
+func (m *MockAccessTokenService) Authenticate(ctx context.Context, token string) (*entity.Principal, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(*entity.Principal), args.Error(1)
+}
+
+func TestAuthRoutes_Authenticate(t *testing.T) {
+	mockUserService := new(MockUserService)
+	mockAPIKeyService := new(MockAPIKeyService)
+	mockTokenService := new(MockAccessTokenService)
+	routes := NewAuthRoutes(nil, mockUserService, mockAPIKeyService, mockTokenService, time.Hour, true)
+
+	mockUserService.On("Authenticate", mock.Anything, "valid").Return(&entity.User{ID: "user-1"}, nil)
+	mockUserService.On("Authenticate", mock.Anything, "expired").Return((*entity.User)(nil), constants.ErrorUnauthorized)
+	mockAPIKeyService.On("Authenticate", mock.Anything, "sk_valid").Return(&entity.Principal{
+		UserID:   "user-2",
+		APIKeyID: "key-1",
+		Scopes:   []string{entity.ScopeLinksRead},
+	}, nil)
+	mockAPIKeyService.On("Authenticate", mock.Anything, "sk_revoked").Return((*entity.Principal)(nil), constants.ErrorUnauthorized)
+	mockTokenService.On("Authenticate", mock.Anything, "eyJ.valid.jwt").Return(&entity.Principal{UserID: "user-3", Subject: "idp|jane"}, nil)
+	mockTokenService.On("Authenticate", mock.Anything, "eyJ.expired.jwt").Return((*entity.Principal)(nil), constants.ErrorUnauthorized)
+
+	var principal *entity.Principal
+	handler := routes.Authenticate(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		principal, _ = services.PrincipalFromContext(r.Context())
+	})
+
+	tests := []struct {
+		name          string
+		cookie        string
+		authorization string
+		code          int
+		userID        string
+	}{
+		{"ValidSession", "valid", "", http.StatusOK, "user-1"},
+		{"ExpiredSession", "expired", "", http.StatusOK, ""},
+		{"Anonymous", "", "", http.StatusOK, ""},
+		{"ValidKey", "", "Bearer sk_valid", http.StatusOK, "user-2"},
+		{"RevokedKey", "valid", "Bearer sk_revoked", http.StatusUnauthorized, ""},
+		{"NotBearer", "", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+		{"ValidToken", "", "Bearer eyJ.valid.jwt", http.StatusOK, "user-3"},
+		{"ExpiredToken", "valid", "Bearer eyJ.expired.jwt", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			req, _ := http.NewRequest("GET", "/shorten-url", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			handler(rr, req, nil)
+
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.userID == "" {
+				assert.Nil(t, principal)
+			} else if assert.NotNil(t, principal) {
+				assert.Equal(t, tt.userID, principal.UserID)
+			}
+		})
+	}
+}
+
+func TestAuthRoutes_Login(t *testing.T) {
+	tmpl := template.Must(template.New("login.html").Parse("{{.Error}}"))
+	mockUserService := new(MockUserService)
+	routes := NewAuthRoutes(tmpl, mockUserService, nil, nil, time.Hour, true)
+
+	mockUserService.On("Login", mock.Anything, "jane@acme.com", "correct horse").Return("token", &entity.User{ID: "user-1"}, nil)
+	mockUserService.On("Login", mock.Anything, "jane@acme.com", "wrong").Return("", (*entity.User)(nil), constants.ErrorUnauthorized)
+
+	router := httprouter.New()
+	router.POST("/login", routes.Login())
+
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBufferString("email=jane@acme.com&password=correct+horse"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	cookies := rr.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "token", cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	}
+
+	req, _ = http.NewRequest("POST", "/login", bytes.NewBufferString("email=jane@acme.com&password=wrong"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Empty(t, rr.Result().Cookies())
+	assert.Equal(t, "Invalid email or password.", rr.Body.String())
+}
+
+func TestAuthRoutes_TwoFactorLogin(t *testing.T) {
+	tmpl := template.Must(template.New("login_verify.html").Parse("{{.Error}}"))
+	mockUserService := new(MockUserService)
+	routes := NewAuthRoutes(tmpl, mockUserService, nil, nil, time.Hour, true)
+	user := &entity.User{ID: "user-1", TwoFactor: &entity.TwoFactor{Enabled: true}}
+
+	mockUserService.On("Login", mock.Anything, "jane@acme.com", "correct horse").Return("pending", user, nil)
+	mockUserService.On("VerifyLogin", mock.Anything, "pending", "123456").Return("token", user, nil)
+	mockUserService.On("VerifyLogin", mock.Anything, "pending", "000000").Return("", (*entity.User)(nil), constants.ErrorUnauthorized)
+
+	router := httprouter.New()
+	router.POST("/login", routes.Login())
+	router.POST("/login/verify", routes.VerifyLogin())
+
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBufferString("email=jane@acme.com&password=correct+horse"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/login/verify", rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "pending", cookies[0].Value)
+		assert.Equal(t, int(services.TwoFactorLoginTTL.Seconds()), cookies[0].MaxAge)
+	}
+
+	req, _ = http.NewRequest("POST", "/login/verify", bytes.NewBufferString("code=000000"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "pending"})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Empty(t, rr.Result().Cookies())
+
+	req, _ = http.NewRequest("POST", "/login/verify", bytes.NewBufferString("code=123456"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "pending"})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/shorten-url", rr.Header().Get("Location"))
+	cookies = rr.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "token", cookies[0].Value)
+		assert.Equal(t, int(time.Hour.Seconds()), cookies[0].MaxAge)
+	}
+}
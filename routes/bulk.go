@@ -0,0 +1,357 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxBulkUploadSize bounds bulk uploads, a thousand rows fit with room to spare
+const maxBulkUploadSize = 5 << 20
+
+// bulkPage shows the bulk upload form and, after an upload, the outcome of every row
+type bulkPage struct {
+	Domains       *[]entity.Domain
+	DefaultDomain string
+	Workspace     string
+	Results       *bulkResponse
+	Error         string
+}
+
+type bulkResponse struct {
+	Created int                  `json:"created"`
+	Failed  int                  `json:"failed"`
+	Results *[]entity.BulkResult `json:"results"`
+}
+
+func newBulkResponse(results *[]entity.BulkResult) *bulkResponse {
+	response := &bulkResponse{Results: results}
+	for _, result := range *results {
+		if result.Link != nil {
+			response.Created++
+		} else {
+			response.Failed++
+		}
+	}
+
+	return response
+}
+
+// bulkCSVColumns maps the accepted CSV headers to the fields of a bulk row
+var bulkCSVColumns = map[string]string{
+	"destination": "originalURL",
+	"originalurl": "originalURL",
+	"url":         "originalURL",
+	"alias":       "alias",
+	"tags":        "tags",
+	"expiry":      "expiresAt",
+	"expires":     "expiresAt",
+	"expiresat":   "expiresAt",
+}
+
+// readBulkCSV reads the rows of a CSV with a header row. Tags are separated by commas or
+// semicolons within their column.
+func readBulkCSV(body io.Reader) ([]entity.BulkLink, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid CSV header: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	columns := map[string]int{}
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := bulkCSVColumns[name]; ok {
+			columns[field] = index
+		}
+	}
+
+	if _, ok := columns["originalURL"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV has no destination column", constants.ErrorInvalidRequest)
+	}
+
+	column := func(record []string, field string) string {
+		index, ok := columns[field]
+		if !ok || index >= len(record) {
+			return ""
+		}
+
+		return record[index]
+	}
+
+	var rows []entity.BulkLink
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid CSV: %v", constants.ErrorInvalidRequest, err)
+		}
+
+		row := entity.BulkLink{
+			OriginalURL: column(record, "originalURL"),
+			Alias:       column(record, "alias"),
+			ExpiresAt:   column(record, "expiresAt"),
+		}
+
+		tags := strings.FieldsFunc(column(record, "tags"), func(r rune) bool {
+			return r == ',' || r == ';'
+		})
+		if len(tags) > 0 {
+			row.Tags = tags
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readBulkLinks reads the rows of a JSON array, or of a CSV when the body does not start
+// like one.
+func readBulkLinks(body io.Reader) ([]entity.BulkLink, error) {
+	buffered := bufio.NewReader(body)
+
+	start, _ := buffered.Peek(512)
+	if !bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(start, []byte("\ufeff"))), []byte("[")) {
+		return readBulkCSV(buffered)
+	}
+
+	var rows []entity.BulkLink
+	err := json.NewDecoder(buffered).Decode(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	return rows, nil
+}
+
+// requestBulkLinks returns the rows of a bulk creation, sent as the request body or uploaded
+// as the file form field.
+func requestBulkLinks(w http.ResponseWriter, r *http.Request) ([]entity.BulkLink, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return readBulkLinks(r.Body)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("%w: no file uploaded", constants.ErrorInvalidRequest)
+	}
+	defer file.Close()
+
+	return readBulkLinks(file)
+}
+
+// bulkDomain returns the domain of a bulk creation, after checking it is registered
+func bulkDomain(r *http.Request, domainService services.DomainService) (string, error) {
+	domain := requestDomain(r, domainService)
+
+	_, err := domainService.GetDomain(r.Context(), domain)
+
+	return domain, err
+}
+
+// BulkCreate creates links from a JSON array or a CSV, reporting the outcome of every row.
+func (routes *APIRoutes) BulkCreate() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rows, err := requestBulkLinks(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		domain, err := bulkDomain(r, routes.domainService)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		results, err := routes.service.BulkShorten(r.Context(), domain, rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newBulkResponse(results))
+	}
+}
+
+// bulkOperationRequest is the body of BulkUpdate. Filter takes the query parameters of a link
+// listing and is only read when no links are listed; links without a domain are on the default one.
+type bulkOperationRequest struct {
+	Action    string            `json:"action"`
+	Links     []entity.LinkKey  `json:"links"`
+	Filter    map[string]string `json:"filter"`
+	Tags      []string          `json:"tags"`
+	ExpiresAt string            `json:"expiresAt"`
+	DryRun    bool              `json:"dryRun"`
+}
+
+// BulkUpdate deletes, disables, retags or sets the expiry of many links at once, or counts the
+// links it would change on a dry run.
+func (routes *APIRoutes) BulkUpdate() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request bulkOperationRequest
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkUploadSize)).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		operation := entity.BulkOperation{
+			Action:    request.Action,
+			Tags:      request.Tags,
+			ExpiresAt: request.ExpiresAt,
+			DryRun:    request.DryRun,
+		}
+
+		for _, link := range request.Links {
+			link.Domain = entity.NormalizeHost(link.Domain)
+			if link.Domain == "" {
+				link.Domain = routes.domainService.DefaultDomain()
+			}
+			operation.Links = append(operation.Links, link)
+		}
+
+		if len(operation.Links) == 0 && request.Filter != nil {
+			values := url.Values{}
+			for name, value := range request.Filter {
+				values.Set(name, value)
+			}
+
+			filter, err := ParseLinkQuery(values)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			operation.Filter = &filter
+		}
+
+		result, err := routes.service.BulkUpdate(r.Context(), operation)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// destinationRewriteRequest is the body of RewriteDestinations
+type destinationRewriteRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	DryRun bool   `json:"dryRun"`
+}
+
+// RewriteDestinations moves the destinations of the selected workspace from one host or URL
+// prefix to another, or lists the links it would change on a dry run.
+func (routes *APIRoutes) RewriteDestinations() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request destinationRewriteRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		result, err := routes.service.RewriteDestinations(r.Context(), entity.DestinationRewrite(request))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// BulkPage renders the bulk upload form.
+func (routes *Routes) BulkPage() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if _, ok := services.PrincipalFromContext(r.Context()); !ok {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		routes.renderBulk(w, r, bulkPage{})
+	}
+}
+
+// BulkCreate creates the links of an uploaded file and renders the outcome of every row.
+func (routes *Routes) BulkCreate() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var page bulkPage
+
+		rows, err := requestBulkLinks(w, r)
+
+		var domain string
+		if err == nil {
+			domain, err = bulkDomain(r, routes.domainService)
+		}
+
+		var results *[]entity.BulkResult
+		if err == nil {
+			results, err = routes.service.BulkShorten(r.Context(), domain, rows)
+		}
+
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if errors.Is(err, constants.ErrorInvalidRequest) || errors.Is(err, constants.ErrorNotFound) ||
+			errors.Is(err, constants.ErrorForbidden) {
+			page.Error = err.Error()
+		} else if err != nil {
+			log.Print(err)
+			page.Error = "The links could not be created, please try again."
+		}
+
+		if results != nil {
+			page.Results = newBulkResponse(results)
+		}
+
+		routes.renderBulk(w, r, page)
+	}
+}
+
+func (routes *Routes) renderBulk(w http.ResponseWriter, r *http.Request, page bulkPage) {
+	domains, err := routes.domainService.ListDomains(r.Context())
+	if err != nil {
+		log.Print(err)
+	}
+
+	page.Domains = domains
+	page.DefaultDomain = routes.domainService.DefaultDomain()
+	page.Workspace = services.WorkspaceFromContext(r.Context())
+	if principal, ok := services.PrincipalFromContext(r.Context()); ok && page.Workspace == "" {
+		page.Workspace = entity.PersonalWorkspaceID(principal.UserID)
+	}
+
+	err = routes.template.ExecuteTemplate(w, "bulk.html", page)
+
+	if err != nil {
+		log.Print(err)
+	}
+}
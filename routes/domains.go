@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// domainVerificationInstructions tells the owner of a pending domain how to prove ownership.
+type domainVerificationInstructions struct {
+	TXTRecordName  string `json:"txtRecordName"`
+	TXTRecordValue string `json:"txtRecordValue"`
+	WellKnownPath  string `json:"wellKnownPath"`
+}
+
+type domainResponse struct {
+	*entity.Domain
+	Instructions *domainVerificationInstructions `json:"instructions,omitempty"`
+}
+
+func newDomainResponse(domain *entity.Domain) domainResponse {
+	response := domainResponse{Domain: domain}
+
+	if !domain.IsVerified() && domain.Verification.Token != "" {
+		response.Instructions = &domainVerificationInstructions{
+			TXTRecordName:  domain.TXTRecordName(),
+			TXTRecordValue: domain.TXTRecordValue(),
+			WellKnownPath:  services.WellKnownVerificationPath,
+		}
+	}
+
+	return response
+}
+
+func (routes *APIRoutes) ListDomains() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		domains, err := routes.domainService.ListDomains(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		responses := make([]domainResponse, len(*domains))
+		for index := range *domains {
+			responses[index] = newDomainResponse(&(*domains)[index])
+		}
+
+		writeJSON(w, http.StatusOK, responses)
+	}
+}
+
+func (routes *APIRoutes) CreateDomain() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var domain entity.Domain
+		err := json.NewDecoder(r.Body).Decode(&domain)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		created, err := routes.domainService.CreateDomain(r.Context(), domain)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, newDomainResponse(created))
+	}
+}
+
+func (routes *APIRoutes) UpdateDomain() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var domain entity.Domain
+		err := json.NewDecoder(r.Body).Decode(&domain)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		updated, err := routes.domainService.UpdateDomain(r.Context(), p.ByName("domain"), domain.RootRedirectURL, domain.NotFoundTemplate,
+			domain.UnavailableTemplate)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newDomainResponse(updated))
+	}
+}
+
+func (routes *APIRoutes) VerifyDomain() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		domain, err := routes.domainService.VerifyDomain(r.Context(), p.ByName("domain"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newDomainResponse(domain))
+	}
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// exportContentTypes are the media types of the entity.ExportFormat formats
+var exportContentTypes = map[string]string{
+	entity.ExportFormatCSV:       "text/csv; charset=utf-8",
+	entity.ExportFormatJSONLines: "application/x-ndjson",
+	entity.ExportFormatParquet:   "application/vnd.apache.parquet",
+}
+
+// ExportRoutes stream the links and clicks of the selected workspace as files.
+type ExportRoutes struct {
+	service services.ExportService
+}
+
+func NewExportRoutes(s services.ExportService) *ExportRoutes {
+	return &ExportRoutes{service: s}
+}
+
+// exportResponse sends the headers of an export with its first bytes, so that an export that
+// fails before writing anything is answered with an error instead.
+type exportResponse struct {
+	http.ResponseWriter
+	name    string
+	format  string
+	started bool
+}
+
+func (e *exportResponse) start() {
+	if e.started {
+		return
+	}
+
+	e.started = true
+	e.Header().Set("Content-Type", exportContentTypes[e.format])
+	e.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, e.name, time.Now().UTC().Format(time.DateOnly), e.format))
+	e.WriteHeader(http.StatusOK)
+}
+
+func (e *exportResponse) Write(data []byte) (int, error) {
+	e.start()
+
+	return e.ResponseWriter.Write(data)
+}
+
+// writeExport answers with the file export writes, in the format given by the format parameter
+func writeExport(w http.ResponseWriter, r *http.Request, name string, export func(format string, w *exportResponse) error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = entity.DefaultExportFormat
+	}
+
+	response := &exportResponse{ResponseWriter: w, name: name, format: format}
+
+	err := export(format, response)
+	if err != nil && !response.started {
+		writeError(w, err)
+		return
+	}
+
+	if err != nil {
+		// the status is sent already, aborting tells the client the file is incomplete
+		log.Printf("error exporting %s %v\n", name, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	response.start()
+}
+
+// ExportLinks streams the links of the selected workspace, narrowed down with the parameters of
+// link listings: tag, domain, status, q and the from and to creation dates.
+func (routes *ExportRoutes) ExportLinks() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query, err := ParseLinkQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeExport(w, r, "links", func(format string, w *exportResponse) error {
+			return routes.service.ExportLinks(r.Context(), format, query, w)
+		})
+	}
+}
+
+// ParseClickQuery reads the tag and domain of the clicked links and the from and to dates of
+// clicks, days or RFC 3339 times of which a to day is included.
+func ParseClickQuery(values url.Values) (entity.ClickQuery, error) {
+	query := entity.ClickQuery{Domain: values.Get("domain"), Tag: values.Get("tag")}
+
+	var err error
+	query.From, err = queryTime(values.Get("from"), false)
+	if err != nil {
+		return query, err
+	}
+
+	query.To, err = queryTime(values.Get("to"), true)
+
+	return query, err
+}
+
+// ExportClicks streams the clicks on the links of the selected workspace, narrowed down by the
+// tag and domain of their links and by the from and to dates they were recorded on.
+func (routes *ExportRoutes) ExportClicks() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query, err := ParseClickQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeExport(w, r, "clicks", func(format string, w *exportResponse) error {
+			return routes.service.ExportClicks(r.Context(), format, query, w)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// maxImportUploadSize bounds import uploads, which hold up to fifty thousand links
+const maxImportUploadSize = 32 << 20
+
+// ImportRoutes start and follow link imports, which run in the background.
+type ImportRoutes struct {
+	service       services.ImportService
+	domainService services.DomainService
+}
+
+func NewImportRoutes(s services.ImportService, ds services.DomainService) *ImportRoutes {
+	return &ImportRoutes{service: s, domainService: ds}
+}
+
+// requestImportFile returns the export of an import and its name, sent as the request body or
+// uploaded as the file form field.
+func requestImportFile(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportUploadSize)
+
+	var body io.Reader = r.Body
+	name := r.URL.Query().Get("name")
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: no file uploaded", constants.ErrorInvalidRequest)
+		}
+		defer file.Close()
+
+		body = file
+		if name == "" {
+			name = header.Filename
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	return data, name, nil
+}
+
+// run runs a job after the request that started it is answered
+func (routes *ImportRoutes) run(ctx context.Context, job *entity.ImportJob, links []entity.ImportLink) {
+	_, err := routes.service.RunImport(context.WithoutCancel(ctx), job, links)
+	if err != nil {
+		log.Printf("error running import %s %v\n", job.ID, err)
+	}
+}
+
+// CreateImport starts importing the links of an export, its format is the format parameter.
+// It answers with the job right away.
+func (routes *ImportRoutes) CreateImport() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		body, name, err := requestImportFile(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		domain, err := bulkDomain(r, routes.domainService)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		job, links, err := routes.service.CreateImport(r.Context(), domain, r.URL.Query().Get("format"), name, body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response := *job
+		go routes.run(r.Context(), job, links)
+
+		writeJSON(w, http.StatusAccepted, response)
+	}
+}
+
+// ResumeImport carries on with a failed or interrupted import, given the same export again.
+func (routes *ImportRoutes) ResumeImport() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		body, _, err := requestImportFile(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		job, links, err := routes.service.ResumeImport(r.Context(), p.ByName("id"), body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response := *job
+		go routes.run(r.Context(), job, links)
+
+		writeJSON(w, http.StatusAccepted, response)
+	}
+}
+
+func (routes *ImportRoutes) GetImport() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		job, err := routes.service.GetImport(r.Context(), p.ByName("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, job)
+	}
+}
@@ -2,36 +2,215 @@ package routes
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
 	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/ilhamtubagus/shortenurl/util"
 	"github.com/julienschmidt/httprouter"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+type openGraphPage struct {
+	Link        *entity.ShortenedURL
+	Destination string
+}
+
+type indexPage struct {
+	Domains       *[]entity.Domain
+	DefaultDomain string
+	Principal     *entity.Principal
+}
+
+// listPage shows the links of the selected workspace, with a switcher to the other workspaces
+type listPage struct {
+	Links      *[]entity.ShortenedURL
+	Workspaces *[]entity.WorkspaceMembership
+	Workspace  string
+	CanEdit    bool
+	CanManage  bool
+	// Query holds the filters and sort order of the listing, as given in the request
+	Query url.Values
+	// FirstPage and NextPage link to the pages of the listing, they are empty when on that page
+	// and on the last page
+	FirstPage string
+	NextPage  string
+	Error     string
+}
+
+// trashPage shows the deleted links of the selected workspace until they are purged
+type trashPage struct {
+	Links     *[]entity.ShortenedURL
+	Workspace string
+	CanEdit   bool
+}
+
 type Routes struct {
-	template *template.Template
-	service  services.ShortenedService
+	template         *template.Template
+	service          services.ShortenedService
+	domainService    services.DomainService
+	workspaceService services.WorkspaceService
+	auditService     services.AuditService
+}
+
+func NewRoutes(t *template.Template, s services.ShortenedService, ds services.DomainService, ws services.WorkspaceService, as services.AuditService) *Routes {
+	return &Routes{template: t, service: s, domainService: ds, workspaceService: ws, auditService: as}
 }
 
-func NewRoutes(t *template.Template, s services.ShortenedService) *Routes {
-	return &Routes{template: t, service: s}
+// requestDomain returns the domain a management request targets,
+// taken from the domain parameter and defaulting to the default domain.
+func requestDomain(r *http.Request, domainService services.DomainService) string {
+	if domain := r.FormValue("domain"); domain != "" {
+		return entity.NormalizeHost(domain)
+	}
+
+	return domainService.DefaultDomain()
+}
+
+// requestLinkQuery returns the filters, sort order and page of a link listing, taken from its
+// query parameters. Dates are days or RFC 3339 times, a to day is included in the range.
+func requestLinkQuery(r *http.Request) (entity.LinkQuery, error) {
+	_ = r.ParseForm()
+
+	return ParseLinkQuery(r.Form)
+}
+
+// ParseLinkQuery reads a link query from the parameters requestLinkQuery describes, the command
+// line takes the same ones.
+func ParseLinkQuery(values url.Values) (entity.LinkQuery, error) {
+	query := entity.LinkQuery{
+		Search: values.Get("q"),
+		Domain: values.Get("domain"),
+		Tag:    values.Get("tag"),
+		Status: values.Get("status"),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	var err error
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid limit %q", constants.ErrorInvalidRequest, limit)
+		}
+	}
+
+	query.CreatedFrom, err = queryTime(values.Get("from"), false)
+	if err != nil {
+		return query, err
+	}
+
+	query.CreatedTo, err = queryTime(values.Get("to"), true)
+	if err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+func queryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			return day.AddDate(0, 0, 1), nil
+		}
+
+		return day, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("%w: invalid date %q", constants.ErrorInvalidRequest, value)
+	}
+
+	return t, nil
+}
+
+// writeStatus reports a failed management request to the page scripts
+func writeStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, constants.ErrorUnauthorized) {
+		w.WriteHeader(http.StatusUnauthorized)
+	} else if errors.Is(err, constants.ErrorForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+	} else if errors.Is(err, constants.ErrorNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		log.Print(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// renderUnavailable renders the page of the requested domain shown instead of redirecting to
+// disabled, blocked and expired links
+func (routes *Routes) renderUnavailable(w http.ResponseWriter, r *http.Request, shortened *entity.ShortenedURL) {
+	name := "unavailable.html"
+
+	domain, err := routes.domainService.ResolveDomain(r.Context(), r.Host)
+	if err == nil && domain.UnavailableTemplate != "" && routes.template.Lookup(domain.UnavailableTemplate) != nil {
+		name = domain.UnavailableTemplate
+	}
+
+	if shortened.LinkStatus() == entity.LinkStatusBlocked {
+		w.WriteHeader(http.StatusForbidden)
+	} else if shortened.IsExpired() {
+		w.WriteHeader(http.StatusGone)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err = routes.template.ExecuteTemplate(w, name, shortened)
+
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// renderNotFound renders the not found page of the requested domain
+func (routes *Routes) renderNotFound(w http.ResponseWriter, r *http.Request) {
+	name := "404.html"
+
+	domain, err := routes.domainService.ResolveDomain(r.Context(), r.Host)
+	if err == nil && domain.NotFoundTemplate != "" && routes.template.Lookup(domain.NotFoundTemplate) != nil {
+		name = domain.NotFoundTemplate
+	}
+
+	err = routes.template.ExecuteTemplate(w, name, nil)
+
+	if err != nil {
+		log.Print(err)
+	}
 }
 
 func (routes *Routes) Index() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		err := routes.template.ExecuteTemplate(w, "index.html", nil)
+		domain, err := routes.domainService.ResolveDomain(r.Context(), r.Host)
+		if err == nil && domain.RootRedirectURL != "" {
+			http.Redirect(w, r, domain.RootRedirectURL, http.StatusFound)
+			return
+		}
+
+		domains, err := routes.domainService.ListDomains(r.Context())
 
 		if err != nil {
 			log.Print(err)
 		}
-	}
-}
 
-func (routes *Routes) NotFound() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := routes.template.ExecuteTemplate(w, "404.html", nil)
+		principal, _ := services.PrincipalFromContext(r.Context())
+
+		err = routes.template.ExecuteTemplate(w, "index.html", indexPage{
+			Domains:       domains,
+			DefaultDomain: routes.domainService.DefaultDomain(),
+			Principal:     principal,
+		})
 
 		if err != nil {
 			log.Print(err)
@@ -39,14 +218,30 @@ func (routes *Routes) NotFound() http.HandlerFunc {
 	}
 }
 
+func (routes *Routes) NotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routes.renderNotFound(w, r)
+	}
+}
+
 func (routes *Routes) ShortenURL() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		originalURL := r.FormValue("originalURL")
+		domain := requestDomain(r, routes.domainService)
 
-		shortenedURL, err := routes.service.ShortenURL(ctx, originalURL)
+		var shortenedURL *entity.ShortenedURL
+		_, err := routes.domainService.GetDomain(ctx, domain)
+		if err == nil {
+			shortenedURL, err = routes.service.ShortenURL(ctx, domain, originalURL)
+		}
+
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
 
 		if err != nil {
 			log.Print(err)
@@ -67,10 +262,38 @@ func (routes *Routes) RedirectURL() httprouter.Handle {
 
 		shortCode := ps.ByName("shortCode")
 
-		// Get the original URL from the service
-		shortenedURL, err := routes.service.GetByShortCode(ctx, shortCode)
+		// links are never served from domains whose ownership is not verified
+		domain, err := routes.domainService.ResolveDomain(ctx, r.Host)
 		if err != nil {
-			err := routes.template.ExecuteTemplate(w, "404.html", nil)
+			routes.renderNotFound(w, r)
+
+			return
+		}
+
+		// Get the original URL from the service, short codes are resolved within the requested domain
+		shortenedURL, err := routes.service.GetByShortCode(ctx, domain.Name, shortCode)
+		if err != nil {
+			routes.renderNotFound(w, r)
+
+			return
+		}
+
+		// paused and expired links keep their code and clicks but go nowhere, crawlers included
+		if !shortenedURL.IsActive() || shortenedURL.IsExpired() {
+			routes.renderUnavailable(w, r, shortenedURL)
+
+			return
+		}
+
+		// Redirect to the original URL, or to the fallback while the primary is down
+		destination, fallback := shortenedURL.Destination()
+
+		// link preview crawlers get the custom card instead of a redirect
+		if shortenedURL.OpenGraph != nil && util.IsSocialCrawler(r.UserAgent()) {
+			err := routes.template.ExecuteTemplate(w, "opengraph.html", openGraphPage{
+				Link:        shortenedURL,
+				Destination: destination,
+			})
 
 			if err != nil {
 				log.Print(err)
@@ -79,10 +302,24 @@ func (routes *Routes) RedirectURL() httprouter.Handle {
 			return
 		}
 
-		// Redirect to the original URL
-		log.Printf("redirecting to %s from %s\n", shortenedURL.OriginalURL, shortenedURL.ShortenedURL)
+		if fallback {
+			log.Printf("primary of %s is down, redirecting to fallback %s\n", shortenedURL.ShortCode, destination)
+		} else {
+			log.Printf("redirecting to %s from %s\n", destination, shortenedURL.ShortenedURL)
+		}
+
+		err = routes.service.RecordClick(ctx, entity.Click{
+			Domain:    shortenedURL.Domain,
+			ShortCode: shortenedURL.ShortCode,
+			Fallback:  fallback,
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+		if err != nil {
+			log.Print(err)
+		}
 
-		http.Redirect(w, r, shortenedURL.OriginalURL, http.StatusSeeOther)
+		http.Redirect(w, r, destination, http.StatusSeeOther)
 
 		return
 	}
@@ -90,13 +327,57 @@ func (routes *Routes) RedirectURL() httprouter.Handle {
 
 func (routes *Routes) ListShortenedURLs() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		shortenedURLs, err := routes.service.ListShortenedURLs(r.Context())
+		page := listPage{Workspace: services.WorkspaceFromContext(r.Context()), Query: r.URL.Query()}
 
+		query, err := requestLinkQuery(r)
+		var links *entity.LinkPage
+		if err == nil {
+			links, err = routes.service.ListShortenedURLs(r.Context(), query)
+		}
+
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if errors.Is(err, constants.ErrorInvalidRequest) {
+			page.Error = err.Error()
+		} else if err != nil {
+			log.Print(err)
+		}
+
+		if first := r.URL.Query(); first.Has("cursor") {
+			first.Del("cursor")
+			page.FirstPage = "/shorten-url?" + first.Encode()
+		}
+
+		if links != nil {
+			page.Links = &links.Links
+
+			if links.NextCursor != "" {
+				next := r.URL.Query()
+				next.Set("cursor", links.NextCursor)
+				page.NextPage = "/shorten-url?" + next.Encode()
+			}
+		}
+
+		if principal, ok := services.PrincipalFromContext(r.Context()); ok && page.Workspace == "" {
+			page.Workspace = entity.PersonalWorkspaceID(principal.UserID)
+		}
+
+		page.Workspaces, err = routes.workspaceService.ListWorkspaces(r.Context())
 		if err != nil {
 			log.Print(err)
+		} else {
+			for _, workspace := range *page.Workspaces {
+				if workspace.ID == page.Workspace {
+					page.CanEdit = entity.RoleAtLeast(workspace.Role, entity.RoleEditor)
+					page.CanManage = entity.RoleAtLeast(workspace.Role, entity.RoleAdmin)
+				}
+			}
 		}
 
-		err = routes.template.ExecuteTemplate(w, "list.html", shortenedURLs)
+		err = routes.template.ExecuteTemplate(w, "list.html", page)
 
 		if err != nil {
 			log.Print(err)
@@ -107,10 +388,10 @@ func (routes *Routes) ListShortenedURLs() httprouter.Handle {
 func (routes *Routes) DeleteShortenedURL() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		shortCode := p.ByName("shortCode")
-		err := routes.service.DeleteShortenedURL(r.Context(), shortCode)
+		err := routes.service.DeleteShortenedURL(r.Context(), requestDomain(r, routes.domainService), shortCode)
 
 		if err != nil {
-			log.Print(err)
+			writeStatus(w, err)
 		}
 	}
 }
@@ -119,11 +400,79 @@ func (routes *Routes) UpdateShortenedURL() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		shortCode := p.ByName("shortCode")
 		newOriginalURL := r.FormValue("newOriginalURL")
+		domain := requestDomain(r, routes.domainService)
 
-		_, err := routes.service.UpdateShortenedURL(r.Context(), shortCode, newOriginalURL)
+		_, err := routes.service.UpdateShortenedURL(r.Context(), domain, shortCode, newOriginalURL)
+
+		if err != nil {
+			writeStatus(w, err)
+			return
+		}
+
+		// fallback URL is optional, an empty value clears it
+		if _, ok := r.Form["fallbackURL"]; ok {
+			_, err = routes.service.UpdateFallbackURL(r.Context(), domain, shortCode, r.FormValue("fallbackURL"))
+
+			if err != nil {
+				writeStatus(w, err)
+			}
+		}
+	}
+}
+
+// Trash renders the deleted links of the selected workspace.
+func (routes *Routes) Trash() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		trash, err := routes.service.ListTrash(r.Context())
+
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if err != nil {
+			log.Print(err)
+		}
+
+		page := trashPage{Links: trash, Workspace: services.WorkspaceFromContext(r.Context())}
+		if principal, ok := services.PrincipalFromContext(r.Context()); ok && page.Workspace == "" {
+			page.Workspace = entity.PersonalWorkspaceID(principal.UserID)
+		}
+
+		_, err = routes.workspaceService.RequireRole(r.Context(), page.Workspace, entity.RoleEditor)
+		page.CanEdit = err == nil
+
+		err = routes.template.ExecuteTemplate(w, "trash.html", page)
 
 		if err != nil {
 			log.Print(err)
 		}
 	}
 }
+
+// SwitchWorkspace remembers the workspace picked in the switcher for the following requests.
+func (routes *Routes) SwitchWorkspace() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		workspace := r.FormValue("workspace")
+
+		_, err := routes.workspaceService.RequireRole(r.Context(), workspace, entity.RoleViewer)
+		if errors.Is(err, constants.ErrorUnauthorized) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if err != nil {
+			writeStatus(w, err)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     workspaceCookieName,
+			Value:    workspace,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, "/shorten-url", http.StatusSeeOther)
+	}
+}
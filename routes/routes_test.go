@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"html/template"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ilhamtubagus/shortenurl/constants"
 	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,35 +23,293 @@ type MockShortenedService struct {
 	mock.Mock
 }
 
-func (m *MockShortenedService) ShortenURL(ctx context.Context, originalURL string) (*entity.ShortenedURL, error) {
-	args := m.Called(ctx, originalURL)
+func (m *MockShortenedService) ShortenURL(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, originalURL)
 	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedService) GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error) {
-	args := m.Called(ctx, shortcode)
+func (m *MockShortenedService) GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
 	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedService) ListShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error) {
+func (m *MockShortenedService) BulkShorten(ctx context.Context, domain string, rows []entity.BulkLink) (*[]entity.BulkResult, error) {
+	args := m.Called(ctx, domain, rows)
+	return args.Get(0).(*[]entity.BulkResult), args.Error(1)
+}
+
+func (m *MockShortenedService) BulkUpdate(ctx context.Context, operation entity.BulkOperation) (*entity.BulkOperationResult, error) {
+	args := m.Called(ctx, operation)
+	return args.Get(0).(*entity.BulkOperationResult), args.Error(1)
+}
+
+func (m *MockShortenedService) RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error) {
+	args := m.Called(ctx, rewrite)
+	return args.Get(0).(*entity.DestinationRewriteResult), args.Error(1)
+}
+
+func (m *MockShortenedService) ImportLinks(ctx context.Context, domain string, rows []entity.ImportLink) (*[]entity.ImportResult, error) {
+	args := m.Called(ctx, domain, rows)
+	return args.Get(0).(*[]entity.ImportResult), args.Error(1)
+}
+
+func (m *MockShortenedService) GetLink(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) ListShortenedURLs(ctx context.Context, query entity.LinkQuery) (*entity.LinkPage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*entity.LinkPage), args.Error(1)
+}
+
+func (m *MockShortenedService) DeleteShortenedURL(ctx context.Context, domain string, shortcode string) error {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Error(0)
+}
+
+func (m *MockShortenedService) UpdateShortenedURL(ctx context.Context, domain string, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, originalURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateFallbackURL(ctx context.Context, domain string, shortcode string, fallbackURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, fallbackURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) RecordClick(ctx context.Context, click entity.Click) error {
+	args := m.Called(ctx, click)
+	return args.Error(0)
+}
+
+func (m *MockShortenedService) RefreshMetadata(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateOpenGraph(ctx context.Context, domain string, shortcode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, openGraph)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateStatus(ctx context.Context, domain string, shortcode string, status string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, status)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) UpdateDetails(ctx context.Context, domain string, shortcode string, details entity.LinkDetails) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, details)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedService) GetLinkStats(ctx context.Context, domain string, shortcode string) (*entity.LinkStats, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.LinkStats), args.Error(1)
+}
+
+func (m *MockShortenedService) ListRevisions(ctx context.Context, domain string, shortcode string) (*[]entity.Revision, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*[]entity.Revision), args.Error(1)
+}
+
+func (m *MockShortenedService) ListTrash(ctx context.Context) (*[]entity.ShortenedURL, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedService) DeleteShortenedURL(ctx context.Context, shortcode string) error {
-	args := m.Called(ctx, shortcode)
-	return args.Error(0)
+func (m *MockShortenedService) RestoreShortenedURL(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedService) UpdateShortenedURL(ctx context.Context, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
-	args := m.Called(ctx, shortcode, originalURL)
+func (m *MockShortenedService) RollbackShortenedURL(ctx context.Context, domain string, shortcode string, revisionID string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, revisionID)
 	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
+// MockDomainService is a mock of the DomainService interface
+type MockDomainService struct {
+	mock.Mock
+}
+
+func (m *MockDomainService) DefaultDomain() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockDomainService) EnsureDefaultDomain(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockDomainService) GetDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainService) ResolveDomain(ctx context.Context, host string) (*entity.Domain, error) {
+	args := m.Called(ctx, host)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainService) CheckLinkDomain(ctx context.Context, name string, workspaceID string) error {
+	args := m.Called(ctx, name, workspaceID)
+	return args.Error(0)
+}
+
+func (m *MockDomainService) ListDomains(ctx context.Context) (*[]entity.Domain, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*[]entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainService) CreateDomain(ctx context.Context, domain entity.Domain) (*entity.Domain, error) {
+	args := m.Called(ctx, domain)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainService) UpdateDomain(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error) {
+	args := m.Called(ctx, name, rootRedirectURL, notFoundTemplate, unavailableTemplate)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainService) VerifyDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+var shortURLDomain = &entity.Domain{
+	Name:         "short.url",
+	Verification: entity.DomainVerification{Status: entity.DomainStatusVerified},
+}
+
+// MockWorkspaceService is a mock of the WorkspaceService interface
+type MockWorkspaceService struct {
+	mock.Mock
+}
+
+func (m *MockWorkspaceService) ListWorkspaces(ctx context.Context) (*[]entity.WorkspaceMembership, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*[]entity.WorkspaceMembership), args.Error(1)
+}
+
+func (m *MockWorkspaceService) CreateWorkspace(ctx context.Context, id string, name string) (*entity.WorkspaceMembership, error) {
+	args := m.Called(ctx, id, name)
+	return args.Get(0).(*entity.WorkspaceMembership), args.Error(1)
+}
+
+func (m *MockWorkspaceService) ListMembers(ctx context.Context, workspaceID string) (*[]entity.Membership, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).(*[]entity.Membership), args.Error(1)
+}
+
+func (m *MockWorkspaceService) AddMember(ctx context.Context, workspaceID string, email string, role string) (*entity.Membership, error) {
+	args := m.Called(ctx, workspaceID, email, role)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+func (m *MockWorkspaceService) UpdateMemberRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error) {
+	args := m.Called(ctx, workspaceID, userID, role)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+func (m *MockWorkspaceService) RemoveMember(ctx context.Context, workspaceID string, userID string) error {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceService) SetTwoFactorRequired(ctx context.Context, workspaceID string, required bool) (*entity.Workspace, error) {
+	args := m.Called(ctx, workspaceID, required)
+	return args.Get(0).(*entity.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceService) RequireRole(ctx context.Context, workspaceID string, role string) (*entity.Membership, error) {
+	args := m.Called(ctx, workspaceID, role)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+// MockAuditService is a mock of the AuditService interface
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, action string, before *entity.ShortenedURL, after *entity.ShortenedURL) error {
+	args := m.Called(ctx, action, before, after)
+	return args.Error(0)
+}
+
+func (m *MockAuditService) ListLinkAudit(ctx context.Context, domain string, shortcode string, limit int) (*[]entity.AuditEntry, error) {
+	args := m.Called(ctx, domain, shortcode, limit)
+	return args.Get(0).(*[]entity.AuditEntry), args.Error(1)
+}
+
+func (m *MockAuditService) ListWorkspaceAudit(ctx context.Context, workspaceID string, limit int) (*[]entity.AuditEntry, error) {
+	args := m.Called(ctx, workspaceID, limit)
+	return args.Get(0).(*[]entity.AuditEntry), args.Error(1)
+}
+
+type MockImportService struct {
+	mock.Mock
+}
+
+func (m *MockImportService) CreateImport(ctx context.Context, domain string, format string, name string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	args := m.Called(ctx, domain, format, name, body)
+	return args.Get(0).(*entity.ImportJob), args.Get(1).([]entity.ImportLink), args.Error(2)
+}
+
+func (m *MockImportService) ResumeImport(ctx context.Context, id string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	args := m.Called(ctx, id, body)
+	return args.Get(0).(*entity.ImportJob), args.Get(1).([]entity.ImportLink), args.Error(2)
+}
+
+func (m *MockImportService) RunImport(ctx context.Context, job *entity.ImportJob, links []entity.ImportLink) (*entity.ImportJob, error) {
+	args := m.Called(ctx, job, links)
+	return args.Get(0).(*entity.ImportJob), args.Error(1)
+}
+
+func (m *MockImportService) GetImport(ctx context.Context, id string) (*entity.ImportJob, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.ImportJob), args.Error(1)
+}
+
+type MockExportService struct {
+	mock.Mock
+}
+
+// ExportLinks writes the output the mock returns
+func (m *MockExportService) ExportLinks(ctx context.Context, format string, query entity.LinkQuery, w io.Writer) error {
+	args := m.Called(ctx, format, query)
+	if args.String(0) != "" {
+		io.WriteString(w, args.String(0))
+	}
+	return args.Error(1)
+}
+
+func (m *MockExportService) ExportClicks(ctx context.Context, format string, query entity.ClickQuery, w io.Writer) error {
+	args := m.Called(ctx, format, query)
+	if args.String(0) != "" {
+		io.WriteString(w, args.String(0))
+	}
+	return args.Error(1)
+}
+
+// newMockDomainService returns a domain service that only knows the default short.url domain
+func newMockDomainService() *MockDomainService {
+	mockDomainService := new(MockDomainService)
+	mockDomainService.On("DefaultDomain").Return("short.url")
+	mockDomainService.On("GetDomain", mock.Anything, "short.url").Return(shortURLDomain, nil)
+	mockDomainService.On("GetDomain", mock.Anything, mock.Anything).Return((*entity.Domain)(nil), constants.ErrorNotFound)
+	mockDomainService.On("ResolveDomain", mock.Anything, "short.url").Return(shortURLDomain, nil)
+	mockDomainService.On("ResolveDomain", mock.Anything, mock.Anything).Return((*entity.Domain)(nil), constants.ErrorNotFound)
+	mockDomainService.On("ListDomains", mock.Anything).Return(&[]entity.Domain{*shortURLDomain}, nil)
+
+	return mockDomainService
+}
+
 func TestRoutes_Index(t *testing.T) {
 	tmpl := template.Must(template.New("index").Parse("Index Page"))
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(tmpl, mockService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
 
 	req, _ := http.NewRequest("GET", "/", nil)
 	rr := httptest.NewRecorder()
@@ -63,7 +325,7 @@ func TestRoutes_Index(t *testing.T) {
 func TestRoutes_NotFound(t *testing.T) {
 	tmpl := template.Must(template.New("404.html").Parse("404 Not Found"))
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(tmpl, mockService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
 
 	req, _ := http.NewRequest("GET", "/notfound", nil)
 	rr := httptest.NewRecorder()
@@ -78,9 +340,9 @@ func TestRoutes_NotFound(t *testing.T) {
 func TestRoutes_ShortenURL(t *testing.T) {
 	tmpl := template.Must(template.New("shorten.html").Parse("Shortened: {{.ShortenedURL}}"))
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(tmpl, mockService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
 
-	mockService.On("ShortenURL", mock.Anything, "https://example.com").Return(&entity.ShortenedURL{
+	mockService.On("ShortenURL", mock.Anything, "short.url", "https://example.com").Return(&entity.ShortenedURL{
 		OriginalURL:  "https://example.com",
 		ShortCode:    "abc123",
 		ShortenedURL: "http://short.url/abc123",
@@ -101,15 +363,16 @@ func TestRoutes_ShortenURL(t *testing.T) {
 func TestRoutes_RedirectURL(t *testing.T) {
 	tmpl := template.Must(template.New("404.html").Parse("404 Not Found"))
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(tmpl, mockService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
 
-	mockService.On("GetByShortCode", mock.Anything, "abc123").Return(&entity.ShortenedURL{
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{
 		OriginalURL:  "https://example.com",
 		ShortCode:    "abc123",
 		ShortenedURL: "http://short.url/abc123",
 	}, nil)
+	mockService.On("RecordClick", mock.Anything, mock.AnythingOfType("entity.Click")).Return(nil)
 
-	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req, _ := http.NewRequest("GET", "http://short.url/abc123", nil)
 	rr := httptest.NewRecorder()
 
 	router := httprouter.New()
@@ -120,33 +383,200 @@ func TestRoutes_RedirectURL(t *testing.T) {
 	assert.Equal(t, "https://example.com", rr.Header().Get("Location"))
 }
 
+func TestRoutes_RedirectURL_Fallback(t *testing.T) {
+	tmpl := template.Must(template.New("404.html").Parse("404 Not Found"))
+	mockService := new(MockShortenedService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
+
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{
+		OriginalURL:  "https://example.com",
+		FallbackURL:  "https://fallback.com",
+		PrimaryDown:  true,
+		ShortCode:    "abc123",
+		ShortenedURL: "http://short.url/abc123",
+	}, nil)
+	mockService.On("RecordClick", mock.Anything, mock.MatchedBy(func(click entity.Click) bool {
+		return click.ShortCode == "abc123" && click.Fallback
+	})).Return(nil)
+
+	req, _ := http.NewRequest("GET", "http://short.url/abc123", nil)
+	rr := httptest.NewRecorder()
+
+	router := httprouter.New()
+	router.GET("/:shortCode", routes.RedirectURL())
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "https://fallback.com", rr.Header().Get("Location"))
+	mockService.AssertExpectations(t)
+}
+
+func TestRoutes_RedirectURL_SocialCrawler(t *testing.T) {
+	tmpl := template.Must(template.New("opengraph.html").Parse(`{{.Link.OpenGraph.Title}} {{.Destination}}`))
+	mockService := new(MockShortenedService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
+
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "abc123").Return(&entity.ShortenedURL{
+		OriginalURL:  "https://example.com",
+		ShortCode:    "abc123",
+		ShortenedURL: "http://short.url/abc123",
+		OpenGraph:    &entity.OpenGraph{Title: "Launch Week"},
+	}, nil)
+	mockService.On("RecordClick", mock.Anything, mock.AnythingOfType("entity.Click")).Return(nil)
+
+	router := httprouter.New()
+	router.GET("/:shortCode", routes.RedirectURL())
+
+	req, _ := http.NewRequest("GET", "http://short.url/abc123", nil)
+	req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Launch Week https://example.com", rr.Body.String())
+	mockService.AssertNotCalled(t, "RecordClick", mock.Anything, mock.Anything)
+
+	req, _ = http.NewRequest("GET", "http://short.url/abc123", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "https://example.com", rr.Header().Get("Location"))
+}
+
+func TestRoutes_RedirectURL_Unavailable(t *testing.T) {
+	tmpl := template.Must(template.New("unavailable.html").Parse("Unavailable {{.LinkStatus}}"))
+	mockService := new(MockShortenedService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), nil, nil)
+
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "paused").Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "paused",
+		Status:      entity.LinkStatusDisabled,
+	}, nil)
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "blocked").Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "blocked",
+		Status:      entity.LinkStatusBlocked,
+	}, nil)
+	expiredAt := time.Now().Add(-time.Minute)
+	mockService.On("GetByShortCode", mock.Anything, "short.url", "expired").Return(&entity.ShortenedURL{
+		OriginalURL: "https://example.com",
+		ShortCode:   "expired",
+		ExpiresAt:   &expiredAt,
+	}, nil)
+
+	router := httprouter.New()
+	router.GET("/:shortCode", routes.RedirectURL())
+
+	req, _ := http.NewRequest("GET", "http://short.url/paused", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "Unavailable disabled", rr.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://short.url/blocked", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "Unavailable blocked-by-admin", rr.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://short.url/expired", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	mockService.AssertNotCalled(t, "RecordClick", mock.Anything, mock.Anything)
+}
+
 func TestRoutes_ListShortenedURLs(t *testing.T) {
-	tmpl := template.Must(template.New("list.html").Parse("{{range .}}{{.ShortenedURL}}\n{{end}}"))
+	tmpl := template.Must(template.New("list.html").Parse(
+		"{{range .Workspaces}}{{.ID}}{{if eq .ID $.Workspace}}*{{end}} {{end}}{{.CanEdit}}\n{{range .Links}}{{.ShortenedURL}}\n{{end}}{{.NextPage}}"))
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(tmpl, mockService)
+	mockWorkspaceService := new(MockWorkspaceService)
+	routes := NewRoutes(tmpl, mockService, newMockDomainService(), mockWorkspaceService, nil)
 
-	mockURLs := &[]entity.ShortenedURL{
+	mockURLs := &entity.LinkPage{Links: []entity.ShortenedURL{
 		{OriginalURL: "https://example1.com", ShortCode: "abc123", ShortenedURL: "http://short.url/abc123"},
 		{OriginalURL: "https://example2.com", ShortCode: "def456", ShortenedURL: "http://short.url/def456"},
-	}
-	mockService.On("ListShortenedURLs", mock.Anything).Return(mockURLs, nil)
+	}}
+	mockService.On("ListShortenedURLs", mock.Anything, entity.LinkQuery{}).Return(mockURLs, nil)
+	mockService.On("ListShortenedURLs", mock.Anything, entity.LinkQuery{Sort: "-clicks", Limit: 2}).Return(&entity.LinkPage{
+		Links:      mockURLs.Links,
+		NextCursor: "next",
+	}, nil)
+	mockWorkspaceService.On("ListWorkspaces", mock.Anything).Return(&[]entity.WorkspaceMembership{
+		{Workspace: entity.Workspace{ID: "acme", Name: "Acme"}, Role: entity.RoleViewer},
+		{Workspace: entity.Workspace{ID: "personal-user-1", Name: "Personal"}, Role: entity.RoleOwner},
+	}, nil)
+
+	router := httprouter.New()
+	router.GET("/list", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		r = r.WithContext(services.WithPrincipal(r.Context(), &entity.Principal{UserID: "user-1"}))
+		routes.ListShortenedURLs()(w, selectWorkspace(r), p)
+	})
 
 	req, _ := http.NewRequest("GET", "/list", nil)
 	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "acme personal-user-1* true\nhttp://short.url/abc123\nhttp://short.url/def456\n", rr.Body.String())
+
+	// viewers get no edit actions
+	req, _ = http.NewRequest("GET", "/list", nil)
+	req.AddCookie(&http.Cookie{Name: workspaceCookieName, Value: "acme"})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, "acme* personal-user-1 false\nhttp://short.url/abc123\nhttp://short.url/def456\n", rr.Body.String())
+
+	// the next page keeps the sort order and page size
+	req, _ = http.NewRequest("GET", "/list?sort=-clicks&limit=2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, "acme personal-user-1* true\nhttp://short.url/abc123\nhttp://short.url/def456\n"+
+		"/shorten-url?cursor=next&amp;limit=2&amp;sort=-clicks", rr.Body.String())
+}
+
+func TestRoutes_SwitchWorkspace(t *testing.T) {
+	mockWorkspaceService := new(MockWorkspaceService)
+	routes := NewRoutes(nil, new(MockShortenedService), newMockDomainService(), mockWorkspaceService, nil)
+
+	mockWorkspaceService.On("RequireRole", mock.Anything, "acme", entity.RoleViewer).Return(&entity.Membership{WorkspaceID: "acme"}, nil)
+	mockWorkspaceService.On("RequireRole", mock.Anything, "initech", entity.RoleViewer).Return((*entity.Membership)(nil), constants.ErrorNotFound)
 
 	router := httprouter.New()
-	router.GET("/list", routes.ListShortenedURLs())
+	router.POST("/workspace", routes.SwitchWorkspace())
+
+	req, _ := http.NewRequest("POST", "/workspace", bytes.NewBufferString("workspace=acme"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, "http://short.url/abc123\nhttp://short.url/def456\n", rr.Body.String())
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	if cookies := rr.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, "acme", cookies[0].Value)
+	}
+
+	req, _ = http.NewRequest("POST", "/workspace", bytes.NewBufferString("workspace=initech"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Result().Cookies())
 }
 
 func TestRoutes_DeleteShortenedURL(t *testing.T) {
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(nil, mockService)
+	routes := NewRoutes(nil, mockService, newMockDomainService(), nil, nil)
 
-	mockService.On("DeleteShortenedURL", mock.Anything, "abc123").Return(nil)
+	mockService.On("DeleteShortenedURL", mock.Anything, "short.url", "abc123").Return(nil)
 
 	req, _ := http.NewRequest("DELETE", "/abc123", nil)
 	rr := httptest.NewRecorder()
@@ -160,9 +590,9 @@ func TestRoutes_DeleteShortenedURL(t *testing.T) {
 
 func TestRoutes_UpdateShortenedURL(t *testing.T) {
 	mockService := new(MockShortenedService)
-	routes := NewRoutes(nil, mockService)
+	routes := NewRoutes(nil, mockService, newMockDomainService(), nil, nil)
 
-	mockService.On("UpdateShortenedURL", mock.Anything, "abc123", "https://newexample.com").Return(&entity.ShortenedURL{
+	mockService.On("UpdateShortenedURL", mock.Anything, "short.url", "abc123", "https://newexample.com").Return(&entity.ShortenedURL{
 		OriginalURL:  "https://newexample.com",
 		ShortCode:    "abc123",
 		ShortenedURL: "http://short.url/abc123",
@@ -178,3 +608,94 @@ func TestRoutes_UpdateShortenedURL(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 }
+
+func TestRoutes_BrandedDomains(t *testing.T) {
+	tmpl := template.Must(template.New("404.html").Parse("404 Not Found"))
+	template.Must(tmpl.New("404-acme.html").Parse("Acme 404"))
+	template.Must(tmpl.New("index.html").Parse("Index Page"))
+	mockService := new(MockShortenedService)
+	mockDomainService := new(MockDomainService)
+	routes := NewRoutes(tmpl, mockService, mockDomainService, nil, nil)
+
+	mockDomainService.On("DefaultDomain").Return("short.url")
+	mockDomainService.On("ResolveDomain", mock.Anything, "GO.ACME.COM:443").Return(&entity.Domain{Name: "go.acme.com"}, nil)
+	mockDomainService.On("ResolveDomain", mock.Anything, "go.acme.com").Return(&entity.Domain{
+		Name:             "go.acme.com",
+		RootRedirectURL:  "https://acme.com",
+		NotFoundTemplate: "404-acme.html",
+	}, nil)
+	mockDomainService.On("ResolveDomain", mock.Anything, "short.url").Return(shortURLDomain, nil)
+	// pending domains never resolve, even when a link exists on them
+	mockDomainService.On("ResolveDomain", mock.Anything, "pending.link").Return((*entity.Domain)(nil), constants.ErrorNotFound)
+	mockDomainService.On("ListDomains", mock.Anything).Return(&[]entity.Domain{{Name: "short.url"}, {Name: "go.acme.com"}}, nil)
+	mockService.On("GetByShortCode", mock.Anything, "pending.link", "q3deck").Return(&entity.ShortenedURL{
+		Domain:      "pending.link",
+		OriginalURL: "https://pending.link/q3",
+		ShortCode:   "q3deck",
+	}, nil)
+	mockService.On("GetByShortCode", mock.Anything, "go.acme.com", "q3deck").Return(&entity.ShortenedURL{
+		Domain:      "go.acme.com",
+		OriginalURL: "https://acme.com/decks/q3",
+		ShortCode:   "q3deck",
+	}, nil)
+	mockService.On("GetByShortCode", mock.Anything, mock.Anything, mock.Anything).Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+	mockService.On("RecordClick", mock.Anything, mock.AnythingOfType("entity.Click")).Return(nil)
+
+	router := httprouter.New()
+	router.GET("/", routes.Index())
+	router.GET("/s/:shortCode", routes.RedirectURL())
+
+	tests := []struct {
+		name     string
+		url      string
+		code     int
+		location string
+		body     string
+	}{
+		{"RootRedirect", "http://go.acme.com/", http.StatusFound, "https://acme.com", ""},
+		{"DefaultDomainIndex", "http://short.url/", http.StatusOK, "", "Index Page"},
+		{"ResolvedByHost", "http://GO.ACME.COM:443/s/q3deck", http.StatusSeeOther, "https://acme.com/decks/q3", ""},
+		{"CustomNotFound", "http://go.acme.com/s/missing", http.StatusOK, "", "Acme 404"},
+		{"DefaultNotFound", "http://short.url/s/q3deck", http.StatusOK, "", "404 Not Found"},
+		{"PendingDomain", "http://pending.link/s/q3deck", http.StatusOK, "", "404 Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			assert.Equal(t, tt.location, rr.Header().Get("Location"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoutes_SignedOut(t *testing.T) {
+	mockService := new(MockShortenedService)
+	routes := NewRoutes(nil, mockService, newMockDomainService(), nil, nil)
+
+	mockService.On("ListShortenedURLs", mock.Anything, mock.Anything).Return((*entity.LinkPage)(nil), constants.ErrorUnauthorized)
+	mockService.On("DeleteShortenedURL", mock.Anything, "short.url", "abc123").Return(constants.ErrorUnauthorized)
+
+	router := httprouter.New()
+	router.GET("/shorten-url", routes.ListShortenedURLs())
+	router.DELETE("/:shortCode", routes.DeleteShortenedURL())
+
+	req, _ := http.NewRequest("GET", "/shorten-url", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/login", rr.Header().Get("Location"))
+
+	req, _ = http.NewRequest("DELETE", "/abc123", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
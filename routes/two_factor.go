@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/ilhamtubagus/shortenurl/util"
+	"github.com/julienschmidt/httprouter"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// securityPage shows the two-factor settings of the signed-in user, along the enrollment
+// in progress or the recovery codes just generated.
+type securityPage struct {
+	TwoFactorEnabled bool
+	Setup            *entity.TwoFactorSetup
+	QRCode           template.HTML
+	RecoveryCodes    []string
+	Error            string
+}
+
+// VerifyLoginPage asks for the code of the second factor after the password was checked.
+func (routes *AuthRoutes) VerifyLoginPage() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		routes.render(w, "login_verify.html", authPage{})
+	}
+}
+
+func (routes *AuthRoutes) VerifyLogin() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || cookie.Value == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		token, _, err := routes.userService.VerifyLogin(r.Context(), cookie.Value, r.FormValue("code"))
+		if err != nil {
+			if !errors.Is(err, constants.ErrorUnauthorized) {
+				log.Print(err)
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+			routes.render(w, "login_verify.html", authPage{Error: "Invalid code, sign in again if it keeps failing."})
+
+			return
+		}
+
+		routes.setSessionCookie(w, token, int(routes.sessionTTL.Seconds()))
+		http.Redirect(w, r, "/shorten-url", http.StatusSeeOther)
+	}
+}
+
+// renderSecurity renders the security page, reporting err when a change failed.
+func (routes *AuthRoutes) renderSecurity(w http.ResponseWriter, r *http.Request, page securityPage, err error) {
+	principal, ok := services.PrincipalFromContext(r.Context())
+	if !ok || errors.Is(err, constants.ErrorUnauthorized) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if errors.Is(err, constants.ErrorInvalidRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+		page.Error = strings.TrimPrefix(err.Error(), constants.ErrorInvalidRequest.Error()+": ")
+	} else if errors.Is(err, constants.ErrorForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		page.Error = strings.TrimPrefix(err.Error(), constants.ErrorForbidden.Error()+": ")
+	} else if errors.Is(err, constants.ErrorAlreadyExists) {
+		w.WriteHeader(http.StatusConflict)
+		page.Error = "Two-factor authentication is already enabled."
+	} else if err != nil {
+		log.Print(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		page.Error = "Something went wrong, please try again."
+	}
+
+	page.TwoFactorEnabled = principal.TwoFactor || page.RecoveryCodes != nil
+
+	if page.Setup != nil {
+		// the QR code is drawn here, the secret never leaves the server for a third party
+		qrCode, err := util.QRCodeSVG(page.Setup.URI, 4)
+		if err != nil {
+			log.Print(err)
+		}
+		page.QRCode = template.HTML(qrCode)
+	}
+
+	routes.render(w, "security.html", page)
+}
+
+func (routes *AuthRoutes) SecurityPage() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		routes.renderSecurity(w, r, securityPage{}, nil)
+	}
+}
+
+func (routes *AuthRoutes) SetupTwoFactor() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		setup, err := routes.userService.SetupTwoFactor(r.Context())
+
+		routes.renderSecurity(w, r, securityPage{Setup: setup}, err)
+	}
+}
+
+func (routes *AuthRoutes) EnableTwoFactor() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		codes, err := routes.userService.EnableTwoFactor(r.Context(), r.FormValue("code"))
+
+		routes.renderSecurity(w, r, securityPage{RecoveryCodes: codes}, err)
+	}
+}
+
+func (routes *AuthRoutes) DisableTwoFactor() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		err := routes.userService.DisableTwoFactor(r.Context(), r.FormValue("code"))
+		if err != nil {
+			routes.renderSecurity(w, r, securityPage{}, err)
+			return
+		}
+
+		http.Redirect(w, r, "/account/security", http.StatusSeeOther)
+	}
+}
+
+func (routes *AuthRoutes) RegenerateRecoveryCodes() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		codes, err := routes.userService.RegenerateRecoveryCodes(r.Context(), r.FormValue("code"))
+
+		routes.renderSecurity(w, r, securityPage{RecoveryCodes: codes}, err)
+	}
+}
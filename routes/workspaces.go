@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type createWorkspaceRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type workspaceSettingsRequest struct {
+	RequireTwoFactor bool `json:"requireTwoFactor"`
+}
+
+type memberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (routes *APIRoutes) ListWorkspaces() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		workspaces, err := routes.workspaceService.ListWorkspaces(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, workspaces)
+	}
+}
+
+func (routes *APIRoutes) CreateWorkspace() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request createWorkspaceRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		workspace, err := routes.workspaceService.CreateWorkspace(r.Context(), request.ID, request.Name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, workspace)
+	}
+}
+
+func (routes *APIRoutes) UpdateWorkspace() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request workspaceSettingsRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		workspace, err := routes.workspaceService.SetTwoFactorRequired(r.Context(), p.ByName("workspace"), request.RequireTwoFactor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, workspace)
+	}
+}
+
+func (routes *APIRoutes) ListMembers() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		members, err := routes.workspaceService.ListMembers(r.Context(), p.ByName("workspace"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, members)
+	}
+}
+
+func (routes *APIRoutes) AddMember() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request memberRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		member, err := routes.workspaceService.AddMember(r.Context(), p.ByName("workspace"), request.Email, request.Role)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, member)
+	}
+}
+
+func (routes *APIRoutes) UpdateMember() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request memberRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", constants.ErrorInvalidRequest, err))
+			return
+		}
+
+		member, err := routes.workspaceService.UpdateMemberRole(r.Context(), p.ByName("workspace"), p.ByName("userID"), request.Role)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, member)
+	}
+}
+
+func (routes *APIRoutes) RemoveMember() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		err := routes.workspaceService.RemoveMember(r.Context(), p.ByName("workspace"), p.ByName("userID"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/routes"
+	"github.com/ilhamtubagus/shortenurl/services"
+	"github.com/julienschmidt/httprouter"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serveCommand migrates the database, starts the background jobs and serves the web pages,
+// redirects and API until the server fails.
+func serveCommand(cfg *config.Config, args []string) error {
+	flags := newFlagSet("serve", "serve [-addr host:port]")
+	addr := flags.String("addr", fmt.Sprintf("%s:%s", cfg.Host, cfg.Port), "address to listen on, SERVICE_HOST:SERVICE_PORT by default")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	tmpl, err := template.New("").ParseGlob("./templates/*")
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx := context.Background()
+
+	err = a.migrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenService, err := a.newAccessTokenService(ctx)
+	if err != nil {
+		return err
+	}
+
+	healthChecker := services.NewHealthChecker(a.shortenedRepository,
+		time.Duration(cfg.Health.Interval)*time.Second,
+		time.Duration(cfg.Health.Timeout)*time.Second)
+	go healthChecker.Start(ctx)
+	go a.domainVerifier.Start(ctx)
+
+	trashPurger := services.NewTrashPurger(a.shortenedRepository, a.clickRepository, a.revisionRepository,
+		time.Duration(cfg.Trash.Retention)*time.Second,
+		time.Duration(cfg.Trash.PurgeInterval)*time.Second)
+	go trashPurger.Start(ctx)
+
+	router := a.newRouter(tmpl, tokenService)
+
+	log.Printf("server running on %s\n", *addr)
+
+	return http.ListenAndServe(*addr, router)
+}
+
+func (a *app) newRouter(tmpl *template.Template, tokenService services.AccessTokenService) *httprouter.Router {
+	router := httprouter.New()
+	routesDefs := routes.NewRoutes(tmpl, a.shortenedService, a.domainService, a.workspaceService, a.auditService)
+	authRoutes := routes.NewAuthRoutes(tmpl, a.userService, a.apiKeyService, tokenService, a.sessionTTL(), a.config.Protocol == "https")
+	authenticate := authRoutes.Authenticate
+
+	router.NotFound = http.HandlerFunc(routesDefs.NotFound())
+
+	router.GET("/", authenticate(routesDefs.Index()))
+	router.POST("/shorten-url", authenticate(routesDefs.ShortenURL()))
+	router.GET("/shorten-url", authenticate(routesDefs.ListShortenedURLs()))
+	router.DELETE("/:shortCode", authenticate(routesDefs.DeleteShortenedURL()))
+	router.PATCH("/:shortCode", authenticate(routesDefs.UpdateShortenedURL()))
+	router.GET("/s/:shortCode", routesDefs.RedirectURL())
+	router.GET("/trash", authenticate(routesDefs.Trash()))
+	router.GET("/bulk", authenticate(routesDefs.BulkPage()))
+	router.POST("/bulk", authenticate(routesDefs.BulkCreate()))
+
+	router.GET("/login", authRoutes.LoginPage())
+	router.POST("/login", authRoutes.Login())
+	router.GET("/register", authRoutes.RegisterPage())
+	router.POST("/register", authRoutes.Register())
+	router.GET("/login/verify", authRoutes.VerifyLoginPage())
+	router.POST("/login/verify", authRoutes.VerifyLogin())
+	router.POST("/logout", authRoutes.Logout())
+	router.GET("/account/security", authenticate(authRoutes.SecurityPage()))
+	router.POST("/account/security/two-factor/setup", authenticate(authRoutes.SetupTwoFactor()))
+	router.POST("/account/security/two-factor/enable", authenticate(authRoutes.EnableTwoFactor()))
+	router.POST("/account/security/two-factor/disable", authenticate(authRoutes.DisableTwoFactor()))
+	router.POST("/account/security/recovery-codes", authenticate(authRoutes.RegenerateRecoveryCodes()))
+	router.POST("/workspace", authenticate(routesDefs.SwitchWorkspace()))
+	router.GET("/audit", authenticate(routesDefs.AuditLog()))
+
+	apiRoutes := routes.NewAPIRoutes(a.shortenedService, a.domainService, a.apiKeyService, a.workspaceService, a.auditService)
+	importRoutes := routes.NewImportRoutes(a.importService, a.domainService)
+	exportRoutes := routes.NewExportRoutes(a.exportService)
+
+	router.GET("/api/v1/links", authenticate(apiRoutes.ListShortenedURLs()))
+	router.POST("/api/v1/links", authenticate(apiRoutes.CreateShortenedURL()))
+	router.GET("/api/v1/links/:shortCode", authenticate(apiRoutes.GetShortenedURL()))
+	router.PUT("/api/v1/links/:shortCode", authenticate(apiRoutes.UpdateShortenedURL()))
+	router.DELETE("/api/v1/links/:shortCode", authenticate(apiRoutes.DeleteShortenedURL()))
+	router.GET("/api/v1/links/:shortCode/stats", authenticate(apiRoutes.GetLinkStats()))
+	router.GET("/api/v1/links/:shortCode/audit", authenticate(apiRoutes.ListLinkAudit()))
+	router.GET("/api/v1/links/:shortCode/revisions", authenticate(apiRoutes.ListRevisions()))
+	router.POST("/api/v1/links/:shortCode/revisions/:revision/rollback", authenticate(apiRoutes.RollbackShortenedURL()))
+	router.POST("/api/v1/links/:shortCode/restore", authenticate(apiRoutes.RestoreShortenedURL()))
+	router.POST("/api/v1/links/:shortCode/metadata", authenticate(apiRoutes.RefreshMetadata()))
+	router.PUT("/api/v1/links/:shortCode/opengraph", authenticate(apiRoutes.UpdateOpenGraph()))
+	router.PUT("/api/v1/links/:shortCode/status", authenticate(apiRoutes.UpdateStatus()))
+	router.PUT("/api/v1/links/:shortCode/details", authenticate(apiRoutes.UpdateDetails()))
+	router.GET("/api/v1/trash", authenticate(apiRoutes.ListTrash()))
+	router.POST("/api/v1/bulk/links", authenticate(apiRoutes.BulkCreate()))
+	router.POST("/api/v1/bulk/operations", authenticate(apiRoutes.BulkUpdate()))
+	router.POST("/api/v1/bulk/destinations", authenticate(apiRoutes.RewriteDestinations()))
+	router.POST("/api/v1/imports", authenticate(importRoutes.CreateImport()))
+	router.GET("/api/v1/imports/:id", authenticate(importRoutes.GetImport()))
+	router.POST("/api/v1/imports/:id/resume", authenticate(importRoutes.ResumeImport()))
+	router.GET("/api/v1/exports/links", authenticate(exportRoutes.ExportLinks()))
+	router.GET("/api/v1/exports/clicks", authenticate(exportRoutes.ExportClicks()))
+	router.GET("/api/v1/domains", authenticate(apiRoutes.ListDomains()))
+	router.POST("/api/v1/domains", authenticate(apiRoutes.CreateDomain()))
+	router.PUT("/api/v1/domains/:domain", authenticate(apiRoutes.UpdateDomain()))
+	router.POST("/api/v1/domains/:domain/verify", authenticate(apiRoutes.VerifyDomain()))
+	router.GET("/api/v1/keys", authenticate(apiRoutes.ListAPIKeys()))
+	router.POST("/api/v1/keys", authenticate(apiRoutes.CreateAPIKey()))
+	router.POST("/api/v1/keys/:id/revoke", authenticate(apiRoutes.RevokeAPIKey()))
+
+	router.GET("/api/v1/workspaces", authenticate(apiRoutes.ListWorkspaces()))
+	router.POST("/api/v1/workspaces", authenticate(apiRoutes.CreateWorkspace()))
+	router.PUT("/api/v1/workspaces/:workspace", authenticate(apiRoutes.UpdateWorkspace()))
+	router.GET("/api/v1/workspaces/:workspace/audit", authenticate(apiRoutes.ListWorkspaceAudit()))
+	router.GET("/api/v1/workspaces/:workspace/members", authenticate(apiRoutes.ListMembers()))
+	router.POST("/api/v1/workspaces/:workspace/members", authenticate(apiRoutes.AddMember()))
+	router.PUT("/api/v1/workspaces/:workspace/members/:userID", authenticate(apiRoutes.UpdateMember()))
+	router.POST("/api/v1/workspaces/:workspace/members/:userID/remove", authenticate(apiRoutes.RemoveMember()))
+
+	return router
+}
@@ -9,7 +9,7 @@ import (
 	"log"
 )
 
-func ConnectMongoClient(mongoConfig config.MongoConfig) *mongo.Client {
+func ConnectMongoClient(mongoConfig config.MongoConfig) (*mongo.Client, error) {
 	connStr := fmt.Sprintf("mongodb://%s:%s@%s/%s?%s",
 		mongoConfig.User,
 		mongoConfig.Password,
@@ -23,16 +23,16 @@ func ConnectMongoClient(mongoConfig config.MongoConfig) *mongo.Client {
 
 	client, err := mongo.Connect(clientOptions)
 	if err != nil {
-		log.Fatalf("failed to connect to MongoDB: %e\n", err)
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
 	// Ping the database to verify the connection
 	err = client.Ping(context.Background(), nil)
 	if err != nil {
-		log.Fatalf("failed to ping MongoDB: %e\n", err)
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
 	log.Printf("connected to MongoDB: %s\n", connStr)
 
-	return client
+	return client, nil
 }
@@ -8,7 +8,7 @@ import (
 	"log"
 )
 
-func ConnectRedisClient(config config.RedisConfig) *redis.Client {
+func ConnectRedisClient(config config.RedisConfig) (*redis.Client, error) {
 	addr := fmt.Sprintf("%s:%s", config.Host, config.Port)
 	log.Println("connecting to Redis server", addr)
 
@@ -20,9 +20,9 @@ func ConnectRedisClient(config config.RedisConfig) *redis.Client {
 
 	_, err := rdbClient.Ping(context.TODO()).Result()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to ping Redis: %w", err)
 	}
 	log.Println("connected to Redis server")
 
-	return rdbClient
+	return rdbClient, nil
 }
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AccessTokenService authenticates access tokens issued by the identity provider.
+type AccessTokenService interface {
+	Authenticate(ctx context.Context, token string) (*entity.Principal, error)
+}
+
+type AccessTokenServiceIml struct {
+	verifier       *TokenVerifier
+	userRepository repository.UserRepository
+	workspaceClaim string
+}
+
+func NewAccessTokenService(verifier *TokenVerifier, userRepo repository.UserRepository, workspaceClaim string) AccessTokenService {
+	return &AccessTokenServiceIml{verifier: verifier, userRepository: userRepo, workspaceClaim: workspaceClaim}
+}
+
+// Authenticate verifies token and returns the principal of the user it was issued to.
+// Tokens carrying scopes of this service are limited to them, others act as their user.
+func (s *AccessTokenServiceIml) Authenticate(ctx context.Context, token string) (*entity.Principal, error) {
+	claims, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(claims.Scope) {
+		if slices.Contains(entity.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &entity.Principal{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Subject:    claims.Subject,
+		Scopes:     scopes,
+		Workspaces: s.workspaces(claims),
+		// a TOTP enrollment of the account is not checked on token sign ins, only the provider's word counts
+		TwoFactor: multiFactor(claims),
+	}, nil
+}
+
+// multiFactor reports whether the identity provider signed the user in with more than a password,
+// according to the authentication methods (amr) claim of RFC 8176.
+func multiFactor(claims *TokenClaims) bool {
+	methods, _ := claims.Raw["amr"].([]any)
+	for _, method := range methods {
+		if method == "mfa" || method == "otp" || method == "hwk" || method == "swk" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveUser finds the user linked to the token subject. Unknown subjects are linked to the
+// account with the same email when the provider verified it, or get a new account otherwise.
+func (s *AccessTokenServiceIml) resolveUser(ctx context.Context, claims *TokenClaims) (*entity.User, error) {
+	user, err := s.userRepository.GetBySubject(ctx, claims.Subject)
+	if !errors.Is(err, constants.ErrorNotFound) {
+		return user, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return nil, fmt.Errorf("%w: token has no email to create an account with", constants.ErrorUnauthorized)
+	}
+
+	existing, err := s.userRepository.GetByEmail(ctx, email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, fmt.Errorf("%w: email %s belongs to an account and is not verified by the provider", constants.ErrorUnauthorized, email)
+		}
+
+		user, err = s.userRepository.LinkSubject(ctx, existing.ID, claims.Subject)
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, fmt.Errorf("%w: account %s is linked to another identity", constants.ErrorUnauthorized, email)
+		}
+
+		return user, err
+	}
+
+	if !errors.Is(err, constants.ErrorNotFound) {
+		return nil, err
+	}
+
+	newUser := entity.User{
+		ID:        bson.NewObjectID().Hex(),
+		Email:     email,
+		Subject:   claims.Subject,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.userRepository.Insert(ctx, newUser)
+	if err != nil {
+		// a concurrent request with the same token may have created the account first
+		if mongo.IsDuplicateKeyError(err) {
+			return s.userRepository.GetBySubject(ctx, claims.Subject)
+		}
+
+		return nil, err
+	}
+
+	return &newUser, nil
+}
+
+// workspaces reads the configured workspace claim, a single name or a list of names.
+func (s *AccessTokenServiceIml) workspaces(claims *TokenClaims) []string {
+	if s.workspaceClaim == "" {
+		return nil
+	}
+
+	switch value := claims.Raw[s.workspaceClaim].(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+
+		return []string{value}
+	case []any:
+		var workspaces []string
+		for _, item := range value {
+			if name, ok := item.(string); ok && name != "" {
+				workspaces = append(workspaces, name)
+			}
+		}
+
+		return workspaces
+	default:
+		return nil
+	}
+}
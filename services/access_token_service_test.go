@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccessTokenServiceIml_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestIdentityProvider(t, "key-1")
+	server := provider.serve(t)
+
+	verifier := NewTokenVerifier(server.URL, testIssuer, testAudience, time.Minute)
+	if err := verifier.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	token := func(overrides map[string]any) string {
+		claims := validClaims()
+		for name, value := range overrides {
+			claims[name] = value
+		}
+
+		return provider.sign(t, "key-1", claims)
+	}
+
+	t.Run("LinkedUser", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewAccessTokenService(verifier, mockRepo, "groups")
+
+		mockRepo.On("GetBySubject", ctx, "idp|jane").Return(&entity.User{ID: "user-1", Email: "jane@acme.com"}, nil)
+
+		principal, err := service.Authenticate(ctx, token(map[string]any{
+			"scope":  "openid links:read profile",
+			"groups": []string{"marketing", "sales"},
+		}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", principal.UserID)
+		assert.Equal(t, "idp|jane", principal.Subject)
+		assert.Equal(t, []string{"marketing", "sales"}, principal.Workspaces)
+		assert.True(t, principal.HasScope(entity.ScopeLinksRead))
+		assert.False(t, principal.HasScope(entity.ScopeLinksWrite))
+	})
+
+	t.Run("UnscopedTokenActsAsUser", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewAccessTokenService(verifier, mockRepo, "")
+
+		mockRepo.On("GetBySubject", ctx, "idp|jane").Return(&entity.User{ID: "user-1"}, nil)
+
+		principal, err := service.Authenticate(ctx, token(map[string]any{"scope": "openid profile"}))
+
+		assert.NoError(t, err)
+		assert.False(t, principal.Restricted())
+		assert.Nil(t, principal.Workspaces)
+	})
+
+	t.Run("NewUser", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewAccessTokenService(verifier, mockRepo, "")
+
+		mockRepo.On("GetBySubject", ctx, "idp|jane").Return((*entity.User)(nil), constants.ErrorNotFound)
+		mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return((*entity.User)(nil), constants.ErrorNotFound)
+		mockRepo.On("Insert", ctx, mock.MatchedBy(func(user entity.User) bool {
+			return user.Email == "jane@acme.com" && user.Subject == "idp|jane" && user.PasswordHash == ""
+		})).Return(nil)
+
+		principal, err := service.Authenticate(ctx, token(nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "jane@acme.com", principal.Email)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ExistingEmail", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewAccessTokenService(verifier, mockRepo, "")
+
+		mockRepo.On("GetBySubject", ctx, "idp|jane").Return((*entity.User)(nil), constants.ErrorNotFound)
+		mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return(&entity.User{ID: "user-1", Email: "jane@acme.com"}, nil)
+		mockRepo.On("LinkSubject", ctx, "user-1", "idp|jane").Return(&entity.User{ID: "user-1", Subject: "idp|jane"}, nil)
+
+		// unverified emails are not trusted to take over an account
+		_, err := service.Authenticate(ctx, token(nil))
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+		mockRepo.AssertNotCalled(t, "LinkSubject", ctx, "user-1", "idp|jane")
+
+		principal, err := service.Authenticate(ctx, token(map[string]any{"email_verified": true}))
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", principal.UserID)
+	})
+
+	t.Run("TwoFactorFromProvider", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewAccessTokenService(verifier, mockRepo, "")
+		enrolled := &entity.User{ID: testUser.UserID, Email: "jane@acme.com", TwoFactor: &entity.TwoFactor{Enabled: true}}
+
+		mockRepo.On("GetBySubject", ctx, "idp|jane").Return(enrolled, nil)
+
+		mockMembers := new(MockMembershipRepository)
+		mockMembers.On("Get", mock.Anything, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleEditor), nil)
+		workspaces := NewWorkspaceService(acmeWorkspaceRepository(true), mockMembers, mockRepo, nil, "")
+
+		// the account has TOTP enrolled, but the provider only checked a password
+		principal, err := service.Authenticate(ctx, token(map[string]any{"amr": []string{"pwd"}}))
+		assert.NoError(t, err)
+		assert.False(t, principal.TwoFactor)
+
+		_, err = workspaces.RequireRole(WithPrincipal(ctx, principal), "acme", entity.RoleViewer)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		principal, err = service.Authenticate(ctx, token(map[string]any{"amr": []string{"pwd", "otp"}}))
+		assert.NoError(t, err)
+		assert.True(t, principal.TwoFactor)
+
+		_, err = workspaces.RequireRole(WithPrincipal(ctx, principal), "acme", entity.RoleViewer)
+		assert.NoError(t, err)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		service := NewAccessTokenService(verifier, new(MockUserRepository), "")
+
+		_, err := service.Authenticate(ctx, token(map[string]any{"aud": "other"}))
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	APIKeyPrefix = "sk_"
+	// lastUsedResolution limits last used writes to one per key and interval
+	lastUsedResolution = time.Minute
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (*entity.APIKey, string, error)
+	ListAPIKeys(ctx context.Context) (*[]entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) (*entity.APIKey, error)
+	Authenticate(ctx context.Context, key string) (*entity.Principal, error)
+}
+
+type APIKeyServiceIml struct {
+	repository     repository.APIKeyRepository
+	userRepository repository.UserRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &APIKeyServiceIml{repository: repo, userRepository: userRepo}
+}
+
+// CreateAPIKey issues a new key for the signed-in user. The returned key is only available now.
+func (s *APIKeyServiceIml) CreateAPIKey(ctx context.Context, name string, scopes []string) (*entity.APIKey, string, error) {
+	principal, err := sessionPrincipal(ctx, "API keys")
+	if err != nil {
+		return nil, "", err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", constants.ErrorInvalidRequest)
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", constants.ErrorInvalidRequest)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(entity.Scopes, scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", constants.ErrorInvalidRequest, scope)
+		}
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	raw := APIKeyPrefix + secret
+	key := entity.APIKey{
+		ID:        bson.NewObjectID().Hex(),
+		UserID:    principal.UserID,
+		Name:      name,
+		Prefix:    raw[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(raw),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now(),
+	}
+
+	err = s.repository.Insert(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &key, raw, nil
+}
+
+func (s *APIKeyServiceIml) ListAPIKeys(ctx context.Context) (*[]entity.APIKey, error) {
+	principal, err := sessionPrincipal(ctx, "API keys")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetByUser(ctx, principal.UserID)
+}
+
+func (s *APIKeyServiceIml) RevokeAPIKey(ctx context.Context, id string) (*entity.APIKey, error) {
+	principal, err := sessionPrincipal(ctx, "API keys")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.Revoke(ctx, principal.UserID, id)
+}
+
+// Authenticate returns the principal of a bearer API key.
+func (s *APIKeyServiceIml) Authenticate(ctx context.Context, raw string) (*entity.Principal, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	key, err := s.repository.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, constants.ErrorUnauthorized
+		}
+
+		return nil, err
+	}
+
+	if key.IsRevoked() {
+		return nil, fmt.Errorf("%w: API key %s is revoked", constants.ErrorUnauthorized, key.Prefix)
+	}
+
+	user, err := s.userRepository.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, constants.ErrorUnauthorized
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		err = s.repository.UpdateLastUsed(ctx, key.ID, now)
+		if err != nil {
+			log.Printf("error updating last use of API key %s %v\n", key.Prefix, err)
+		}
+	}
+
+	return &entity.Principal{
+		UserID:    user.ID,
+		Email:     user.Email,
+		APIKeyID:  key.ID,
+		Scopes:    key.Scopes,
+		TwoFactor: user.TwoFactorEnabled(),
+	}, nil
+}
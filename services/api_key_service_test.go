@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyRepository is a mock type for repository.APIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Insert(ctx context.Context, key entity.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(*entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByUser(ctx context.Context, userID string) (*[]entity.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*[]entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, userID string, id string) (*entity.APIKey, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	args := m.Called(ctx, id, lastUsedAt)
+	return args.Error(0)
+}
+
+func TestAPIKeyServiceIml_CreateAPIKey(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(MockAPIKeyRepository)
+		service := NewAPIKeyService(mockRepo, new(MockUserRepository))
+
+		var stored entity.APIKey
+		mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.APIKey")).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(entity.APIKey)
+		}).Return(nil)
+
+		key, raw, err := service.CreateAPIKey(ctx, "ci", []string{entity.ScopeLinksWrite, entity.ScopeLinksRead, entity.ScopeLinksWrite})
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(raw, "sk_"))
+		assert.True(t, strings.HasPrefix(raw, key.Prefix))
+		assert.Equal(t, testUser.UserID, stored.UserID)
+		assert.Equal(t, hashToken(raw), stored.KeyHash)
+		assert.NotContains(t, stored.KeyHash, raw)
+		assert.Equal(t, []string{entity.ScopeLinksRead, entity.ScopeLinksWrite}, stored.Scopes)
+	})
+
+	t.Run("UnknownScope", func(t *testing.T) {
+		service := NewAPIKeyService(new(MockAPIKeyRepository), new(MockUserRepository))
+
+		_, _, err := service.CreateAPIKey(ctx, "ci", []string{"links:admin"})
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("KeysCannotCreateKeys", func(t *testing.T) {
+		service := NewAPIKeyService(new(MockAPIKeyRepository), new(MockUserRepository))
+		keyCtx := WithPrincipal(context.Background(), &entity.Principal{UserID: "user-1", APIKeyID: "key-1", Scopes: entity.Scopes})
+
+		_, _, err := service.CreateAPIKey(keyCtx, "ci", []string{entity.ScopeLinksRead})
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		service := NewAPIKeyService(new(MockAPIKeyRepository), new(MockUserRepository))
+
+		_, _, err := service.CreateAPIKey(context.Background(), "ci", []string{entity.ScopeLinksRead})
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestAPIKeyServiceIml_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	raw := "sk_secret"
+	recently := time.Now().Add(-10 * time.Second)
+	revoked := time.Now().Add(-time.Hour)
+
+	mockRepo := new(MockAPIKeyRepository)
+	mockUserRepo := new(MockUserRepository)
+	service := NewAPIKeyService(mockRepo, mockUserRepo)
+
+	mockUserRepo.On("GetByID", ctx, "user-1").Return(&entity.User{ID: "user-1", Email: "jane@acme.com"}, nil)
+	mockRepo.On("GetByHash", ctx, hashToken(raw)).Return(&entity.APIKey{
+		ID: "key-1", UserID: "user-1", Scopes: []string{entity.ScopeLinksRead},
+	}, nil).Once()
+	mockRepo.On("UpdateLastUsed", ctx, "key-1", mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	principal, err := service.Authenticate(ctx, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", principal.APIKeyID)
+	assert.True(t, principal.HasScope(entity.ScopeLinksRead))
+	assert.False(t, principal.HasScope(entity.ScopeLinksWrite))
+
+	// used moments ago, the last use is not written again
+	mockRepo.On("GetByHash", ctx, hashToken(raw)).Return(&entity.APIKey{
+		ID: "key-1", UserID: "user-1", LastUsedAt: &recently,
+	}, nil).Once()
+
+	_, err = service.Authenticate(ctx, raw)
+	assert.NoError(t, err)
+
+	mockRepo.On("GetByHash", ctx, hashToken(raw)).Return(&entity.APIKey{
+		ID: "key-1", UserID: "user-1", RevokedAt: &revoked,
+	}, nil).Once()
+
+	_, err = service.Authenticate(ctx, raw)
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	mockRepo.On("GetByHash", ctx, hashToken("sk_unknown")).Return((*entity.APIKey)(nil), constants.ErrorNotFound)
+
+	_, err = service.Authenticate(ctx, "sk_unknown")
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	mockRepo.AssertExpectations(t)
+}
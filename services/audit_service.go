@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditService keeps the audit log of link changes. Entries are recorded for the principal
+// and client IP of ctx, only workspace admins read them.
+type AuditService interface {
+	Record(ctx context.Context, action string, before *entity.ShortenedURL, after *entity.ShortenedURL) error
+	ListLinkAudit(ctx context.Context, domain string, shortcode string, limit int) (*[]entity.AuditEntry, error)
+	ListWorkspaceAudit(ctx context.Context, workspaceID string, limit int) (*[]entity.AuditEntry, error)
+}
+
+type AuditServiceIml struct {
+	repository       repository.AuditRepository
+	workspaceService WorkspaceService
+}
+
+func NewAuditService(repo repository.AuditRepository, workspaceService WorkspaceService) AuditService {
+	return &AuditServiceIml{repository: repo, workspaceService: workspaceService}
+}
+
+// snapshot copies a link for the audit log, without the URL generated on the fly
+func snapshot(link *entity.ShortenedURL) *entity.ShortenedURL {
+	if link == nil {
+		return nil
+	}
+
+	copied := *link
+	copied.ShortenedURL = ""
+
+	return &copied
+}
+
+// Record appends an entry for a change of a link from before to after.
+func (s *AuditServiceIml) Record(ctx context.Context, action string, before *entity.ShortenedURL, after *entity.ShortenedURL) error {
+	link := after
+	if link == nil {
+		link = before
+	}
+
+	entry := entity.AuditEntry{
+		ID:        bson.NewObjectID().Hex(),
+		Workspace: link.Workspace,
+		Domain:    link.Domain,
+		ShortCode: link.ShortCode,
+		Action:    action,
+		IP:        ClientIPFromContext(ctx),
+		Before:    snapshot(before),
+		After:     snapshot(after),
+		CreatedAt: time.Now(),
+	}
+
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		entry.ActorID = principal.UserID
+		entry.ActorEmail = principal.Email
+		entry.APIKeyID = principal.APIKeyID
+	}
+
+	return s.repository.Insert(ctx, entry)
+}
+
+func auditLimit(limit int) int64 {
+	if limit <= 0 {
+		return defaultAuditLimit
+	}
+
+	return int64(min(limit, maxAuditLimit))
+}
+
+// ListLinkAudit returns the newest entries of a link, deleted links included. The link
+// belongs to the workspace of its latest entry.
+func (s *AuditServiceIml) ListLinkAudit(ctx context.Context, domain string, shortcode string, limit int) (*[]entity.AuditEntry, error) {
+	_, err := authorize(ctx, entity.ScopeLinksRead)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repository.GetByLink(ctx, domain, shortcode, auditLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*entries) == 0 || (*entries)[0].Workspace == "" {
+		return nil, fmt.Errorf("%w: %s/%s", constants.ErrorNotFound, domain, shortcode)
+	}
+
+	_, err = s.workspaceService.RequireRole(ctx, (*entries)[0].Workspace, entity.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ListWorkspaceAudit returns the newest entries of a workspace, or of the selected workspace
+// when workspaceID is empty.
+func (s *AuditServiceIml) ListWorkspaceAudit(ctx context.Context, workspaceID string, limit int) (*[]entity.AuditEntry, error) {
+	_, err := authorize(ctx, entity.ScopeLinksRead)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, workspaceID, entity.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetByWorkspace(ctx, membership.WorkspaceID, auditLimit(limit))
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+// MockAuditRepository is a mock type for repository.AuditRepository
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Insert(ctx context.Context, entry entity.AuditEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) GetByLink(ctx context.Context, domain string, shortCode string, limit int64) (*[]entity.AuditEntry, error) {
+	args := m.Called(ctx, domain, shortCode, limit)
+	return args.Get(0).(*[]entity.AuditEntry), args.Error(1)
+}
+
+func (m *MockAuditRepository) GetByWorkspace(ctx context.Context, workspace string, limit int64) (*[]entity.AuditEntry, error) {
+	args := m.Called(ctx, workspace, limit)
+	return args.Get(0).(*[]entity.AuditEntry), args.Error(1)
+}
+
+func TestAuditServiceIml_Record(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	service := NewAuditService(mockRepo, testWorkspaces)
+	ctx := WithClientIP(WithPrincipal(context.Background(), testUser), "203.0.113.7")
+
+	var recorded entity.AuditEntry
+	mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.AuditEntry")).Run(func(args mock.Arguments) {
+		recorded = args.Get(1).(entity.AuditEntry)
+	}).Return(nil)
+
+	before := ownedLink("abc123")
+	after := ownedLink("abc123")
+	after.OriginalURL = "https://changed.example.com"
+	after.ShortenedURL = "http://short.url/s/abc123"
+
+	err := service.Record(ctx, entity.AuditUpdate, before, after)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, recorded.ID)
+	assert.Equal(t, entity.AuditUpdate, recorded.Action)
+	assert.Equal(t, testWorkspace, recorded.Workspace)
+	assert.Equal(t, "abc123", recorded.ShortCode)
+	assert.Equal(t, testUser.UserID, recorded.ActorID)
+	assert.Equal(t, testUser.Email, recorded.ActorEmail)
+	assert.Equal(t, "203.0.113.7", recorded.IP)
+	assert.Equal(t, "https://example.com", recorded.Before.OriginalURL)
+	assert.Equal(t, "https://changed.example.com", recorded.After.OriginalURL)
+	assert.Empty(t, recorded.After.ShortenedURL)
+	assert.False(t, recorded.CreatedAt.IsZero())
+}
+
+func TestAuditServiceIml_ListLinkAudit(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleAdmin, "globex": entity.RoleEditor}}
+	service := NewAuditService(mockRepo, workspaces)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		entries := &[]entity.AuditEntry{{Workspace: testWorkspace, Domain: testDomain, ShortCode: "abc123", Action: entity.AuditCreate}}
+		mockRepo.On("GetByLink", ctx, testDomain, "abc123", int64(defaultAuditLimit)).Return(entries, nil)
+
+		result, err := service.ListLinkAudit(ctx, testDomain, "abc123", 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entries, result)
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		entries := &[]entity.AuditEntry{{Workspace: "globex", Domain: testDomain, ShortCode: "globex1"}}
+		mockRepo.On("GetByLink", ctx, testDomain, "globex1", int64(maxAuditLimit)).Return(entries, nil)
+
+		_, err := service.ListLinkAudit(ctx, testDomain, "globex1", 5000)
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		mockRepo.On("GetByLink", ctx, testDomain, "missing", int64(10)).Return(&[]entity.AuditEntry{}, nil)
+
+		_, err := service.ListLinkAudit(ctx, testDomain, "missing", 10)
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		_, err := service.ListLinkAudit(context.Background(), testDomain, "abc123", 0)
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestAuditServiceIml_ListWorkspaceAudit(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleAdmin, "globex": entity.RoleEditor}}
+	service := NewAuditService(mockRepo, workspaces)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("SelectedWorkspace", func(t *testing.T) {
+		entries := &[]entity.AuditEntry{{Workspace: testWorkspace, Action: entity.AuditDelete}}
+		mockRepo.On("GetByWorkspace", ctx, testWorkspace, int64(defaultAuditLimit)).Return(entries, nil)
+
+		result, err := service.ListWorkspaceAudit(ctx, "", 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entries, result)
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		_, err := service.ListWorkspaceAudit(ctx, "globex", 0)
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+		mockRepo.AssertNotCalled(t, "GetByWorkspace", ctx, "globex", int64(defaultAuditLimit))
+	})
+}
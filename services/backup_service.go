@@ -0,0 +1,399 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"time"
+)
+
+const (
+	backupManifestFile = "manifest.json"
+	// restoreBatchSize is how many documents restores write at a time
+	restoreBatchSize = 1000
+	// maxBSONDocumentSize is the largest document MongoDB stores
+	maxBSONDocumentSize = 16 << 20
+)
+
+// collection names of archives, which become file names
+var backupCollectionPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// BackupService writes the collections of the database to a gzipped tar archive and loads
+// them back. The archive starts with a manifest.json listing its collections, each of them a
+// file of the documents one after the other, with their count and SHA-256 checksum. Backups
+// and restores are run by operators and bypass workspace permissions.
+type BackupService interface {
+	Backup(ctx context.Context, w io.Writer, allowInconsistent bool) (*entity.BackupManifest, error)
+	Restore(ctx context.Context, archive io.ReadSeeker, mode string) ([]entity.RestoredCollection, error)
+}
+
+type BackupServiceIml struct {
+	repository  repository.BackupRepository
+	collections []string
+}
+
+// NewBackupService backs up the given collections and the daily click counts of links.
+func NewBackupService(repo repository.BackupRepository, collections []string) BackupService {
+	return &BackupServiceIml{repository: repo, collections: collections}
+}
+
+// backupFile is a collection written to a temporary file, archives need the size of their
+// files before their content
+type backupFile struct {
+	collection entity.BackupCollection
+	file       *os.File
+}
+
+func (s *BackupServiceIml) dump(ctx context.Context, collection string, stream func(ctx context.Context, fn func(document bson.Raw) error) error) (*backupFile, error) {
+	file, err := os.CreateTemp("", "backup-"+collection+"-*.bson")
+	if err != nil {
+		return nil, err
+	}
+
+	dumped := &backupFile{file: file, collection: entity.BackupCollection{Name: collection, File: collection + ".bson"}}
+	checksum := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(file, checksum))
+
+	err = stream(ctx, func(document bson.Raw) error {
+		dumped.collection.Documents++
+		_, err := w.Write(document)
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return dumped, err
+	}
+
+	dumped.collection.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+
+	return dumped, nil
+}
+
+// Backup writes an archive of the collections and daily click counts to w, read from a
+// snapshot of the database so that they are consistent with each other. Servers without
+// snapshot reads fail with constants.ErrorSnapshotUnsupported unless allowInconsistent is set,
+// then the collections are read one after the other while the service keeps running.
+func (s *BackupServiceIml) Backup(ctx context.Context, w io.Writer, allowInconsistent bool) (*entity.BackupManifest, error) {
+	manifest := &entity.BackupManifest{Version: entity.BackupFormatVersion, CreatedAt: time.Now().UTC(), Consistent: true}
+
+	var files []*backupFile
+	defer func() {
+		for _, file := range files {
+			file.file.Close()
+			os.Remove(file.file.Name())
+		}
+	}()
+
+	dumpAll := func(ctx context.Context) error {
+		for _, collection := range s.collections {
+			file, err := s.dump(ctx, collection, func(ctx context.Context, fn func(document bson.Raw) error) error {
+				return s.repository.StreamCollection(ctx, collection, fn)
+			})
+			if file != nil {
+				files = append(files, file)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		file, err := s.dump(ctx, entity.BackupClickRollups, s.repository.StreamClickRollups)
+		if file != nil {
+			files = append(files, file)
+		}
+
+		return err
+	}
+
+	err := s.repository.Snapshot(ctx, dumpAll)
+	if errors.Is(err, constants.ErrorSnapshotUnsupported) && allowInconsistent {
+		log.Printf("backing up without a snapshot: %v\n", err)
+		manifest.Consistent = false
+		err = dumpAll(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		manifest.Collections = append(manifest.Collections, file.collection)
+	}
+
+	return manifest, writeBackupArchive(w, manifest, files)
+}
+
+func writeBackupArchive(w io.Writer, manifest *entity.BackupManifest, files []*backupFile) error {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = archive.WriteHeader(&tar.Header{Name: backupManifestFile, Mode: 0o644, Size: int64(len(encoded)), ModTime: manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+
+	if _, err := archive.Write(encoded); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		info, err := file.file.Stat()
+		if err != nil {
+			return err
+		}
+
+		err = archive.WriteHeader(&tar.Header{Name: file.collection.File, Mode: 0o644, Size: info.Size(), ModTime: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+
+		if _, err := file.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(archive, file.file); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return compressed.Close()
+}
+
+// backupReader reads the collections of an archive after its manifest
+type backupReader struct {
+	archive  *tar.Reader
+	manifest entity.BackupManifest
+}
+
+func openBackupArchive(r io.Reader) (*backupReader, error) {
+	decompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a backup archive: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	reader := &backupReader{archive: tar.NewReader(decompressed)}
+
+	header, err := reader.archive.Next()
+	if err != nil || header.Name != backupManifestFile {
+		return nil, fmt.Errorf("%w: the archive does not start with a manifest", constants.ErrorInvalidRequest)
+	}
+
+	err = json.NewDecoder(reader.archive).Decode(&reader.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	if reader.manifest.Version < 1 || reader.manifest.Version > entity.BackupFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported backup version %d", constants.ErrorInvalidRequest, reader.manifest.Version)
+	}
+
+	for _, collection := range reader.manifest.Collections {
+		if !backupCollectionPattern.MatchString(collection.Name) || collection.File != collection.Name+".bson" {
+			return nil, fmt.Errorf("%w: invalid collection %q in the manifest", constants.ErrorInvalidRequest, collection.Name)
+		}
+	}
+
+	return reader, nil
+}
+
+// each calls fn with every document of every collection of the archive, in the order of the
+// manifest, and checks their count and checksum once a collection is read.
+func (b *backupReader) each(fn func(collection string, document bson.Raw) error) error {
+	for _, collection := range b.manifest.Collections {
+		header, err := b.archive.Next()
+		if err != nil || header.Name != collection.File {
+			return fmt.Errorf("%w: %s is missing from the archive", constants.ErrorInvalidRequest, collection.File)
+		}
+
+		checksum := sha256.New()
+		documents := bufio.NewReader(io.TeeReader(b.archive, checksum))
+
+		var count int64
+		for {
+			document, err := readBSONDocument(documents)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", constants.ErrorInvalidRequest, collection.File, err)
+			}
+
+			count++
+			if err := fn(collection.Name, document); err != nil {
+				return err
+			}
+		}
+
+		if count != collection.Documents || !sameChecksum(checksum, collection.SHA256) {
+			return fmt.Errorf("%w: %s does not match its checksum", constants.ErrorInvalidRequest, collection.File)
+		}
+	}
+
+	return nil
+}
+
+func sameChecksum(checksum hash.Hash, expected string) bool {
+	return hex.EncodeToString(checksum.Sum(nil)) == expected
+}
+
+// readBSONDocument reads the next document of a stream of documents, io.EOF at its end
+func readBSONDocument(r *bufio.Reader) (bson.Raw, error) {
+	prefix, err := r.Peek(4)
+	if errors.Is(err, io.EOF) && len(prefix) == 0 {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	length := binary.LittleEndian.Uint32(prefix)
+	if length < 5 || length > maxBSONDocumentSize {
+		return nil, fmt.Errorf("invalid document length %d", length)
+	}
+
+	document := make(bson.Raw, length)
+	if _, err := io.ReadFull(r, document); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return document, document.Validate()
+}
+
+// Restore loads an archive into the database. The whole archive is checked against its
+// manifest before anything is written; documents whose ID exists already are handled with
+// mode, one of the entity.Restore conflict modes. In fail mode nothing is written when any of
+// them exists.
+func (s *BackupServiceIml) Restore(ctx context.Context, archive io.ReadSeeker, mode string) ([]entity.RestoredCollection, error) {
+	if !entity.IsRestoreMode(mode) {
+		return nil, fmt.Errorf("%w: unknown conflict mode %q", constants.ErrorInvalidRequest, mode)
+	}
+
+	reader, err := openBackupArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []bson.RawValue
+	checkExisting := func(collection string) error {
+		if len(ids) == 0 {
+			return nil
+		}
+
+		existing, err := s.repository.CountExisting(ctx, collection, ids)
+		ids = ids[:0]
+		if err != nil {
+			return err
+		}
+
+		if existing > 0 {
+			return fmt.Errorf("%w: documents of %s exist already", constants.ErrorAlreadyExists, collection)
+		}
+
+		return nil
+	}
+
+	current := ""
+	err = reader.each(func(collection string, document bson.Raw) error {
+		if mode != entity.RestoreFail {
+			return nil
+		}
+
+		if collection != current || len(ids) == restoreBatchSize {
+			if err := checkExisting(current); err != nil {
+				return err
+			}
+			current = collection
+		}
+
+		ids = append(ids, document.Lookup("_id"))
+
+		return nil
+	})
+	if err == nil {
+		err = checkExisting(current)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader, err = openBackupArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]entity.RestoredCollection, len(reader.manifest.Collections))
+	positions := map[string]int{}
+	for index, collection := range reader.manifest.Collections {
+		restored[index].Name = collection.Name
+		positions[collection.Name] = index
+	}
+
+	var batch []bson.Raw
+	current = ""
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		result, err := s.repository.RestoreDocuments(ctx, current, batch, mode)
+		batch = batch[:0]
+		if err != nil {
+			return err
+		}
+
+		total := &restored[positions[current]]
+		total.Inserted += result.Inserted
+		total.Replaced += result.Replaced
+		total.Skipped += result.Skipped
+
+		return nil
+	}
+
+	err = reader.each(func(collection string, document bson.Raw) error {
+		if collection != current || len(batch) == restoreBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = collection
+		}
+
+		batch = append(batch, document)
+
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	return restored, err
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"io"
+	"testing"
+)
+
+// memoryBackupRepository keeps collections in memory, documents are keyed by their string _id
+type memoryBackupRepository struct {
+	collections map[string][]bson.Raw
+	snapshots   bool
+}
+
+func (m *memoryBackupRepository) Snapshot(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.snapshots {
+		return constants.ErrorSnapshotUnsupported
+	}
+
+	return fn(ctx)
+}
+
+func (m *memoryBackupRepository) StreamCollection(ctx context.Context, collection string, fn func(document bson.Raw) error) error {
+	for _, document := range m.collections[collection] {
+		if err := fn(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackupRepository) StreamClickRollups(ctx context.Context, fn func(document bson.Raw) error) error {
+	return m.StreamCollection(ctx, entity.BackupClickRollups, fn)
+}
+
+func (m *memoryBackupRepository) find(collection string, id bson.RawValue) int {
+	for index, document := range m.collections[collection] {
+		if document.Lookup("_id").Equal(id) {
+			return index
+		}
+	}
+	return -1
+}
+
+func (m *memoryBackupRepository) CountExisting(ctx context.Context, collection string, ids []bson.RawValue) (int64, error) {
+	var count int64
+	for _, id := range ids {
+		if m.find(collection, id) >= 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryBackupRepository) RestoreDocuments(ctx context.Context, collection string, documents []bson.Raw, mode string) (*entity.RestoredCollection, error) {
+	restored := &entity.RestoredCollection{Name: collection}
+	for _, document := range documents {
+		index := m.find(collection, document.Lookup("_id"))
+		switch {
+		case index < 0:
+			m.collections[collection] = append(m.collections[collection], document)
+			restored.Inserted++
+		case mode == entity.RestoreOverwrite:
+			m.collections[collection][index] = document
+			restored.Replaced++
+		case mode == entity.RestoreSkip:
+			restored.Skipped++
+		default:
+			return nil, constants.ErrorAlreadyExists
+		}
+	}
+	return restored, nil
+}
+
+func backupDocument(id string, fields ...bson.E) bson.Raw {
+	document, _ := bson.Marshal(append(bson.D{{"_id", id}}, fields...))
+	return document
+}
+
+func TestBackupServiceIml(t *testing.T) {
+	ctx := context.Background()
+	collections := []string{"shorten", "users"}
+	source := &memoryBackupRepository{snapshots: true, collections: map[string][]bson.Raw{
+		"shorten": {
+			backupDocument("link-1", bson.E{Key: "shortCode", Value: "deck"}),
+			backupDocument("link-2", bson.E{Key: "shortCode", Value: "notes"}),
+		},
+		"users":                   {backupDocument("user-1", bson.E{Key: "email", Value: "user@short.url"})},
+		entity.BackupClickRollups: {backupDocument("deck-2024-03-01", bson.E{Key: "clicks", Value: 42})},
+	}}
+
+	var archive bytes.Buffer
+	manifest, err := NewBackupService(source, collections).Backup(ctx, &archive, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, manifest.Consistent)
+	assert.Len(t, manifest.Collections, 3)
+	assert.Equal(t, int64(2), manifest.Collections[0].Documents)
+	assert.Equal(t, entity.BackupClickRollups+".bson", manifest.Collections[2].File)
+
+	t.Run("EmptyDatabase", func(t *testing.T) {
+		target := &memoryBackupRepository{collections: map[string][]bson.Raw{}}
+
+		restored, err := NewBackupService(target, collections).Restore(ctx, bytes.NewReader(archive.Bytes()), entity.RestoreFail)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.RestoredCollection{
+			{Name: "shorten", Inserted: 2}, {Name: "users", Inserted: 1}, {Name: entity.BackupClickRollups, Inserted: 1},
+		}, restored)
+		assert.Equal(t, source.collections, target.collections)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		existing := func() *memoryBackupRepository {
+			return &memoryBackupRepository{collections: map[string][]bson.Raw{
+				"users": {backupDocument("user-1", bson.E{Key: "email", Value: "changed@short.url"})},
+			}}
+		}
+
+		target := existing()
+		_, err := NewBackupService(target, collections).Restore(ctx, bytes.NewReader(archive.Bytes()), entity.RestoreFail)
+
+		assert.ErrorIs(t, err, constants.ErrorAlreadyExists)
+		assert.Empty(t, target.collections["shorten"])
+
+		target = existing()
+		restored, err := NewBackupService(target, collections).Restore(ctx, bytes.NewReader(archive.Bytes()), entity.RestoreSkip)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.RestoredCollection{Name: "users", Skipped: 1}, restored[1])
+		assert.Equal(t, "changed@short.url", target.collections["users"][0].Lookup("email").StringValue())
+
+		target = existing()
+		restored, err = NewBackupService(target, collections).Restore(ctx, bytes.NewReader(archive.Bytes()), entity.RestoreOverwrite)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.RestoredCollection{Name: "users", Replaced: 1}, restored[1])
+		assert.Equal(t, "user@short.url", target.collections["users"][0].Lookup("email").StringValue())
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		// the same archive with another short code in its links
+		decompressed, err := gzip.NewReader(bytes.NewReader(archive.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := io.ReadAll(decompressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var corrupted bytes.Buffer
+		compressed := gzip.NewWriter(&corrupted)
+		compressed.Write(bytes.Replace(contents, []byte("notes"), []byte("nodes"), 1))
+		compressed.Close()
+
+		target := &memoryBackupRepository{collections: map[string][]bson.Raw{}}
+		_, err = NewBackupService(target, collections).Restore(ctx, bytes.NewReader(corrupted.Bytes()), entity.RestoreSkip)
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+		assert.Empty(t, target.collections)
+
+		_, err = NewBackupService(target, collections).Restore(ctx, bytes.NewReader([]byte("not an archive")), entity.RestoreSkip)
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("WithoutSnapshot", func(t *testing.T) {
+		standalone := &memoryBackupRepository{collections: source.collections}
+
+		_, err := NewBackupService(standalone, collections).Backup(ctx, &bytes.Buffer{}, false)
+
+		assert.ErrorIs(t, err, constants.ErrorSnapshotUnsupported)
+
+		manifest, err := NewBackupService(standalone, collections).Backup(ctx, &bytes.Buffer{}, true)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, manifest.Consistent)
+	})
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxBulkLinks  = 1000
+	bulkBatchSize = 100
+	// maxShortCodeAttempts bounds the retries of generated short codes that are taken
+	maxShortCodeAttempts = 10
+)
+
+// aliases are used as short codes, so they are limited to characters that need no escaping in paths
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,64}$`)
+
+// bulkInsert is a valid row of a bulk creation waiting to be inserted
+type bulkInsert struct {
+	index     int
+	link      entity.ShortenedURL
+	generated bool
+}
+
+func validateOriginalURL(originalURL string) error {
+	parsed, err := url.Parse(originalURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: invalid original URL %q", constants.ErrorInvalidRequest, originalURL)
+	}
+
+	return nil
+}
+
+// parseExpiry reads the expiry of a bulk row, a day expires at its end.
+func parseExpiry(expiresAt string, now time.Time) (*time.Time, error) {
+	if expiresAt == "" {
+		return nil, nil
+	}
+
+	expiry, err := time.Parse(time.DateOnly, expiresAt)
+	if err == nil {
+		expiry = expiry.AddDate(0, 0, 1)
+	} else if expiry, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+		return nil, fmt.Errorf("%w: invalid expiry %q", constants.ErrorInvalidRequest, expiresAt)
+	}
+
+	if !expiry.After(now) {
+		return nil, fmt.Errorf("%w: expiry %q is in the past", constants.ErrorInvalidRequest, expiresAt)
+	}
+
+	return &expiry, nil
+}
+
+// bulkLink validates a bulk row and returns the link it creates, without its short code
+// unless the row has an alias.
+func bulkLink(row entity.BulkLink, now time.Time) (entity.ShortenedURL, error) {
+	link := entity.ShortenedURL{OriginalURL: strings.TrimSpace(row.OriginalURL), CreatedAt: &now, UpdatedAt: &now}
+
+	err := validateOriginalURL(link.OriginalURL)
+	if err != nil {
+		return link, err
+	}
+
+	link.ShortCode = strings.TrimSpace(row.Alias)
+	if link.ShortCode != "" && !aliasPattern.MatchString(link.ShortCode) {
+		return link, fmt.Errorf("%w: alias %q must be 3 to 64 letters, digits, dashes or underscores",
+			constants.ErrorInvalidRequest, link.ShortCode)
+	}
+
+	details, err := normalizeDetails(entity.LinkDetails{Tags: row.Tags})
+	if err != nil {
+		return link, err
+	}
+
+	if len(details.Tags) > 0 {
+		link.Tags = details.Tags
+	}
+
+	link.ExpiresAt, err = parseExpiry(strings.TrimSpace(row.ExpiresAt), now)
+
+	return link, err
+}
+
+// BulkShorten creates a link on domain in the selected workspace for every row, inserting them
+// in batches. Rows fail on their own, with their error in the result; the error is only set
+// when none of them could be created.
+func (s *ShortenedServiceIml) BulkShorten(ctx context.Context, domain string, rows []entity.BulkLink) (*[]entity.BulkResult, error) {
+	principal, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.domainService.CheckLinkDomain(ctx, domain, membership.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no links given", constants.ErrorInvalidRequest)
+	}
+
+	if len(rows) > maxBulkLinks {
+		return nil, fmt.Errorf("%w: more than %d links", constants.ErrorInvalidRequest, maxBulkLinks)
+	}
+
+	now := time.Now()
+	results := make([]entity.BulkResult, len(rows))
+	aliases := map[string]int{}
+	pending := make([]bulkInsert, 0, len(rows))
+
+	for index, row := range rows {
+		results[index].Row = index + 1
+
+		link, err := bulkLink(row, now)
+		if err == nil && link.ShortCode != "" {
+			if first, taken := aliases[link.ShortCode]; taken {
+				err = fmt.Errorf("%w: alias %q is also used by row %d", constants.ErrorAlreadyExists, link.ShortCode, first)
+			}
+			aliases[link.ShortCode] = index + 1
+		}
+
+		if err != nil {
+			results[index].Error = err.Error()
+			continue
+		}
+
+		link.Domain = domain
+		link.Owner = principal.UserID
+		link.Workspace = membership.WorkspaceID
+		pending = append(pending, bulkInsert{index: index, link: link, generated: link.ShortCode == ""})
+	}
+
+	report := func(insert bulkInsert, link *entity.ShortenedURL, err error) {
+		results[insert.index].Link = link
+		if err != nil {
+			results[insert.index].Error = err.Error()
+		}
+	}
+
+	for start := 0; start < len(pending); start += bulkBatchSize {
+		s.insertBatch(ctx, pending[start:min(start+bulkBatchSize, len(pending))], report)
+	}
+
+	return &results, nil
+}
+
+// insertBatch inserts a batch of rows and reports the link or the error of each. Rows whose
+// generated short code is taken are retried with another one, like insertWithRetry does; taken
+// codes that were given fail with constants.ErrorAlreadyExists.
+func (s *ShortenedServiceIml) insertBatch(ctx context.Context, batch []bulkInsert, report func(insert bulkInsert, link *entity.ShortenedURL, err error)) {
+	for attempt := 1; len(batch) > 0; attempt++ {
+		links := make([]entity.ShortenedURL, len(batch))
+		for index, insert := range batch {
+			if insert.generated {
+				insert.link.GenerateShortCode(strconv.Itoa(attempt))
+			}
+			links[index] = insert.link
+		}
+
+		errs, err := s.repository.InsertMany(ctx, links)
+		if err != nil {
+			log.Printf("error inserting %d shortened URLs: %v\n", len(links), err)
+
+			for _, insert := range batch {
+				report(insert, nil, errors.New("internal server error"))
+			}
+
+			return
+		}
+
+		var retry []bulkInsert
+		for index, insert := range batch {
+			link := links[index]
+
+			switch {
+			case errs[index] == nil:
+				_ = link.GenerateShortenedURL()
+				report(insert, &link, nil)
+
+				s.audit(ctx, entity.AuditCreate, nil, &link)
+				s.revise(ctx, &link, "")
+				s.enqueueMetadata(link)
+			case !mongo.IsDuplicateKeyError(errs[index]):
+				log.Printf("error inserting shortened URL %s: %v\n", link.ShortCode, errs[index])
+				report(insert, nil, errors.New("internal server error"))
+			case !insert.generated:
+				report(insert, nil, fmt.Errorf("%w: alias %q is taken", constants.ErrorAlreadyExists, link.ShortCode))
+			case attempt < maxShortCodeAttempts:
+				log.Printf("attempt %d: duplicate shortCode '%s', retrying...\n", attempt, link.ShortCode)
+				retry = append(retry, insert)
+			default:
+				report(insert, nil, errors.New("too many duplicate attempts"))
+			}
+		}
+
+		batch = retry
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"testing"
+	"time"
+)
+
+// batchOf matches InsertMany calls with the links of the given destinations
+func batchOf(originalURLs ...string) any {
+	return mock.MatchedBy(func(links []entity.ShortenedURL) bool {
+		if len(links) != len(originalURLs) {
+			return false
+		}
+
+		for i, link := range links {
+			if link.OriginalURL != originalURLs[i] {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+func TestShortenedServiceIml_BulkShorten(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	duplicate := mongo.WriteError{Code: 11000, Message: "duplicate key error"}
+
+	t.Run("PartialFailure", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		revisions := &memoryRevisionRepository{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), revisions, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		rows := []entity.BulkLink{
+			{OriginalURL: "https://example.com/a", Tags: []string{"Launch", "q3"}, ExpiresAt: "2999-01-01"},
+			{OriginalURL: "ftp://example.com/b"},
+			{OriginalURL: "https://example.com/c", Alias: "q3-deck"},
+			{OriginalURL: "https://example.com/d", Alias: "q3-deck"},
+			{OriginalURL: "https://example.com/e"},
+			{OriginalURL: "https://example.com/f", ExpiresAt: "2000-01-01"},
+			{OriginalURL: "https://example.com/g", Alias: "a/b"},
+		}
+		mockRepo.On("InsertMany", ctx, batchOf("https://example.com/a", "https://example.com/c", "https://example.com/e")).
+			Return([]error{nil, duplicate, duplicate}, nil).Once()
+		mockRepo.On("InsertMany", ctx, batchOf("https://example.com/e")).Return([]error{nil}, nil).Once()
+
+		results, err := service.BulkShorten(ctx, testDomain, rows)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, *results, len(rows))
+		for i, result := range *results {
+			assert.Equal(t, i+1, result.Row)
+		}
+
+		created := (*results)[0].Link
+		if created == nil {
+			t.Fatalf("row 1 failed: %s", (*results)[0].Error)
+		}
+		assert.Equal(t, testDomain, created.Domain)
+		assert.Equal(t, testWorkspace, created.Workspace)
+		assert.Equal(t, testUser.UserID, created.Owner)
+		assert.NotEmpty(t, created.ShortCode)
+		assert.NotEmpty(t, created.ShortenedURL)
+		assert.Equal(t, []string{"launch", "q3"}, created.Tags)
+		assert.Equal(t, time.Date(2999, 1, 2, 0, 0, 0, 0, time.UTC), *created.ExpiresAt)
+
+		assert.Contains(t, (*results)[1].Error, "invalid original URL")
+		assert.Contains(t, (*results)[2].Error, `alias "q3-deck" is taken`)
+		assert.Contains(t, (*results)[3].Error, "also used by row 3")
+		assert.NotNil(t, (*results)[4].Link)
+		assert.Contains(t, (*results)[5].Error, "in the past")
+		assert.Contains(t, (*results)[6].Error, "alias")
+		mockRepo.AssertExpectations(t)
+
+		assert.Len(t, audit.entries, 2)
+		assert.Len(t, revisions.revisions, 2)
+	})
+
+	t.Run("TooManyRows", func(t *testing.T) {
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+
+		_, err := service.BulkShorten(ctx, testDomain, make([]entity.BulkLink, maxBulkLinks+1))
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleViewer}}
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, workspaces, testDomains, &memoryAuditService{}, false)
+
+		_, err := service.BulkShorten(ctx, testDomain, []entity.BulkLink{{OriginalURL: "https://example.com"}})
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+}
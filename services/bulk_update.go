@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"strings"
+	"time"
+)
+
+// bulkChange returns the change an update operation makes, delete operations make none.
+func bulkChange(operation entity.BulkOperation, now time.Time) (entity.LinkChange, error) {
+	var change entity.LinkChange
+
+	switch operation.Action {
+	case entity.BulkActionDelete:
+	case entity.BulkActionDisable:
+		change.Status = entity.LinkStatusDisabled
+	case entity.BulkActionAddTags, entity.BulkActionRemoveTags:
+		details, err := normalizeDetails(entity.LinkDetails{Tags: operation.Tags})
+		if err != nil {
+			return change, err
+		}
+
+		if len(details.Tags) == 0 {
+			return change, fmt.Errorf("%w: no tags given", constants.ErrorInvalidRequest)
+		}
+
+		if operation.Action == entity.BulkActionAddTags {
+			change.AddTags = details.Tags
+		} else {
+			change.RemoveTags = details.Tags
+		}
+	case entity.BulkActionSetExpiry:
+		expiresAt, err := parseExpiry(strings.TrimSpace(operation.ExpiresAt), now)
+		if err != nil {
+			return change, err
+		}
+
+		change.ExpiresAt = expiresAt
+		change.ClearExpiry = expiresAt == nil
+	default:
+		return change, fmt.Errorf("%w: unknown action %q", constants.ErrorInvalidRequest, operation.Action)
+	}
+
+	return change, nil
+}
+
+// BulkUpdate deletes, disables, retags or sets the expiry of up to maxBulkLinks links of the
+// selected workspace at once. Links the operation leaves as they are are not changed, nor are
+// blocked links, which only admins change one at a time.
+func (s *ShortenedServiceIml) BulkUpdate(ctx context.Context, operation entity.BulkOperation) (*entity.BulkOperationResult, error) {
+	_, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := bulkChange(operation, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(operation.Links) == 0 && operation.Filter == nil {
+		return nil, fmt.Errorf("%w: no links selected", constants.ErrorInvalidRequest)
+	}
+
+	if len(operation.Links) > maxBulkLinks {
+		return nil, fmt.Errorf("%w: more than %d links", constants.ErrorInvalidRequest, maxBulkLinks)
+	}
+
+	if operation.Filter != nil {
+		filter, err := normalizeLinkQuery(*operation.Filter)
+		if err != nil {
+			return nil, err
+		}
+		operation.Filter = &filter
+	}
+
+	links, err := s.repository.SelectLinks(ctx, membership.WorkspaceID, operation.Links, operation.Filter, maxBulkLinks+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*links) > maxBulkLinks {
+		return nil, fmt.Errorf("%w: the filter matches more than %d links", constants.ErrorInvalidRequest, maxBulkLinks)
+	}
+
+	result := &entity.BulkOperationResult{Action: operation.Action, DryRun: operation.DryRun, Matched: len(*links)}
+
+	var keys []entity.LinkKey
+	befores := map[entity.LinkKey]entity.ShortenedURL{}
+	afters := map[entity.LinkKey]entity.ShortenedURL{}
+	for _, link := range *links {
+		key := entity.LinkKey{Domain: link.Domain, ShortCode: link.ShortCode}
+
+		after, changed := change.Apply(link)
+		if operation.Action == entity.BulkActionDelete {
+			changed = true
+		}
+
+		if !changed || link.LinkStatus() == entity.LinkStatusBlocked || len(after.Tags) > maxTags {
+			continue
+		}
+
+		keys = append(keys, key)
+		befores[key], afters[key] = link, after
+	}
+
+	result.Changed = len(keys)
+	if operation.DryRun || len(keys) == 0 {
+		return result, nil
+	}
+
+	if operation.Action == entity.BulkActionDelete {
+		_, err = s.repository.DeleteLinks(ctx, keys)
+	} else {
+		_, err = s.repository.UpdateLinks(ctx, keys, change)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		before, after := befores[key], afters[key]
+
+		switch operation.Action {
+		case entity.BulkActionDelete:
+			s.audit(ctx, entity.AuditDelete, &before, nil)
+		case entity.BulkActionDisable:
+			s.audit(ctx, entity.AuditSettings, &before, &after)
+		default:
+			s.audit(ctx, entity.AuditUpdate, &before, &after)
+		}
+	}
+
+	return result, nil
+}
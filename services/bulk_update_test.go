@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestShortenedServiceIml_BulkUpdate(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	newLinks := func() *[]entity.ShortenedURL {
+		tagged := ownedLink("tagged")
+		tagged.Tags = []string{"q3"}
+		disabled := ownedLink("disabled")
+		disabled.Status = entity.LinkStatusDisabled
+		blocked := ownedLink("blocked")
+		blocked.Status = entity.LinkStatusBlocked
+
+		return &[]entity.ShortenedURL{*ownedLink("plain"), *tagged, *disabled, *blocked}
+	}
+	key := func(shortCode string) entity.LinkKey {
+		return entity.LinkKey{Domain: testDomain, ShortCode: shortCode}
+	}
+	selection := []entity.LinkKey{key("plain"), key("tagged"), key("disabled"), key("blocked")}
+
+	t.Run("DryRun", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		mockRepo.On("SelectLinks", ctx, testWorkspace, selection, (*entity.LinkQuery)(nil), maxBulkLinks+1).Return(newLinks(), nil)
+
+		result, err := service.BulkUpdate(ctx, entity.BulkOperation{Action: entity.BulkActionAddTags, Links: selection, Tags: []string{"Q3"}, DryRun: true})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &entity.BulkOperationResult{Action: entity.BulkActionAddTags, DryRun: true, Matched: 4, Changed: 2}, result)
+		mockRepo.AssertNotCalled(t, "UpdateLinks", mock.Anything, mock.Anything, mock.Anything)
+		assert.Empty(t, audit.entries)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		mockRepo.On("SelectLinks", ctx, testWorkspace, selection, (*entity.LinkQuery)(nil), maxBulkLinks+1).Return(newLinks(), nil)
+		mockRepo.On("UpdateLinks", ctx, []entity.LinkKey{key("plain"), key("tagged")}, entity.LinkChange{Status: entity.LinkStatusDisabled}).Return(int64(2), nil)
+
+		result, err := service.BulkUpdate(ctx, entity.BulkOperation{Action: entity.BulkActionDisable, Links: selection})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 4, result.Matched)
+		assert.Equal(t, 2, result.Changed)
+		mockRepo.AssertExpectations(t)
+		assert.Len(t, audit.entries, 2)
+	})
+
+	t.Run("DeleteFilter", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		filter := mock.MatchedBy(func(query *entity.LinkQuery) bool {
+			return query != nil && query.Tag == "q3"
+		})
+		mockRepo.On("SelectLinks", ctx, testWorkspace, []entity.LinkKey(nil), filter, maxBulkLinks+1).
+			Return(&[]entity.ShortenedURL{*ownedLink("tagged")}, nil)
+		mockRepo.On("DeleteLinks", ctx, []entity.LinkKey{key("tagged")}).Return(int64(1), nil)
+
+		result, err := service.BulkUpdate(ctx, entity.BulkOperation{Action: entity.BulkActionDelete, Filter: &entity.LinkQuery{Tag: "Q3"}})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, result.Changed)
+		mockRepo.AssertExpectations(t)
+		assert.Len(t, audit.entries, 1)
+	})
+
+	t.Run("FilterTooBroad", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+
+		links := make([]entity.ShortenedURL, maxBulkLinks+1)
+		mockRepo.On("SelectLinks", ctx, testWorkspace, []entity.LinkKey(nil), mock.Anything, maxBulkLinks+1).Return(&links, nil)
+
+		_, err := service.BulkUpdate(ctx, entity.BulkOperation{Action: entity.BulkActionDelete, Filter: &entity.LinkQuery{}})
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+
+		operations := []entity.BulkOperation{
+			{Action: "archive", Links: selection},
+			{Action: entity.BulkActionDelete},
+			{Action: entity.BulkActionRemoveTags, Links: selection},
+			{Action: entity.BulkActionSetExpiry, Links: selection, ExpiresAt: "2000-01-01"},
+		}
+		for _, operation := range operations {
+			_, err := service.BulkUpdate(ctx, operation)
+
+			assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+		}
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleViewer}}
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, workspaces, testDomains, &memoryAuditService{}, false)
+
+		_, err := service.BulkUpdate(ctx, entity.BulkOperation{Action: entity.BulkActionDelete, Links: selection})
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+)
+
+type principalContextKey struct{}
+
+type workspaceContextKey struct{}
+
+type clientIPContextKey struct{}
+
+// WithPrincipal returns a copy of ctx acting for principal.
+func WithPrincipal(ctx context.Context, principal *entity.Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal attached by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (*entity.Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*entity.Principal)
+
+	return principal, ok && principal != nil
+}
+
+// WithWorkspace returns a copy of ctx working in the workspace with the given ID.
+func WithWorkspace(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, workspaceContextKey{}, workspaceID)
+}
+
+// WorkspaceFromContext returns the workspace selected with WithWorkspace, or an empty string.
+func WorkspaceFromContext(ctx context.Context) string {
+	workspaceID, _ := ctx.Value(workspaceContextKey{}).(string)
+
+	return workspaceID
+}
+
+// WithClientIP returns a copy of ctx serving a request of the client at ip.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
+
+// ClientIPFromContext returns the client IP attached by WithClientIP, or an empty string.
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+
+	return ip
+}
+
+// authorize returns the principal of ctx when it may act within scope.
+func authorize(ctx context.Context, scope string) (*entity.Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	if !principal.HasScope(scope) {
+		return nil, fmt.Errorf("%w: missing scope %s", constants.ErrorForbidden, scope)
+	}
+
+	return principal, nil
+}
+
+// sessionPrincipal returns the principal of ctx for managing what, which API keys and scoped
+// access tokens are not allowed to.
+func sessionPrincipal(ctx context.Context, what string) (*entity.Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	if principal.Restricted() {
+		return nil, fmt.Errorf("%w: %s are managed with a signed-in session", constants.ErrorForbidden, what)
+	}
+
+	return principal, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// destinationPrefix is a side of a destination rewrite, its path is escaped and has no trailing
+// slash. An empty scheme matches, or keeps, any scheme.
+type destinationPrefix struct {
+	scheme string
+	host   string
+	path   string
+}
+
+func parseDestinationPrefix(prefix string) (destinationPrefix, error) {
+	raw := strings.TrimSpace(prefix)
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" ||
+		(parsed.Scheme != "" && parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return destinationPrefix{}, fmt.Errorf("%w: %q is not a host or an http(s) URL prefix", constants.ErrorInvalidRequest, prefix)
+	}
+
+	return destinationPrefix{
+		scheme: parsed.Scheme,
+		host:   entity.NormalizeHost(parsed.Host),
+		path:   strings.TrimSuffix(parsed.EscapedPath(), "/"),
+	}, nil
+}
+
+// matches reports whether destination starts with the prefix, its path only matches whole segments
+func (p destinationPrefix) matches(destination *url.URL) bool {
+	if entity.NormalizeHost(destination.Host) != p.host || (p.scheme != "" && destination.Scheme != p.scheme) {
+		return false
+	}
+
+	path := destination.EscapedPath()
+
+	return p.path == "" || path == p.path || strings.HasPrefix(path, p.path+"/")
+}
+
+// rewriteDestination replaces the from prefix of destination with to, keeping the rest of its
+// path, its query and its fragment.
+func rewriteDestination(destination *url.URL, from destinationPrefix, to destinationPrefix) string {
+	scheme := to.scheme
+	if scheme == "" {
+		scheme = destination.Scheme
+	}
+
+	var rewritten strings.Builder
+	rewritten.WriteString(scheme + "://")
+	if destination.User != nil {
+		rewritten.WriteString(destination.User.String() + "@")
+	}
+	rewritten.WriteString(to.host + to.path + strings.TrimPrefix(destination.EscapedPath(), from.path))
+
+	if destination.ForceQuery || destination.RawQuery != "" {
+		rewritten.WriteString("?" + destination.RawQuery)
+	}
+
+	if destination.Fragment != "" {
+		rewritten.WriteString("#" + destination.EscapedFragment())
+	}
+
+	return rewritten.String()
+}
+
+// RewriteDestinations moves the destinations of the selected workspace from one host or URL
+// prefix to another. Every link gets a revision and an audit entry like a single update does;
+// blocked links and links whose destination changes while the rewrite runs are left as they
+// are and reported with an error.
+func (s *ShortenedServiceIml) RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error) {
+	_, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseDestinationPrefix(rewrite.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseDestinationPrefix(rewrite.To)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == to {
+		return nil, fmt.Errorf("%w: the destinations are rewritten to themselves", constants.ErrorInvalidRequest)
+	}
+
+	links, err := s.repository.GetByDestinationHost(ctx, membership.WorkspaceID, from.host)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &entity.DestinationRewriteResult{DryRun: rewrite.DryRun, Links: []entity.DestinationChange{}}
+	for _, link := range *links {
+		destination, err := url.Parse(link.OriginalURL)
+		if err != nil || !from.matches(destination) {
+			continue
+		}
+
+		change := entity.DestinationChange{
+			Domain:    link.Domain,
+			ShortCode: link.ShortCode,
+			Before:    link.OriginalURL,
+			After:     rewriteDestination(destination, from, to),
+		}
+		if change.After == change.Before {
+			continue
+		}
+
+		result.Matched++
+
+		// blocked links are left to admins one at a time, as bulk updates leave them
+		if link.LinkStatus() == entity.LinkStatusBlocked {
+			change.Error = "the link is blocked by an admin"
+		} else if err := validateOriginalURL(change.After); err != nil {
+			change.Error = err.Error()
+		} else if !rewrite.DryRun {
+			s.applyDestinationChange(ctx, link, &change)
+		}
+
+		if change.Error == "" {
+			result.Changed++
+		}
+		result.Links = append(result.Links, change)
+	}
+
+	return result, nil
+}
+
+func (s *ShortenedServiceIml) applyDestinationChange(ctx context.Context, before entity.ShortenedURL, change *entity.DestinationChange) {
+	shortened, err := s.repository.RewriteDestination(ctx, change.Domain, change.ShortCode, change.Before, change.After)
+	if errors.Is(err, constants.ErrorNotFound) {
+		change.Error = "the link changed during the rewrite"
+		return
+	}
+
+	if err != nil {
+		log.Printf("error rewriting the destination of %s %v\n", change.ShortCode, err)
+		change.Error = "internal server error"
+		return
+	}
+
+	s.audit(ctx, entity.AuditUpdate, &before, shortened)
+	s.revise(ctx, shortened, "")
+
+	s.enqueueMetadata(*shortened)
+}
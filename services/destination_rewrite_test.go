@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/url"
+	"testing"
+)
+
+func TestRewriteDestination(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        string
+		to          string
+		destination string
+		want        string
+	}{
+		{"Host", "docs.old.com", "docs.new.com", "https://docs.old.com/guide/setup?lang=en#install", "https://docs.new.com/guide/setup?lang=en#install"},
+		{"HostKeepsScheme", "docs.old.com", "docs.new.com", "http://DOCS.old.com", "http://docs.new.com"},
+		{"Prefix", "https://docs.old.com/v1", "https://docs.new.com/v2/", "https://docs.old.com/v1/api?q=a%20b", "https://docs.new.com/v2/api?q=a%20b"},
+		{"PrefixWhole", "docs.old.com/v1/", "docs.new.com", "https://docs.old.com/v1", "https://docs.new.com"},
+		{"EscapedPath", "docs.old.com", "https://docs.new.com", "http://docs.old.com/a%2Fb", "https://docs.new.com/a%2Fb"},
+		{"Port", "docs.old.com:8080", "docs.new.com", "https://docs.old.com:8080/a", "https://docs.new.com/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := parseDestinationPrefix(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := parseDestinationPrefix(tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			destination, err := url.Parse(tt.destination)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, from.matches(destination))
+			assert.Equal(t, tt.want, rewriteDestination(destination, from, to))
+		})
+	}
+
+	t.Run("NoMatch", func(t *testing.T) {
+		from, _ := parseDestinationPrefix("https://docs.old.com/v1")
+
+		for _, destination := range []string{"https://docs.old.com/v10", "http://docs.old.com/v1", "https://docs.old.com.evil.com/v1", "https://old.com/v1"} {
+			parsed, _ := url.Parse(destination)
+
+			assert.False(t, from.matches(parsed), destination)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, prefix := range []string{"", "ftp://docs.old.com", "docs.old.com/?page=1", "https://"} {
+			_, err := parseDestinationPrefix(prefix)
+
+			assert.ErrorIs(t, err, constants.ErrorInvalidRequest, prefix)
+		}
+	})
+}
+
+func TestShortenedServiceIml_RewriteDestinations(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	newLinks := func() *[]entity.ShortenedURL {
+		guide := ownedLink("guide")
+		guide.OriginalURL = "https://docs.old.com/guide?lang=en"
+		root := ownedLink("root")
+		root.OriginalURL = "https://docs.old.com"
+		other := ownedLink("other")
+		other.OriginalURL = "https://docs.old.com.example.com/guide"
+
+		return &[]entity.ShortenedURL{*guide, *root, *other}
+	}
+	rewrite := entity.DestinationRewrite{From: "docs.old.com", To: "docs.new.com"}
+
+	t.Run("DryRun", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		mockRepo.On("GetByDestinationHost", ctx, testWorkspace, "docs.old.com").Return(newLinks(), nil)
+
+		dryRun := rewrite
+		dryRun.DryRun = true
+		result, err := service.RewriteDestinations(ctx, dryRun)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, result.Matched)
+		assert.Equal(t, 2, result.Changed)
+		assert.Equal(t, []entity.DestinationChange{
+			{Domain: testDomain, ShortCode: "guide", Before: "https://docs.old.com/guide?lang=en", After: "https://docs.new.com/guide?lang=en"},
+			{Domain: testDomain, ShortCode: "root", Before: "https://docs.old.com", After: "https://docs.new.com"},
+		}, result.Links)
+		mockRepo.AssertNotCalled(t, "RewriteDestination", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		assert.Empty(t, audit.entries)
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		revisions := &memoryRevisionRepository{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), revisions, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		rewritten := ownedLink("guide")
+		rewritten.OriginalURL = "https://docs.new.com/guide?lang=en"
+		mockRepo.On("GetByDestinationHost", ctx, testWorkspace, "docs.old.com").Return(newLinks(), nil)
+		mockRepo.On("RewriteDestination", ctx, testDomain, "guide", "https://docs.old.com/guide?lang=en", "https://docs.new.com/guide?lang=en").
+			Return(rewritten, nil)
+		mockRepo.On("RewriteDestination", ctx, testDomain, "root", "https://docs.old.com", "https://docs.new.com").
+			Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+		result, err := service.RewriteDestinations(ctx, rewrite)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, result.Matched)
+		assert.Equal(t, 1, result.Changed)
+		assert.Empty(t, result.Links[0].Error)
+		assert.NotEmpty(t, result.Links[1].Error)
+		mockRepo.AssertExpectations(t)
+		assert.Len(t, audit.entries, 1)
+		assert.Len(t, revisions.revisions, 1)
+	})
+
+	t.Run("Blocked", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		audit := &memoryAuditService{}
+		service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+		links := newLinks()
+		(*links)[0].Status = entity.LinkStatusBlocked
+		rewritten := ownedLink("root")
+		rewritten.OriginalURL = "https://docs.new.com"
+		mockRepo.On("GetByDestinationHost", ctx, testWorkspace, "docs.old.com").Return(links, nil)
+		mockRepo.On("RewriteDestination", ctx, testDomain, "root", "https://docs.old.com", "https://docs.new.com").
+			Return(rewritten, nil)
+
+		result, err := service.RewriteDestinations(ctx, rewrite)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, result.Matched)
+		assert.Equal(t, 1, result.Changed)
+		assert.Equal(t, "guide", result.Links[0].ShortCode)
+		assert.NotEmpty(t, result.Links[0].Error)
+		assert.Empty(t, result.Links[1].Error)
+		mockRepo.AssertNotCalled(t, "RewriteDestination", ctx, testDomain, "guide", mock.Anything, mock.Anything)
+		assert.Len(t, audit.entries, 1)
+	})
+
+	t.Run("SameDestination", func(t *testing.T) {
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+
+		_, err := service.RewriteDestinations(ctx, entity.DestinationRewrite{From: "docs.old.com/", To: "DOCS.old.com"})
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleViewer}}
+		service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, workspaces, testDomains, &memoryAuditService{}, false)
+
+		_, err := service.RewriteDestinations(ctx, rewrite)
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+}
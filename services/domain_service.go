@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"log"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// domainNamePattern matches public host names: at least two labels, the last one starting with a
+// letter, so IP addresses, single-label hosts such as localhost and ports are refused.
+var domainNamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]([a-z0-9-]*[a-z0-9])?$`)
+
+type DomainService interface {
+	DefaultDomain() string
+	EnsureDefaultDomain(ctx context.Context) error
+	GetDomain(ctx context.Context, name string) (*entity.Domain, error)
+	ResolveDomain(ctx context.Context, host string) (*entity.Domain, error)
+	CheckLinkDomain(ctx context.Context, name string, workspaceID string) error
+	ListDomains(ctx context.Context) (*[]entity.Domain, error)
+	CreateDomain(ctx context.Context, domain entity.Domain) (*entity.Domain, error)
+	UpdateDomain(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error)
+	VerifyDomain(ctx context.Context, name string) (*entity.Domain, error)
+}
+
+// DomainServiceIml lets admins of a workspace register domains for it and manage them. Other
+// users only see verified domains, without their verification token.
+type DomainServiceIml struct {
+	repository    repository.DomainRepository
+	verifier      *DomainVerifier
+	workspaces    WorkspaceService
+	defaultDomain string
+}
+
+func NewDomainService(repo repository.DomainRepository, verifier *DomainVerifier, workspaces WorkspaceService, defaultDomain string) DomainService {
+	return &DomainServiceIml{repository: repo, verifier: verifier, workspaces: workspaces, defaultDomain: entity.NormalizeHost(defaultDomain)}
+}
+
+// manager returns the membership of a signed-in admin of the workspace owning domain. Domains
+// without a workspace are managed by the operators of the deployment, in the database.
+func (s *DomainServiceIml) manager(ctx context.Context, domain *entity.Domain) (*entity.Membership, error) {
+	_, err := sessionPrincipal(ctx, "domains")
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.Workspace == "" {
+		return nil, fmt.Errorf("%w: domain %s is managed by the operators", constants.ErrorForbidden, domain.Name)
+	}
+
+	return s.workspaces.RequireRole(ctx, domain.Workspace, entity.RoleAdmin)
+}
+
+// managedDomain returns the domain called name when the principal of ctx manages it
+func (s *DomainServiceIml) managedDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	_, err := sessionPrincipal(ctx, "domains")
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := s.GetDomain(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.manager(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain, nil
+}
+
+func (s *DomainServiceIml) DefaultDomain() string {
+	return s.defaultDomain
+}
+
+// EnsureDefaultDomain registers the default domain so it can be listed and configured like any other.
+func (s *DomainServiceIml) EnsureDefaultDomain(ctx context.Context) error {
+	now := time.Now()
+	verified := entity.DomainVerification{Status: entity.DomainStatusVerified, VerifiedAt: &now}
+
+	domain, err := s.repository.GetByName(ctx, s.defaultDomain)
+	if err == nil {
+		// the default domain is served by this deployment, it needs no ownership proof
+		if !domain.IsVerified() {
+			_, err = s.repository.UpdateVerification(ctx, s.defaultDomain, verified)
+		}
+
+		return err
+	}
+
+	if !errors.Is(err, constants.ErrorNotFound) {
+		return err
+	}
+
+	log.Printf("registering default domain %s\n", s.defaultDomain)
+
+	err = s.repository.Insert(ctx, entity.Domain{Name: s.defaultDomain, Verification: verified, CreatedAt: now})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *DomainServiceIml) GetDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	return s.repository.GetByName(ctx, entity.NormalizeHost(name))
+}
+
+// ResolveDomain returns the domain serving host, only verified domains resolve.
+func (s *DomainServiceIml) ResolveDomain(ctx context.Context, host string) (*entity.Domain, error) {
+	domain, err := s.GetDomain(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if !domain.IsVerified() {
+		return nil, fmt.Errorf("%w: domain %s is not verified", constants.ErrorNotFound, domain.Name)
+	}
+
+	return domain, nil
+}
+
+// CheckLinkDomain checks a workspace can create links on the domain called name: the default
+// domain, or a verified domain the workspace registered. Domains of other workspaces are
+// reported as not found, pending ones of the workspace as forbidden.
+func (s *DomainServiceIml) CheckLinkDomain(ctx context.Context, name string, workspaceID string) error {
+	if entity.NormalizeHost(name) == s.defaultDomain {
+		return nil
+	}
+
+	domain, err := s.GetDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if workspaceID == "" || domain.Workspace != workspaceID {
+		return fmt.Errorf("%w: domain %s", constants.ErrorNotFound, domain.Name)
+	}
+
+	if !domain.IsVerified() {
+		return fmt.Errorf("%w: domain %s is not verified", constants.ErrorForbidden, domain.Name)
+	}
+
+	return nil
+}
+
+// ListDomains returns the verified domains, and the pending ones of the workspaces the principal
+// of ctx is an admin of. Verification tokens are only kept for those admins.
+func (s *DomainServiceIml) ListDomains(ctx context.Context) (*[]entity.Domain, error) {
+	domains, err := s.repository.GetDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := map[string]bool{}
+	listed := make([]entity.Domain, 0, len(*domains))
+
+	for _, domain := range *domains {
+		manages, checked := managed[domain.Workspace]
+		if !checked {
+			_, err := s.manager(ctx, &domain)
+			manages = err == nil
+			managed[domain.Workspace] = manages
+		}
+
+		if manages {
+			listed = append(listed, domain)
+			continue
+		}
+
+		if domain.IsVerified() {
+			domain.Verification.Token = ""
+			listed = append(listed, domain)
+		}
+	}
+
+	return &listed, nil
+}
+
+// CreateDomain registers a domain for the selected workspace, which the principal of ctx has to
+// be an admin of.
+func (s *DomainServiceIml) CreateDomain(ctx context.Context, domain entity.Domain) (*entity.Domain, error) {
+	_, err := sessionPrincipal(ctx, "domains")
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaces.RequireRole(ctx, "", entity.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	domain.Workspace = membership.WorkspaceID
+	domain.Name = entity.NormalizeHost(domain.Name)
+	if !domainNamePattern.MatchString(domain.Name) {
+		return nil, fmt.Errorf("%w: invalid domain name %q", constants.ErrorInvalidRequest, domain.Name)
+	}
+
+	err = validateRootRedirectURL(domain.RootRedirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// new domains stay pending until their ownership has been verified
+	domain.Verification = entity.DomainVerification{Status: entity.DomainStatusPending, Token: token}
+	domain.CreatedAt = time.Now()
+
+	err = s.repository.Insert(ctx, domain)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("%w: domain %s", constants.ErrorAlreadyExists, domain.Name)
+		}
+
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// UpdateDomain changes where the bare domain redirects to and the pages it serves, for admins
+// of the workspace owning it.
+func (s *DomainServiceIml) UpdateDomain(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error) {
+	err := validateRootRedirectURL(rootRedirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := s.managedDomain(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.UpdateByName(ctx, domain.Name, rootRedirectURL, notFoundTemplate, unavailableTemplate)
+}
+
+// VerifyDomain checks the ownership of a pending domain right away instead of
+// waiting for the next periodic check, for admins of the workspace owning it.
+func (s *DomainServiceIml) VerifyDomain(ctx context.Context, name string) (*entity.Domain, error) {
+	domain, err := s.managedDomain(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	// domains registered before verification existed get their token on first request
+	if domain.Verification.Token == "" {
+		domain.Verification.Status = entity.DomainStatusPending
+		domain.Verification.Token, err = generateVerificationToken()
+		if err != nil {
+			return nil, err
+		}
+
+		return s.repository.UpdateVerification(ctx, domain.Name, domain.Verification)
+	}
+
+	return s.verifier.Verify(ctx, *domain)
+}
+
+func generateVerificationToken() (string, error) {
+	token := make([]byte, 16)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+func validateRootRedirectURL(rootRedirectURL string) error {
+	if rootRedirectURL == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(rootRedirectURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: invalid root redirect URL %q", constants.ErrorInvalidRequest, rootRedirectURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDomainRepository is a mock type for repository.DomainRepository
+type MockDomainRepository struct {
+	mock.Mock
+}
+
+func (m *MockDomainRepository) GetByName(ctx context.Context, name string) (*entity.Domain, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainRepository) Insert(ctx context.Context, domain entity.Domain) error {
+	args := m.Called(ctx, domain)
+	return args.Error(0)
+}
+
+func (m *MockDomainRepository) GetDomains(ctx context.Context) (*[]entity.Domain, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*[]entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainRepository) UpdateByName(ctx context.Context, name string, rootRedirectURL string, notFoundTemplate string, unavailableTemplate string) (*entity.Domain, error) {
+	args := m.Called(ctx, name, rootRedirectURL, notFoundTemplate, unavailableTemplate)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+func (m *MockDomainRepository) DeletePending(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *MockDomainRepository) UpdateVerification(ctx context.Context, name string, verification entity.DomainVerification) (*entity.Domain, error) {
+	args := m.Called(ctx, name, verification)
+	return args.Get(0).(*entity.Domain), args.Error(1)
+}
+
+// domainAdmins makes testUser an admin of testWorkspace
+var domainAdmins = roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleAdmin}}
+
+func TestDomainServiceIml_EnsureDefaultDomain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Missing", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		service := NewDomainService(mockRepo, nil, nil, "Go.Acme.com")
+
+		mockRepo.On("GetByName", ctx, "go.acme.com").Return((*entity.Domain)(nil), constants.ErrorNotFound)
+		mockRepo.On("Insert", ctx, mock.MatchedBy(func(domain entity.Domain) bool {
+			return domain.Name == "go.acme.com" && domain.IsVerified()
+		})).Return(nil)
+
+		err := service.EnsureDefaultDomain(ctx)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Existing", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		service := NewDomainService(mockRepo, nil, nil, "go.acme.com")
+
+		mockRepo.On("GetByName", ctx, "go.acme.com").Return(&entity.Domain{
+			Name:         "go.acme.com",
+			Verification: entity.DomainVerification{Status: entity.DomainStatusVerified},
+		}, nil)
+
+		err := service.EnsureDefaultDomain(ctx)
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "Insert", ctx, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateVerification", ctx, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ExistingUnverified", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		service := NewDomainService(mockRepo, nil, nil, "go.acme.com")
+
+		mockRepo.On("GetByName", ctx, "go.acme.com").Return(&entity.Domain{Name: "go.acme.com"}, nil)
+		mockRepo.On("UpdateVerification", ctx, "go.acme.com", mock.MatchedBy(func(verification entity.DomainVerification) bool {
+			return verification.Status == entity.DomainStatusVerified
+		})).Return(&entity.Domain{Name: "go.acme.com"}, nil)
+
+		err := service.EnsureDefaultDomain(ctx)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestDomainServiceIml_CreateDomain(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		service := NewDomainService(mockRepo, nil, domainAdmins, "go.acme.com")
+
+		mockRepo.On("Insert", ctx, mock.MatchedBy(func(domain entity.Domain) bool {
+			return domain.Name == "acme.link" && domain.RootRedirectURL == "https://acme.com" && domain.Workspace == testWorkspace
+		})).Return(nil)
+
+		domain, err := service.CreateDomain(ctx, entity.Domain{
+			Name:            "ACME.link.",
+			RootRedirectURL: "https://acme.com",
+			Verification:    entity.DomainVerification{Status: entity.DomainStatusVerified},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "acme.link", domain.Name)
+		assert.Equal(t, entity.DomainStatusPending, domain.Verification.Status)
+		assert.Len(t, domain.Verification.Token, 32)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		service := NewDomainService(new(MockDomainRepository), nil, domainAdmins, "go.acme.com")
+
+		names := []string{"acme link/path", "localhost", "localhost:6379", "acme.link:8080", "169.254.169.254", "10.0.0.1", "[::1]", "::1", "intranet"}
+		for _, name := range names {
+			_, err := service.CreateDomain(ctx, entity.Domain{Name: name})
+
+			assert.ErrorIs(t, err, constants.ErrorInvalidRequest, name)
+		}
+	})
+
+	t.Run("InvalidRootRedirect", func(t *testing.T) {
+		service := NewDomainService(new(MockDomainRepository), nil, domainAdmins, "go.acme.com")
+
+		_, err := service.CreateDomain(ctx, entity.Domain{Name: "acme.link", RootRedirectURL: "javascript:alert(1)"})
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		service := NewDomainService(mockRepo, nil, domainAdmins, "go.acme.com")
+
+		mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.Domain")).Return(createDuplicateKeyError())
+
+		_, err := service.CreateDomain(ctx, entity.Domain{Name: "acme.link"})
+
+		assert.ErrorIs(t, err, constants.ErrorAlreadyExists)
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		service := NewDomainService(new(MockDomainRepository), nil, testWorkspaces, "go.acme.com")
+
+		_, err := service.CreateDomain(ctx, entity.Domain{Name: "acme.link"})
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		service := NewDomainService(new(MockDomainRepository), nil, domainAdmins, "go.acme.com")
+
+		_, err := service.CreateDomain(context.Background(), entity.Domain{Name: "acme.link"})
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestDomainServiceIml_UpdateDomain(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	mockRepo := new(MockDomainRepository)
+	service := NewDomainService(mockRepo, nil, domainAdmins, "short.url")
+
+	mockRepo.On("GetByName", ctx, "acme.link").Return(&entity.Domain{Name: "acme.link", Workspace: testWorkspace}, nil)
+	mockRepo.On("GetByName", ctx, "globex.link").Return(&entity.Domain{Name: "globex.link", Workspace: "globex"}, nil)
+	mockRepo.On("GetByName", ctx, "short.url").Return(&entity.Domain{Name: "short.url"}, nil)
+	mockRepo.On("UpdateByName", ctx, "acme.link", "https://acme.com", "", "").Return(&entity.Domain{Name: "acme.link"}, nil)
+
+	t.Run("Admin", func(t *testing.T) {
+		domain, err := service.UpdateDomain(ctx, "acme.link", "https://acme.com", "", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "acme.link", domain.Name)
+	})
+
+	t.Run("OtherWorkspace", func(t *testing.T) {
+		_, err := service.UpdateDomain(ctx, "globex.link", "https://evil.example", "", "")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+
+	t.Run("DefaultDomain", func(t *testing.T) {
+		_, err := service.UpdateDomain(ctx, "short.url", "https://evil.example", "", "")
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		_, err := service.UpdateDomain(context.Background(), "acme.link", "https://evil.example", "", "")
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+		mockRepo.AssertNumberOfCalls(t, "UpdateByName", 1)
+	})
+}
+
+func TestDomainServiceIml_ListDomains(t *testing.T) {
+	mockRepo := new(MockDomainRepository)
+	service := NewDomainService(mockRepo, nil, domainAdmins, "short.url")
+	pending := entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"}
+	verified := entity.DomainVerification{Status: entity.DomainStatusVerified, Token: "def"}
+
+	mockRepo.On("GetDomains", mock.Anything).Return(&[]entity.Domain{
+		{Name: "short.url", Verification: entity.DomainVerification{Status: entity.DomainStatusVerified}},
+		{Name: "acme.link", Workspace: testWorkspace, Verification: pending},
+		{Name: "go.acme.com", Workspace: testWorkspace, Verification: verified},
+		{Name: "globex.link", Workspace: "globex", Verification: pending},
+		{Name: "go.globex.com", Workspace: "globex", Verification: verified},
+	}, nil)
+
+	t.Run("Admin", func(t *testing.T) {
+		domains, err := service.ListDomains(WithPrincipal(context.Background(), testUser))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Domain{
+			{Name: "short.url", Verification: entity.DomainVerification{Status: entity.DomainStatusVerified}},
+			{Name: "acme.link", Workspace: testWorkspace, Verification: pending},
+			{Name: "go.acme.com", Workspace: testWorkspace, Verification: verified},
+			{Name: "go.globex.com", Workspace: "globex", Verification: entity.DomainVerification{Status: entity.DomainStatusVerified}},
+		}, *domains)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		domains, err := service.ListDomains(context.Background())
+
+		assert.NoError(t, err)
+		assert.Len(t, *domains, 3)
+		for _, domain := range *domains {
+			assert.True(t, domain.IsVerified())
+			assert.Empty(t, domain.Verification.Token)
+		}
+	})
+}
+
+func TestDomainServiceIml_ResolveDomain(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDomainRepository)
+	service := NewDomainService(mockRepo, nil, nil, "short.url")
+
+	mockRepo.On("GetByName", ctx, "short.url").Return(&entity.Domain{
+		Name:         "short.url",
+		Verification: entity.DomainVerification{Status: entity.DomainStatusVerified},
+	}, nil)
+	mockRepo.On("GetByName", ctx, "acme.link").Return(&entity.Domain{
+		Name:         "acme.link",
+		Verification: entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"},
+	}, nil)
+
+	domain, err := service.ResolveDomain(ctx, "SHORT.URL:80")
+	assert.NoError(t, err)
+	assert.Equal(t, "short.url", domain.Name)
+
+	_, err = service.ResolveDomain(ctx, "acme.link")
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+}
+
+func TestDomainServiceIml_CheckLinkDomain(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDomainRepository)
+	service := NewDomainService(mockRepo, nil, nil, "short.url")
+
+	verified := entity.DomainVerification{Status: entity.DomainStatusVerified}
+	mockRepo.On("GetByName", ctx, "go.acme.com").Return(&entity.Domain{Name: "go.acme.com", Workspace: testWorkspace, Verification: verified}, nil)
+	mockRepo.On("GetByName", ctx, "new.acme.com").Return(&entity.Domain{Name: "new.acme.com", Workspace: testWorkspace,
+		Verification: entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"}}, nil)
+	mockRepo.On("GetByName", ctx, "go.globex.com").Return(&entity.Domain{Name: "go.globex.com", Workspace: "globex", Verification: verified}, nil)
+	mockRepo.On("GetByName", ctx, "legacy.link").Return(&entity.Domain{Name: "legacy.link", Verification: verified}, nil)
+	mockRepo.On("GetByName", ctx, "missing.link").Return((*entity.Domain)(nil), constants.ErrorNotFound)
+
+	tests := []struct {
+		name      string
+		domain    string
+		workspace string
+		err       error
+	}{
+		{"DefaultDomain", "SHORT.URL", testWorkspace, nil},
+		{"AnonymousOnDefaultDomain", "short.url", "", nil},
+		{"VerifiedDomainOfWorkspace", "go.acme.com", testWorkspace, nil},
+		{"PendingDomainOfWorkspace", "new.acme.com", testWorkspace, constants.ErrorForbidden},
+		{"ForeignDomain", "go.globex.com", testWorkspace, constants.ErrorNotFound},
+		{"OperatorDomain", "legacy.link", testWorkspace, constants.ErrorNotFound},
+		{"AnonymousOnOperatorDomain", "legacy.link", "", constants.ErrorNotFound},
+		{"UnknownDomain", "missing.link", testWorkspace, constants.ErrorNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := service.CheckLinkDomain(ctx, test.domain, test.workspace)
+
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WellKnownVerificationPath is where a domain can serve its verification value
+// when adding a DNS TXT record is not an option.
+const WellKnownVerificationPath = "/.well-known/shortenurl-verification"
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it, tests use a fake.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainVerifier checks that registered domains are controlled by whoever added
+// them, either through a DNS TXT record or a well-known file served by the domain.
+// Verified domains are rechecked every interval and fall back to pending once
+// verification failed maxFailedChecks times in a row. Registrations never verified within
+// pendingTTL are removed, so the name can be registered by its owner. The well-known file is
+// only fetched from public addresses and redirects are not followed.
+type DomainVerifier struct {
+	repository      repository.DomainRepository
+	resolver        TXTResolver
+	client          *http.Client
+	interval        time.Duration
+	pendingTTL      time.Duration
+	maxFailedChecks int
+	schemes         []string
+}
+
+func NewDomainVerifier(repo repository.DomainRepository, resolver TXTResolver, interval time.Duration, timeout time.Duration, pendingTTL time.Duration, maxFailedChecks int) *DomainVerifier {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: publicTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &DomainVerifier{
+		repository:      repo,
+		resolver:        resolver,
+		client:          client,
+		interval:        interval,
+		pendingTTL:      pendingTTL,
+		maxFailedChecks: maxFailedChecks,
+		schemes:         []string{"https", "http"},
+	}
+}
+
+// Start rechecks every domain each interval until ctx is cancelled.
+func (v *DomainVerifier) Start(ctx context.Context) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		v.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (v *DomainVerifier) CheckAll(ctx context.Context) {
+	domains, err := v.repository.GetDomains(ctx)
+	if err != nil {
+		log.Printf("domain verification: error getting domains %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, domain := range *domains {
+		// domains without a token are configured by the operator and need no proof
+		if domain.Verification.Token == "" {
+			continue
+		}
+
+		if v.expired(domain, now) {
+			log.Printf("domain verification: %s was not verified in time, removing it\n", domain.Name)
+
+			err := v.repository.DeletePending(ctx, domain.Name)
+			if err != nil {
+				log.Printf("domain verification: error removing %s %v\n", domain.Name, err)
+			}
+			continue
+		}
+
+		_, err := v.Verify(ctx, domain)
+		if err != nil {
+			log.Printf("domain verification: error updating %s %v\n", domain.Name, err)
+		}
+	}
+}
+
+// expired tells whether a domain registered by a workspace has been pending for longer than
+// pendingTTL without ever being verified. Domains that were verified keep their links.
+func (v *DomainVerifier) expired(domain entity.Domain, now time.Time) bool {
+	return domain.Workspace != "" && !domain.IsVerified() && domain.Verification.VerifiedAt == nil &&
+		now.Sub(domain.CreatedAt) > v.pendingTTL
+}
+
+// Verify checks the ownership of domain and stores the outcome.
+func (v *DomainVerifier) Verify(ctx context.Context, domain entity.Domain) (*entity.Domain, error) {
+	now := time.Now()
+	verification := domain.Verification
+	verification.CheckedAt = &now
+
+	if v.owns(ctx, &domain) {
+		if !domain.IsVerified() {
+			log.Printf("domain verification: %s verified\n", domain.Name)
+			verification.VerifiedAt = &now
+		}
+
+		verification.Status = entity.DomainStatusVerified
+		verification.FailedChecks = 0
+	} else {
+		verification.FailedChecks++
+
+		if domain.IsVerified() && verification.FailedChecks >= v.maxFailedChecks {
+			log.Printf("domain verification: %s failed %d checks, marking pending\n", domain.Name, verification.FailedChecks)
+			verification.Status = entity.DomainStatusPending
+		}
+	}
+
+	return v.repository.UpdateVerification(ctx, domain.Name, verification)
+}
+
+func (v *DomainVerifier) owns(ctx context.Context, domain *entity.Domain) bool {
+	return v.hasTXTRecord(ctx, domain) || v.servesWellKnown(ctx, domain)
+}
+
+func (v *DomainVerifier) hasTXTRecord(ctx context.Context, domain *entity.Domain) bool {
+	records, err := v.resolver.LookupTXT(ctx, domain.TXTRecordName())
+	if err != nil {
+		return false
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.TXTRecordValue() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *DomainVerifier) servesWellKnown(ctx context.Context, domain *entity.Domain) bool {
+	for _, scheme := range v.schemes {
+		url := fmt.Sprintf("%s://%s%s", scheme, domain.Name, WellKnownVerificationPath)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false
+		}
+
+		resp, err := v.client.Do(req)
+		if err != nil {
+			continue
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		if err == nil && resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == domain.TXTRecordValue() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeTXTResolver serves TXT records from memory
+type fakeTXTResolver map[string][]string
+
+func (f fakeTXTResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := f[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	return records, nil
+}
+
+func pendingDomain(name string) entity.Domain {
+	return entity.Domain{
+		Name:         name,
+		Verification: entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"},
+	}
+}
+
+func matchVerification(status string, failedChecks int) any {
+	return mock.MatchedBy(func(verification entity.DomainVerification) bool {
+		return verification.Status == status && verification.FailedChecks == failedChecks && verification.CheckedAt != nil
+	})
+}
+
+func TestDomainVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("TXTRecord", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		resolver := fakeTXTResolver{"_shortenurl-challenge.acme.link": {"v=spf1 -all", "shortenurl-verification=abc"}}
+		verifier := NewDomainVerifier(mockRepo, resolver, time.Hour, time.Second, 24*time.Hour, 3)
+
+		mockRepo.On("UpdateVerification", ctx, "acme.link", mock.MatchedBy(func(verification entity.DomainVerification) bool {
+			return verification.Status == entity.DomainStatusVerified && verification.VerifiedAt != nil
+		})).Return(&entity.Domain{Name: "acme.link"}, nil)
+
+		_, err := verifier.Verify(ctx, pendingDomain("acme.link"))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("WellKnownFile", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != WellKnownVerificationPath {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			_, _ = w.Write([]byte("shortenurl-verification=abc\n"))
+		}))
+		defer server.Close()
+
+		name := strings.TrimPrefix(server.URL, "http://")
+		mockRepo := new(MockDomainRepository)
+		verifier := NewDomainVerifier(mockRepo, fakeTXTResolver{}, time.Hour, time.Second, 24*time.Hour, 3)
+		verifier.schemes = []string{"http"}
+		allowLoopback(verifier.client)
+
+		mockRepo.On("UpdateVerification", ctx, name, matchVerification(entity.DomainStatusVerified, 0)).Return(&entity.Domain{Name: name}, nil)
+
+		_, err := verifier.Verify(ctx, pendingDomain(name))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("WellKnownFileRedirectNotFollowed", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == WellKnownVerificationPath {
+				http.Redirect(w, r, "/token", http.StatusFound)
+				return
+			}
+
+			_, _ = w.Write([]byte("shortenurl-verification=abc"))
+		}))
+		defer server.Close()
+
+		name := strings.TrimPrefix(server.URL, "http://")
+		mockRepo := new(MockDomainRepository)
+		verifier := NewDomainVerifier(mockRepo, fakeTXTResolver{}, time.Hour, time.Second, 24*time.Hour, 3)
+		verifier.schemes = []string{"http"}
+		allowLoopback(verifier.client)
+
+		mockRepo.On("UpdateVerification", ctx, name, matchVerification(entity.DomainStatusPending, 1)).Return(&entity.Domain{Name: name}, nil)
+
+		_, err := verifier.Verify(ctx, pendingDomain(name))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("WellKnownFileOnPrivateAddress", func(t *testing.T) {
+		requested := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = true
+			_, _ = w.Write([]byte("shortenurl-verification=abc"))
+		}))
+		defer server.Close()
+
+		name := strings.TrimPrefix(server.URL, "http://")
+		mockRepo := new(MockDomainRepository)
+		verifier := NewDomainVerifier(mockRepo, fakeTXTResolver{}, time.Hour, time.Second, 24*time.Hour, 3)
+		verifier.schemes = []string{"http"}
+
+		mockRepo.On("UpdateVerification", ctx, name, matchVerification(entity.DomainStatusPending, 1)).Return(&entity.Domain{Name: name}, nil)
+
+		_, err := verifier.Verify(ctx, pendingDomain(name))
+
+		assert.NoError(t, err)
+		assert.False(t, requested)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("WrongToken", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		resolver := fakeTXTResolver{"_shortenurl-challenge.acme.link": {"shortenurl-verification=other"}}
+		verifier := NewDomainVerifier(mockRepo, resolver, time.Hour, time.Second, 24*time.Hour, 3)
+		verifier.schemes = nil
+
+		mockRepo.On("UpdateVerification", ctx, "acme.link", matchVerification(entity.DomainStatusPending, 1)).Return(&entity.Domain{Name: "acme.link"}, nil)
+
+		_, err := verifier.Verify(ctx, pendingDomain("acme.link"))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("VerifiedKeptUntilMaxFailedChecks", func(t *testing.T) {
+		mockRepo := new(MockDomainRepository)
+		verifier := NewDomainVerifier(mockRepo, fakeTXTResolver{}, time.Hour, time.Second, 24*time.Hour, 3)
+		verifier.schemes = nil
+
+		domain := pendingDomain("acme.link")
+		domain.Verification.Status = entity.DomainStatusVerified
+		domain.Verification.FailedChecks = 1
+
+		mockRepo.On("UpdateVerification", ctx, "acme.link", matchVerification(entity.DomainStatusVerified, 2)).Return(&entity.Domain{Name: "acme.link"}, nil).Once()
+
+		_, err := verifier.Verify(ctx, domain)
+		assert.NoError(t, err)
+
+		domain.Verification.FailedChecks = 2
+		mockRepo.On("UpdateVerification", ctx, "acme.link", matchVerification(entity.DomainStatusPending, 3)).Return(&entity.Domain{Name: "acme.link"}, nil).Once()
+
+		_, err = verifier.Verify(ctx, domain)
+		assert.NoError(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestDomainVerifier_CheckAll(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockDomainRepository)
+	resolver := fakeTXTResolver{"_shortenurl-challenge.acme.link": {"shortenurl-verification=abc"}}
+	verifier := NewDomainVerifier(mockRepo, resolver, time.Hour, time.Second, 24*time.Hour, 3)
+
+	// pending for longer than the pending TTL, a registration of a workspace and a domain that
+	// was verified before
+	expired := pendingDomain("squatted.link")
+	expired.Workspace = testWorkspace
+	expired.CreatedAt = time.Now().Add(-48 * time.Hour)
+	lapsed := pendingDomain("lapsed.link")
+	lapsed.Workspace = testWorkspace
+	lapsed.CreatedAt = expired.CreatedAt
+	lapsed.Verification.VerifiedAt = &lapsed.CreatedAt
+
+	mockRepo.On("GetDomains", ctx).Return(&[]entity.Domain{
+		{Name: "short.url", Verification: entity.DomainVerification{Status: entity.DomainStatusVerified}},
+		pendingDomain("acme.link"),
+		expired,
+		lapsed,
+	}, nil)
+	mockRepo.On("UpdateVerification", ctx, "acme.link", matchVerification(entity.DomainStatusVerified, 0)).Return(&entity.Domain{Name: "acme.link"}, nil)
+	mockRepo.On("DeletePending", ctx, "squatted.link").Return(nil)
+	mockRepo.On("UpdateVerification", ctx, "lapsed.link", matchVerification(entity.DomainStatusPending, 1)).Return(&entity.Domain{Name: "lapsed.link"}, nil)
+
+	verifier.CheckAll(ctx)
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateVerification", ctx, "short.url", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateVerification", ctx, "squatted.link", mock.Anything)
+}
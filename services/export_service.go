@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"io"
+)
+
+// ExportService streams the links and clicks of the selected workspace in one of the
+// entity.ExportFormat formats. Rows are written as they are read, so exports of any size use
+// little memory; an export that fails midway leaves w with the rows written so far.
+type ExportService interface {
+	ExportLinks(ctx context.Context, format string, query entity.LinkQuery, w io.Writer) error
+	ExportClicks(ctx context.Context, format string, query entity.ClickQuery, w io.Writer) error
+}
+
+type ExportServiceIml struct {
+	linkRepository   repository.ShortenedRepository
+	clickRepository  repository.ClickRepository
+	workspaceService WorkspaceService
+}
+
+func NewExportService(linkRepo repository.ShortenedRepository, clickRepo repository.ClickRepository, workspaceService WorkspaceService) ExportService {
+	return &ExportServiceIml{
+		linkRepository:   linkRepo,
+		clickRepository:  clickRepo,
+		workspaceService: workspaceService,
+	}
+}
+
+// exportWorkspace checks the principal may export with scope and returns the selected workspace
+func (s *ExportServiceIml) exportWorkspace(ctx context.Context, scope string, format string) (string, error) {
+	_, err := authorize(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleViewer)
+	if err != nil {
+		return "", err
+	}
+
+	if !entity.IsExportFormat(format) {
+		return "", fmt.Errorf("%w: unknown export format %q", constants.ErrorInvalidRequest, format)
+	}
+
+	return membership.WorkspaceID, nil
+}
+
+// ExportLinks writes the links matching query, oldest first. The sort, cursor and limit of
+// query are ignored.
+func (s *ExportServiceIml) ExportLinks(ctx context.Context, format string, query entity.LinkQuery, w io.Writer) error {
+	workspace, err := s.exportWorkspace(ctx, entity.ScopeLinksRead, format)
+	if err != nil {
+		return err
+	}
+
+	query, err = normalizeLinkQuery(query)
+	if err != nil {
+		return err
+	}
+
+	rows, err := newExportWriter(format, w, linkExportColumns)
+	if err != nil {
+		return err
+	}
+
+	err = s.linkRepository.StreamLinks(ctx, workspace, query, func(link entity.ShortenedURL) error {
+		return rows.Write(linkExportRow(link))
+	})
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+// ExportClicks writes the clicks on the links matching query in the order they were recorded.
+func (s *ExportServiceIml) ExportClicks(ctx context.Context, format string, query entity.ClickQuery, w io.Writer) error {
+	workspace, err := s.exportWorkspace(ctx, entity.ScopeStatsRead, format)
+	if err != nil {
+		return err
+	}
+
+	query.Tag = normalizeTag(query.Tag)
+	query.Domain = entity.NormalizeHost(query.Domain)
+	if !query.From.IsZero() && !query.To.IsZero() && !query.To.After(query.From) {
+		return fmt.Errorf("%w: the date range ends before it starts", constants.ErrorInvalidRequest)
+	}
+
+	rows, err := newExportWriter(format, w, clickExportColumns)
+	if err != nil {
+		return err
+	}
+
+	err = s.clickRepository.StreamClicks(ctx, workspace, query, func(click entity.Click) error {
+		return rows.Write(clickExportRow(click))
+	})
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
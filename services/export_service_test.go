@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestExportServiceIml_ExportLinks(t *testing.T) {
+	t.Setenv("SERVICE_PROTOCOL", "https")
+	ctx := WithPrincipal(context.Background(), testUser)
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	tagged := ownedLink("deck")
+	tagged.Title = "Q3, deck"
+	tagged.Tags = []string{"sales", "q3"}
+	tagged.Clicks = 42
+	tagged.CreatedAt = &created
+	links := []entity.ShortenedURL{*tagged, *ownedLink("notes")}
+
+	t.Run("CSV", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		service := NewExportService(mockRepo, new(MockClickRepository), testWorkspaces)
+		mockRepo.On("StreamLinks", ctx, testWorkspace, mock.MatchedBy(func(query entity.LinkQuery) bool {
+			return query.Tag == "sales" && query.CreatedFrom.Equal(created)
+		})).Return(links, nil)
+
+		var out bytes.Buffer
+		err := service.ExportLinks(ctx, entity.ExportFormatCSV, entity.LinkQuery{Tag: " Sales ", CreatedFrom: created}, &out)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "domain,shortCode,shortURL,originalURL,fallbackURL,status,title,notes,tags,clicks,owner,createdAt,updatedAt,expiresAt\n"+
+			"short.url,deck,https://short.url/s/deck,https://example.com,,active,\"Q3, deck\",,sales;q3,42,user-1,2024-03-01T09:30:00Z,,\n"+
+			"short.url,notes,https://short.url/s/notes,https://example.com,,active,,,,0,user-1,,,\n", out.String())
+
+		imported, err := ParseImport(entity.ImportFormatCSV, out.Bytes())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.ImportLink{Source: "csv:deck", ShortCode: "deck", OriginalURL: "https://example.com", Title: "Q3, deck",
+			Tags: []string{"sales", "q3"}, CreatedAt: &created, Clicks: 42}, imported[0])
+	})
+
+	t.Run("JSONLines", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		service := NewExportService(mockRepo, new(MockClickRepository), testWorkspaces)
+		mockRepo.On("StreamLinks", ctx, testWorkspace, mock.Anything).Return(links[:1], nil)
+
+		var out bytes.Buffer
+		err := service.ExportLinks(ctx, entity.ExportFormatJSONLines, entity.LinkQuery{}, &out)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `{"domain":"short.url","shortCode":"deck","shortURL":"https://short.url/s/deck","originalURL":"https://example.com",`+
+			`"fallbackURL":"","status":"active","title":"Q3, deck","notes":"","tags":"sales;q3","clicks":42,"owner":"user-1",`+
+			`"createdAt":"2024-03-01T09:30:00Z"}`+"\n", out.String())
+	})
+
+	t.Run("Parquet", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		service := NewExportService(mockRepo, new(MockClickRepository), testWorkspaces)
+		mockRepo.On("StreamLinks", ctx, testWorkspace, mock.Anything).Return(links, nil)
+
+		var out bytes.Buffer
+		err := service.ExportLinks(ctx, entity.ExportFormatParquet, entity.LinkQuery{}, &out)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "PAR1", out.String()[:4])
+		assert.Equal(t, "PAR1", out.String()[out.Len()-4:])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		service := NewExportService(new(MockShortenedRepository), new(MockClickRepository), testWorkspaces)
+
+		err := service.ExportLinks(ctx, "xlsx", entity.LinkQuery{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		err = service.ExportLinks(ctx, entity.ExportFormatCSV, entity.LinkQuery{CreatedFrom: created, CreatedTo: created}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		err = service.ExportLinks(context.Background(), entity.ExportFormatCSV, entity.LinkQuery{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestExportServiceIml_ExportClicks(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	clicked := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		mockClicks := new(MockClickRepository)
+		workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleViewer}}
+		service := NewExportService(new(MockShortenedRepository), mockClicks, workspaces)
+		mockClicks.On("StreamClicks", ctx, testWorkspace, entity.ClickQuery{Tag: "sales", From: clicked}).
+			Return([]entity.Click{{Domain: testDomain, ShortCode: "deck", Fallback: true, Referrer: "https://news.example", CreatedAt: clicked}}, nil)
+
+		var out bytes.Buffer
+		err := service.ExportClicks(ctx, entity.ExportFormatCSV, entity.ClickQuery{Tag: "Sales", From: clicked}, &out)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "domain,shortCode,createdAt,fallback,referrer,userAgent\n"+
+			"short.url,deck,2024-03-01T09:30:00Z,true,https://news.example,\n", out.String())
+		mockClicks.AssertExpectations(t)
+	})
+
+	t.Run("NotMember", func(t *testing.T) {
+		workspaces := roleWorkspaceService{roles: map[string]string{}}
+		service := NewExportService(new(MockShortenedRepository), new(MockClickRepository), workspaces)
+
+		err := service.ExportClicks(ctx, entity.ExportFormatCSV, entity.ClickQuery{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/util"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parquetRowGroupSize is how many rows Parquet exports buffer before writing them
+const parquetRowGroupSize = 10000
+
+// the columns of link exports, which the generic CSV import reads back
+var linkExportColumns = []util.ParquetColumn{
+	{Name: "domain", Type: util.ParquetString},
+	{Name: "shortCode", Type: util.ParquetString},
+	{Name: "shortURL", Type: util.ParquetString},
+	{Name: "originalURL", Type: util.ParquetString},
+	{Name: "fallbackURL", Type: util.ParquetString},
+	{Name: "status", Type: util.ParquetString},
+	{Name: "title", Type: util.ParquetString},
+	{Name: "notes", Type: util.ParquetString},
+	{Name: "tags", Type: util.ParquetString},
+	{Name: "clicks", Type: util.ParquetInt64},
+	{Name: "owner", Type: util.ParquetString},
+	{Name: "createdAt", Type: util.ParquetTimestamp},
+	{Name: "updatedAt", Type: util.ParquetTimestamp},
+	{Name: "expiresAt", Type: util.ParquetTimestamp},
+}
+
+// linkExportRow returns the values of a link in the order of linkExportColumns, tags are
+// separated by semicolons
+func linkExportRow(link entity.ShortenedURL) []any {
+	_ = link.GenerateShortenedURL()
+
+	return []any{
+		link.Domain,
+		link.ShortCode,
+		link.ShortenedURL,
+		link.OriginalURL,
+		link.FallbackURL,
+		link.LinkStatus(),
+		link.Title,
+		link.Notes,
+		strings.Join(link.Tags, ";"),
+		link.Clicks,
+		link.Owner,
+		link.CreatedAt,
+		link.UpdatedAt,
+		link.ExpiresAt,
+	}
+}
+
+var clickExportColumns = []util.ParquetColumn{
+	{Name: "domain", Type: util.ParquetString},
+	{Name: "shortCode", Type: util.ParquetString},
+	{Name: "createdAt", Type: util.ParquetTimestamp},
+	{Name: "fallback", Type: util.ParquetBool},
+	{Name: "referrer", Type: util.ParquetString},
+	{Name: "userAgent", Type: util.ParquetString},
+}
+
+func clickExportRow(click entity.Click) []any {
+	return []any{click.Domain, click.ShortCode, click.CreatedAt, click.Fallback, click.Referrer, click.UserAgent}
+}
+
+// exportWriter writes the rows of an export in one of the entity.ExportFormat formats, Close
+// must be called once all rows are written.
+type exportWriter interface {
+	Write(row []any) error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, columns []util.ParquetColumn) (exportWriter, error) {
+	switch format {
+	case entity.ExportFormatCSV:
+		return newCSVExportWriter(w, columns)
+	case entity.ExportFormatJSONLines:
+		return &jsonLinesExportWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case entity.ExportFormatParquet:
+		return util.NewParquetWriter(w, columns, parquetRowGroupSize), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown export format %q", constants.ErrorInvalidRequest, format)
+	}
+}
+
+// exportValue returns a value of a row without pointers, nil for missing times
+func exportValue(value any) any {
+	if at, ok := value.(*time.Time); ok {
+		if at == nil {
+			return nil
+		}
+
+		return *at
+	}
+
+	return value
+}
+
+// csvExportWriter writes a header line and a line per row, times in RFC 3339 and missing
+// values as empty fields
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer, columns []util.ParquetColumn) (*csvExportWriter, error) {
+	writer := &csvExportWriter{w: csv.NewWriter(w)}
+
+	header := make([]string, len(columns))
+	for index, column := range columns {
+		header[index] = column.Name
+	}
+
+	return writer, writer.w.Write(header)
+}
+
+func (c *csvExportWriter) Write(row []any) error {
+	record := make([]string, len(row))
+	for index, value := range row {
+		switch v := exportValue(value).(type) {
+		case nil:
+		case string:
+			record[index] = v
+		case time.Time:
+			record[index] = v.UTC().Format(time.RFC3339)
+		default:
+			record[index] = fmt.Sprint(v)
+		}
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+// jsonLinesExportWriter writes a JSON object per row, keyed by the column names in order and
+// leaving out missing values
+type jsonLinesExportWriter struct {
+	w       *bufio.Writer
+	columns []util.ParquetColumn
+}
+
+func (j *jsonLinesExportWriter) Write(row []any) error {
+	line := []byte{'{'}
+	for index, value := range row {
+		value = exportValue(value)
+		if value == nil {
+			continue
+		}
+
+		if len(line) > 1 {
+			line = append(line, ',')
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		line = strconv.AppendQuote(line, j.columns[index].Name)
+		line = append(line, ':')
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+
+	_, err := j.w.Write(line)
+
+	return err
+}
+
+func (j *jsonLinesExportWriter) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"log"
+	"net/http"
+	"time"
+)
+
+// HealthChecker periodically probes the primary destination of every link that
+// has a fallback URL and records whether the primary is down, so redirects can
+// switch to the fallback and back again without manual intervention. Primaries on internal
+// addresses are never probed and count as down, so links cannot tell which internal hosts
+// are reachable.
+type HealthChecker struct {
+	repository repository.ShortenedRepository
+	client     *http.Client
+	interval   time.Duration
+}
+
+func NewHealthChecker(repo repository.ShortenedRepository, interval time.Duration, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		repository: repo,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: publicTransport(),
+			// a redirecting primary is still up, there is no need to follow it
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		interval: interval,
+	}
+}
+
+// Start runs a check every interval until ctx is cancelled.
+func (h *HealthChecker) Start(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecker) CheckAll(ctx context.Context) {
+	shortenedURLs, err := h.repository.GetWithFallback(ctx)
+	if err != nil {
+		log.Printf("health check: error getting shortened URLs with fallback %v\n", err)
+		return
+	}
+
+	for _, shortened := range *shortenedURLs {
+		down := !h.isHealthy(ctx, shortened.OriginalURL)
+		if down == shortened.PrimaryDown {
+			continue
+		}
+
+		if down {
+			log.Printf("health check: primary of %s/%s is down, redirecting to fallback\n", shortened.Domain, shortened.ShortCode)
+		} else {
+			log.Printf("health check: primary of %s/%s recovered\n", shortened.Domain, shortened.ShortCode)
+		}
+
+		err := h.repository.UpdatePrimaryDown(ctx, shortened.Domain, shortened.ShortCode, down)
+		if err != nil {
+			log.Printf("health check: error updating %s %v\n", shortened.ShortCode, err)
+		}
+	}
+}
+
+func (h *HealthChecker) isHealthy(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
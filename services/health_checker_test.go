@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/entity"
+)
+
+func TestHealthChecker_CheckAll(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	ctx := context.Background()
+
+	t.Run("MarksFailingPrimaryDown", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		checker := NewHealthChecker(mockRepo, time.Minute, time.Second)
+		allowLoopback(checker.client)
+
+		mockRepo.On("GetWithFallback", ctx).Return(&[]entity.ShortenedURL{
+			{Domain: testDomain, ShortCode: "abc123", OriginalURL: down.URL, FallbackURL: up.URL},
+		}, nil)
+		mockRepo.On("UpdatePrimaryDown", ctx, testDomain, "abc123", true).Return(nil)
+
+		checker.CheckAll(ctx)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("MarksRecoveredPrimaryUp", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		checker := NewHealthChecker(mockRepo, time.Minute, time.Second)
+		allowLoopback(checker.client)
+
+		mockRepo.On("GetWithFallback", ctx).Return(&[]entity.ShortenedURL{
+			{Domain: testDomain, ShortCode: "abc123", OriginalURL: up.URL, FallbackURL: down.URL, PrimaryDown: true},
+		}, nil)
+		mockRepo.On("UpdatePrimaryDown", ctx, testDomain, "abc123", false).Return(nil)
+
+		checker.CheckAll(ctx)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UnchangedStateIsNotWritten", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		checker := NewHealthChecker(mockRepo, time.Minute, time.Second)
+		allowLoopback(checker.client)
+
+		mockRepo.On("GetWithFallback", ctx).Return(&[]entity.ShortenedURL{
+			{Domain: testDomain, ShortCode: "abc123", OriginalURL: up.URL, FallbackURL: down.URL},
+		}, nil)
+
+		checker.CheckAll(ctx)
+
+		mockRepo.AssertNotCalled(t, "UpdatePrimaryDown")
+	})
+
+	t.Run("PrivatePrimaryIsDown", func(t *testing.T) {
+		mockRepo := new(MockShortenedRepository)
+		checker := NewHealthChecker(mockRepo, time.Minute, time.Second)
+
+		mockRepo.On("GetWithFallback", ctx).Return(&[]entity.ShortenedURL{
+			{Domain: testDomain, ShortCode: "abc123", OriginalURL: up.URL, FallbackURL: down.URL},
+		}, nil)
+		mockRepo.On("UpdatePrimaryDown", ctx, testDomain, "abc123", true).Return(nil)
+
+		checker.CheckAll(ctx)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
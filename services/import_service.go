@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxImportProblems = 1000
+	// importStaleAfter is how long a running job goes without progress before it counts as
+	// interrupted and can be resumed
+	importStaleAfter = 5 * time.Minute
+)
+
+// other shorteners allow shorter codes than aliases, which are kept as long as they need no escaping
+var importCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// importedLink validates an imported row and returns the link it creates, without its short
+// code when the code it had cannot be kept.
+func importedLink(row entity.ImportLink, now time.Time) (entity.ShortenedURL, error) {
+	link := entity.ShortenedURL{
+		OriginalURL:  strings.TrimSpace(row.OriginalURL),
+		Clicks:       row.Clicks,
+		ImportedFrom: row.Source,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    &now,
+	}
+
+	if link.CreatedAt == nil {
+		link.CreatedAt = &now
+	}
+
+	err := validateOriginalURL(link.OriginalURL)
+	if err != nil {
+		return link, err
+	}
+
+	if importCodePattern.MatchString(row.ShortCode) {
+		link.ShortCode = row.ShortCode
+	}
+
+	// titles of other shorteners may be longer, they are cut rather than rejected
+	title := strings.TrimSpace(row.Title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength])
+	}
+
+	details, err := normalizeDetails(entity.LinkDetails{Title: title, Tags: row.Tags})
+	if err != nil {
+		return link, err
+	}
+
+	link.Title = details.Title
+	if len(details.Tags) > 0 {
+		link.Tags = details.Tags
+	}
+
+	return link, nil
+}
+
+// ImportLinks creates the links of up to maxBulkLinks imported rows on domain in the selected
+// workspace, keeping their short codes when they are free. Rows imported before, recognized by
+// their source, are skipped so imports can be run again.
+func (s *ShortenedServiceIml) ImportLinks(ctx context.Context, domain string, rows []entity.ImportLink) (*[]entity.ImportResult, error) {
+	principal, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.domainService.CheckLinkDomain(ctx, domain, membership.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > maxBulkLinks {
+		return nil, fmt.Errorf("%w: more than %d links", constants.ErrorInvalidRequest, maxBulkLinks)
+	}
+
+	results := make([]entity.ImportResult, len(rows))
+	var sources []string
+	for index, row := range rows {
+		results[index] = entity.ImportResult{Row: index + 1, Source: row.Source}
+		if row.Source != "" {
+			sources = append(sources, row.Source)
+		}
+	}
+
+	// imported maps the sources already imported to their short codes
+	imported := map[string]string{}
+	if len(sources) > 0 {
+		links, err := s.repository.GetImported(ctx, membership.WorkspaceID, sources)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range *links {
+			imported[link.ImportedFrom] = link.ShortCode
+		}
+	}
+
+	now := time.Now()
+	pending := make([]bulkInsert, 0, len(rows))
+	for index, row := range rows {
+		if code, ok := imported[row.Source]; ok && row.Source != "" {
+			results[index].Status = entity.ImportExists
+			results[index].ShortCode = code
+			continue
+		}
+
+		link, err := importedLink(row, now)
+		if err != nil {
+			results[index].Status = entity.ImportFailed
+			results[index].Error = err.Error()
+			continue
+		}
+
+		imported[row.Source] = link.ShortCode
+
+		link.Domain = domain
+		link.Owner = principal.UserID
+		link.Workspace = membership.WorkspaceID
+		pending = append(pending, bulkInsert{index: index, link: link, generated: link.ShortCode == ""})
+	}
+
+	report := func(insert bulkInsert, link *entity.ShortenedURL, err error) {
+		result := &results[insert.index]
+
+		switch {
+		case err == nil:
+			result.Status = entity.ImportCreated
+			result.ShortCode = link.ShortCode
+		case errors.Is(err, constants.ErrorAlreadyExists):
+			result.Status = entity.ImportConflict
+			result.ShortCode = insert.link.ShortCode
+			result.Error = fmt.Sprintf("short code %q is already used on %s", insert.link.ShortCode, domain)
+		default:
+			result.Status = entity.ImportFailed
+			result.Error = err.Error()
+		}
+	}
+
+	for start := 0; start < len(pending); start += bulkBatchSize {
+		s.insertBatch(ctx, pending[start:min(start+bulkBatchSize, len(pending))], report)
+	}
+
+	return &results, nil
+}
+
+// ImportService imports the links of other shorteners into the selected workspace. An import
+// is a job that runs a batch at a time; jobs that are interrupted are resumed with the same
+// file, and rows imported before are skipped.
+type ImportService interface {
+	CreateImport(ctx context.Context, domain string, format string, name string, body []byte) (*entity.ImportJob, []entity.ImportLink, error)
+	ResumeImport(ctx context.Context, id string, body []byte) (*entity.ImportJob, []entity.ImportLink, error)
+	RunImport(ctx context.Context, job *entity.ImportJob, links []entity.ImportLink) (*entity.ImportJob, error)
+	GetImport(ctx context.Context, id string) (*entity.ImportJob, error)
+}
+
+type ImportServiceIml struct {
+	repository       repository.ImportRepository
+	shortenedService ShortenedService
+	workspaceService WorkspaceService
+}
+
+func NewImportService(repo repository.ImportRepository, shortenedService ShortenedService, workspaceService WorkspaceService) ImportService {
+	return &ImportServiceIml{
+		repository:       repo,
+		shortenedService: shortenedService,
+		workspaceService: workspaceService,
+	}
+}
+
+func importChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateImport reads an export in one of the entity.ImportFormat formats and records the job
+// importing its links on domain. RunImport runs it.
+func (s *ImportServiceIml) CreateImport(ctx context.Context, domain string, format string, name string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	principal, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	links, err := ParseImport(format, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	job := &entity.ImportJob{
+		ID:        bson.NewObjectID().Hex(),
+		Workspace: membership.WorkspaceID,
+		Domain:    domain,
+		Format:    format,
+		Name:      name,
+		Checksum:  importChecksum(body),
+		CreatedBy: principal.UserID,
+		Status:    entity.ImportStatusRunning,
+		Total:     len(links),
+		Problems:  []entity.ImportResult{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = s.repository.Insert(ctx, *job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return job, links, nil
+}
+
+// ResumeImport reopens a failed or interrupted job with the file it was created with, RunImport
+// carries on where it stopped.
+func (s *ImportServiceIml) ResumeImport(ctx context.Context, id string, body []byte) (*entity.ImportJob, []entity.ImportLink, error) {
+	_, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	job, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = s.workspaceService.RequireRole(ctx, job.Workspace, entity.RoleEditor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case job.Status == entity.ImportStatusCompleted:
+		return nil, nil, fmt.Errorf("%w: import %s is completed", constants.ErrorInvalidRequest, id)
+	case job.Status == entity.ImportStatusRunning && time.Since(job.UpdatedAt) < importStaleAfter:
+		return nil, nil, fmt.Errorf("%w: import %s is still running", constants.ErrorAlreadyExists, id)
+	case importChecksum(body) != job.Checksum:
+		return nil, nil, fmt.Errorf("%w: the file is not the one import %s was created with", constants.ErrorInvalidRequest, id)
+	}
+
+	links, err := ParseImport(job.Format, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	job.Status = entity.ImportStatusRunning
+	job.Error = ""
+	job.UpdatedAt = time.Now()
+
+	err = s.repository.Update(ctx, *job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return job, links, nil
+}
+
+// RunImport imports the links of a job from its position on, saving its progress after every
+// batch, and returns the job once it completed or failed.
+func (s *ImportServiceIml) RunImport(ctx context.Context, job *entity.ImportJob, links []entity.ImportLink) (*entity.ImportJob, error) {
+	ctx = WithWorkspace(ctx, job.Workspace)
+
+	for job.Position < len(links) {
+		end := min(job.Position+maxBulkLinks, len(links))
+
+		results, err := s.shortenedService.ImportLinks(ctx, job.Domain, links[job.Position:end])
+		if err != nil {
+			job.Status = entity.ImportStatusFailed
+			job.Error = err.Error()
+			s.save(ctx, job)
+
+			return job, err
+		}
+
+		for _, result := range *results {
+			result.Row += job.Position
+
+			switch result.Status {
+			case entity.ImportCreated:
+				job.Created++
+			case entity.ImportExists:
+				job.Existing++
+			case entity.ImportConflict:
+				job.Conflicts++
+			default:
+				job.Failed++
+			}
+
+			if result.Status != entity.ImportCreated && result.Status != entity.ImportExists && len(job.Problems) < maxImportProblems {
+				job.Problems = append(job.Problems, result)
+			}
+		}
+
+		job.Position = end
+		if job.Position == len(links) {
+			job.Status = entity.ImportStatusCompleted
+		}
+		s.save(ctx, job)
+	}
+
+	return job, nil
+}
+
+// save records the progress of a job, a job whose progress is lost only redoes its last rows
+func (s *ImportServiceIml) save(ctx context.Context, job *entity.ImportJob) {
+	job.UpdatedAt = time.Now()
+
+	err := s.repository.Update(context.WithoutCancel(ctx), *job)
+	if err != nil {
+		log.Printf("error saving import %s %v\n", job.ID, err)
+	}
+}
+
+// GetImport returns a job and its progress.
+func (s *ImportServiceIml) GetImport(ctx context.Context, id string) (*entity.ImportJob, error) {
+	_, err := authorize(ctx, entity.ScopeLinksRead)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.workspaceService.RequireRole(ctx, job.Workspace, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"testing"
+	"time"
+)
+
+// memoryImportRepository keeps import jobs in memory
+type memoryImportRepository struct {
+	jobs map[string]entity.ImportJob
+}
+
+func (m *memoryImportRepository) Insert(ctx context.Context, job entity.ImportJob) error {
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *memoryImportRepository) GetByID(ctx context.Context, id string) (*entity.ImportJob, error) {
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, constants.ErrorNotFound
+	}
+
+	return &job, nil
+}
+
+func (m *memoryImportRepository) Update(ctx context.Context, job entity.ImportJob) error {
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func TestShortenedServiceIml_ImportLinks(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	duplicate := mongo.WriteError{Code: 11000, Message: "duplicate key error"}
+
+	mockRepo := new(MockShortenedRepository)
+	audit := &memoryAuditService{}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+
+	rows := []entity.ImportLink{
+		{Source: "bitly:bit.ly/deck", ShortCode: "deck", OriginalURL: "https://example.com/deck", Tags: []string{"Sales"}, Clicks: 42},
+		{Source: "bitly:bit.ly/done", ShortCode: "done", OriginalURL: "https://example.com/done"},
+		{Source: "bitly:bit.ly/taken", ShortCode: "taken", OriginalURL: "https://example.com/taken"},
+		{Source: "bitly:bit.ly/a.b", ShortCode: "a.b", OriginalURL: "https://example.com/dotted"},
+		{Source: "bitly:bit.ly/bad", ShortCode: "bad", OriginalURL: "javascript:alert(1)"},
+	}
+	mockRepo.On("GetImported", ctx, testWorkspace, []string{"bitly:bit.ly/deck", "bitly:bit.ly/done", "bitly:bit.ly/taken", "bitly:bit.ly/a.b", "bitly:bit.ly/bad"}).
+		Return(&[]entity.ShortenedURL{{ShortCode: "done", ImportedFrom: "bitly:bit.ly/done"}}, nil)
+	mockRepo.On("InsertMany", ctx, mock.MatchedBy(func(links []entity.ShortenedURL) bool {
+		return len(links) == 3 && links[0].ShortCode == "deck" && links[1].ShortCode == "taken" && links[2].ShortCode != ""
+	})).Return([]error{nil, duplicate, nil}, nil)
+
+	results, err := service.ImportLinks(ctx, testDomain, rows)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, entity.ImportCreated, (*results)[0].Status)
+	assert.Equal(t, "deck", (*results)[0].ShortCode)
+	assert.Equal(t, entity.ImportExists, (*results)[1].Status)
+	assert.Equal(t, entity.ImportConflict, (*results)[2].Status)
+	assert.Contains(t, (*results)[2].Error, `"taken" is already used`)
+	assert.Equal(t, entity.ImportCreated, (*results)[3].Status)
+	assert.NotEqual(t, "a.b", (*results)[3].ShortCode)
+	assert.Equal(t, entity.ImportFailed, (*results)[4].Status)
+	mockRepo.AssertExpectations(t)
+	assert.Len(t, audit.entries, 2)
+
+	inserted := mockRepo.Calls[1].Arguments.Get(1).([]entity.ShortenedURL)[0]
+	assert.Equal(t, "bitly:bit.ly/deck", inserted.ImportedFrom)
+	assert.Equal(t, int64(42), inserted.Clicks)
+	assert.Equal(t, []string{"sales"}, inserted.Tags)
+	assert.Equal(t, testWorkspace, inserted.Workspace)
+}
+
+// importShortenedService answers ImportLinks with fixed statuses
+type importShortenedService struct {
+	ShortenedService
+	calls int
+	err   error
+}
+
+func (s *importShortenedService) ImportLinks(ctx context.Context, domain string, rows []entity.ImportLink) (*[]entity.ImportResult, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	results := make([]entity.ImportResult, len(rows))
+	for index, row := range rows {
+		results[index] = entity.ImportResult{Row: index + 1, Source: row.Source, Status: entity.ImportCreated}
+		if row.ShortCode == "taken" {
+			results[index].Status = entity.ImportConflict
+		}
+	}
+
+	return &results, nil
+}
+
+func TestImportServiceIml(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	body := []byte("destination,code\nhttps://example.com/a,a\nhttps://example.com/b,taken\n")
+
+	t.Run("Run", func(t *testing.T) {
+		repo := &memoryImportRepository{jobs: map[string]entity.ImportJob{}}
+		links := &importShortenedService{}
+		service := NewImportService(repo, links, testWorkspaces)
+
+		job, rows, err := service.CreateImport(ctx, testDomain, entity.ImportFormatCSV, "links.csv", body)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, testWorkspace, job.Workspace)
+		assert.Equal(t, 2, job.Total)
+		assert.Len(t, rows, 2)
+
+		job, err = service.RunImport(ctx, job, rows)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.ImportStatusCompleted, job.Status)
+		assert.Equal(t, 2, job.Position)
+		assert.Equal(t, 1, job.Created)
+		assert.Equal(t, 1, job.Conflicts)
+		assert.Equal(t, []entity.ImportResult{{Row: 2, Source: "csv:taken", Status: entity.ImportConflict}}, job.Problems)
+
+		saved, err := service.GetImport(ctx, job.ID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, job.Created, saved.Created)
+
+		_, _, err = service.ResumeImport(ctx, job.ID, body)
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		repo := &memoryImportRepository{jobs: map[string]entity.ImportJob{}}
+		links := &importShortenedService{err: constants.ErrorForbidden}
+		service := NewImportService(repo, links, testWorkspaces)
+
+		job, rows, err := service.CreateImport(ctx, testDomain, entity.ImportFormatCSV, "", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = service.RunImport(ctx, job, rows)
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+		assert.Equal(t, entity.ImportStatusFailed, repo.jobs[job.ID].Status)
+
+		_, _, err = service.ResumeImport(ctx, job.ID, []byte("destination\nhttps://example.com/other\n"))
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		links.err = nil
+		job, rows, err = service.ResumeImport(ctx, job.ID, body)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.ImportStatusRunning, job.Status)
+		assert.Empty(t, job.Error)
+
+		job, err = service.RunImport(ctx, job, rows)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entity.ImportStatusCompleted, job.Status)
+		assert.Equal(t, 2, links.calls)
+	})
+
+	t.Run("StillRunning", func(t *testing.T) {
+		repo := &memoryImportRepository{jobs: map[string]entity.ImportJob{}}
+		service := NewImportService(repo, &importShortenedService{}, testWorkspaces)
+
+		job, _, err := service.CreateImport(ctx, testDomain, entity.ImportFormatCSV, "", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = service.ResumeImport(ctx, job.ID, body)
+
+		assert.ErrorIs(t, err, constants.ErrorAlreadyExists)
+
+		stale := repo.jobs[job.ID]
+		stale.UpdatedAt = time.Now().Add(-importStaleAfter)
+		repo.jobs[job.ID] = stale
+
+		_, _, err = service.ResumeImport(ctx, job.ID, body)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleViewer}}
+		service := NewImportService(&memoryImportRepository{jobs: map[string]entity.ImportJob{}}, &importShortenedService{}, workspaces)
+
+		_, _, err := service.CreateImport(ctx, testDomain, entity.ImportFormatCSV, "", body)
+
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+}
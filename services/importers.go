@@ -0,0 +1,521 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxImportLinks bounds the rows of an import
+const maxImportLinks = 50000
+
+// importTimeLayouts are the creation times found in exports, YOURLS uses MySQL datetimes
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", time.DateOnly}
+
+// parseImportTime reads a creation time of an export, times it cannot read are left out
+func parseImportTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t := time.Unix(seconds, 0).UTC()
+		return &t
+	}
+
+	return nil
+}
+
+func parseImportClicks(value string) int64 {
+	clicks, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || clicks < 0 {
+		return 0
+	}
+
+	return clicks
+}
+
+func splitImportTags(value string) []string {
+	tags := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == '|'
+	})
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return tags
+}
+
+// shortLinkCode returns the short code of a short link, the last segment of its path, and the
+// link without its scheme to identify it.
+func shortLinkCode(link string) (string, string) {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return "", ""
+	}
+
+	path := strings.Trim(parsed.Path, "/")
+
+	return path[strings.LastIndex(path, "/")+1:], strings.ToLower(parsed.Host) + "/" + path
+}
+
+// importCSV reads the rows of a CSV export with a header row. columns maps the headers, lower
+// cased without spaces or underscores, to ImportLink fields; destination is required.
+func importCSV(body io.Reader, columns map[string]string, row func(record func(field string) string) entity.ImportLink) ([]entity.ImportLink, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid CSV header: %v", constants.ErrorInvalidRequest, err)
+	}
+
+	indexes := map[string]int{}
+	for index, name := range header {
+		name = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || r == '_' || r == '-' {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, strings.TrimPrefix(name, "\ufeff"))
+
+		if field, ok := columns[name]; ok {
+			if _, seen := indexes[field]; !seen {
+				indexes[field] = index
+			}
+		}
+	}
+
+	if _, ok := indexes["destination"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV has no destination column", constants.ErrorInvalidRequest)
+	}
+
+	var links []entity.ImportLink
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid CSV: %v", constants.ErrorInvalidRequest, err)
+		}
+
+		links = append(links, row(func(field string) string {
+			index, ok := indexes[field]
+			if !ok || index >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[index])
+		}))
+	}
+
+	return links, nil
+}
+
+// bitlyColumns maps the headers of the Bitly link exports
+var bitlyColumns = map[string]string{
+	"bitlink":     "link",
+	"link":        "link",
+	"shortlink":   "link",
+	"longurl":     "destination",
+	"title":       "title",
+	"tags":        "tags",
+	"created":     "created",
+	"createdat":   "created",
+	"datecreated": "created",
+	"clicks":      "clicks",
+	"totalclicks": "clicks",
+}
+
+// parseBitly reads a Bitly CSV export, its back-halves become the short codes.
+func parseBitly(body io.Reader) ([]entity.ImportLink, error) {
+	return importCSV(body, bitlyColumns, func(record func(field string) string) entity.ImportLink {
+		code, link := shortLinkCode(record("link"))
+
+		imported := entity.ImportLink{
+			ShortCode:   code,
+			OriginalURL: record("destination"),
+			Title:       record("title"),
+			Tags:        splitImportTags(record("tags")),
+			CreatedAt:   parseImportTime(record("created")),
+			Clicks:      parseImportClicks(record("clicks")),
+		}
+		if link != "" {
+			imported.Source = entity.ImportFormatBitly + ":" + link
+		}
+
+		return imported
+	})
+}
+
+// csvColumns maps the headers of the generic CSV
+var csvColumns = map[string]string{
+	"destination": "destination",
+	"originalurl": "destination",
+	"url":         "destination",
+	"code":        "code",
+	"shortcode":   "code",
+	"alias":       "code",
+	"title":       "title",
+	"tags":        "tags",
+	"created":     "created",
+	"createdat":   "created",
+	"clicks":      "clicks",
+}
+
+// parseCSV reads the generic CSV, rows without a code are identified by their destination.
+func parseCSV(body io.Reader) ([]entity.ImportLink, error) {
+	return importCSV(body, csvColumns, func(record func(field string) string) entity.ImportLink {
+		imported := entity.ImportLink{
+			ShortCode:   record("code"),
+			OriginalURL: record("destination"),
+			Title:       record("title"),
+			Tags:        splitImportTags(record("tags")),
+			CreatedAt:   parseImportTime(record("created")),
+			Clicks:      parseImportClicks(record("clicks")),
+		}
+
+		imported.Source = entity.ImportFormatCSV + ":" + imported.ShortCode
+		if imported.ShortCode == "" {
+			imported.Source = entity.ImportFormatCSV + ":" + imported.OriginalURL
+		}
+
+		return imported
+	})
+}
+
+// yourlsLink is a link of a YOURLS JSON dump, either a row of its yourls_url table or a link of
+// its stats API, which has the short URL instead of the keyword.
+type yourlsLink struct {
+	Keyword   string          `json:"keyword"`
+	ShortURL  string          `json:"shorturl"`
+	URL       string          `json:"url"`
+	Title     string          `json:"title"`
+	Timestamp string          `json:"timestamp"`
+	Clicks    json.RawMessage `json:"clicks"`
+}
+
+func (l yourlsLink) importLink() entity.ImportLink {
+	keyword := l.Keyword
+	if keyword == "" {
+		keyword, _ = shortLinkCode(l.ShortURL)
+	}
+
+	imported := entity.ImportLink{
+		ShortCode:   keyword,
+		OriginalURL: l.URL,
+		Title:       l.Title,
+		CreatedAt:   parseImportTime(l.Timestamp),
+		Clicks:      parseImportClicks(strings.Trim(string(l.Clicks), `"`)),
+	}
+	if keyword != "" {
+		imported.Source = entity.ImportFormatYOURLS + ":" + keyword
+	}
+
+	return imported
+}
+
+// parseYOURLSJSON reads a JSON array of links, or the response of the stats API whose links
+// are keyed link_1, link_2 and so on.
+func parseYOURLSJSON(body []byte) ([]entity.ImportLink, error) {
+	var rows []yourlsLink
+	if err := json.Unmarshal(body, &rows); err != nil {
+		var stats struct {
+			Links map[string]yourlsLink `json:"links"`
+		}
+		if err := json.Unmarshal(body, &stats); err != nil {
+			return nil, fmt.Errorf("%w: invalid YOURLS JSON: %v", constants.ErrorInvalidRequest, err)
+		}
+
+		keys := make([]string, 0, len(stats.Links))
+		for key := range stats.Links {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(strings.TrimPrefix(keys[i], "link_"))
+			b, _ := strconv.Atoi(strings.TrimPrefix(keys[j], "link_"))
+			return a < b
+		})
+
+		for _, key := range keys {
+			rows = append(rows, stats.Links[key])
+		}
+	}
+
+	links := make([]entity.ImportLink, 0, len(rows))
+	for _, row := range rows {
+		links = append(links, row.importLink())
+	}
+
+	return links, nil
+}
+
+// yourlsColumns are the columns of the yourls_url table, in the order of its dumps
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// sqlScanner reads the INSERT statements of a SQL dump
+type sqlScanner struct {
+	reader *bufio.Reader
+}
+
+// skipSpace skips whitespace and returns the next byte without consuming it
+func (s *sqlScanner) skipSpace() (byte, error) {
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if !unicode.IsSpace(rune(b)) {
+			return b, s.reader.UnreadByte()
+		}
+	}
+}
+
+// literal reads a quoted string, a number or NULL
+func (s *sqlScanner) literal() (string, error) {
+	b, err := s.skipSpace()
+	if err != nil {
+		return "", err
+	}
+
+	if b != '\'' {
+		var value strings.Builder
+		for {
+			b, err := s.reader.ReadByte()
+			if err != nil {
+				return "", err
+			}
+
+			if b == ',' || b == ')' || unicode.IsSpace(rune(b)) {
+				if value.String() == "NULL" {
+					return "", s.reader.UnreadByte()
+				}
+				return value.String(), s.reader.UnreadByte()
+			}
+			value.WriteByte(b)
+		}
+	}
+
+	_, _ = s.reader.ReadByte()
+
+	var value strings.Builder
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch b {
+		case '\\':
+			escaped, err := s.reader.ReadByte()
+			if err != nil {
+				return "", err
+			}
+
+			switch escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '0':
+				value.WriteByte(0)
+			default:
+				value.WriteByte(escaped)
+			}
+		case '\'':
+			next, err := s.reader.ReadByte()
+			if err == nil && next == '\'' {
+				value.WriteByte('\'')
+				continue
+			}
+
+			if err == nil {
+				_ = s.reader.UnreadByte()
+			}
+
+			return value.String(), nil
+		default:
+			value.WriteByte(b)
+		}
+	}
+}
+
+// tuple reads a parenthesized list of literals
+func (s *sqlScanner) tuple() ([]string, error) {
+	var values []string
+	_, _ = s.reader.ReadByte()
+
+	for {
+		value, err := s.literal()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		b, err := s.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+		_, _ = s.reader.ReadByte()
+
+		if b == ')' {
+			return values, nil
+		}
+
+		if b != ',' {
+			return nil, fmt.Errorf("unexpected %q in a row", b)
+		}
+	}
+}
+
+// parseYOURLSSQL reads the rows inserted into the yourls_url table, or any table whose name
+// ends with url, of a mysqldump. The other tables are skipped.
+func parseYOURLSSQL(body []byte) ([]entity.ImportLink, error) {
+	var links []entity.ImportLink
+
+	for _, statement := range bytes.Split(body, []byte("INSERT INTO "))[1:] {
+		scanner := &sqlScanner{reader: bufio.NewReader(bytes.NewReader(statement))}
+
+		header, err := scanner.reader.ReadString('(')
+		if err != nil {
+			continue
+		}
+
+		table, rest, _ := strings.Cut(strings.TrimSpace(strings.TrimSuffix(header, "(")), " ")
+		table = strings.Trim(table, "`\"")
+		if table != "url" && !strings.HasSuffix(table, "_url") {
+			continue
+		}
+
+		columns := yourlsColumns
+		_ = scanner.reader.UnreadByte()
+
+		if !strings.Contains(strings.ToUpper(rest), "VALUES") {
+			list, err := scanner.reader.ReadString(')')
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid YOURLS SQL: %v", constants.ErrorInvalidRequest, err)
+			}
+
+			columns = nil
+			for _, column := range strings.Split(strings.Trim(list, "()"), ",") {
+				columns = append(columns, strings.Trim(strings.TrimSpace(column), "`\""))
+			}
+
+			values, err := scanner.reader.ReadString('(')
+			if err != nil || !strings.Contains(strings.ToUpper(values), "VALUES") {
+				return nil, fmt.Errorf("%w: invalid YOURLS SQL: no VALUES", constants.ErrorInvalidRequest)
+			}
+			_ = scanner.reader.UnreadByte()
+		}
+
+		for {
+			values, err := scanner.tuple()
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid YOURLS SQL: %v", constants.ErrorInvalidRequest, err)
+			}
+
+			row := yourlsLink{}
+			for index, column := range columns {
+				if index >= len(values) {
+					break
+				}
+
+				switch column {
+				case "keyword":
+					row.Keyword = values[index]
+				case "url":
+					row.URL = values[index]
+				case "title":
+					row.Title = values[index]
+				case "timestamp":
+					row.Timestamp = values[index]
+				case "clicks":
+					row.Clicks = json.RawMessage(strconv.Quote(values[index]))
+				}
+			}
+			links = append(links, row.importLink())
+
+			b, err := scanner.skipSpace()
+			if err != nil || b != ',' {
+				break
+			}
+			_, _ = scanner.reader.ReadByte()
+			if _, err := scanner.skipSpace(); err != nil {
+				break
+			}
+		}
+	}
+
+	return links, nil
+}
+
+// parseYOURLS reads a YOURLS dump, in JSON when it starts like JSON and in SQL otherwise.
+func parseYOURLS(body []byte) ([]entity.ImportLink, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\ufeff")))
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseYOURLSJSON(trimmed)
+	}
+
+	return parseYOURLSSQL(trimmed)
+}
+
+// ParseImport reads the links of an export in one of the entity.ImportFormat formats.
+func ParseImport(format string, body []byte) ([]entity.ImportLink, error) {
+	var links []entity.ImportLink
+	var err error
+
+	switch format {
+	case entity.ImportFormatBitly:
+		links, err = parseBitly(bytes.NewReader(body))
+	case entity.ImportFormatYOURLS:
+		links, err = parseYOURLS(body)
+	case entity.ImportFormatCSV:
+		links, err = parseCSV(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("%w: unknown import format %q", constants.ErrorInvalidRequest, format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(links) == 0 {
+		return nil, fmt.Errorf("%w: no links found", constants.ErrorInvalidRequest)
+	}
+
+	if len(links) > maxImportLinks {
+		return nil, fmt.Errorf("%w: more than %d links", constants.ErrorInvalidRequest, maxImportLinks)
+	}
+
+	return links, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseImport(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	t.Run("Bitly", func(t *testing.T) {
+		body := "Title,Bitlink,Long URL,Date Created,Tags,Total Clicks\n" +
+			"Q3 deck,https://bit.ly/3xYz12,https://example.com/deck,2024-03-01T09:30:00Z,\"sales,q3\",42\n" +
+			"Pricing,bit.ly/pricing,https://example.com/pricing,,,\n"
+
+		links, err := ParseImport(entity.ImportFormatBitly, []byte(body))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.ImportLink{
+			{Source: "bitly:bit.ly/3xYz12", ShortCode: "3xYz12", OriginalURL: "https://example.com/deck", Title: "Q3 deck",
+				Tags: []string{"sales", "q3"}, CreatedAt: &created, Clicks: 42},
+			{Source: "bitly:bit.ly/pricing", ShortCode: "pricing", OriginalURL: "https://example.com/pricing", Title: "Pricing"},
+		}, links)
+	})
+
+	t.Run("YOURLSSQL", func(t *testing.T) {
+		body := "-- MySQL dump\n" +
+			"INSERT INTO `yourls_options` VALUES (1,'version','1.9');\n" +
+			"INSERT INTO `yourls_url` VALUES ('ozh','https://ozh.org/?a=1&b=2','Ozh\\'s blog','2024-03-01 09:30:00','127.0.0.1',7)," +
+			"\n('x','https://example.com','It''s here','2024-03-01 09:30:00','127.0.0.1',0);\n" +
+			"INSERT INTO `yourls_url` (`url`, `keyword`, `title`) VALUES ('https://example.com/a, b', 'ab', NULL);\n"
+
+		links, err := ParseImport(entity.ImportFormatYOURLS, []byte(body))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.ImportLink{
+			{Source: "yourls:ozh", ShortCode: "ozh", OriginalURL: "https://ozh.org/?a=1&b=2", Title: "Ozh's blog", CreatedAt: &created, Clicks: 7},
+			{Source: "yourls:x", ShortCode: "x", OriginalURL: "https://example.com", Title: "It's here", CreatedAt: &created},
+			{Source: "yourls:ab", ShortCode: "ab", OriginalURL: "https://example.com/a, b"},
+		}, links)
+	})
+
+	t.Run("YOURLSJSON", func(t *testing.T) {
+		stats := `{"links": {"link_2": {"shorturl": "https://sho.rt/b", "url": "https://example.com/b", "clicks": "3"},
+			"link_1": {"shorturl": "https://sho.rt/a", "url": "https://example.com/a", "timestamp": "2024-03-01 09:30:00", "clicks": "1"}}}`
+		rows := `[{"keyword": "a", "url": "https://example.com/a", "title": "A", "clicks": 1}]`
+
+		links, err := ParseImport(entity.ImportFormatYOURLS, []byte(stats))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.ImportLink{
+			{Source: "yourls:a", ShortCode: "a", OriginalURL: "https://example.com/a", CreatedAt: &created, Clicks: 1},
+			{Source: "yourls:b", ShortCode: "b", OriginalURL: "https://example.com/b", Clicks: 3},
+		}, links)
+
+		links, err = ParseImport(entity.ImportFormatYOURLS, []byte(rows))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.ImportLink{{Source: "yourls:a", ShortCode: "a", OriginalURL: "https://example.com/a", Title: "A", Clicks: 1}}, links)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		body := "destination,code,title,tags,created,clicks\n" +
+			"https://example.com/deck,q3-deck,Deck,sales;q3,2024-03-01T09:30:00Z,5\n" +
+			"https://example.com/notes,,,,,\n"
+
+		links, err := ParseImport(entity.ImportFormatCSV, []byte(body))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []entity.ImportLink{
+			{Source: "csv:q3-deck", ShortCode: "q3-deck", OriginalURL: "https://example.com/deck", Title: "Deck",
+				Tags: []string{"sales", "q3"}, CreatedAt: &created, Clicks: 5},
+			{Source: "csv:https://example.com/notes", OriginalURL: "https://example.com/notes"},
+		}, links)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		inputs := map[string]string{
+			entity.ImportFormatBitly:  "Title,Bitlink\nDeck,bit.ly/a\n",
+			entity.ImportFormatCSV:    "destination\n",
+			entity.ImportFormatYOURLS: "INSERT INTO `yourls_url` VALUES ('a','https://example.com'",
+			"rebrandly":               "destination\nhttps://example.com\n",
+		}
+
+		for format, body := range inputs {
+			_, err := ParseImport(format, []byte(body))
+
+			assert.ErrorIs(t, err, constants.ErrorInvalidRequest, format)
+		}
+	})
+}
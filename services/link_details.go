@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTitleLength  = 200
+	maxNotesLength  = 2000
+	maxTags         = 20
+	maxTagLength    = 50
+	maxSearchLength = 200
+
+	defaultLinkPageSize = 50
+	maxLinkPageSize     = 200
+)
+
+// normalizeTag makes tags case insensitive
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeDetails trims the details of a link and removes empty and repeated tags.
+func normalizeDetails(details entity.LinkDetails) (entity.LinkDetails, error) {
+	details.Title = strings.TrimSpace(details.Title)
+	details.Notes = strings.TrimSpace(details.Notes)
+
+	if utf8.RuneCountInString(details.Title) > maxTitleLength {
+		return details, fmt.Errorf("%w: title longer than %d characters", constants.ErrorInvalidRequest, maxTitleLength)
+	}
+
+	if utf8.RuneCountInString(details.Notes) > maxNotesLength {
+		return details, fmt.Errorf("%w: notes longer than %d characters", constants.ErrorInvalidRequest, maxNotesLength)
+	}
+
+	tags := make([]string, 0, len(details.Tags))
+	seen := map[string]bool{}
+	for _, tag := range details.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return details, fmt.Errorf("%w: tag %q longer than %d characters", constants.ErrorInvalidRequest, tag, maxTagLength)
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > maxTags {
+		return details, fmt.Errorf("%w: more than %d tags", constants.ErrorInvalidRequest, maxTags)
+	}
+
+	details.Tags = tags
+
+	return details, nil
+}
+
+// normalizeLinkQuery validates the filters and sort order of a link listing and bounds its page size.
+func normalizeLinkQuery(query entity.LinkQuery) (entity.LinkQuery, error) {
+	query.Search = strings.TrimSpace(query.Search)
+	query.Tag = normalizeTag(query.Tag)
+	query.Domain = entity.NormalizeHost(query.Domain)
+
+	if utf8.RuneCountInString(query.Search) > maxSearchLength {
+		return query, fmt.Errorf("%w: search longer than %d characters", constants.ErrorInvalidRequest, maxSearchLength)
+	}
+
+	if query.Sort == "" {
+		query.Sort = entity.DefaultLinkSort
+	}
+
+	if !entity.IsLinkSort(query.Sort) {
+		return query, fmt.Errorf("%w: unknown sort %q", constants.ErrorInvalidRequest, query.Sort)
+	}
+
+	if query.Status != "" && !entity.IsLinkStatus(query.Status) {
+		return query, fmt.Errorf("%w: unknown status %q", constants.ErrorInvalidRequest, query.Status)
+	}
+
+	if !query.CreatedFrom.IsZero() && !query.CreatedTo.IsZero() && !query.CreatedTo.After(query.CreatedFrom) {
+		return query, fmt.Errorf("%w: the date range ends before it starts", constants.ErrorInvalidRequest)
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultLinkPageSize
+	} else if query.Limit > maxLinkPageSize {
+		query.Limit = maxLinkPageSize
+	}
+
+	return query, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"golang.org/x/net/html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, pageURL string) (*entity.Metadata, error)
+}
+
+// HTTPMetadataFetcher reads the title, description, favicon and og:image of a page.
+// Only the first maxBytes of the response body are parsed, and only public addresses are
+// requested so links cannot read the pages of internal hosts.
+type HTTPMetadataFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func NewHTTPMetadataFetcher(timeout time.Duration, maxBytes int64) *HTTPMetadataFetcher {
+	return &HTTPMetadataFetcher{
+		client:   &http.Client{Timeout: timeout, Transport: publicTransport()},
+		maxBytes: maxBytes,
+	}
+}
+
+func (f *HTTPMetadataFetcher) Fetch(ctx context.Context, pageURL string) (*entity.Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("unexpected status fetching metadata: %d", resp.StatusCode)
+	}
+
+	// redirects are followed, relative links resolve against the final page
+	metadata := parseMetadata(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	metadata.FetchedAt = time.Now()
+
+	return metadata, nil
+}
+
+func parseMetadata(r io.Reader, base *url.URL) *entity.Metadata {
+	metadata := &entity.Metadata{}
+	var ogTitle, ogDescription string
+	tokenizer := html.NewTokenizer(r)
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		switch token.Data {
+		case "title":
+			if metadata.Title == "" && tokenizer.Next() == html.TextToken {
+				metadata.Title = strings.TrimSpace(string(tokenizer.Text()))
+			}
+		case "meta":
+			name := strings.ToLower(attr(token, "name"))
+			if name == "" {
+				name = strings.ToLower(attr(token, "property"))
+			}
+			content := strings.TrimSpace(attr(token, "content"))
+
+			switch name {
+			case "description":
+				metadata.Description = content
+			case "og:title":
+				ogTitle = content
+			case "og:description":
+				ogDescription = content
+			case "og:image":
+				metadata.ImageURL = resolveURL(base, content)
+			}
+		case "link":
+			rel := strings.Fields(strings.ToLower(attr(token, "rel")))
+			for _, r := range rel {
+				if r == "icon" && metadata.FaviconURL == "" {
+					metadata.FaviconURL = resolveURL(base, attr(token, "href"))
+				}
+			}
+		case "body":
+			// everything we are interested in lives in <head>
+			return withOpenGraphDefaults(metadata, ogTitle, ogDescription, base)
+		}
+	}
+
+	return withOpenGraphDefaults(metadata, ogTitle, ogDescription, base)
+}
+
+func withOpenGraphDefaults(metadata *entity.Metadata, ogTitle string, ogDescription string, base *url.URL) *entity.Metadata {
+	if metadata.Title == "" {
+		metadata.Title = ogTitle
+	}
+	if metadata.Description == "" {
+		metadata.Description = ogDescription
+	}
+	if metadata.FaviconURL == "" {
+		metadata.FaviconURL = resolveURL(base, "/favicon.ico")
+	}
+
+	return metadata
+}
+
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	return base.ResolveReference(parsed).String()
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+// allowLoopback lets a client reach the test servers, which listen on the loopback address
+// the transport of destinations refuses
+func allowLoopback(client *http.Client) {
+	client.Transport = http.DefaultTransport
+}
+
+const metadataPage = `<!DOCTYPE html>
+<html>
+<head>
+    <title> Q3 Planning Deck </title>
+    <meta name="description" content="Goals for the third quarter">
+    <meta property="og:image" content="/images/cover.png">
+    <link rel="shortcut icon" href="/static/icon.png">
+</head>
+<body><meta name="description" content="ignored"></body>
+</html>`
+
+func TestHTTPMetadataFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/deck":
+			_, _ = w.Write([]byte(metadataPage))
+		case "/og-only":
+			_, _ = w.Write([]byte(`<head><meta property="og:title" content="OG Title"><meta property="og:description" content="OG Description"></head>`))
+		case "/large":
+			_, _ = w.Write([]byte("<head>" + strings.Repeat("<!-- padding -->", 100) + "<title>Too far</title></head>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		fetcher := NewHTTPMetadataFetcher(time.Second, 1<<20)
+		allowLoopback(fetcher.client)
+
+		metadata, err := fetcher.Fetch(ctx, server.URL+"/deck")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Q3 Planning Deck", metadata.Title)
+		assert.Equal(t, "Goals for the third quarter", metadata.Description)
+		assert.Equal(t, server.URL+"/images/cover.png", metadata.ImageURL)
+		assert.Equal(t, server.URL+"/static/icon.png", metadata.FaviconURL)
+		assert.False(t, metadata.FetchedAt.IsZero())
+	})
+
+	t.Run("OpenGraphDefaults", func(t *testing.T) {
+		fetcher := NewHTTPMetadataFetcher(time.Second, 1<<20)
+		allowLoopback(fetcher.client)
+
+		metadata, err := fetcher.Fetch(ctx, server.URL+"/og-only")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "OG Title", metadata.Title)
+		assert.Equal(t, "OG Description", metadata.Description)
+		assert.Equal(t, server.URL+"/favicon.ico", metadata.FaviconURL)
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		fetcher := NewHTTPMetadataFetcher(time.Second, 512)
+		allowLoopback(fetcher.client)
+
+		metadata, err := fetcher.Fetch(ctx, server.URL+"/large")
+
+		assert.NoError(t, err)
+		assert.Empty(t, metadata.Title)
+	})
+
+	t.Run("ErrorStatus", func(t *testing.T) {
+		fetcher := NewHTTPMetadataFetcher(time.Second, 1<<20)
+		allowLoopback(fetcher.client)
+
+		metadata, err := fetcher.Fetch(ctx, server.URL+"/missing")
+
+		assert.Error(t, err)
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("PrivateAddress", func(t *testing.T) {
+		fetcher := NewHTTPMetadataFetcher(time.Second, 1<<20)
+
+		metadata, err := fetcher.Fetch(ctx, server.URL+"/deck")
+
+		assert.ErrorIs(t, err, constants.ErrorPrivateAddress)
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("RedirectToPrivateAddress", func(t *testing.T) {
+		redirect := func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, server.URL+"/deck", http.StatusFound)
+		}
+		fetcher := NewHTTPMetadataFetcher(time.Second, 1<<20)
+		// the first request is answered without dialing, the redirect is dialed
+		fetcher.client.Transport = &redirectTransport{redirect: redirect, next: fetcher.client.Transport}
+
+		metadata, err := fetcher.Fetch(ctx, "http://public.example/start")
+
+		assert.ErrorIs(t, err, constants.ErrorPrivateAddress)
+		assert.Nil(t, metadata)
+	})
+}
+
+// redirectTransport answers requests to public.example with redirect and sends the others on
+type redirectTransport struct {
+	redirect http.HandlerFunc
+	next     http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "public.example" {
+		return t.next.RoundTrip(req)
+	}
+
+	recorder := httptest.NewRecorder()
+	t.redirect(recorder, req)
+
+	return recorder.Result(), nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"log"
+	"time"
+)
+
+// migrationLockTTL is how long the migration lock is held without being extended, migrations
+// extend it before each of them, so one migration may run this long before another instance
+// considers its owner gone.
+const migrationLockTTL = 10 * time.Minute
+
+// Migration changes the database from one version to the next. Up has to be safe to run again,
+// an instance stopping between applying a migration and recording it applies it again.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context) error
+}
+
+// Migrator applies the migrations the database has not had yet, in the order of their versions,
+// and records them. Migrations are never changed once released, changes of the database are new
+// migrations with a higher version.
+type Migrator interface {
+	Migrate(ctx context.Context) ([]entity.Migration, error)
+	Status(ctx context.Context) ([]entity.Migration, error)
+}
+
+type MigratorIml struct {
+	repository repository.MigrationRepository
+	migrations []Migration
+	// retryInterval is how often the lock is tried while another instance migrates
+	retryInterval time.Duration
+}
+
+func NewMigrator(repo repository.MigrationRepository, migrations []Migration, retryInterval time.Duration) *MigratorIml {
+	return &MigratorIml{repository: repo, migrations: migrations, retryInterval: retryInterval}
+}
+
+// checkOrder refuses migrations out of the order of their versions, or sharing one
+func (m *MigratorIml) checkOrder() error {
+	for index := 1; index < len(m.migrations); index++ {
+		if m.migrations[index].Version <= m.migrations[index-1].Version {
+			return fmt.Errorf("migration %d %s is out of order", m.migrations[index].Version, m.migrations[index].Name)
+		}
+	}
+
+	return nil
+}
+
+// lock takes the migration lock for owner, waiting for instances migrating at the same time
+func (m *MigratorIml) lock(ctx context.Context, owner string) error {
+	waiting := false
+
+	for {
+		err := m.repository.Lock(ctx, owner, migrationLockTTL)
+		if !errors.Is(err, constants.ErrorMigrationLocked) {
+			return err
+		}
+
+		if !waiting {
+			log.Println("migrations: waiting for another instance to finish migrating")
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.retryInterval):
+		}
+	}
+}
+
+// Migrate applies the pending migrations and returns them. It stops at the first one failing,
+// returning the ones applied before it, the next run starts again with the failed one.
+func (m *MigratorIml) Migrate(ctx context.Context) ([]entity.Migration, error) {
+	err := m.checkOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.lock(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := m.repository.Unlock(context.WithoutCancel(ctx), owner)
+		if err != nil {
+			log.Printf("migrations: error releasing the lock %v\n", err)
+		}
+	}()
+
+	// the lock is held, so no migration is applied meanwhile
+	applied, err := m.repository.ListApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		versions[migration.Version] = true
+	}
+
+	if len(applied) > 0 && len(m.migrations) > 0 && applied[len(applied)-1].Version > m.migrations[len(m.migrations)-1].Version {
+		log.Printf("migrations: the database has migration %d, newer than this version knows\n", applied[len(applied)-1].Version)
+	}
+
+	migrated := make([]entity.Migration, 0)
+	for _, migration := range m.migrations {
+		if versions[migration.Version] {
+			continue
+		}
+
+		err = m.repository.Lock(ctx, owner, migrationLockTTL)
+		if err != nil {
+			return migrated, err
+		}
+
+		log.Printf("migrations: applying %d %s\n", migration.Version, migration.Name)
+
+		err = migration.Up(ctx)
+		if err != nil {
+			return migrated, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		appliedAt := time.Now()
+		record := entity.Migration{Version: migration.Version, Name: migration.Name, AppliedAt: &appliedAt}
+
+		err = m.repository.Record(ctx, record)
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated = append(migrated, record)
+	}
+
+	return migrated, nil
+}
+
+// Status returns the migrations of this version, with the time they were applied, followed by
+// the ones applied by newer versions.
+func (m *MigratorIml) Status(ctx context.Context) ([]entity.Migration, error) {
+	applied, err := m.repository.ListApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]*time.Time, len(applied))
+	for _, migration := range applied {
+		appliedAt[migration.Version] = migration.AppliedAt
+	}
+
+	status := make([]entity.Migration, 0, len(m.migrations))
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		status = append(status, entity.Migration{Version: migration.Version, Name: migration.Name, AppliedAt: appliedAt[migration.Version]})
+		known[migration.Version] = true
+	}
+
+	for _, migration := range applied {
+		if !known[migration.Version] {
+			status = append(status, migration)
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// memoryMigrationRepository keeps applied migrations in memory, the lock is refused to the
+// first lockedFor attempts
+type memoryMigrationRepository struct {
+	applied   []entity.Migration
+	owner     string
+	lockedFor int
+	attempts  int
+}
+
+func (m *memoryMigrationRepository) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	m.attempts++
+	if m.attempts <= m.lockedFor || (m.owner != "" && m.owner != owner) {
+		return constants.ErrorMigrationLocked
+	}
+
+	m.owner = owner
+	return nil
+}
+
+func (m *memoryMigrationRepository) Unlock(ctx context.Context, owner string) error {
+	if m.owner == owner {
+		m.owner = ""
+	}
+	return nil
+}
+
+func (m *memoryMigrationRepository) ListApplied(ctx context.Context) ([]entity.Migration, error) {
+	return append([]entity.Migration{}, m.applied...), nil
+}
+
+func (m *memoryMigrationRepository) Record(ctx context.Context, migration entity.Migration) error {
+	m.applied = append(m.applied, migration)
+	return nil
+}
+
+func (m *memoryMigrationRepository) SetValidator(ctx context.Context, collection string, schema bson.D) error {
+	return nil
+}
+
+func (m *memoryMigrationRepository) CreateIndexes(ctx context.Context, collection string, indexes []mongo.IndexModel) error {
+	return nil
+}
+
+func TestMigratorIml_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	var ran []int
+	migration := func(version int, err error) Migration {
+		return Migration{Version: version, Name: "step", Up: func(ctx context.Context) error {
+			ran = append(ran, version)
+			return err
+		}}
+	}
+
+	t.Run("applies the pending migrations in order", func(t *testing.T) {
+		ran = nil
+		appliedAt := time.Now()
+		repo := &memoryMigrationRepository{applied: []entity.Migration{{Version: 1, Name: "step", AppliedAt: &appliedAt}}}
+		migrator := NewMigrator(repo, []Migration{migration(1, nil), migration(2, nil), migration(3, nil)}, time.Millisecond)
+
+		migrated, err := migrator.Migrate(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2, 3}, ran)
+		assert.Len(t, migrated, 2)
+		assert.Len(t, repo.applied, 3)
+		assert.Empty(t, repo.owner)
+
+		ran = nil
+		migrated, err = migrator.Migrate(ctx)
+
+		assert.NoError(t, err)
+		assert.Empty(t, ran)
+		assert.Empty(t, migrated)
+	})
+
+	t.Run("stops at a failing migration", func(t *testing.T) {
+		ran = nil
+		failure := errors.New("index build failed")
+		repo := &memoryMigrationRepository{}
+		migrator := NewMigrator(repo, []Migration{migration(1, nil), migration(2, failure), migration(3, nil)}, time.Millisecond)
+
+		migrated, err := migrator.Migrate(ctx)
+
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, []int{1, 2}, ran)
+		assert.Len(t, migrated, 1)
+		assert.Len(t, repo.applied, 1)
+		assert.Empty(t, repo.owner)
+	})
+
+	t.Run("waits for the lock", func(t *testing.T) {
+		ran = nil
+		repo := &memoryMigrationRepository{lockedFor: 2}
+		migrator := NewMigrator(repo, []Migration{migration(1, nil)}, time.Millisecond)
+
+		_, err := migrator.Migrate(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, ran)
+	})
+
+	t.Run("stops waiting for the lock when the context is done", func(t *testing.T) {
+		ran = nil
+		repo := &memoryMigrationRepository{owner: "other"}
+		migrator := NewMigrator(repo, []Migration{migration(1, nil)}, time.Millisecond)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		_, err := migrator.Migrate(timeoutCtx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, ran)
+		assert.Equal(t, "other", repo.owner)
+	})
+
+	t.Run("refuses migrations out of order", func(t *testing.T) {
+		ran = nil
+		migrator := NewMigrator(&memoryMigrationRepository{}, []Migration{migration(2, nil), migration(1, nil)}, time.Millisecond)
+
+		_, err := migrator.Migrate(ctx)
+
+		assert.Error(t, err)
+		assert.Empty(t, ran)
+	})
+}
+
+func TestMigratorIml_Status(t *testing.T) {
+	appliedAt := time.Now()
+	repo := &memoryMigrationRepository{applied: []entity.Migration{
+		{Version: 1, Name: "first", AppliedAt: &appliedAt},
+		{Version: 5, Name: "newer", AppliedAt: &appliedAt},
+	}}
+	noop := func(ctx context.Context) error { return nil }
+	migrator := NewMigrator(repo, []Migration{{Version: 1, Name: "first", Up: noop}, {Version: 2, Name: "second", Up: noop}}, time.Millisecond)
+
+	status, err := migrator.Status(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []entity.Migration{
+		{Version: 1, Name: "first", AppliedAt: &appliedAt},
+		{Version: 2, Name: "second"},
+		{Version: 5, Name: "newer", AppliedAt: &appliedAt},
+	}, status)
+}
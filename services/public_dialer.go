@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, private to the networks using it
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddress reports whether addr is reachable on the internet, rather than the loopback,
+// private, link-local or unspecified address of a host or network the server is in
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() &&
+		!addr.IsUnspecified() && !sharedAddressSpace.Contains(addr)
+}
+
+// refusePrivateAddress is the Control of the dialer of publicTransport, it runs after the host
+// was resolved so names pointing at internal addresses are refused like the addresses themselves.
+func refusePrivateAddress(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !isPublicAddress(addr) {
+		return fmt.Errorf("%w: %s", constants.ErrorPrivateAddress, addr)
+	}
+
+	return nil
+}
+
+// publicTransport requests the destinations of links, which anyone shortening a link chooses,
+// so it only connects to public addresses, redirects included. It uses no proxy, which would
+// connect for it without the check.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refusePrivateAddress}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	addresses := map[string]bool{
+		"93.184.216.34":          true,
+		"2606:2800:220:1::248":   true,
+		"127.0.0.1":              false,
+		"::1":                    false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"fe80::1":                false,
+		"fd00::1":                false,
+		"0.0.0.0":                false,
+		"::":                     false,
+		"100.64.0.1":             false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+	}
+
+	for address, public := range addresses {
+		assert.Equal(t, public, isPublicAddress(netip.MustParseAddr(address)), address)
+	}
+}
@@ -3,37 +3,114 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
 	"github.com/ilhamtubagus/shortenurl/entity"
 	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"log"
 	"strconv"
+	"time"
 )
 
+// ShortenedService manages shortened URLs. Links belong to workspaces, every method needs the
+// principal attached with WithPrincipal to have a role in the workspace of the link, or in the
+// selected workspace, within its scopes: viewers read links and stats, editors and above
+// create and change them. GetByShortCode and RecordClick serve redirects and are public.
 type ShortenedService interface {
-	ShortenURL(ctx context.Context, originalURL string) (*entity.ShortenedURL, error)
-	GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error)
-	ListShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error)
-	DeleteShortenedURL(ctx context.Context, shortcode string) error
-	UpdateShortenedURL(ctx context.Context, shortcode string, originalURL string) (*entity.ShortenedURL, error)
+	ShortenURL(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error)
+	BulkShorten(ctx context.Context, domain string, rows []entity.BulkLink) (*[]entity.BulkResult, error)
+	BulkUpdate(ctx context.Context, operation entity.BulkOperation) (*entity.BulkOperationResult, error)
+	RewriteDestinations(ctx context.Context, rewrite entity.DestinationRewrite) (*entity.DestinationRewriteResult, error)
+	ImportLinks(ctx context.Context, domain string, rows []entity.ImportLink) (*[]entity.ImportResult, error)
+	GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error)
+	GetLink(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error)
+	ListShortenedURLs(ctx context.Context, query entity.LinkQuery) (*entity.LinkPage, error)
+	DeleteShortenedURL(ctx context.Context, domain string, shortcode string) error
+	UpdateShortenedURL(ctx context.Context, domain string, shortcode string, originalURL string) (*entity.ShortenedURL, error)
+	UpdateFallbackURL(ctx context.Context, domain string, shortcode string, fallbackURL string) (*entity.ShortenedURL, error)
+	RecordClick(ctx context.Context, click entity.Click) error
+	RefreshMetadata(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error)
+	UpdateOpenGraph(ctx context.Context, domain string, shortcode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error)
+	UpdateStatus(ctx context.Context, domain string, shortcode string, status string) (*entity.ShortenedURL, error)
+	UpdateDetails(ctx context.Context, domain string, shortcode string, details entity.LinkDetails) (*entity.ShortenedURL, error)
+	GetLinkStats(ctx context.Context, domain string, shortcode string) (*entity.LinkStats, error)
+	ListRevisions(ctx context.Context, domain string, shortcode string) (*[]entity.Revision, error)
+	RollbackShortenedURL(ctx context.Context, domain string, shortcode string, revisionID string) (*entity.ShortenedURL, error)
+	ListTrash(ctx context.Context) (*[]entity.ShortenedURL, error)
+	RestoreShortenedURL(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error)
 }
 
 type ShortenedServiceIml struct {
-	repository repository.ShortenedRepository
+	repository         repository.ShortenedRepository
+	clickRepository    repository.ClickRepository
+	revisionRepository repository.RevisionRepository
+	metadataFetcher    MetadataFetcher
+	workspaceService   WorkspaceService
+	domainService      DomainService
+	auditService       AuditService
+	metadataTasks      chan entity.ShortenedURL
+	allowAnonymous     bool
 }
 
-func NewShortenedService(repo repository.ShortenedRepository) ShortenedService {
-	return &ShortenedServiceIml{repository: repo}
+func NewShortenedService(repo repository.ShortenedRepository, clickRepo repository.ClickRepository, revisionRepo repository.RevisionRepository, fetcher MetadataFetcher, workspaceService WorkspaceService, domainService DomainService, auditService AuditService, allowAnonymous bool) ShortenedService {
+	service := &ShortenedServiceIml{
+		repository:         repo,
+		clickRepository:    clickRepo,
+		revisionRepository: revisionRepo,
+		metadataFetcher:    fetcher,
+		workspaceService:   workspaceService,
+		domainService:      domainService,
+		auditService:       auditService,
+		metadataTasks:      make(chan entity.ShortenedURL, 100),
+		allowAnonymous:     allowAnonymous,
+	}
+
+	for i := 0; i < 2; i++ {
+		go service.metadataWorker()
+	}
+
+	return service
+}
+
+func (s *ShortenedServiceIml) metadataWorker() {
+	for shortened := range s.metadataTasks {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+
+		_, err := s.fetchMetadata(ctx, shortened)
+		if err != nil {
+			log.Printf("error fetching metadata of %s %v\n", shortened.ShortCode, err)
+		}
+
+		cancel()
+	}
+}
+
+// enqueueMetadata schedules a background metadata fetch, dropping it when the queue is full
+// since it can always be refreshed manually later.
+func (s *ShortenedServiceIml) enqueueMetadata(shortened entity.ShortenedURL) {
+	select {
+	case s.metadataTasks <- shortened:
+	default:
+		log.Printf("metadata queue full, skipping %s\n", shortened.ShortCode)
+	}
 }
 
-func (s *ShortenedServiceIml) insertWithRetry(ctx context.Context, originalURL string, attempt int) (*entity.ShortenedURL, error) {
+func (s *ShortenedServiceIml) fetchMetadata(ctx context.Context, shortened entity.ShortenedURL) (*entity.ShortenedURL, error) {
+	metadata, err := s.metadataFetcher.Fetch(ctx, shortened.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.UpdateMetadataByShortCode(ctx, shortened.Domain, shortened.ShortCode, *metadata)
+}
+
+func (s *ShortenedServiceIml) insertWithRetry(ctx context.Context, shortened entity.ShortenedURL, attempt int) (*entity.ShortenedURL, error) {
 	if attempt > 10 {
 		return nil, errors.New("too many duplicate attempts")
 	}
 
-	shortened := entity.ShortenedURL{
-		OriginalURL: originalURL,
-	}
 	shortened.GenerateShortCode(strconv.Itoa(attempt))
 
 	err := s.repository.Insert(ctx, shortened)
@@ -41,7 +118,7 @@ func (s *ShortenedServiceIml) insertWithRetry(ctx context.Context, originalURL s
 		if mongo.IsDuplicateKeyError(err) {
 			log.Printf("attempt %d: duplicate shortCode '%s', retrying...\n", attempt, shortened.ShortCode)
 
-			return s.insertWithRetry(ctx, originalURL, attempt+1)
+			return s.insertWithRetry(ctx, shortened, attempt+1)
 		}
 		return nil, err
 	}
@@ -49,25 +126,119 @@ func (s *ShortenedServiceIml) insertWithRetry(ctx context.Context, originalURL s
 	return &shortened, nil
 }
 
-func (s *ShortenedServiceIml) ShortenURL(ctx context.Context, originalURL string) (*entity.ShortenedURL, error) {
-	shorten, err := s.insertWithRetry(ctx, originalURL, 1)
+// audit records a change of a link, failures are only logged since the change is already made.
+func (s *ShortenedServiceIml) audit(ctx context.Context, action string, before *entity.ShortenedURL, after *entity.ShortenedURL) {
+	err := s.auditService.Record(ctx, action, before, after)
+	if err != nil {
+		log.Printf("error recording %s audit entry %v\n", action, err)
+	}
+}
+
+// revise keeps the current destination of a link as a new revision. Like audit entries, failures
+// are only logged.
+func (s *ShortenedServiceIml) revise(ctx context.Context, shortened *entity.ShortenedURL, restoredFrom string) {
+	revision := entity.Revision{
+		ID:           bson.NewObjectID().Hex(),
+		Domain:       shortened.Domain,
+		ShortCode:    shortened.ShortCode,
+		OriginalURL:  shortened.OriginalURL,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    time.Now(),
+	}
+
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		revision.CreatedBy = principal.UserID
+	}
+
+	err := s.revisionRepository.Insert(ctx, revision)
+	if err != nil {
+		log.Printf("error recording revision of %s %v\n", shortened.ShortCode, err)
+	}
+}
+
+// workspaceLink returns the link when scope is granted and the principal has at least role in
+// its workspace. Links of other workspaces are reported as not found so their existence is not disclosed.
+func (s *ShortenedServiceIml) workspaceLink(ctx context.Context, domain string, shortcode string, scope string, role string) (*entity.ShortenedURL, error) {
+	_, err := authorize(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.GetByShortCode(ctx, domain, shortcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return shortened, s.requireLinkRole(ctx, shortened, role)
+}
+
+// requireLinkRole checks the principal has at least role in the workspace of a link.
+func (s *ShortenedServiceIml) requireLinkRole(ctx context.Context, shortened *entity.ShortenedURL, role string) error {
+	if shortened.Workspace == "" {
+		return fmt.Errorf("%w: %s/%s", constants.ErrorNotFound, shortened.Domain, shortened.ShortCode)
+	}
+
+	_, err := s.workspaceService.RequireRole(ctx, shortened.Workspace, role)
+	if errors.Is(err, constants.ErrorNotFound) {
+		return fmt.Errorf("%w: %s/%s", constants.ErrorNotFound, shortened.Domain, shortened.ShortCode)
+	}
+
+	return err
+}
+
+func (s *ShortenedServiceIml) ShortenURL(ctx context.Context, domain string, originalURL string) (*entity.ShortenedURL, error) {
+	now := time.Now()
+	shortened := entity.ShortenedURL{
+		Domain:      domain,
+		OriginalURL: originalURL,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+
+	// anonymous links have no owner, everyone else needs to edit links in the selected workspace
+	_, signedIn := PrincipalFromContext(ctx)
+	if signedIn || !s.allowAnonymous {
+		principal, err := authorize(ctx, entity.ScopeLinksWrite)
+		if err != nil {
+			return nil, err
+		}
+
+		membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleEditor)
+		if err != nil {
+			return nil, err
+		}
+
+		shortened.Owner = principal.UserID
+		shortened.Workspace = membership.WorkspaceID
+	}
+
+	// anonymous links only go on the default domain
+	err := s.domainService.CheckLinkDomain(ctx, domain, shortened.Workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	shorten, err := s.insertWithRetry(ctx, shortened, 1)
 
 	if err != nil {
 		return nil, err
 	}
 
 	_ = shorten.GenerateShortenedURL()
+	s.audit(ctx, entity.AuditCreate, nil, shorten)
+	s.revise(ctx, shorten, "")
+	s.enqueueMetadata(*shorten)
 
 	return shorten, nil
 }
 
-func (s *ShortenedServiceIml) GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error) {
-	shorten, err := s.repository.GetByShortCode(ctx, shortcode)
+func (s *ShortenedServiceIml) GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	shorten, err := s.repository.GetByShortCode(ctx, domain, shortcode)
 	if err != nil {
 		return nil, err
 	}
-	// shortened URL with server host generated on the fly
-	// in database we are not saving server host, instead we are only saving short code
+	// shortened URL with domain generated on the fly
+	// in database we are not saving the full URL, instead we are only saving domain and short code
 	err = shorten.GenerateShortenedURL()
 	if err != nil {
 		return nil, err
@@ -76,33 +247,298 @@ func (s *ShortenedServiceIml) GetByShortCode(ctx context.Context, shortcode stri
 	return shorten, nil
 }
 
-func (s *ShortenedServiceIml) ListShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error) {
-	shortenedURLs, err := s.repository.GetShortenedURLs(ctx)
+// GetLink returns a link of a workspace the principal is a member of.
+func (s *ShortenedServiceIml) GetLink(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	shortened, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksRead, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+// ListShortenedURLs returns a page of the links of the selected workspace matching query.
+func (s *ShortenedServiceIml) ListShortenedURLs(ctx context.Context, query entity.LinkQuery) (*entity.LinkPage, error) {
+	_, err := authorize(ctx, entity.ScopeLinksRead)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleViewer)
 	if err != nil {
 		return nil, err
 	}
 
-	for i := range *shortenedURLs {
-		_ = (*shortenedURLs)[i].GenerateShortenedURL()
+	query, err = normalizeLinkQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.repository.GetShortenedURLs(ctx, membership.WorkspaceID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range page.Links {
+		_ = page.Links[i].GenerateShortenedURL()
 	}
 
-	return shortenedURLs, nil
+	return page, nil
 }
 
-func (s *ShortenedServiceIml) DeleteShortenedURL(ctx context.Context, shortcode string) error {
-	err := s.repository.DeleteByShortCode(ctx, shortcode)
+// DeleteShortenedURL moves a link to the trash, it can be restored until the trash is purged.
+func (s *ShortenedServiceIml) DeleteShortenedURL(ctx context.Context, domain string, shortcode string) error {
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
 	if err != nil {
 		return err
 	}
 
+	err = s.repository.DeleteByShortCode(ctx, domain, shortcode)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, entity.AuditDelete, before, nil)
+
 	return nil
 }
 
-func (s *ShortenedServiceIml) UpdateShortenedURL(ctx context.Context, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
-	shortened, err := s.repository.UpdateByShortCode(ctx, shortcode, originalURL)
+func (s *ShortenedServiceIml) UpdateShortenedURL(ctx context.Context, domain string, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.UpdateByShortCode(ctx, domain, shortcode, originalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditUpdate, before, shortened)
+	s.revise(ctx, shortened, "")
+
+	s.enqueueMetadata(*shortened)
+
+	return shortened, nil
+}
+
+func (s *ShortenedServiceIml) UpdateFallbackURL(ctx context.Context, domain string, shortcode string, fallbackURL string) (*entity.ShortenedURL, error) {
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.UpdateFallbackByShortCode(ctx, domain, shortcode, fallbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditSettings, before, shortened)
+
+	return shortened, nil
+}
+
+func (s *ShortenedServiceIml) RecordClick(ctx context.Context, click entity.Click) error {
+	if click.CreatedAt.IsZero() {
+		click.CreatedAt = time.Now()
+	}
+
+	return s.clickRepository.Insert(ctx, click)
+}
+
+func (s *ShortenedServiceIml) RefreshMetadata(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	shortened, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
 	if err != nil {
 		return nil, err
 	}
 
+	shortened, err = s.fetchMetadata(ctx, *shortened)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+func (s *ShortenedServiceIml) UpdateOpenGraph(ctx context.Context, domain string, shortcode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error) {
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.UpdateOpenGraphByShortCode(ctx, domain, shortcode, openGraph)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditSettings, before, shortened)
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+// UpdateStatus enables or disables a link, keeping its code and clicks. Only admins block links
+// and unblock them.
+func (s *ShortenedServiceIml) UpdateStatus(ctx context.Context, domain string, shortcode string, status string) (*entity.ShortenedURL, error) {
+	if !entity.IsLinkStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", constants.ErrorInvalidRequest, status)
+	}
+
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == entity.LinkStatusBlocked || before.LinkStatus() == entity.LinkStatusBlocked {
+		err = s.requireLinkRole(ctx, before, entity.RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shortened, err := s.repository.UpdateStatusByShortCode(ctx, domain, shortcode, status)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditSettings, before, shortened)
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+// UpdateDetails replaces the title, notes and tags of a link.
+func (s *ShortenedServiceIml) UpdateDetails(ctx context.Context, domain string, shortcode string, details entity.LinkDetails) (*entity.ShortenedURL, error) {
+	details, err := normalizeDetails(details)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.UpdateDetailsByShortCode(ctx, domain, shortcode, details)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditUpdate, before, shortened)
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+func (s *ShortenedServiceIml) GetLinkStats(ctx context.Context, domain string, shortcode string) (*entity.LinkStats, error) {
+	_, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeStatsRead, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.clickRepository.GetStats(ctx, domain, shortcode)
+}
+
+// ListRevisions returns the destinations a link had, the current one first. Links created before
+// revisions were kept start their history with their first change.
+func (s *ShortenedServiceIml) ListRevisions(ctx context.Context, domain string, shortcode string) (*[]entity.Revision, error) {
+	_, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksRead, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.revisionRepository.GetByLink(ctx, domain, shortcode)
+}
+
+// RollbackShortenedURL restores the destination of a previous revision, keeping it as the newest one.
+func (s *ShortenedServiceIml) RollbackShortenedURL(ctx context.Context, domain string, shortcode string, revisionID string) (*entity.ShortenedURL, error) {
+	before, err := s.workspaceLink(ctx, domain, shortcode, entity.ScopeLinksWrite, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.revisionRepository.GetByID(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision.Domain != domain || revision.ShortCode != shortcode {
+		return nil, fmt.Errorf("%w: revision %s of %s/%s", constants.ErrorNotFound, revisionID, domain, shortcode)
+	}
+
+	shortened, err := s.repository.UpdateByShortCode(ctx, domain, shortcode, revision.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditUpdate, before, shortened)
+	s.revise(ctx, shortened, revision.ID)
+
+	if before.OriginalURL != shortened.OriginalURL {
+		s.enqueueMetadata(*shortened)
+	}
+
+	_ = shortened.GenerateShortenedURL()
+
+	return shortened, nil
+}
+
+// ListTrash returns the deleted links of the selected workspace, the most recently deleted first.
+func (s *ShortenedServiceIml) ListTrash(ctx context.Context) (*[]entity.ShortenedURL, error) {
+	_, err := authorize(ctx, entity.ScopeLinksRead)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.workspaceService.RequireRole(ctx, "", entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	trash, err := s.repository.GetDeleted(ctx, membership.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range *trash {
+		_ = (*trash)[i].GenerateShortenedURL()
+	}
+
+	return trash, nil
+}
+
+// RestoreShortenedURL takes a link out of the trash, it resolves again right away.
+func (s *ShortenedServiceIml) RestoreShortenedURL(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	_, err := authorize(ctx, entity.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.repository.GetDeletedByShortCode(ctx, domain, shortcode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireLinkRole(ctx, before, entity.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	shortened, err := s.repository.RestoreByShortCode(ctx, domain, shortcode)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, entity.AuditRestore, before, shortened)
+
+	_ = shortened.GenerateShortenedURL()
+
 	return shortened, nil
 }
@@ -3,13 +3,65 @@ package services
 import (
 	"context"
 	"errors"
+	"github.com/ilhamtubagus/shortenurl/constants"
 	"github.com/ilhamtubagus/shortenurl/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"strings"
 	"testing"
+	"time"
 )
 
+const testDomain = "short.url"
+
+const testWorkspace = "acme"
+
+var testUser = &entity.Principal{UserID: "user-1", Email: "user@short.url"}
+
+// testWorkspaces makes testUser an editor of testWorkspace
+var testWorkspaces = roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleEditor}}
+
+// testDomains only lets links be created on the default domain, testDomain
+var testDomains = NewDomainService(new(MockDomainRepository), nil, testWorkspaces, testDomain)
+
+// ownedLink returns a link of testWorkspace, membership is checked before every change
+func ownedLink(shortcode string) *entity.ShortenedURL {
+	return &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://example.com", Owner: testUser.UserID, Workspace: testWorkspace}
+}
+
+// roleWorkspaceService gives the principal fixed roles by workspace, testWorkspace is selected by default
+type roleWorkspaceService struct {
+	WorkspaceService
+	roles map[string]string
+}
+
+func (s roleWorkspaceService) RequireRole(ctx context.Context, workspaceID string, role string) (*entity.Membership, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	if workspaceID == "" {
+		workspaceID = WorkspaceFromContext(ctx)
+	}
+
+	if workspaceID == "" {
+		workspaceID = testWorkspace
+	}
+
+	granted, ok := s.roles[workspaceID]
+	if !ok {
+		return nil, constants.ErrorNotFound
+	}
+
+	if !entity.RoleAtLeast(granted, role) {
+		return nil, constants.ErrorForbidden
+	}
+
+	return &entity.Membership{WorkspaceID: workspaceID, UserID: principal.UserID, Role: granted}, nil
+}
+
 // MockShortenedRepository is a mock type for repository.ShortenedRepository
 type MockShortenedRepository struct {
 	mock.Mock
@@ -20,26 +72,261 @@ func (m *MockShortenedRepository) Insert(ctx context.Context, shortened entity.S
 	return args.Error(0)
 }
 
-func (m *MockShortenedRepository) GetByShortCode(ctx context.Context, shortcode string) (*entity.ShortenedURL, error) {
-	args := m.Called(ctx, shortcode)
+func (m *MockShortenedRepository) GetByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) InsertMany(ctx context.Context, shortenedURLs []entity.ShortenedURL) ([]error, error) {
+	args := m.Called(ctx, shortenedURLs)
+	return args.Get(0).([]error), args.Error(1)
+}
+
+func (m *MockShortenedRepository) SelectLinks(ctx context.Context, workspace string, keys []entity.LinkKey, query *entity.LinkQuery, limit int) (*[]entity.ShortenedURL, error) {
+	args := m.Called(ctx, workspace, keys, query, limit)
+	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) UpdateLinks(ctx context.Context, keys []entity.LinkKey, change entity.LinkChange) (int64, error) {
+	args := m.Called(ctx, keys, change)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockShortenedRepository) DeleteLinks(ctx context.Context, keys []entity.LinkKey) (int64, error) {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockShortenedRepository) GetByDestinationHost(ctx context.Context, workspace string, host string) (*[]entity.ShortenedURL, error) {
+	args := m.Called(ctx, workspace, host)
+	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) RewriteDestination(ctx context.Context, domain string, shortCode string, from string, to string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortCode, from, to)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) GetImported(ctx context.Context, workspace string, sources []string) (*[]entity.ShortenedURL, error) {
+	args := m.Called(ctx, workspace, sources)
+	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
+}
+
+// StreamLinks streams the links the mock returns
+func (m *MockShortenedRepository) StreamLinks(ctx context.Context, workspace string, query entity.LinkQuery, fn func(link entity.ShortenedURL) error) error {
+	args := m.Called(ctx, workspace, query)
+	for _, link := range args.Get(0).([]entity.ShortenedURL) {
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockShortenedRepository) GetShortenedURLs(ctx context.Context, workspace string, query entity.LinkQuery) (*entity.LinkPage, error) {
+	args := m.Called(ctx, workspace, query)
+	return args.Get(0).(*entity.LinkPage), args.Error(1)
+}
+
+func (m *MockShortenedRepository) DeleteByShortCode(ctx context.Context, domain string, shortcode string) error {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Error(0)
+}
+
+func (m *MockShortenedRepository) GetDeletedByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) GetDeleted(ctx context.Context, workspace string) (*[]entity.ShortenedURL, error) {
+	args := m.Called(ctx, workspace)
+	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) RestoreByShortCode(ctx context.Context, domain string, shortcode string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (*[]entity.ShortenedURL, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) UpdateByShortCode(ctx context.Context, domain string, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, originalURL)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) UpdateFallbackByShortCode(ctx context.Context, domain string, shortcode string, fallbackURL string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, fallbackURL)
 	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedRepository) GetShortenedURLs(ctx context.Context) (*[]entity.ShortenedURL, error) {
+func (m *MockShortenedRepository) GetWithFallback(ctx context.Context) (*[]entity.ShortenedURL, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(*[]entity.ShortenedURL), args.Error(1)
 }
 
-func (m *MockShortenedRepository) DeleteByShortCode(ctx context.Context, shortcode string) error {
-	args := m.Called(ctx, shortcode)
+func (m *MockShortenedRepository) UpdatePrimaryDown(ctx context.Context, domain string, shortcode string, down bool) error {
+	args := m.Called(ctx, domain, shortcode, down)
 	return args.Error(0)
 }
 
-func (m *MockShortenedRepository) UpdateByShortCode(ctx context.Context, shortcode string, originalURL string) (*entity.ShortenedURL, error) {
-	args := m.Called(ctx, shortcode, originalURL)
+func (m *MockShortenedRepository) UpdateMetadataByShortCode(ctx context.Context, domain string, shortcode string, metadata entity.Metadata) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, metadata)
 	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
 }
 
+func (m *MockShortenedRepository) UpdateOpenGraphByShortCode(ctx context.Context, domain string, shortcode string, openGraph entity.OpenGraph) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, openGraph)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) UpdateStatusByShortCode(ctx context.Context, domain string, shortcode string, status string) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, status)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) UpdateDetailsByShortCode(ctx context.Context, domain string, shortcode string, details entity.LinkDetails) (*entity.ShortenedURL, error) {
+	args := m.Called(ctx, domain, shortcode, details)
+	return args.Get(0).(*entity.ShortenedURL), args.Error(1)
+}
+
+func (m *MockShortenedRepository) AssignDomain(ctx context.Context, domain string) error {
+	args := m.Called(ctx, domain)
+	return args.Error(0)
+}
+
+func (m *MockShortenedRepository) AssignWorkspace(ctx context.Context, owner string, workspace string) error {
+	args := m.Called(ctx, owner, workspace)
+	return args.Error(0)
+}
+
+func (m *MockShortenedRepository) BackfillCreatedAt(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockShortenedRepository) FindDuplicateCodes(ctx context.Context) ([]entity.LinkKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.LinkKey), args.Error(1)
+}
+
+// MockClickRepository is a mock type for repository.ClickRepository
+type MockClickRepository struct {
+	mock.Mock
+}
+
+func (m *MockClickRepository) Insert(ctx context.Context, click entity.Click) error {
+	args := m.Called(ctx, click)
+	return args.Error(0)
+}
+
+func (m *MockClickRepository) GetStats(ctx context.Context, domain string, shortcode string) (*entity.LinkStats, error) {
+	args := m.Called(ctx, domain, shortcode)
+	return args.Get(0).(*entity.LinkStats), args.Error(1)
+}
+
+func (m *MockClickRepository) BackfillLinkClicks(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// StreamClicks streams the clicks the mock returns
+func (m *MockClickRepository) StreamClicks(ctx context.Context, workspace string, query entity.ClickQuery, fn func(click entity.Click) error) error {
+	args := m.Called(ctx, workspace, query)
+	for _, click := range args.Get(0).([]entity.Click) {
+		if err := fn(click); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockClickRepository) DeleteByLink(ctx context.Context, domain string, shortCode string) error {
+	args := m.Called(ctx, domain, shortCode)
+	return args.Error(0)
+}
+
+// MockMetadataFetcher is a mock type for MetadataFetcher
+type MockMetadataFetcher struct {
+	mock.Mock
+}
+
+func (m *MockMetadataFetcher) Fetch(ctx context.Context, pageURL string) (*entity.Metadata, error) {
+	args := m.Called(ctx, pageURL)
+	return args.Get(0).(*entity.Metadata), args.Error(1)
+}
+
+// unavailableMetadataFetcher keeps background metadata fetches away from the mocked repository
+type unavailableMetadataFetcher struct{}
+
+func (unavailableMetadataFetcher) Fetch(ctx context.Context, pageURL string) (*entity.Metadata, error) {
+	return nil, errors.New("metadata unavailable")
+}
+
+// memoryRevisionRepository keeps revisions in memory
+type memoryRevisionRepository struct {
+	revisions []entity.Revision
+}
+
+func (m *memoryRevisionRepository) Insert(ctx context.Context, revision entity.Revision) error {
+	m.revisions = append(m.revisions, revision)
+	return nil
+}
+
+func (m *memoryRevisionRepository) GetByID(ctx context.Context, id string) (*entity.Revision, error) {
+	for _, revision := range m.revisions {
+		if revision.ID == id {
+			return &revision, nil
+		}
+	}
+
+	return nil, constants.ErrorNotFound
+}
+
+func (m *memoryRevisionRepository) GetByLink(ctx context.Context, domain string, shortCode string) (*[]entity.Revision, error) {
+	revisions := []entity.Revision{}
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if m.revisions[i].Domain == domain && m.revisions[i].ShortCode == shortCode {
+			revisions = append(revisions, m.revisions[i])
+		}
+	}
+
+	return &revisions, nil
+}
+
+func (m *memoryRevisionRepository) DeleteByLink(ctx context.Context, domain string, shortCode string) error {
+	kept := m.revisions[:0]
+	for _, revision := range m.revisions {
+		if revision.Domain != domain || revision.ShortCode != shortCode {
+			kept = append(kept, revision)
+		}
+	}
+	m.revisions = kept
+
+	return nil
+}
+
+// memoryAuditService keeps the recorded audit entries in memory
+type memoryAuditService struct {
+	entries []entity.AuditEntry
+}
+
+func (m *memoryAuditService) Record(ctx context.Context, action string, before *entity.ShortenedURL, after *entity.ShortenedURL) error {
+	m.entries = append(m.entries, entity.AuditEntry{Action: action, Before: before, After: after})
+	return nil
+}
+
+func (m *memoryAuditService) ListLinkAudit(ctx context.Context, domain string, shortcode string, limit int) (*[]entity.AuditEntry, error) {
+	return &m.entries, nil
+}
+
+func (m *memoryAuditService) ListWorkspaceAudit(ctx context.Context, workspaceID string, limit int) (*[]entity.AuditEntry, error) {
+	return &m.entries, nil
+}
+
 func createDuplicateKeyError() error {
 	writeErr := mongo.WriteException{
 		WriteErrors: []mongo.WriteError{
@@ -51,29 +338,53 @@ func createDuplicateKeyError() error {
 
 func TestShortenedServiceIml_ShortenURL(t *testing.T) {
 	mockRepo := new(MockShortenedRepository)
-	service := NewShortenedService(mockRepo)
-	ctx := context.Background()
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
 
 	t.Run("Success", func(t *testing.T) {
 		originalURL := "https://example.com"
 		mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.ShortenedURL")).Return(nil)
 
-		result, err := service.ShortenURL(ctx, originalURL)
+		result, err := service.ShortenURL(ctx, testDomain, originalURL)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
 		assert.Equal(t, originalURL, result.OriginalURL)
+		assert.Equal(t, testUser.UserID, result.Owner)
+		assert.Equal(t, testWorkspace, result.Workspace)
 		assert.NotEmpty(t, result.ShortCode)
+		assert.NotNil(t, result.CreatedAt)
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("AnonymousNotAllowed", func(t *testing.T) {
+		result, err := service.ShortenURL(context.Background(), testDomain, "https://example.com")
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+		assert.Nil(t, result)
+	})
+
+	t.Run("AnonymousAllowed", func(t *testing.T) {
+		anonymousRepo := new(MockShortenedRepository)
+		anonymousService := NewShortenedService(anonymousRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, true)
+		anonymousRepo.On("Insert", mock.Anything, mock.MatchedBy(func(shortened entity.ShortenedURL) bool {
+			return shortened.Owner == ""
+		})).Return(nil)
+
+		result, err := anonymousService.ShortenURL(context.Background(), testDomain, "https://example.com")
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Owner)
+		anonymousRepo.AssertExpectations(t)
+	})
+
 	t.Run("DuplicateKeyError", func(t *testing.T) {
 		originalURL := "https://example.com"
 		mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.ShortenedURL")).
 			Return(createDuplicateKeyError()).
 			Times(10)
 
-		result, err := service.ShortenURL(ctx, originalURL)
+		result, err := service.ShortenURL(ctx, testDomain, originalURL)
 		assert.Error(t, err)
 		assert.Nil(t, result)
 		assert.EqualError(t, err, "too many duplicate attempts")
@@ -83,15 +394,15 @@ func TestShortenedServiceIml_ShortenURL(t *testing.T) {
 
 func TestShortenedServiceIml_GetByShortCode(t *testing.T) {
 	mockRepo := new(MockShortenedRepository)
-	service := NewShortenedService(mockRepo)
-	ctx := context.Background()
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
 
 	t.Run("Success", func(t *testing.T) {
 		shortcode := "abc123"
-		expectedURL := &entity.ShortenedURL{ShortCode: shortcode, OriginalURL: "https://example.com"}
-		mockRepo.On("GetByShortCode", ctx, shortcode).Return(expectedURL, nil)
+		expectedURL := &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://example.com"}
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(expectedURL, nil)
 
-		result, err := service.GetByShortCode(ctx, shortcode)
+		result, err := service.GetByShortCode(ctx, testDomain, shortcode)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedURL, result)
@@ -100,9 +411,9 @@ func TestShortenedServiceIml_GetByShortCode(t *testing.T) {
 
 	t.Run("NotFound", func(t *testing.T) {
 		shortcode := "notfound"
-		mockRepo.On("GetByShortCode", ctx, shortcode).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
 
-		result, err := service.GetByShortCode(ctx, shortcode)
+		result, err := service.GetByShortCode(ctx, testDomain, shortcode)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -110,89 +421,624 @@ func TestShortenedServiceIml_GetByShortCode(t *testing.T) {
 	})
 }
 
+// firstPage is the query the service passes on for a listing without parameters
+var firstPage = entity.LinkQuery{Sort: entity.DefaultLinkSort, Limit: defaultLinkPageSize}
+
 func TestShortenedServiceIml_ListShortenedURLs(t *testing.T) {
 	mockRepo := new(MockShortenedRepository)
-	service := NewShortenedService(mockRepo)
-	ctx := context.Background()
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
 
 	t.Run("Success", func(t *testing.T) {
-		expectedURLs := &[]entity.ShortenedURL{
-			{ShortCode: "abc123", OriginalURL: "https://example1.com"},
-			{ShortCode: "def456", OriginalURL: "https://example2.com"},
+		expected := &entity.LinkPage{
+			Links: []entity.ShortenedURL{
+				{ShortCode: "abc123", OriginalURL: "https://example1.com"},
+				{ShortCode: "def456", OriginalURL: "https://example2.com"},
+			},
+			NextCursor: "next",
+		}
+		mockRepo.On("GetShortenedURLs", ctx, testWorkspace, firstPage).Return(expected, nil)
+
+		result, err := service.ListShortenedURLs(ctx, entity.LinkQuery{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+		assert.Equal(t, "next", result.NextCursor)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		query := entity.LinkQuery{
+			Search:      " q3 deck ",
+			Domain:      "Go.Example.com",
+			Tag:         " Launch",
+			Status:      entity.LinkStatusDisabled,
+			CreatedFrom: from,
+			CreatedTo:   from.AddDate(0, 1, 0),
+			Sort:        "-clicks",
+			Cursor:      "cursor",
+			Limit:       1000,
 		}
-		mockRepo.On("GetShortenedURLs", ctx).Return(expectedURLs, nil)
+		expected := query
+		expected.Search, expected.Domain, expected.Tag, expected.Limit = "q3 deck", "go.example.com", "launch", maxLinkPageSize
+		mockRepo.On("GetShortenedURLs", ctx, testWorkspace, expected).Return(&entity.LinkPage{}, nil)
 
-		result, err := service.ListShortenedURLs(ctx)
+		_, err := service.ListShortenedURLs(ctx, query)
 
 		assert.NoError(t, err)
-		assert.Equal(t, expectedURLs, result)
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("InvalidQuery", func(t *testing.T) {
+		for name, query := range map[string]entity.LinkQuery{
+			"Search": {Search: strings.Repeat("a", maxSearchLength+1)},
+			"Sort":   {Sort: "title"},
+			"Status": {Status: "paused"},
+			"Range":  {CreatedFrom: time.Now(), CreatedTo: time.Now().Add(-time.Hour)},
+		} {
+			_, err := service.ListShortenedURLs(ctx, query)
+
+			assert.ErrorIs(t, err, constants.ErrorInvalidRequest, name)
+		}
+	})
+
 	t.Run("Error", func(t *testing.T) {
-		mockRepo.On("GetShortenedURLs", ctx).Return((*[]entity.ShortenedURL)(nil), errors.New("database error"))
+		query := entity.LinkQuery{Sort: entity.LinkSortCode, Limit: 10}
+		mockRepo.On("GetShortenedURLs", ctx, testWorkspace, query).Return((*entity.LinkPage)(nil), errors.New("database error"))
 
-		result, err := service.ListShortenedURLs(ctx)
+		result, err := service.ListShortenedURLs(ctx, query)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		result, err := service.ListShortenedURLs(context.Background(), entity.LinkQuery{})
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+		assert.Nil(t, result)
+	})
 }
 
 func TestShortenedServiceIml_DeleteShortenedURL(t *testing.T) {
 	mockRepo := new(MockShortenedRepository)
-	service := NewShortenedService(mockRepo)
-	ctx := context.Background()
+	audit := &memoryAuditService{}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+	ctx := WithPrincipal(context.Background(), testUser)
 
 	t.Run("Success", func(t *testing.T) {
 		shortcode := "abc123"
-		mockRepo.On("DeleteByShortCode", ctx, shortcode).Return(nil)
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("DeleteByShortCode", ctx, testDomain, shortcode).Return(nil)
 
-		err := service.DeleteShortenedURL(ctx, shortcode)
+		err := service.DeleteShortenedURL(ctx, testDomain, shortcode)
 
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
+		if len(audit.entries) != 1 {
+			t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+		}
+		assert.Equal(t, entity.AuditDelete, audit.entries[0].Action)
+		assert.Equal(t, shortcode, audit.entries[0].Before.ShortCode)
+		assert.Nil(t, audit.entries[0].After)
 	})
 
 	t.Run("Error", func(t *testing.T) {
 		shortcode := "notfound"
-		mockRepo.On("DeleteByShortCode", ctx, shortcode).Return(errors.New("not found"))
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("DeleteByShortCode", ctx, testDomain, shortcode).Return(errors.New("not found"))
 
-		err := service.DeleteShortenedURL(ctx, shortcode)
+		err := service.DeleteShortenedURL(ctx, testDomain, shortcode)
 
 		assert.Error(t, err)
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("OtherWorkspace", func(t *testing.T) {
+		shortcode := "others"
+		link := ownedLink(shortcode)
+		link.Workspace = "globex"
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(link, nil)
+
+		err := service.DeleteShortenedURL(ctx, testDomain, shortcode)
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+		mockRepo.AssertNotCalled(t, "DeleteByShortCode", ctx, testDomain, shortcode)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		err := service.DeleteShortenedURL(context.Background(), testDomain, "abc123")
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
 }
 
 func TestShortenedServiceIml_UpdateShortenedURL(t *testing.T) {
 	mockRepo := new(MockShortenedRepository)
-	service := NewShortenedService(mockRepo)
-	ctx := context.Background()
+	audit := &memoryAuditService{}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+	ctx := WithPrincipal(context.Background(), testUser)
 
 	t.Run("Success", func(t *testing.T) {
 		shortcode := "abc123"
 		originalURL := "https://newexample.com"
-		expectedURL := &entity.ShortenedURL{ShortCode: shortcode, OriginalURL: originalURL}
-		mockRepo.On("UpdateByShortCode", ctx, shortcode, originalURL).Return(expectedURL, nil)
+		expectedURL := &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: originalURL}
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateByShortCode", ctx, testDomain, shortcode, originalURL).Return(expectedURL, nil)
 
-		result, err := service.UpdateShortenedURL(ctx, shortcode, originalURL)
+		result, err := service.UpdateShortenedURL(ctx, testDomain, shortcode, originalURL)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedURL, result)
 		mockRepo.AssertExpectations(t)
+		if len(audit.entries) != 1 {
+			t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+		}
+		assert.Equal(t, entity.AuditUpdate, audit.entries[0].Action)
+		assert.Equal(t, ownedLink(shortcode).OriginalURL, audit.entries[0].Before.OriginalURL)
+		assert.Equal(t, originalURL, audit.entries[0].After.OriginalURL)
 	})
 
 	t.Run("Error", func(t *testing.T) {
 		shortcode := "notfound"
 		originalURL := "https://newexample.com"
-		mockRepo.On("UpdateByShortCode", ctx, shortcode, originalURL).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateByShortCode", ctx, testDomain, shortcode, originalURL).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
 
-		result, err := service.UpdateShortenedURL(ctx, shortcode, originalURL)
+		result, err := service.UpdateShortenedURL(ctx, testDomain, shortcode, originalURL)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestShortenedServiceIml_UpdateFallbackURL(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		shortcode := "abc123"
+		fallbackURL := "https://fallback.com"
+		expectedURL := &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://example.com", FallbackURL: fallbackURL}
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateFallbackByShortCode", ctx, testDomain, shortcode, fallbackURL).Return(expectedURL, nil)
+
+		result, err := service.UpdateFallbackURL(ctx, testDomain, shortcode, fallbackURL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedURL, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		shortcode := "notfound"
+		fallbackURL := "https://fallback.com"
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateFallbackByShortCode", ctx, testDomain, shortcode, fallbackURL).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
+
+		result, err := service.UpdateFallbackURL(ctx, testDomain, shortcode, fallbackURL)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestShortenedServiceIml_RecordClick(t *testing.T) {
+	mockClickRepo := new(MockClickRepository)
+	service := NewShortenedService(new(MockShortenedRepository), mockClickRepo, &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	mockClickRepo.On("Insert", ctx, mock.MatchedBy(func(click entity.Click) bool {
+		return click.ShortCode == "abc123" && click.Fallback && !click.CreatedAt.IsZero()
+	})).Return(nil)
+
+	err := service.RecordClick(ctx, entity.Click{ShortCode: "abc123", Fallback: true})
+
+	assert.NoError(t, err)
+	mockClickRepo.AssertExpectations(t)
+}
+
+func TestShortenedServiceIml_RefreshMetadata(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	mockFetcher := new(MockMetadataFetcher)
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, mockFetcher, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		shortcode := "abc123"
+		shortened := ownedLink(shortcode)
+		metadata := &entity.Metadata{Title: "Example Domain"}
+		expectedURL := &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://example.com", Metadata: metadata}
+
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(shortened, nil)
+		mockFetcher.On("Fetch", ctx, "https://example.com").Return(metadata, nil)
+		mockRepo.On("UpdateMetadataByShortCode", ctx, testDomain, shortcode, *metadata).Return(expectedURL, nil)
+
+		result, err := service.RefreshMetadata(ctx, testDomain, shortcode)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Example Domain", result.Metadata.Title)
+		mockRepo.AssertExpectations(t)
+		mockFetcher.AssertExpectations(t)
+	})
+
+	t.Run("FetchError", func(t *testing.T) {
+		shortcode := "unreachable"
+		shortened := ownedLink(shortcode)
+		shortened.OriginalURL = "https://unreachable.example"
+
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(shortened, nil)
+		mockFetcher.On("Fetch", ctx, "https://unreachable.example").Return((*entity.Metadata)(nil), errors.New("timeout"))
+
+		result, err := service.RefreshMetadata(ctx, testDomain, shortcode)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockRepo.AssertNotCalled(t, "UpdateMetadataByShortCode", ctx, testDomain, shortcode, mock.Anything)
+	})
+}
+
+func TestShortenedServiceIml_UpdateOpenGraph(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		shortcode := "abc123"
+		openGraph := entity.OpenGraph{Title: "Launch", ImageURL: "https://cdn.example.com/card.png"}
+		expectedURL := &entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://example.com", OpenGraph: &openGraph}
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateOpenGraphByShortCode", ctx, testDomain, shortcode, openGraph).Return(expectedURL, nil)
+
+		result, err := service.UpdateOpenGraph(ctx, testDomain, shortcode, openGraph)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Launch", result.OpenGraph.Title)
+		assert.NotEmpty(t, result.ShortenedURL)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		shortcode := "notfound"
+		openGraph := entity.OpenGraph{Title: "Launch"}
+		mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+		mockRepo.On("UpdateOpenGraphByShortCode", ctx, testDomain, shortcode, openGraph).Return((*entity.ShortenedURL)(nil), errors.New("not found"))
+
+		result, err := service.UpdateOpenGraph(ctx, testDomain, shortcode, openGraph)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestShortenedServiceIml_Scopes(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	mockClickRepo := new(MockClickRepository)
+	service := NewShortenedService(mockRepo, mockClickRepo, &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	readOnly := WithPrincipal(context.Background(), &entity.Principal{
+		UserID:   testUser.UserID,
+		APIKeyID: "key-1",
+		Scopes:   []string{entity.ScopeLinksRead, entity.ScopeStatsRead},
+	})
+
+	_, err := service.ShortenURL(readOnly, testDomain, "https://example.com")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	err = service.DeleteShortenedURL(readOnly, testDomain, "abc123")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	mockRepo.On("GetShortenedURLs", readOnly, testWorkspace, firstPage).Return(&entity.LinkPage{}, nil)
+	_, err = service.ListShortenedURLs(readOnly, entity.LinkQuery{})
+	assert.NoError(t, err)
+
+	stats := &entity.LinkStats{Domain: testDomain, ShortCode: "abc123", Clicks: 42, FallbackClicks: 2}
+	mockRepo.On("GetByShortCode", readOnly, testDomain, "abc123").Return(ownedLink("abc123"), nil)
+	mockClickRepo.On("GetStats", readOnly, testDomain, "abc123").Return(stats, nil)
+
+	result, err := service.GetLinkStats(readOnly, testDomain, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), result.Clicks)
+
+	writeOnly := WithPrincipal(context.Background(), &entity.Principal{
+		UserID:   testUser.UserID,
+		APIKeyID: "key-2",
+		Scopes:   []string{entity.ScopeLinksWrite},
+	})
+
+	_, err = service.GetLinkStats(writeOnly, testDomain, "abc123")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	mockRepo.AssertNotCalled(t, "DeleteByShortCode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestShortenedServiceIml_Roles(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	mockClickRepo := new(MockClickRepository)
+	workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleEditor, "globex": entity.RoleViewer}}
+	service := NewShortenedService(mockRepo, mockClickRepo, &memoryRevisionRepository{}, unavailableMetadataFetcher{}, workspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+	viewing := WithWorkspace(ctx, "globex")
+
+	link := ownedLink("xyz789")
+	link.Workspace = "globex"
+	mockRepo.On("GetByShortCode", mock.Anything, testDomain, "xyz789").Return(link, nil)
+
+	// viewers read links and their stats
+	mockRepo.On("GetShortenedURLs", viewing, "globex", firstPage).Return(&entity.LinkPage{Links: []entity.ShortenedURL{*link}}, nil)
+	links, err := service.ListShortenedURLs(viewing, entity.LinkQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, links.Links, 1)
+
+	result, err := service.GetLink(ctx, testDomain, "xyz789")
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", result.Workspace)
+
+	mockClickRepo.On("GetStats", ctx, testDomain, "xyz789").Return(&entity.LinkStats{Clicks: 3}, nil)
+	_, err = service.GetLinkStats(ctx, testDomain, "xyz789")
+	assert.NoError(t, err)
+
+	// but cannot create or change them
+	_, err = service.ShortenURL(viewing, testDomain, "https://example.com")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	_, err = service.UpdateShortenedURL(ctx, testDomain, "xyz789", "https://example.org")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	err = service.DeleteShortenedURL(ctx, testDomain, "xyz789")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+	// workspaces the user is not a member of do not exist for them
+	_, err = service.ListShortenedURLs(WithWorkspace(ctx, "initech"), entity.LinkQuery{})
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	mockRepo.AssertNotCalled(t, "UpdateByShortCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteByShortCode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestShortenedServiceIml_Revisions(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	revisions := &memoryRevisionRepository{revisions: []entity.Revision{
+		{ID: "rev-1", Domain: testDomain, ShortCode: "abc123", OriginalURL: "https://first.example.com"},
+		{ID: "rev-other", Domain: testDomain, ShortCode: "other", OriginalURL: "https://other.example.com"},
+	}}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), revisions, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	shortcode := "abc123"
+	mockRepo.On("GetByShortCode", ctx, testDomain, shortcode).Return(ownedLink(shortcode), nil)
+	mockRepo.On("UpdateByShortCode", ctx, testDomain, shortcode, "https://second.example.com").
+		Return(&entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://second.example.com"}, nil)
+	mockRepo.On("UpdateByShortCode", ctx, testDomain, shortcode, "https://first.example.com").
+		Return(&entity.ShortenedURL{Domain: testDomain, ShortCode: shortcode, OriginalURL: "https://first.example.com"}, nil)
+
+	_, err := service.UpdateShortenedURL(ctx, testDomain, shortcode, "https://second.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		result, err := service.ListRevisions(ctx, testDomain, shortcode)
+
+		assert.NoError(t, err)
+		if len(*result) != 2 {
+			t.Fatalf("expected 2 revisions, got %d", len(*result))
+		}
+		assert.Equal(t, "https://second.example.com", (*result)[0].OriginalURL)
+		assert.Equal(t, testUser.UserID, (*result)[0].CreatedBy)
+		assert.Equal(t, "rev-1", (*result)[1].ID)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		result, err := service.RollbackShortenedURL(ctx, testDomain, shortcode, "rev-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://first.example.com", result.OriginalURL)
+
+		history, _ := service.ListRevisions(ctx, testDomain, shortcode)
+		assert.Len(t, *history, 3)
+		assert.Equal(t, "rev-1", (*history)[0].RestoredFrom)
+	})
+
+	t.Run("RevisionOfOtherLink", func(t *testing.T) {
+		_, err := service.RollbackShortenedURL(ctx, testDomain, shortcode, "rev-other")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+
+	t.Run("UnknownRevision", func(t *testing.T) {
+		_, err := service.RollbackShortenedURL(ctx, testDomain, shortcode, "missing")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+}
+
+func TestShortenedServiceIml_Trash(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	audit := &memoryAuditService{}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, audit, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	deletedAt := time.Now()
+	trashed := func(shortcode string) *entity.ShortenedURL {
+		link := ownedLink(shortcode)
+		link.DeletedAt = &deletedAt
+		return link
+	}
+
+	t.Run("List", func(t *testing.T) {
+		mockRepo.On("GetDeleted", ctx, testWorkspace).Return(&[]entity.ShortenedURL{*trashed("abc123")}, nil)
+
+		result, err := service.ListTrash(ctx)
+
+		assert.NoError(t, err)
+		assert.Len(t, *result, 1)
+		assert.NotEmpty(t, (*result)[0].ShortenedURL)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		mockRepo.On("GetDeletedByShortCode", ctx, testDomain, "abc123").Return(trashed("abc123"), nil)
+		mockRepo.On("RestoreByShortCode", ctx, testDomain, "abc123").Return(ownedLink("abc123"), nil)
+
+		result, err := service.RestoreShortenedURL(ctx, testDomain, "abc123")
+
+		assert.NoError(t, err)
+		assert.Nil(t, result.DeletedAt)
+		if len(audit.entries) != 1 {
+			t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+		}
+		assert.Equal(t, entity.AuditRestore, audit.entries[0].Action)
+		assert.NotNil(t, audit.entries[0].Before.DeletedAt)
+	})
+
+	t.Run("OtherWorkspace", func(t *testing.T) {
+		link := trashed("others")
+		link.Workspace = "globex"
+		mockRepo.On("GetDeletedByShortCode", ctx, testDomain, "others").Return(link, nil)
+
+		_, err := service.RestoreShortenedURL(ctx, testDomain, "others")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+		mockRepo.AssertNotCalled(t, "RestoreByShortCode", ctx, testDomain, "others")
+	})
+
+	t.Run("NotInTrash", func(t *testing.T) {
+		mockRepo.On("GetDeletedByShortCode", ctx, testDomain, "missing").Return((*entity.ShortenedURL)(nil), constants.ErrorNotFound)
+
+		_, err := service.RestoreShortenedURL(ctx, testDomain, "missing")
+
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+}
+
+func TestShortenedServiceIml_UpdateStatus(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	workspaces := roleWorkspaceService{roles: map[string]string{testWorkspace: entity.RoleEditor, "globex": entity.RoleAdmin}}
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, workspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	withStatus := func(shortcode string, workspace string, status string) *entity.ShortenedURL {
+		link := ownedLink(shortcode)
+		link.Workspace = workspace
+		link.Status = status
+		return link
+	}
+
+	mockRepo.On("GetByShortCode", ctx, testDomain, "active").Return(withStatus("active", testWorkspace, ""), nil)
+	mockRepo.On("GetByShortCode", ctx, testDomain, "blocked").Return(withStatus("blocked", testWorkspace, entity.LinkStatusBlocked), nil)
+	mockRepo.On("GetByShortCode", ctx, testDomain, "globex").Return(withStatus("globex", "globex", ""), nil)
+	mockRepo.On("UpdateStatusByShortCode", ctx, testDomain, "active", entity.LinkStatusDisabled).
+		Return(withStatus("active", testWorkspace, entity.LinkStatusDisabled), nil)
+	mockRepo.On("UpdateStatusByShortCode", ctx, testDomain, "globex", entity.LinkStatusBlocked).
+		Return(withStatus("globex", "globex", entity.LinkStatusBlocked), nil)
+
+	tests := []struct {
+		name      string
+		shortcode string
+		status    string
+		err       error
+	}{
+		{"EditorDisables", "active", entity.LinkStatusDisabled, nil},
+		{"EditorBlocks", "active", entity.LinkStatusBlocked, constants.ErrorForbidden},
+		{"EditorUnblocks", "blocked", entity.LinkStatusActive, constants.ErrorForbidden},
+		{"AdminBlocks", "globex", entity.LinkStatusBlocked, nil},
+		{"UnknownStatus", "active", "paused", constants.ErrorInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.UpdateStatus(ctx, testDomain, tt.shortcode, tt.status)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.status, result.Status)
+		})
+	}
+}
+
+func TestShortenedServiceIml_UpdateDetails(t *testing.T) {
+	mockRepo := new(MockShortenedRepository)
+	service := NewShortenedService(mockRepo, new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, testDomains, &memoryAuditService{}, false)
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("Success", func(t *testing.T) {
+		details := entity.LinkDetails{Title: "Launch", Notes: "Spring campaign", Tags: []string{"marketing", "launch"}}
+		updated := ownedLink("abc123")
+		updated.Title, updated.Notes, updated.Tags = details.Title, details.Notes, details.Tags
+		mockRepo.On("GetByShortCode", ctx, testDomain, "abc123").Return(ownedLink("abc123"), nil)
+		mockRepo.On("UpdateDetailsByShortCode", ctx, testDomain, "abc123", details).Return(updated, nil)
+
+		result, err := service.UpdateDetails(ctx, testDomain, "abc123", entity.LinkDetails{
+			Title: "  Launch ",
+			Notes: "Spring campaign",
+			Tags:  []string{"Marketing", " launch", "", "marketing"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"marketing", "launch"}, result.Tags)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TitleTooLong", func(t *testing.T) {
+		_, err := service.UpdateDetails(ctx, testDomain, "abc123", entity.LinkDetails{Title: strings.Repeat("a", maxTitleLength+1)})
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("FilterByTag", func(t *testing.T) {
+		tagged := &entity.LinkPage{Links: []entity.ShortenedURL{*ownedLink("abc123")}}
+		query := firstPage
+		query.Tag = "marketing"
+		mockRepo.On("GetShortenedURLs", ctx, testWorkspace, query).Return(tagged, nil)
+
+		result, err := service.ListShortenedURLs(ctx, entity.LinkQuery{Tag: " Marketing"})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Links, 1)
+	})
+}
+
+func TestShortenedServiceIml_LinkDomains(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	domainRepo := new(MockDomainRepository)
+	domainRepo.On("GetByName", ctx, "go.globex.com").Return(&entity.Domain{Name: "go.globex.com", Workspace: "globex",
+		Verification: entity.DomainVerification{Status: entity.DomainStatusVerified}}, nil)
+	domainRepo.On("GetByName", ctx, "new.acme.com").Return(&entity.Domain{Name: "new.acme.com", Workspace: testWorkspace,
+		Verification: entity.DomainVerification{Status: entity.DomainStatusPending, Token: "abc"}}, nil)
+	domains := NewDomainService(domainRepo, nil, testWorkspaces, testDomain)
+
+	// the repository has no expectations, no link may be written
+	service := NewShortenedService(new(MockShortenedRepository), new(MockClickRepository), &memoryRevisionRepository{}, unavailableMetadataFetcher{}, testWorkspaces, domains, &memoryAuditService{}, false)
+
+	tests := []struct {
+		name   string
+		domain string
+		err    error
+	}{
+		{"ForeignDomain", "go.globex.com", constants.ErrorNotFound},
+		{"PendingDomain", "new.acme.com", constants.ErrorForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.ShortenURL(ctx, test.domain, "https://example.com")
+			assert.ErrorIs(t, err, test.err)
+
+			_, err = service.BulkShorten(ctx, test.domain, []entity.BulkLink{{OriginalURL: "https://example.com"}})
+			assert.ErrorIs(t, err, test.err)
+
+			_, err = service.ImportLinks(ctx, test.domain, []entity.ImportLink{{Source: "bitly:bit.ly/deck", ShortCode: "deck", OriginalURL: "https://example.com"}})
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
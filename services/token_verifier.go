@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits how often an unknown key id triggers a JWKS reload
+const jwksRefreshInterval = time.Minute
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verificationKey struct {
+	alg    string
+	public crypto.PublicKey
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience accepts both forms of the aud claim, a single string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*a = list
+
+	return nil
+}
+
+// TokenClaims are the verified claims of an access token.
+type TokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     float64  `json:"exp"`
+	NotBefore     float64  `json:"nbf"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Scope         string   `json:"scope"`
+	// Raw holds every claim, for claims whose name is configured
+	Raw map[string]any `json:"-"`
+}
+
+// TokenVerifier verifies JWT access tokens signed with RS256/384/512 or ES256/384
+// against a JWKS loaded from a file or URL.
+type TokenVerifier struct {
+	source   string
+	issuer   string
+	audience string
+	leeway   time.Duration
+	client   *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]verificationKey
+	refreshed time.Time
+}
+
+func NewTokenVerifier(source string, issuer string, audience string, leeway time.Duration) *TokenVerifier {
+	return &TokenVerifier{
+		source:   source,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   leeway,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *TokenVerifier) isRemote() bool {
+	return strings.HasPrefix(v.source, "https://") || strings.HasPrefix(v.source, "http://")
+}
+
+// Load reads the JWKS, replacing the keys loaded before.
+func (v *TokenVerifier) Load(ctx context.Context) error {
+	data, err := v.readSource(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading JWKS from %s: %w", v.source, err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err = json.Unmarshal(data, &set)
+	if err != nil {
+		return fmt.Errorf("error parsing JWKS from %s: %w", v.source, err)
+	}
+
+	keys := make(map[string]verificationKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			log.Printf("skipping JWKS key %q: %v\n", jwk.Kid, err)
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no usable signing keys in JWKS from %s", v.source)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.refreshed = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (v *TokenVerifier) readSource(ctx context.Context) ([]byte, error) {
+	if !v.isRemote() {
+		return os.ReadFile(v.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// key returns the key for kid, reloading a remote JWKS once in a while so rotated keys are picked up.
+func (v *TokenVerifier) key(ctx context.Context, kid string) (verificationKey, bool) {
+	v.mu.RLock()
+	key, ok := v.lookup(kid)
+	stale := time.Since(v.refreshed) >= jwksRefreshInterval
+	v.mu.RUnlock()
+
+	if ok || !v.isRemote() || !stale {
+		return key, ok
+	}
+
+	err := v.Load(ctx)
+	if err != nil {
+		log.Print(err)
+		return verificationKey{}, false
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.lookup(kid)
+}
+
+func (v *TokenVerifier) lookup(kid string) (verificationKey, bool) {
+	// tokens without a key id are accepted when the set holds a single key
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+
+	return key, ok
+}
+
+// Verify checks the signature, issuer, audience and lifetime of token and returns its claims.
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", constants.ErrorUnauthorized)
+	}
+
+	var header tokenHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", constants.ErrorUnauthorized)
+	}
+
+	key, ok := v.key(ctx, header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", constants.ErrorUnauthorized, header.Kid)
+	}
+
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("%w: algorithm %s does not match the signing key", constants.ErrorUnauthorized, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token signature", constants.ErrorUnauthorized)
+	}
+
+	err = verifySignature(header.Alg, key.public, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrorUnauthorized, err)
+	}
+
+	var claims TokenClaims
+	if decodeSegment(parts[1], &claims) != nil || decodeSegment(parts[1], &claims.Raw) != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", constants.ErrorUnauthorized)
+	}
+
+	err = v.validateClaims(&claims, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrorUnauthorized, err)
+	}
+
+	return &claims, nil
+}
+
+func (v *TokenVerifier) validateClaims(claims *TokenClaims, now time.Time) error {
+	if claims.Issuer != v.issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+
+	if !slices.Contains(claims.Audience, v.audience) {
+		return fmt.Errorf("token is not meant for audience %q", v.audience)
+	}
+
+	if claims.Subject == "" {
+		return errors.New("token has no subject")
+	}
+
+	if claims.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+
+	if now.Add(-v.leeway).After(unixTime(claims.ExpiresAt)) {
+		return errors.New("token is expired")
+	}
+
+	if claims.NotBefore != 0 && now.Add(v.leeway).Before(unixTime(claims.NotBefore)) {
+		return errors.New("token is not valid yet")
+	}
+
+	return nil
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, target)
+}
+
+func verifySignature(alg string, public crypto.PublicKey, signed []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	digest := digest(hash, signed)
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match an RSA key", alg)
+		}
+
+		if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("algorithm %s does not match an EC key", alg)
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+
+	return nil
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func parseJSONWebKey(jwk jsonWebKey) (verificationKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return verificationKey{}, err
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return verificationKey{}, err
+		}
+
+		return verificationKey{alg: jwk.Alg, public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return verificationKey{}, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return verificationKey{}, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return verificationKey{}, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return verificationKey{}, errors.New("point is not on the curve")
+		}
+
+		return verificationKey{alg: jwk.Alg, public: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://idp.acme.com"
+	testAudience = "shortenurl"
+)
+
+// testIdentityProvider stands in for an identity provider, it signs tokens and serves its JWKS
+type testIdentityProvider struct {
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+func newTestIdentityProvider(t *testing.T, kids ...string) *testIdentityProvider {
+	provider := &testIdentityProvider{keys: map[string]*rsa.PrivateKey{}}
+	for _, kid := range kids {
+		provider.addKey(t, kid)
+	}
+
+	return provider
+}
+
+func (p *testIdentityProvider) addKey(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[kid] = key
+}
+
+func (p *testIdentityProvider) jwks() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var keys []jsonWebKey
+	for kid, key := range p.keys {
+		keys = append(keys, jsonWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+
+	return data
+}
+
+func (p *testIdentityProvider) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(p.jwks())
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (p *testIdentityProvider) sign(t *testing.T, kid string, claims map[string]any) string {
+	p.mu.Lock()
+	key := p.keys[kid]
+	p.mu.Unlock()
+
+	signed := encodeSegment(t, map[string]any{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   testIssuer,
+		"sub":   "idp|jane",
+		"aud":   []string{"other", testAudience},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"email": "jane@acme.com",
+	}
+}
+
+func TestTokenVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestIdentityProvider(t, "key-1")
+	server := provider.serve(t)
+
+	verifier := NewTokenVerifier(server.URL, testIssuer, testAudience, time.Minute)
+	if err := verifier.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(name string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		valid  bool
+	}{
+		{"Valid", validClaims(), true},
+		{"SingleAudience", with("aud", testAudience), true},
+		{"WithinLeeway", with("exp", time.Now().Add(-30*time.Second).Unix()), true},
+		{"Expired", with("exp", time.Now().Add(-2*time.Minute).Unix()), false},
+		{"NoExpiry", with("exp", nil), false},
+		{"NotYetValid", with("nbf", time.Now().Add(time.Hour).Unix()), false},
+		{"WrongIssuer", with("iss", "https://evil.example.com"), false},
+		{"WrongAudience", with("aud", "other"), false},
+		{"NoSubject", with("sub", nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(ctx, provider.sign(t, "key-1", tt.claims))
+
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, "idp|jane", claims.Subject)
+				assert.Equal(t, "jane@acme.com", claims.Raw["email"])
+			} else {
+				assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+			}
+		})
+	}
+
+	t.Run("TamperedClaims", func(t *testing.T) {
+		parts := strings.Split(provider.sign(t, "key-1", validClaims()), ".")
+		parts[1] = encodeSegment(t, with("sub", "idp|admin"))
+
+		_, err := verifier.Verify(ctx, strings.Join(parts, "."))
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+
+	t.Run("NoneAlgorithm", func(t *testing.T) {
+		token := encodeSegment(t, map[string]any{"alg": "none", "kid": "key-1"}) + "." + encodeSegment(t, validClaims()) + "."
+
+		_, err := verifier.Verify(ctx, token)
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, "not-a-token")
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestTokenVerifier_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestIdentityProvider(t, "key-1")
+	server := provider.serve(t)
+
+	verifier := NewTokenVerifier(server.URL, testIssuer, testAudience, time.Minute)
+	if err := verifier.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.addKey(t, "key-2")
+	token := provider.sign(t, "key-2", validClaims())
+
+	// the JWKS was just loaded, unknown keys do not reload it right away
+	_, err := verifier.Verify(ctx, token)
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	verifier.refreshed = time.Now().Add(-jwksRefreshInterval)
+
+	_, err = verifier.Verify(ctx, token)
+	assert.NoError(t, err)
+}
+
+func TestTokenVerifier_FileAndECKeys(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []jsonWebKey{{
+		Kty: "EC",
+		Kid: "ec-1",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}})
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewTokenVerifier(path, testIssuer, testAudience, 0)
+	if err := verifier.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	signed := encodeSegment(t, map[string]any{"alg": "ES256", "kid": "ec-1"}) + "." + encodeSegment(t, validClaims())
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	claims, err := verifier.Verify(ctx, signed+"."+base64.RawURLEncoding.EncodeToString(signature))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "idp|jane", claims.Subject)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTOTPSecret is the SHA1 key of the RFC 6238 test vectors
+var testTOTPSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestMatchTOTP(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		code string
+		step int64
+		ok   bool
+	}{
+		{"RFC6238_59", 59, "287082", 1, true},
+		{"RFC6238_1111111109", 1111111109, "081804", 37037036, true},
+		{"RFC6238_1234567890", 1234567890, "005924", 41152263, true},
+		{"PreviousStep", 89, "287082", 1, true},
+		{"Spaces", 59, " 287 082 ", 1, true},
+		{"Expired", 120, "287082", 0, false},
+		{"Wrong", 59, "287083", 0, false},
+		{"TooShort", 59, "28708", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(testTOTPSecret, tt.code, time.Unix(tt.unix, 0))
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.step, step)
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(totpURI("Shorten URL", "jane@acme.com", "JBSWY3DPEHPK3PXP"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Shorten URL:jane@acme.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Shorten URL", uri.Query().Get("issuer"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	for _, code := range codes {
+		assert.Len(t, code, 11)
+		assert.Equal(t, hashRecoveryCode(code), hashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"log"
+	"time"
+)
+
+// TrashPurger periodically deletes the links that have been in the trash for longer than the
+// retention period, together with their revisions and clicks, so their short codes can be used
+// again. Audit entries of purged links are kept.
+type TrashPurger struct {
+	repository         repository.ShortenedRepository
+	clickRepository    repository.ClickRepository
+	revisionRepository repository.RevisionRepository
+	retention          time.Duration
+	interval           time.Duration
+}
+
+func NewTrashPurger(repo repository.ShortenedRepository, clickRepo repository.ClickRepository, revisionRepo repository.RevisionRepository, retention time.Duration, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		repository:         repo,
+		clickRepository:    clickRepo,
+		revisionRepository: revisionRepo,
+		retention:          retention,
+		interval:           interval,
+	}
+}
+
+// Start purges the trash every interval until ctx is cancelled.
+func (p *TrashPurger) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Purge(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes the links deleted before now minus the retention period.
+func (p *TrashPurger) Purge(ctx context.Context, now time.Time) {
+	purged, err := p.repository.PurgeDeleted(ctx, now.Add(-p.retention))
+	if err != nil {
+		log.Printf("trash purge: error purging deleted links %v\n", err)
+	}
+
+	if purged == nil {
+		return
+	}
+
+	for _, shortened := range *purged {
+		log.Printf("trash purge: purged %s/%s\n", shortened.Domain, shortened.ShortCode)
+
+		// a later link may get the same short code, it starts with a history and clicks of its own
+		err := p.revisionRepository.DeleteByLink(ctx, shortened.Domain, shortened.ShortCode)
+		if err != nil {
+			log.Printf("trash purge: error deleting revisions of %s %v\n", shortened.ShortCode, err)
+		}
+
+		err = p.clickRepository.DeleteByLink(ctx, shortened.Domain, shortened.ShortCode)
+		if err != nil {
+			log.Printf("trash purge: error deleting clicks of %s %v\n", shortened.ShortCode, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashPurger_Purge(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockShortenedRepository)
+	revisions := &memoryRevisionRepository{revisions: []entity.Revision{
+		{ID: "rev-1", Domain: testDomain, ShortCode: "expired"},
+		{ID: "rev-2", Domain: testDomain, ShortCode: "kept"},
+	}}
+	clicks := new(MockClickRepository)
+	purger := NewTrashPurger(mockRepo, clicks, revisions, 24*time.Hour, time.Hour)
+
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	mockRepo.On("PurgeDeleted", ctx, now.Add(-24*time.Hour)).Return(&[]entity.ShortenedURL{
+		{Domain: testDomain, ShortCode: "expired"},
+	}, nil)
+	clicks.On("DeleteByLink", ctx, testDomain, "expired").Return(nil)
+
+	purger.Purge(ctx, now)
+
+	mockRepo.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+	if len(revisions.revisions) != 1 {
+		t.Fatalf("expected 1 revision left, got %d", len(revisions.revisions))
+	}
+	assert.Equal(t, "kept", revisions.revisions[0].ShortCode)
+}
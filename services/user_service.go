@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"golang.org/x/crypto/bcrypt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	minPasswordLength = 8
+	// TwoFactorLoginTTL is how long a login waits for the code of the second factor
+	TwoFactorLoginTTL = 5 * time.Minute
+	// maxTwoFactorAttempts invalid codes end a login, the password has to be entered again
+	maxTwoFactorAttempts = 5
+)
+
+// UserService manages accounts and their sessions. Users with two-factor authentication
+// enabled sign in with Login followed by VerifyLogin.
+type UserService interface {
+	Register(ctx context.Context, email string, password string) (*entity.User, error)
+	Login(ctx context.Context, email string, password string) (string, *entity.User, error)
+	VerifyLogin(ctx context.Context, token string, code string) (string, *entity.User, error)
+	Logout(ctx context.Context, token string) error
+	Authenticate(ctx context.Context, token string) (*entity.User, error)
+	SetupTwoFactor(ctx context.Context) (*entity.TwoFactorSetup, error)
+	EnableTwoFactor(ctx context.Context, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error)
+}
+
+type UserServiceIml struct {
+	repository        repository.UserRepository
+	sessionRepository repository.SessionRepository
+	sessionTTL        time.Duration
+	totpIssuer        string
+}
+
+// NewUserService creates the user service, totpIssuer names this service in authenticator apps.
+func NewUserService(repo repository.UserRepository, sessionRepo repository.SessionRepository, sessionTTL time.Duration, totpIssuer string) UserService {
+	return &UserServiceIml{repository: repo, sessionRepository: sessionRepo, sessionTTL: sessionTTL, totpIssuer: totpIssuer}
+}
+
+func (s *UserServiceIml) Register(ctx context.Context, email string, password string) (*entity.User, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid email %q", constants.ErrorInvalidRequest, email)
+	}
+
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("%w: password must have at least %d characters", constants.ErrorInvalidRequest, minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := entity.User{
+		ID:           bson.NewObjectID().Hex(),
+		Email:        strings.ToLower(address.Address),
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+
+	err = s.repository.Insert(ctx, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("%w: user %s", constants.ErrorAlreadyExists, user.Email)
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// unknownUserHash is compared with the password of logins of unknown users and users without a
+// password, so they take as long as those of users with a password and do not tell which
+// accounts exist. It has the cost of the hashes of Register.
+const unknownUserHash = "$2a$10$/gAZEgwBP9ho3g/nD1f8NuyGfpVPW03lJS/kzJZWHgmakHf3igARS"
+
+// Login checks the credentials and starts a session, the returned token belongs in the session cookie.
+// The session of a user with two-factor authentication only becomes valid with VerifyLogin.
+func (s *UserServiceIml) Login(ctx context.Context, email string, password string) (string, *entity.User, error) {
+	user, err := s.repository.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil && !errors.Is(err, constants.ErrorNotFound) {
+		return "", nil, err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword([]byte(unknownUserHash), []byte(password))
+		return "", nil, fmt.Errorf("%w: invalid email or password", constants.ErrorUnauthorized)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: invalid email or password", constants.ErrorUnauthorized)
+	}
+
+	token, err := s.startSession(ctx, user.ID, user.TwoFactorEnabled())
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+func (s *UserServiceIml) startSession(ctx context.Context, userID string, twoFactorPending bool) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session := entity.Session{UserID: userID, TwoFactorPending: twoFactorPending, CreatedAt: now, ExpiresAt: now.Add(s.sessionTTL)}
+	if twoFactorPending {
+		session.ExpiresAt = now.Add(TwoFactorLoginTTL)
+	}
+
+	err = s.sessionRepository.Insert(ctx, hashToken(token), session)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyLogin completes the login of token with a code of the authenticator app or a recovery
+// code. It returns the token of the new session, the one of the login is no longer valid.
+func (s *UserServiceIml) VerifyLogin(ctx context.Context, token string, code string) (string, *entity.User, error) {
+	session, err := s.sessionRepository.GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return "", nil, constants.ErrorUnauthorized
+		}
+
+		return "", nil, err
+	}
+
+	if !session.TwoFactorPending || time.Now().After(session.ExpiresAt) {
+		return "", nil, constants.ErrorUnauthorized
+	}
+
+	user, err := s.repository.GetByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return "", nil, constants.ErrorUnauthorized
+		}
+
+		return "", nil, err
+	}
+
+	err = s.checkSecondFactor(ctx, user, code)
+	if errors.Is(err, constants.ErrorInvalidRequest) {
+		session.FailedAttempts++
+		if session.FailedAttempts >= maxTwoFactorAttempts {
+			err = s.sessionRepository.Delete(ctx, hashToken(token))
+		} else {
+			err = s.sessionRepository.Insert(ctx, hashToken(token), *session)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "", nil, fmt.Errorf("%w: invalid two-factor code", constants.ErrorUnauthorized)
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = s.sessionRepository.Delete(ctx, hashToken(token))
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err = s.startSession(ctx, user.ID, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+func (s *UserServiceIml) Logout(ctx context.Context, token string) error {
+	return s.sessionRepository.Delete(ctx, hashToken(token))
+}
+
+// Authenticate returns the user signed in with the session token.
+func (s *UserServiceIml) Authenticate(ctx context.Context, token string) (*entity.User, error) {
+	session, err := s.sessionRepository.GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, constants.ErrorUnauthorized
+		}
+
+		return nil, err
+	}
+
+	if session.TwoFactorPending || time.Now().After(session.ExpiresAt) {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	user, err := s.repository.GetByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, constants.ErrorUnauthorized
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkSecondFactor accepts a code of the authenticator app or an unused recovery code of
+// user once, other codes fail with ErrorInvalidRequest.
+func (s *UserServiceIml) checkSecondFactor(ctx context.Context, user *entity.User, code string) error {
+	if !user.TwoFactorEnabled() {
+		return fmt.Errorf("%w: two-factor authentication is not enabled", constants.ErrorInvalidRequest)
+	}
+
+	err := constants.ErrorNotFound
+	if step, ok := matchTOTP(user.TwoFactor.Secret, code, time.Now()); ok {
+		err = s.repository.UseTOTPStep(ctx, user.ID, step)
+	} else if strings.TrimSpace(code) != "" {
+		err = s.repository.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+	}
+
+	if errors.Is(err, constants.ErrorNotFound) {
+		return fmt.Errorf("%w: invalid two-factor code", constants.ErrorInvalidRequest)
+	}
+
+	return err
+}
+
+// currentUser returns the account of the signed-in user managing their two-factor settings.
+func (s *UserServiceIml) currentUser(ctx context.Context) (*entity.User, error) {
+	principal, err := sessionPrincipal(ctx, "two-factor settings")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.GetByID(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, constants.ErrorUnauthorized
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetupTwoFactor starts the enrollment of an authenticator app, EnableTwoFactor completes it.
+func (s *UserServiceIml) SetupTwoFactor(ctx context.Context) (*entity.TwoFactorSetup, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", constants.ErrorAlreadyExists)
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.SetTwoFactor(ctx, user.ID, entity.TwoFactor{Secret: secret})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.TwoFactorSetup{Secret: secret, URI: totpURI(s.totpIssuer, user.Email, secret)}, nil
+}
+
+// EnableTwoFactor confirms the enrollment with a first code and returns the recovery codes,
+// they are only available now.
+func (s *UserServiceIml) EnableTwoFactor(ctx context.Context, code string) ([]string, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", constants.ErrorAlreadyExists)
+	}
+
+	if user.TwoFactor == nil {
+		return nil, fmt.Errorf("%w: set up two-factor authentication first", constants.ErrorInvalidRequest)
+	}
+
+	step, ok := matchTOTP(user.TwoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid two-factor code", constants.ErrorInvalidRequest)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.repository.SetTwoFactor(ctx, user.ID, entity.TwoFactor{
+		Secret:        user.TwoFactor.Secret,
+		Enabled:       true,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+		EnabledAt:     &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a code.
+func (s *UserServiceIml) DisableTwoFactor(ctx context.Context, code string) error {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.DeleteTwoFactor(ctx, user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code.
+func (s *UserServiceIml) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	user, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.SetRecoveryCodes(ctx, user.ID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
+
+func generateToken() (string, error) {
+	token := make([]byte, 32)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashToken keeps raw tokens out of storage, a leaked store does not leak usable sessions.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
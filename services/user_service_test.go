@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MockUserRepository is a mock type for repository.UserRepository
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetBySubject(ctx context.Context, subject string) (*entity.User, error) {
+	args := m.Called(ctx, subject)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) LinkSubject(ctx context.Context, id string, subject string) (*entity.User, error) {
+	args := m.Called(ctx, id, subject)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Insert(ctx context.Context, user entity.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetTwoFactor(ctx context.Context, id string, twoFactor entity.TwoFactor) error {
+	args := m.Called(ctx, id, twoFactor)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteTwoFactor(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetRecoveryCodes(ctx context.Context, id string, codeHashes []string) error {
+	args := m.Called(ctx, id, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	args := m.Called(ctx, id, step)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UseRecoveryCode(ctx context.Context, id string, codeHash string) error {
+	args := m.Called(ctx, id, codeHash)
+	return args.Error(0)
+}
+
+// memorySessionRepository keeps sessions in a map
+type memorySessionRepository map[string]entity.Session
+
+func (m memorySessionRepository) Insert(ctx context.Context, tokenHash string, session entity.Session) error {
+	m[tokenHash] = session
+	return nil
+}
+
+func (m memorySessionRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entity.Session, error) {
+	session, ok := m[tokenHash]
+	if !ok {
+		return nil, constants.ErrorNotFound
+	}
+
+	return &session, nil
+}
+
+func (m memorySessionRepository) Delete(ctx context.Context, tokenHash string) error {
+	delete(m, tokenHash)
+	return nil
+}
+
+func TestUserServiceIml_Register(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewUserService(mockRepo, memorySessionRepository{}, time.Hour, "Shorten URL")
+
+		mockRepo.On("Insert", ctx, mock.MatchedBy(func(user entity.User) bool {
+			return user.Email == "jane@acme.com" && user.ID != "" &&
+				bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("correct horse")) == nil
+		})).Return(nil)
+
+		user, err := service.Register(ctx, " Jane@Acme.com ", "correct horse")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "jane@acme.com", user.Email)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		service := NewUserService(new(MockUserRepository), memorySessionRepository{}, time.Hour, "Shorten URL")
+
+		_, err := service.Register(ctx, "not an email", "correct horse")
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("ShortPassword", func(t *testing.T) {
+		service := NewUserService(new(MockUserRepository), memorySessionRepository{}, time.Hour, "Shorten URL")
+
+		_, err := service.Register(ctx, "jane@acme.com", "short")
+
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewUserService(mockRepo, memorySessionRepository{}, time.Hour, "Shorten URL")
+
+		mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.User")).Return(createDuplicateKeyError())
+
+		_, err := service.Register(ctx, "jane@acme.com", "correct horse")
+
+		assert.ErrorIs(t, err, constants.ErrorAlreadyExists)
+	})
+}
+
+func TestUserServiceIml_Session(t *testing.T) {
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	user := &entity.User{ID: "user-1", Email: "jane@acme.com", PasswordHash: string(hash)}
+
+	mockRepo := new(MockUserRepository)
+	sessions := memorySessionRepository{}
+	service := NewUserService(mockRepo, sessions, time.Hour, "Shorten URL")
+
+	mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return(user, nil)
+	mockRepo.On("GetByEmail", ctx, "john@acme.com").Return((*entity.User)(nil), constants.ErrorNotFound)
+	mockRepo.On("GetByEmail", ctx, "sso@acme.com").Return(&entity.User{ID: "user-2", Email: "sso@acme.com"}, nil)
+	mockRepo.On("GetByID", ctx, "user-1").Return(user, nil)
+
+	_, _, err := service.Login(ctx, "jane@acme.com", "wrong password")
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	_, _, err = service.Login(ctx, "john@acme.com", "correct horse")
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	_, _, err = service.Login(ctx, "sso@acme.com", "")
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+	// unknown users are checked against a hash as slow as those of registered users
+	cost, err := bcrypt.Cost([]byte(unknownUserHash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+
+	token, _, err := service.Login(ctx, "Jane@acme.com", "correct horse")
+	assert.NoError(t, err)
+	assert.NotContains(t, sessions, token, "raw tokens must not be stored")
+
+	authenticated, err := service.Authenticate(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", authenticated.ID)
+
+	err = service.Logout(ctx, token)
+	assert.NoError(t, err)
+
+	_, err = service.Authenticate(ctx, token)
+	assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+}
+
+// currentTOTP returns the code of secret for now
+func currentTOTP(secret string) string {
+	key, _ := totpEncoding.DecodeString(secret)
+
+	return totpCode(key, time.Now().Unix()/totpPeriod)
+}
+
+func TestUserServiceIml_TwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	user := &entity.User{
+		ID:           "user-1",
+		Email:        "jane@acme.com",
+		PasswordHash: string(hash),
+		TwoFactor:    &entity.TwoFactor{Secret: testTOTPSecret, Enabled: true},
+	}
+
+	t.Run("Code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		sessions := memorySessionRepository{}
+		service := NewUserService(mockRepo, sessions, time.Hour, "Shorten URL")
+
+		mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return(user, nil)
+		mockRepo.On("GetByID", ctx, "user-1").Return(user, nil)
+		mockRepo.On("UseTOTPStep", ctx, "user-1", mock.AnythingOfType("int64")).Return(nil).Once()
+		mockRepo.On("UseTOTPStep", ctx, "user-1", mock.AnythingOfType("int64")).Return(constants.ErrorNotFound)
+
+		pending, _, err := service.Login(ctx, "jane@acme.com", "correct horse")
+		assert.NoError(t, err)
+
+		// the password alone does not sign in
+		_, err = service.Authenticate(ctx, pending)
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+
+		token, _, err := service.VerifyLogin(ctx, pending, currentTOTP(testTOTPSecret))
+		assert.NoError(t, err)
+
+		authenticated, err := service.Authenticate(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", authenticated.ID)
+
+		_, _, err = service.VerifyLogin(ctx, pending, currentTOTP(testTOTPSecret))
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized, "the login is over")
+
+		// a code is only accepted once
+		pending, _, _ = service.Login(ctx, "jane@acme.com", "correct horse")
+		_, _, err = service.VerifyLogin(ctx, pending, currentTOTP(testTOTPSecret))
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+
+	t.Run("RecoveryCode", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := NewUserService(mockRepo, memorySessionRepository{}, time.Hour, "Shorten URL")
+
+		mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return(user, nil)
+		mockRepo.On("GetByID", ctx, "user-1").Return(user, nil)
+		mockRepo.On("UseRecoveryCode", ctx, "user-1", hashRecoveryCode("abcde-fghij")).Return(nil)
+
+		pending, _, _ := service.Login(ctx, "jane@acme.com", "correct horse")
+
+		_, _, err := service.VerifyLogin(ctx, pending, "ABCDE FGHIJ")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TooManyAttempts", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		sessions := memorySessionRepository{}
+		service := NewUserService(mockRepo, sessions, time.Hour, "Shorten URL")
+
+		mockRepo.On("GetByEmail", ctx, "jane@acme.com").Return(user, nil)
+		mockRepo.On("GetByID", ctx, "user-1").Return(user, nil)
+		mockRepo.On("UseRecoveryCode", ctx, "user-1", mock.Anything).Return(constants.ErrorNotFound)
+
+		pending, _, _ := service.Login(ctx, "jane@acme.com", "correct horse")
+
+		for i := 0; i < maxTwoFactorAttempts; i++ {
+			_, _, err := service.VerifyLogin(ctx, pending, "wrong")
+			assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+		}
+
+		assert.Empty(t, sessions)
+	})
+}
+
+func TestUserServiceIml_EnableTwoFactor(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	secret, _ := generateTOTPSecret()
+
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, memorySessionRepository{}, time.Hour, "Shorten URL")
+
+	mockRepo.On("GetByID", ctx, testUser.UserID).Return(&entity.User{ID: testUser.UserID, Email: testUser.Email}, nil).Once()
+	mockRepo.On("SetTwoFactor", ctx, testUser.UserID, mock.MatchedBy(func(twoFactor entity.TwoFactor) bool {
+		return !twoFactor.Enabled && twoFactor.Secret != ""
+	})).Return(nil).Once()
+
+	setup, err := service.SetupTwoFactor(ctx)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(setup.URI, "otpauth://totp/Shorten%20URL:user@short.url?"))
+
+	pending := &entity.User{ID: testUser.UserID, Email: testUser.Email, TwoFactor: &entity.TwoFactor{Secret: secret}}
+	mockRepo.On("GetByID", ctx, testUser.UserID).Return(pending, nil).Twice()
+	mockRepo.On("SetTwoFactor", ctx, testUser.UserID, mock.MatchedBy(func(twoFactor entity.TwoFactor) bool {
+		return twoFactor.Enabled && twoFactor.Secret == secret && len(twoFactor.RecoveryCodes) == recoveryCodeCount
+	})).Return(nil).Once()
+
+	_, err = service.EnableTwoFactor(ctx, "000000")
+	assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+	codes, err := service.EnableTwoFactor(ctx, currentTOTP(secret))
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	mockRepo.AssertExpectations(t)
+
+	keyCtx := WithPrincipal(context.Background(), &entity.Principal{UserID: testUser.UserID, APIKeyID: "key-1", Scopes: entity.Scopes})
+	_, err = service.SetupTwoFactor(keyCtx)
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/ilhamtubagus/shortenurl/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var workspaceIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,38}[a-z0-9])?$`)
+
+// WorkspaceService manages workspaces and their members, and decides what members may do.
+// Every user has a personal workspace, it is the selected workspace unless WithWorkspace
+// picks another one.
+type WorkspaceService interface {
+	ListWorkspaces(ctx context.Context) (*[]entity.WorkspaceMembership, error)
+	CreateWorkspace(ctx context.Context, id string, name string) (*entity.WorkspaceMembership, error)
+	ListMembers(ctx context.Context, workspaceID string) (*[]entity.Membership, error)
+	AddMember(ctx context.Context, workspaceID string, email string, role string) (*entity.Membership, error)
+	UpdateMemberRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error)
+	RemoveMember(ctx context.Context, workspaceID string, userID string) error
+	SetTwoFactorRequired(ctx context.Context, workspaceID string, required bool) (*entity.Workspace, error)
+	RequireRole(ctx context.Context, workspaceID string, role string) (*entity.Membership, error)
+}
+
+type WorkspaceServiceIml struct {
+	repository           repository.WorkspaceRepository
+	membershipRepository repository.MembershipRepository
+	userRepository       repository.UserRepository
+	shortenedRepository  repository.ShortenedRepository
+	claimRole            string
+}
+
+// NewWorkspaceService creates the workspace service. Access tokens listing a workspace in their
+// workspace claim act in it with claimRole, unless it is empty.
+func NewWorkspaceService(repo repository.WorkspaceRepository, membershipRepo repository.MembershipRepository, userRepo repository.UserRepository, shortenedRepo repository.ShortenedRepository, claimRole string) WorkspaceService {
+	return &WorkspaceServiceIml{
+		repository:           repo,
+		membershipRepository: membershipRepo,
+		userRepository:       userRepo,
+		shortenedRepository:  shortenedRepo,
+		claimRole:            claimRole,
+	}
+}
+
+// ensurePersonalWorkspace creates the personal workspace of the principal on first use, and
+// moves the links the user created before workspaces existed into it.
+func (s *WorkspaceServiceIml) ensurePersonalWorkspace(ctx context.Context, principal *entity.Principal) (*entity.Membership, error) {
+	id := entity.PersonalWorkspaceID(principal.UserID)
+	now := time.Now()
+
+	err := s.repository.Insert(ctx, entity.Workspace{ID: id, Name: "Personal", CreatedAt: now})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	membership := entity.Membership{WorkspaceID: id, UserID: principal.UserID, Role: entity.RoleOwner, CreatedAt: now}
+
+	err = s.membershipRepository.Insert(ctx, membership)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	err = s.shortenedRepository.AssignWorkspace(ctx, principal.UserID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// membership returns the membership of the principal in workspaceID, stored or granted by a claim.
+func (s *WorkspaceServiceIml) membership(ctx context.Context, principal *entity.Principal, workspaceID string) (*entity.Membership, error) {
+	membership, err := s.membershipRepository.Get(ctx, workspaceID, principal.UserID)
+	if !errors.Is(err, constants.ErrorNotFound) {
+		return membership, err
+	}
+
+	if workspaceID == entity.PersonalWorkspaceID(principal.UserID) {
+		return s.ensurePersonalWorkspace(ctx, principal)
+	}
+
+	if s.claimRole == "" || !slices.Contains(principal.Workspaces, workspaceID) {
+		return nil, err
+	}
+
+	_, err = s.repository.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.Membership{WorkspaceID: workspaceID, UserID: principal.UserID, Role: s.claimRole}, nil
+}
+
+// RequireRole returns the membership of the principal of ctx in workspaceID, or in the selected
+// workspace when workspaceID is empty, when its role grants at least role and the principal
+// meets the two-factor requirement of the workspace. Workspaces the principal is not a member
+// of are reported as not found.
+func (s *WorkspaceServiceIml) RequireRole(ctx context.Context, workspaceID string, role string) (*entity.Membership, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	if workspaceID == "" {
+		workspaceID = WorkspaceFromContext(ctx)
+	}
+
+	if workspaceID == "" {
+		workspaceID = entity.PersonalWorkspaceID(principal.UserID)
+	}
+
+	membership, err := s.membership(ctx, principal, workspaceID)
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, fmt.Errorf("%w: workspace %s", constants.ErrorNotFound, workspaceID)
+		}
+
+		return nil, err
+	}
+
+	if !entity.RoleAtLeast(membership.Role, role) {
+		return nil, fmt.Errorf("%w: %s of workspace %s needs to be %s", constants.ErrorForbidden, membership.Role, workspaceID, role)
+	}
+
+	if !principal.TwoFactor && !strings.HasPrefix(workspaceID, entity.PersonalWorkspacePrefix) {
+		workspace, err := s.repository.GetByID(ctx, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if workspace.RequireTwoFactor {
+			return nil, fmt.Errorf("%w: workspace %s requires two-factor authentication", constants.ErrorForbidden, workspaceID)
+		}
+	}
+
+	return membership, nil
+}
+
+func (s *WorkspaceServiceIml) ListWorkspaces(ctx context.Context) (*[]entity.WorkspaceMembership, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, constants.ErrorUnauthorized
+	}
+
+	memberships, err := s.membershipRepository.GetByUser(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := map[string]string{}
+	for _, membership := range *memberships {
+		roles[membership.WorkspaceID] = membership.Role
+	}
+
+	personal := entity.PersonalWorkspaceID(principal.UserID)
+	if _, ok := roles[personal]; !ok {
+		membership, err := s.ensurePersonalWorkspace(ctx, principal)
+		if err != nil {
+			return nil, err
+		}
+
+		roles[personal] = membership.Role
+	}
+
+	if s.claimRole != "" {
+		for _, id := range principal.Workspaces {
+			if _, ok := roles[id]; !ok {
+				roles[id] = s.claimRole
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+
+	// claimed workspaces that do not exist are left out here
+	workspaces, err := s.repository.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entity.WorkspaceMembership, 0, len(*workspaces))
+	for _, workspace := range *workspaces {
+		result = append(result, entity.WorkspaceMembership{Workspace: workspace, Role: roles[workspace.ID]})
+	}
+
+	return &result, nil
+}
+
+// CreateWorkspace creates a workspace owned by the signed-in user.
+func (s *WorkspaceServiceIml) CreateWorkspace(ctx context.Context, id string, name string) (*entity.WorkspaceMembership, error) {
+	principal, err := sessionPrincipal(ctx, "workspaces")
+	if err != nil {
+		return nil, err
+	}
+
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !workspaceIDPattern.MatchString(id) || strings.HasPrefix(id, entity.PersonalWorkspacePrefix) {
+		return nil, fmt.Errorf("%w: invalid workspace id %q, use up to 40 lowercase letters, digits and dashes", constants.ErrorInvalidRequest, id)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", constants.ErrorInvalidRequest)
+	}
+
+	workspace := entity.Workspace{ID: id, Name: name, CreatedAt: time.Now()}
+
+	err = s.repository.Insert(ctx, workspace)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("%w: workspace %s", constants.ErrorAlreadyExists, id)
+		}
+
+		return nil, err
+	}
+
+	err = s.membershipRepository.Insert(ctx, entity.Membership{
+		WorkspaceID: id,
+		UserID:      principal.UserID,
+		Role:        entity.RoleOwner,
+		CreatedAt:   workspace.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.WorkspaceMembership{Workspace: workspace, Role: entity.RoleOwner}, nil
+}
+
+func (s *WorkspaceServiceIml) ListMembers(ctx context.Context, workspaceID string) (*[]entity.Membership, error) {
+	_, err := s.RequireRole(ctx, workspaceID, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.membershipRepository.GetByWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, membership := range *memberships {
+		user, err := s.userRepository.GetByID(ctx, membership.UserID)
+		if err == nil {
+			(*memberships)[i].Email = user.Email
+		}
+	}
+
+	return memberships, nil
+}
+
+// manager returns the membership of a signed-in admin or owner of workspaceID.
+func (s *WorkspaceServiceIml) manager(ctx context.Context, workspaceID string) (*entity.Membership, error) {
+	_, err := sessionPrincipal(ctx, "workspace members")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.RequireRole(ctx, workspaceID, entity.RoleAdmin)
+}
+
+// AddMember adds the user with email to the workspace, only owners can add owners.
+func (s *WorkspaceServiceIml) AddMember(ctx context.Context, workspaceID string, email string, role string) (*entity.Membership, error) {
+	actor, err := s.manager(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entity.IsRole(role) {
+		return nil, fmt.Errorf("%w: unknown role %q", constants.ErrorInvalidRequest, role)
+	}
+
+	if role == entity.RoleOwner && actor.Role != entity.RoleOwner {
+		return nil, fmt.Errorf("%w: only owners can add owners", constants.ErrorForbidden)
+	}
+
+	if strings.HasPrefix(workspaceID, entity.PersonalWorkspacePrefix) {
+		return nil, fmt.Errorf("%w: personal workspaces cannot be shared", constants.ErrorInvalidRequest)
+	}
+
+	user, err := s.userRepository.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return nil, fmt.Errorf("%w: no account with email %s", constants.ErrorNotFound, email)
+		}
+
+		return nil, err
+	}
+
+	membership := entity.Membership{
+		WorkspaceID: workspaceID,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        role,
+		CreatedAt:   time.Now(),
+	}
+
+	err = s.membershipRepository.Insert(ctx, membership)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("%w: %s is already a member of %s", constants.ErrorAlreadyExists, user.Email, workspaceID)
+		}
+
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// SetTwoFactorRequired changes whether members need two-factor authentication to access the
+// workspace. Admins requiring it need to have it enabled themselves.
+func (s *WorkspaceServiceIml) SetTwoFactorRequired(ctx context.Context, workspaceID string, required bool) (*entity.Workspace, error) {
+	_, err := s.manager(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(workspaceID, entity.PersonalWorkspacePrefix) {
+		return nil, fmt.Errorf("%w: personal workspaces follow the settings of their account", constants.ErrorInvalidRequest)
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	if required && !principal.TwoFactor {
+		return nil, fmt.Errorf("%w: enable two-factor authentication on your account first", constants.ErrorInvalidRequest)
+	}
+
+	return s.repository.UpdateRequireTwoFactor(ctx, workspaceID, required)
+}
+
+// ensureOtherOwner fails when the owner membership is the last owner of its workspace.
+func (s *WorkspaceServiceIml) ensureOtherOwner(ctx context.Context, membership *entity.Membership) error {
+	if membership.Role != entity.RoleOwner {
+		return nil
+	}
+
+	owners, err := s.membershipRepository.CountByRole(ctx, membership.WorkspaceID, entity.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return fmt.Errorf("%w: workspace %s needs another owner first", constants.ErrorInvalidRequest, membership.WorkspaceID)
+	}
+
+	return nil
+}
+
+// UpdateMemberRole changes the role of a member, only owners can change owners or grant ownership.
+func (s *WorkspaceServiceIml) UpdateMemberRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error) {
+	actor, err := s.manager(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entity.IsRole(role) {
+		return nil, fmt.Errorf("%w: unknown role %q", constants.ErrorInvalidRequest, role)
+	}
+
+	target, err := s.membershipRepository.Get(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if (target.Role == entity.RoleOwner || role == entity.RoleOwner) && actor.Role != entity.RoleOwner {
+		return nil, fmt.Errorf("%w: only owners can change owners", constants.ErrorForbidden)
+	}
+
+	if role != entity.RoleOwner {
+		err = s.ensureOtherOwner(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.membershipRepository.UpdateRole(ctx, workspaceID, userID, role)
+}
+
+// RemoveMember removes a member. Admins remove other members, every member can leave.
+func (s *WorkspaceServiceIml) RemoveMember(ctx context.Context, workspaceID string, userID string) error {
+	principal, err := sessionPrincipal(ctx, "workspace members")
+	if err != nil {
+		return err
+	}
+
+	required := entity.RoleAdmin
+	if userID == principal.UserID {
+		required = entity.RoleViewer
+	}
+
+	actor, err := s.RequireRole(ctx, workspaceID, required)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.membershipRepository.Get(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if target.Role == entity.RoleOwner && actor.Role != entity.RoleOwner {
+		return fmt.Errorf("%w: only owners can remove owners", constants.ErrorForbidden)
+	}
+
+	err = s.ensureOtherOwner(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return s.membershipRepository.Delete(ctx, workspaceID, userID)
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilhamtubagus/shortenurl/constants"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWorkspaceRepository is a mock type for repository.WorkspaceRepository
+type MockWorkspaceRepository struct {
+	mock.Mock
+}
+
+func (m *MockWorkspaceRepository) GetByID(ctx context.Context, id string) (*entity.Workspace, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) GetByIDs(ctx context.Context, ids []string) (*[]entity.Workspace, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(*[]entity.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) Insert(ctx context.Context, workspace entity.Workspace) error {
+	args := m.Called(ctx, workspace)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) UpdateRequireTwoFactor(ctx context.Context, id string, required bool) (*entity.Workspace, error) {
+	args := m.Called(ctx, id, required)
+	return args.Get(0).(*entity.Workspace), args.Error(1)
+}
+
+// MockMembershipRepository is a mock type for repository.MembershipRepository
+type MockMembershipRepository struct {
+	mock.Mock
+}
+
+func (m *MockMembershipRepository) Get(ctx context.Context, workspaceID string, userID string) (*entity.Membership, error) {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) GetByUser(ctx context.Context, userID string) (*[]entity.Membership, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*[]entity.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) GetByWorkspace(ctx context.Context, workspaceID string) (*[]entity.Membership, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).(*[]entity.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) Insert(ctx context.Context, membership entity.Membership) error {
+	args := m.Called(ctx, membership)
+	return args.Error(0)
+}
+
+func (m *MockMembershipRepository) UpdateRole(ctx context.Context, workspaceID string, userID string, role string) (*entity.Membership, error) {
+	args := m.Called(ctx, workspaceID, userID, role)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) Delete(ctx context.Context, workspaceID string, userID string) error {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Error(0)
+}
+
+func (m *MockMembershipRepository) CountByRole(ctx context.Context, workspaceID string, role string) (int64, error) {
+	args := m.Called(ctx, workspaceID, role)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// acmeWorkspaceRepository returns a repository holding the acme workspace
+func acmeWorkspaceRepository(requireTwoFactor bool) *MockWorkspaceRepository {
+	mockRepo := new(MockWorkspaceRepository)
+	mockRepo.On("GetByID", mock.Anything, "acme").Return(&entity.Workspace{ID: "acme", RequireTwoFactor: requireTwoFactor}, nil)
+
+	return mockRepo
+}
+
+func member(workspaceID string, userID string, role string) *entity.Membership {
+	return &entity.Membership{WorkspaceID: workspaceID, UserID: userID, Role: role}
+}
+
+func TestWorkspaceServiceIml_RequireRole(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	personal := entity.PersonalWorkspaceID(testUser.UserID)
+
+	t.Run("PersonalWorkspaceOnFirstUse", func(t *testing.T) {
+		mockRepo := new(MockWorkspaceRepository)
+		mockMembers := new(MockMembershipRepository)
+		mockLinks := new(MockShortenedRepository)
+		service := NewWorkspaceService(mockRepo, mockMembers, new(MockUserRepository), mockLinks, "")
+
+		mockMembers.On("Get", ctx, personal, testUser.UserID).Return((*entity.Membership)(nil), constants.ErrorNotFound)
+		mockRepo.On("Insert", ctx, mock.MatchedBy(func(workspace entity.Workspace) bool {
+			return workspace.ID == personal
+		})).Return(nil)
+		mockMembers.On("Insert", ctx, mock.MatchedBy(func(membership entity.Membership) bool {
+			return membership.WorkspaceID == personal && membership.Role == entity.RoleOwner
+		})).Return(nil)
+		mockLinks.On("AssignWorkspace", ctx, testUser.UserID, personal).Return(nil)
+
+		membership, err := service.RequireRole(ctx, "", entity.RoleEditor)
+
+		assert.NoError(t, err)
+		assert.Equal(t, personal, membership.WorkspaceID)
+		mockRepo.AssertExpectations(t)
+		mockMembers.AssertExpectations(t)
+		mockLinks.AssertExpectations(t)
+	})
+
+	t.Run("SelectedWorkspace", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(false), mockMembers, new(MockUserRepository), nil, "")
+		selected := WithWorkspace(ctx, "acme")
+
+		mockMembers.On("Get", selected, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleViewer), nil)
+		mockMembers.On("Get", selected, "initech", testUser.UserID).Return((*entity.Membership)(nil), constants.ErrorNotFound)
+
+		_, err := service.RequireRole(selected, "", entity.RoleViewer)
+		assert.NoError(t, err)
+
+		_, err = service.RequireRole(selected, "", entity.RoleEditor)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		_, err = service.RequireRole(selected, "initech", entity.RoleViewer)
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+
+	t.Run("WorkspaceClaim", func(t *testing.T) {
+		mockRepo := new(MockWorkspaceRepository)
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(mockRepo, mockMembers, new(MockUserRepository), nil, entity.RoleEditor)
+		tokenCtx := WithPrincipal(context.Background(), &entity.Principal{UserID: "user-1", Subject: "idp|jane", Workspaces: []string{"acme"}})
+
+		mockMembers.On("Get", tokenCtx, mock.Anything, "user-1").Return((*entity.Membership)(nil), constants.ErrorNotFound)
+		mockRepo.On("GetByID", tokenCtx, "acme").Return(&entity.Workspace{ID: "acme"}, nil)
+
+		membership, err := service.RequireRole(tokenCtx, "acme", entity.RoleEditor)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.RoleEditor, membership.Role)
+
+		_, err = service.RequireRole(tokenCtx, "acme", entity.RoleAdmin)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		_, err = service.RequireRole(tokenCtx, "globex", entity.RoleViewer)
+		assert.ErrorIs(t, err, constants.ErrorNotFound)
+	})
+
+	t.Run("TwoFactorRequired", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(true), mockMembers, new(MockUserRepository), nil, "")
+		protected := WithPrincipal(context.Background(), &entity.Principal{UserID: testUser.UserID, TwoFactor: true})
+
+		mockMembers.On("Get", mock.Anything, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleEditor), nil)
+
+		_, err := service.RequireRole(ctx, "acme", entity.RoleViewer)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		_, err = service.RequireRole(protected, "acme", entity.RoleEditor)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		service := NewWorkspaceService(nil, nil, nil, nil, "")
+
+		_, err := service.RequireRole(context.Background(), "acme", entity.RoleViewer)
+
+		assert.ErrorIs(t, err, constants.ErrorUnauthorized)
+	})
+}
+
+func TestWorkspaceServiceIml_CreateWorkspace(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	mockRepo := new(MockWorkspaceRepository)
+	mockMembers := new(MockMembershipRepository)
+	service := NewWorkspaceService(mockRepo, mockMembers, new(MockUserRepository), nil, "")
+
+	mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.Workspace")).Return(nil).Once()
+	mockMembers.On("Insert", ctx, mock.MatchedBy(func(membership entity.Membership) bool {
+		return membership.WorkspaceID == "acme" && membership.UserID == testUser.UserID && membership.Role == entity.RoleOwner
+	})).Return(nil)
+
+	workspace, err := service.CreateWorkspace(ctx, " Acme ", "Acme Inc")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", workspace.ID)
+	assert.Equal(t, entity.RoleOwner, workspace.Role)
+
+	for _, id := range []string{"", "Acme Inc", "-acme", "personal-user-2"} {
+		_, err = service.CreateWorkspace(ctx, id, "Acme")
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest, id)
+	}
+
+	mockRepo.On("Insert", ctx, mock.AnythingOfType("entity.Workspace")).Return(createDuplicateKeyError()).Once()
+	_, err = service.CreateWorkspace(ctx, "acme", "Acme")
+	assert.ErrorIs(t, err, constants.ErrorAlreadyExists)
+
+	keyCtx := WithPrincipal(context.Background(), &entity.Principal{UserID: "user-1", APIKeyID: "key-1", Scopes: entity.Scopes})
+	_, err = service.CreateWorkspace(keyCtx, "globex", "Globex")
+	assert.ErrorIs(t, err, constants.ErrorForbidden)
+}
+
+func TestWorkspaceServiceIml_Members(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+
+	t.Run("AdminsCannotGrantOwnership", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		mockUsers := new(MockUserRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(false), mockMembers, mockUsers, nil, "")
+
+		mockMembers.On("Get", ctx, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleAdmin), nil)
+		mockMembers.On("Get", ctx, "acme", "user-2").Return(member("acme", "user-2", entity.RoleEditor), nil)
+		mockUsers.On("GetByEmail", ctx, "jane@acme.com").Return(&entity.User{ID: "user-2", Email: "jane@acme.com"}, nil)
+		mockMembers.On("Insert", ctx, mock.AnythingOfType("entity.Membership")).Return(nil)
+
+		_, err := service.AddMember(ctx, "acme", "Jane@acme.com", entity.RoleOwner)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		_, err = service.AddMember(ctx, "acme", "jane@acme.com", "superuser")
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		membership, err := service.AddMember(ctx, "acme", "Jane@acme.com", entity.RoleEditor)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-2", membership.UserID)
+
+		_, err = service.UpdateMemberRole(ctx, "acme", "user-2", entity.RoleOwner)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("EditorsCannotManage", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(false), mockMembers, new(MockUserRepository), nil, "")
+
+		mockMembers.On("Get", ctx, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleEditor), nil)
+
+		_, err := service.AddMember(ctx, "acme", "jane@acme.com", entity.RoleViewer)
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+
+		err = service.RemoveMember(ctx, "acme", "user-2")
+		assert.ErrorIs(t, err, constants.ErrorForbidden)
+	})
+
+	t.Run("LastOwner", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(false), mockMembers, new(MockUserRepository), nil, "")
+
+		mockMembers.On("Get", ctx, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleOwner), nil)
+		mockMembers.On("CountByRole", ctx, "acme", entity.RoleOwner).Return(int64(1), nil)
+
+		_, err := service.UpdateMemberRole(ctx, "acme", testUser.UserID, entity.RoleAdmin)
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		err = service.RemoveMember(ctx, "acme", testUser.UserID)
+		assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+		mockMembers.AssertNotCalled(t, "Delete", ctx, "acme", testUser.UserID)
+	})
+
+	t.Run("MembersLeave", func(t *testing.T) {
+		mockMembers := new(MockMembershipRepository)
+		service := NewWorkspaceService(acmeWorkspaceRepository(false), mockMembers, new(MockUserRepository), nil, "")
+
+		mockMembers.On("Get", ctx, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleViewer), nil)
+		mockMembers.On("Delete", ctx, "acme", testUser.UserID).Return(nil)
+
+		err := service.RemoveMember(ctx, "acme", testUser.UserID)
+
+		assert.NoError(t, err)
+		mockMembers.AssertExpectations(t)
+	})
+}
+
+func TestWorkspaceServiceIml_SetTwoFactorRequired(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), testUser)
+	protected := WithPrincipal(context.Background(), &entity.Principal{UserID: testUser.UserID, TwoFactor: true})
+
+	mockRepo := acmeWorkspaceRepository(false)
+	mockMembers := new(MockMembershipRepository)
+	service := NewWorkspaceService(mockRepo, mockMembers, new(MockUserRepository), nil, "")
+
+	mockMembers.On("Get", mock.Anything, "acme", testUser.UserID).Return(member("acme", testUser.UserID, entity.RoleAdmin), nil)
+	mockRepo.On("UpdateRequireTwoFactor", protected, "acme", true).Return(&entity.Workspace{ID: "acme", RequireTwoFactor: true}, nil)
+	mockRepo.On("UpdateRequireTwoFactor", ctx, "acme", false).Return(&entity.Workspace{ID: "acme"}, nil)
+
+	// admins cannot lock themselves out
+	_, err := service.SetTwoFactorRequired(ctx, "acme", true)
+	assert.ErrorIs(t, err, constants.ErrorInvalidRequest)
+
+	workspace, err := service.SetTwoFactorRequired(protected, "acme", true)
+	assert.NoError(t, err)
+	assert.True(t, workspace.RequireTwoFactor)
+
+	workspace, err = service.SetTwoFactorRequired(ctx, "acme", false)
+	assert.NoError(t, err)
+	assert.False(t, workspace.RequireTwoFactor)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/ilhamtubagus/shortenurl/config"
+	"github.com/ilhamtubagus/shortenurl/entity"
+	"log"
+	"os"
+	"strings"
+)
+
+const usersUsage = "users create|get|disable-2fa [arguments]"
+
+// userInfo is a user as the users command prints it
+type userInfo struct {
+	*entity.User
+	TwoFactor bool `json:"twoFactor"`
+}
+
+// usersCommand manages accounts on the database, for operators setting up the first users or
+// helping users locked out of their authenticator app.
+func usersCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: shortenurl %s\n", usersUsage)
+		return errUsage
+	}
+
+	synopses := map[string]string{
+		"create":      "users create email < password",
+		"get":         "users get email",
+		"disable-2fa": "users disable-2fa email",
+	}
+
+	synopsis, ok := synopses[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown users command %q\nusage: shortenurl %s\n", args[0], usersUsage)
+		return errUsage
+	}
+
+	flags := newFlagSet("users "+args[0], synopsis)
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		return usageError(flags)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx := context.Background()
+	email := strings.ToLower(strings.TrimSpace(flags.Arg(0)))
+
+	if args[0] == "create" {
+		return usersCreate(ctx, a, email)
+	}
+
+	user, err := a.userRepository.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", email, err)
+	}
+
+	if args[0] == "disable-2fa" {
+		err = a.userRepository.DeleteTwoFactor(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("turned off two-factor authentication of %s\n", user.Email)
+		user.TwoFactor = nil
+	}
+
+	return printJSON(userInfo{User: user, TwoFactor: user.TwoFactorEnabled()})
+}
+
+// usersCreate registers a user with the password on the first line of standard input, so that
+// it stays out of the shell history
+func usersCreate(ctx context.Context, a *app, email string) error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("reading the password from standard input: %w", err)
+	}
+
+	user, err := a.userService.Register(ctx, email, strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(userInfo{User: user})
+}
@@ -0,0 +1,29 @@
+package util
+
+import "strings"
+
+// user agent fragments of crawlers that render link previews
+var socialCrawlers = []string{
+	"slackbot",
+	"twitterbot",
+	"linkedinbot",
+	"facebookexternalhit",
+	"facebot",
+	"discordbot",
+	"whatsapp",
+	"telegrambot",
+	"skypeuripreview",
+	"pinterest",
+}
+
+// IsSocialCrawler reports whether the user agent belongs to a known link preview crawler
+func IsSocialCrawler(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, crawler := range socialCrawlers {
+		if strings.Contains(userAgent, crawler) {
+			return true
+		}
+	}
+
+	return false
+}
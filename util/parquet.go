@@ -0,0 +1,415 @@
+package util
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ParquetType is the type of the values of a Parquet column
+type ParquetType int
+
+// Column types, timestamps are stored as milliseconds since the epoch in UTC
+const (
+	ParquetString ParquetType = iota
+	ParquetInt64
+	ParquetBool
+	ParquetTimestamp
+)
+
+// ParquetColumn is a top level column of a Parquet file, all columns are optional so nil
+// values are written as nulls.
+type ParquetColumn struct {
+	Name string
+	Type ParquetType
+}
+
+// physical types, converted types, encodings and page types of the Parquet format
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetOptional = 1
+	parquetDataPage = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetChunk is the column chunk of a column in the row group being written
+type parquetChunk struct {
+	// present holds the definition level of every row, false for nulls
+	present []bool
+	values  []byte
+	bools   []bool
+}
+
+// parquetChunkMeta locates a written column chunk for the footer
+type parquetChunkMeta struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunkMeta
+	size   int64
+	rows   int64
+}
+
+// ParquetWriter writes rows to a Parquet file, uncompressed and with plain encoding so that
+// it needs nothing outside the standard library. Rows are buffered and written a row group at
+// a time, so memory stays bounded by the row group size however many rows there are.
+type ParquetWriter struct {
+	w            io.Writer
+	columns      []ParquetColumn
+	rowGroupSize int
+	chunks       []parquetChunk
+	rows         int
+	rowGroups    []parquetRowGroup
+	totalRows    int64
+	offset       int64
+	err          error
+}
+
+// NewParquetWriter starts a Parquet file with the given columns on w, writing a row group
+// every rowGroupSize rows. Close must be called to finish the file.
+func NewParquetWriter(w io.Writer, columns []ParquetColumn, rowGroupSize int) *ParquetWriter {
+	writer := &ParquetWriter{
+		w:            w,
+		columns:      columns,
+		rowGroupSize: max(rowGroupSize, 1),
+		chunks:       make([]parquetChunk, len(columns)),
+	}
+	writer.write(parquetMagic)
+
+	return writer
+}
+
+func (p *ParquetWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// Write adds a row, its values are in the order of the columns. Strings take string values,
+// Int64 int64 or int values, Bool bool values and Timestamp time.Time or *time.Time values.
+func (p *ParquetWriter) Write(row []any) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	if len(row) != len(p.columns) {
+		return fmt.Errorf("parquet row has %d values for %d columns", len(row), len(p.columns))
+	}
+
+	for index, value := range row {
+		err := p.chunks[index].add(p.columns[index], value)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.rows++
+	if p.rows == p.rowGroupSize {
+		p.flush()
+	}
+
+	return p.err
+}
+
+func (c *parquetChunk) add(column ParquetColumn, value any) error {
+	if at, ok := value.(*time.Time); ok {
+		if at == nil {
+			value = nil
+		} else {
+			value = *at
+		}
+	}
+
+	if value == nil {
+		c.present = append(c.present, false)
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if column.Type == ParquetString {
+			c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+			c.values = append(c.values, v...)
+			c.present = append(c.present, true)
+			return nil
+		}
+	case int64:
+		if column.Type == ParquetInt64 {
+			c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+			c.present = append(c.present, true)
+			return nil
+		}
+	case int:
+		if column.Type == ParquetInt64 {
+			c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+			c.present = append(c.present, true)
+			return nil
+		}
+	case bool:
+		if column.Type == ParquetBool {
+			c.bools = append(c.bools, v)
+			c.present = append(c.present, true)
+			return nil
+		}
+	case time.Time:
+		if column.Type == ParquetTimestamp {
+			c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v.UnixMilli()))
+			c.present = append(c.present, true)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("parquet column %s cannot hold %T", column.Name, value)
+}
+
+// page returns the data page of the chunk: its definition levels and its values
+func (c *parquetChunk) page() []byte {
+	// definition levels are run length encoded with a bit width of one
+	var levels []byte
+	for start := 0; start < len(c.present); {
+		end := start
+		for end < len(c.present) && c.present[end] == c.present[start] {
+			end++
+		}
+
+		levels = binary.AppendUvarint(levels, uint64(end-start)<<1)
+		if c.present[start] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		start = end
+	}
+
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	page = append(page, c.values...)
+
+	// booleans are bit packed, least significant bit first
+	for start := 0; start < len(c.bools); start += 8 {
+		var packed byte
+		for bit := 0; bit < 8 && start+bit < len(c.bools); bit++ {
+			if c.bools[start+bit] {
+				packed |= 1 << bit
+			}
+		}
+		page = append(page, packed)
+	}
+
+	return page
+}
+
+// flush writes the buffered rows as a row group
+func (p *ParquetWriter) flush() {
+	if p.rows == 0 {
+		return
+	}
+
+	group := parquetRowGroup{rows: int64(p.rows)}
+	for index := range p.chunks {
+		chunk := &p.chunks[index]
+		page := chunk.page()
+
+		var header thriftWriter
+		header.beginStruct()
+		header.i32Field(1, parquetDataPage)
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.structField(5)
+		header.i32Field(1, int32(len(chunk.present)))
+		header.i32Field(2, parquetPlain)
+		header.i32Field(3, parquetRLE)
+		header.i32Field(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+
+		meta := parquetChunkMeta{offset: p.offset, size: int64(len(header.buf) + len(page)), values: int64(len(chunk.present))}
+		p.write(header.buf)
+		p.write(page)
+
+		group.chunks = append(group.chunks, meta)
+		group.size += meta.size
+		*chunk = parquetChunk{present: chunk.present[:0], values: chunk.values[:0], bools: chunk.bools[:0]}
+	}
+
+	p.rowGroups = append(p.rowGroups, group)
+	p.totalRows += int64(p.rows)
+	p.rows = 0
+}
+
+func (column ParquetColumn) physicalType() int32 {
+	switch column.Type {
+	case ParquetInt64, ParquetTimestamp:
+		return parquetInt64
+	case ParquetBool:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// Close writes the remaining rows and the footer describing the file. It does not close the
+// underlying writer.
+func (p *ParquetWriter) Close() error {
+	p.flush()
+
+	var footer thriftWriter
+	footer.beginStruct()
+	footer.i32Field(1, 1)
+
+	footer.listField(2, thriftStruct, len(p.columns)+1)
+	footer.beginStruct()
+	footer.stringField(4, "schema")
+	footer.i32Field(5, int32(len(p.columns)))
+	footer.endStruct()
+	for _, column := range p.columns {
+		footer.beginStruct()
+		footer.i32Field(1, column.physicalType())
+		footer.i32Field(3, parquetOptional)
+		footer.stringField(4, column.Name)
+		switch column.Type {
+		case ParquetString:
+			footer.i32Field(6, parquetUTF8)
+		case ParquetTimestamp:
+			footer.i32Field(6, parquetTimestampMillis)
+		}
+		footer.endStruct()
+	}
+
+	footer.i64Field(3, p.totalRows)
+
+	footer.listField(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		footer.beginStruct()
+		footer.listField(1, thriftStruct, len(group.chunks))
+		for index, chunk := range group.chunks {
+			footer.beginStruct()
+			footer.i64Field(2, chunk.offset)
+			footer.structField(3)
+			footer.i32Field(1, p.columns[index].physicalType())
+			footer.listField(2, thriftI32, 2)
+			footer.zigzag(parquetPlain)
+			footer.zigzag(parquetRLE)
+			footer.listField(3, thriftBinary, 1)
+			footer.binary(p.columns[index].Name)
+			footer.i32Field(4, 0)
+			footer.i64Field(5, chunk.values)
+			footer.i64Field(6, chunk.size)
+			footer.i64Field(7, chunk.size)
+			footer.i64Field(9, chunk.offset)
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.i64Field(2, group.size)
+		footer.i64Field(3, group.rows)
+		footer.endStruct()
+	}
+
+	footer.stringField(6, "shortenurl")
+	footer.endStruct()
+
+	p.write(footer.buf)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer.buf))))
+	p.write(parquetMagic)
+
+	return p.err
+}
+
+// types of the thrift compact protocol, which Parquet metadata is encoded with
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the thrift compact protocol. Fields of a struct are
+// written in increasing order of their IDs.
+type thriftWriter struct {
+	buf []byte
+	// last is the ID of the previous field of the current struct, stack those of the outer ones
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.buf = binary.AppendUvarint(t.buf, uint64((v<<1)^(v>>63)))
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|kind)
+	} else {
+		t.buf = append(t.buf, kind)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) stringField(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) listField(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|kind)
+		return
+	}
+
+	t.buf = append(t.buf, 0xf0|kind)
+	t.buf = binary.AppendUvarint(t.buf, uint64(size))
+}
+
+// structField starts a struct field, endStruct ends it
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.beginStruct()
+}
+
+// beginStruct starts a struct that is not a field, like the top level struct or a list element
+func (t *thriftWriter) beginStruct() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf = append(t.buf, 0)
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
@@ -0,0 +1,188 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftReader decodes the thrift compact protocol into maps of field IDs, lists, int64s and strings
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(kind byte) any {
+	switch kind {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		length, n := binary.Uvarint(r.data[r.pos:])
+		r.pos += n
+		s := string(r.data[r.pos : r.pos+int(length)])
+		r.pos += int(length)
+		return s
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			length, n := binary.Uvarint(r.data[r.pos:])
+			r.pos += n
+			size = int(length)
+		}
+		list := []any{}
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	default:
+		fields := map[int16]any{}
+		var last int16
+		for {
+			header := r.data[r.pos]
+			r.pos++
+			if header == 0 {
+				return fields
+			}
+			if delta := int16(header >> 4); delta > 0 {
+				last += delta
+			} else {
+				last = int16(r.varint())
+			}
+			fields[last] = r.value(header & 0x0f)
+		}
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	columns := []ParquetColumn{
+		{"shortCode", ParquetString},
+		{"clicks", ParquetInt64},
+		{"fallback", ParquetBool},
+		{"createdAt", ParquetTimestamp},
+	}
+
+	var file bytes.Buffer
+	writer := NewParquetWriter(&file, columns, 2)
+	rows := [][]any{
+		{"a", int64(3), true, created},
+		{"b", 0, false, (*time.Time)(nil)},
+		{nil, int64(-1), true, &created},
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := file.Bytes()
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{data: data[len(data)-8-length : len(data)-8]}).value(thriftStruct).(map[int16]any)
+
+	assert.Equal(t, int64(3), footer[3])
+	schema := footer[2].([]any)
+	assert.Len(t, schema, 5)
+	assert.Equal(t, "createdAt", schema[4].(map[int16]any)[4])
+	assert.Equal(t, int64(parquetTimestampMillis), schema[4].(map[int16]any)[6])
+
+	groups := footer[4].([]any)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, int64(2), groups[0].(map[int16]any)[3])
+
+	// the short codes of the first row group
+	chunk := groups[0].(map[int16]any)[1].([]any)[0].(map[int16]any)[3].(map[int16]any)
+	reader := &thriftReader{data: data, pos: int(chunk[9].(int64))}
+	header := reader.value(thriftStruct).(map[int16]any)
+	assert.Equal(t, int64(2), header[5].(map[int16]any)[1])
+
+	page := data[reader.pos : reader.pos+int(header[2].(int64))]
+	levels := int(binary.LittleEndian.Uint32(page))
+	assert.Equal(t, []byte{4, 1}, page[4:4+levels])
+	assert.Equal(t, []byte{1, 0, 0, 0, 'a', 1, 0, 0, 0, 'b'}, page[4+levels:])
+
+	err := NewParquetWriter(&bytes.Buffer{}, columns, 2).Write([]any{1, 2, 3, 4})
+	assert.Error(t, err)
+}
+
+// parquetLink is a row of the file TestParquetWriter_ReadBack writes, as parquet-go reads it
+type parquetLink struct {
+	ShortCode *string    `parquet:"shortCode,optional"`
+	Clicks    *int64     `parquet:"clicks,optional"`
+	Fallback  *bool      `parquet:"fallback,optional"`
+	CreatedAt *time.Time `parquet:"createdAt,optional"`
+}
+
+func TestParquetWriter_ReadBack(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 250000000, time.UTC)
+	code, clicks, fallback := "a", int64(3), true
+
+	var file bytes.Buffer
+	writer := NewParquetWriter(&file, []ParquetColumn{
+		{"shortCode", ParquetString},
+		{"clicks", ParquetInt64},
+		{"fallback", ParquetBool},
+		{"createdAt", ParquetTimestamp},
+	}, 2)
+	rows := [][]any{
+		{code, clicks, fallback, created},
+		{"b", 0, false, (*time.Time)(nil)},
+		{nil, nil, nil, &created},
+		{"d", int64(-1), nil, nil},
+		{"e", 7, true, created.Add(time.Hour)},
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := parquet.OpenFile(bytes.NewReader(file.Bytes()), int64(file.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var groups []int64
+	for _, group := range opened.RowGroups() {
+		groups = append(groups, group.NumRows())
+	}
+	assert.Equal(t, []int64{2, 2, 1}, groups)
+
+	read := make([]parquetLink, len(rows))
+	n, err := parquet.NewGenericReader[parquetLink](opened).Read(read)
+	if n < len(rows) {
+		t.Fatalf("read %d of %d rows: %v", n, len(rows), err)
+	}
+
+	pointer := func(at time.Time) *time.Time { return &at }
+	b, d, e := "b", "d", "e"
+	zero, minusOne, seven := int64(0), int64(-1), int64(7)
+	no := false
+	assert.Equal(t, []parquetLink{
+		{ShortCode: &code, Clicks: &clicks, Fallback: &fallback, CreatedAt: pointer(created)},
+		{ShortCode: &b, Clicks: &zero, Fallback: &no},
+		{CreatedAt: pointer(created)},
+		{ShortCode: &d, Clicks: &minusOne},
+		{ShortCode: &e, Clicks: &seven, Fallback: &fallback, CreatedAt: pointer(created.Add(time.Hour))},
+	}, read[:n])
+}
@@ -0,0 +1,339 @@
+package util
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// HELLO WORLD at version 1-M, from the specification walkthrough
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+
+	ec := reedSolomonRemainder(data, reedSolomonDivisor(10))
+
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ec)
+}
+
+func TestEncodeQRCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		version int
+	}{
+		{"Version1", 14, 1},
+		{"Version2", 15, 2},
+		{"Version7", 120, 7},
+		{"Version10", 213, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := encodeQRCode([]byte(strings.Repeat("a", tt.length)))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version*4+17, code.size)
+
+			// finder pattern in the top left corner, separated from the data
+			assert.True(t, code.modules[0][0])
+			assert.False(t, code.modules[1][1])
+			assert.True(t, code.modules[3][3])
+			assert.False(t, code.modules[7][7])
+			// dark module next to the bottom left finder
+			assert.True(t, code.modules[code.size-8][8])
+		})
+	}
+
+	_, err := encodeQRCode([]byte(strings.Repeat("a", 214)))
+	assert.ErrorIs(t, err, ErrQRCodeTooLong)
+}
+
+func TestQRCodeSVG(t *testing.T) {
+	svg, err := QRCodeSVG("otpauth://totp/Shorten%20URL:jane@example.com?secret=JBSWY3DPEHPK3PXP", 4)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `width="180"`)
+}
+
+// qrFormatM are the format information strings of error correction level M by mask, from
+// table C.1 of ISO/IEC 18004
+var qrFormatM = []string{
+	"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+	"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+}
+
+// qrReferenceBlocks are the error correction blocks of level M, from table 9 of ISO/IEC 18004
+var qrReferenceBlocks = []struct {
+	ecPerBlock int
+	blocks     [][2]int // count and data codewords per block
+}{
+	{10, [][2]int{{1, 16}}},
+	{16, [][2]int{{1, 28}}},
+	{26, [][2]int{{1, 44}}},
+	{18, [][2]int{{2, 32}}},
+	{24, [][2]int{{2, 43}}},
+	{16, [][2]int{{4, 27}}},
+	{18, [][2]int{{4, 31}}},
+	{22, [][2]int{{2, 38}, {2, 39}}},
+	{22, [][2]int{{3, 36}, {2, 37}}},
+	{26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+// decodeQRCode reads a symbol the way a scanner does, independently of the encoder: it checks
+// the format and version information, removes the mask, reads the codewords, checks every
+// block with its Reed-Solomon syndromes and returns the content of the byte mode segment.
+func decodeQRCode(t *testing.T, modules [][]bool) []byte {
+	t.Helper()
+
+	size := len(modules)
+	version := (size - 17) / 4
+	if version < 1 || version > 10 || version*4+17 != size {
+		t.Fatalf("unexpected size %d", size)
+	}
+
+	dark := func(row int, col int) int {
+		if modules[row][col] {
+			return 1
+		}
+		return 0
+	}
+
+	// format information, most significant bit first: row 8 left of the timing pattern,
+	// then column 8 upwards, and the copy below and right of the other finders
+	var first, second strings.Builder
+	for col := 0; col <= 8; col++ {
+		if col != 6 {
+			first.WriteByte(byte('0' + dark(8, col)))
+		}
+	}
+	for row := 7; row >= 0; row-- {
+		if row != 6 {
+			first.WriteByte(byte('0' + dark(row, 8)))
+		}
+	}
+	for row := size - 1; row >= size-7; row-- {
+		second.WriteByte(byte('0' + dark(row, 8)))
+	}
+	for col := size - 8; col < size; col++ {
+		second.WriteByte(byte('0' + dark(8, col)))
+	}
+
+	format := first.String()
+	if format != second.String() {
+		t.Fatalf("format copies differ: %s and %s", format, second.String())
+	}
+	mask := slices.Index(qrFormatM, format)
+	if mask < 0 {
+		t.Fatalf("format %s is not level M", format)
+	}
+	if dark(size-8, 8) != 1 {
+		t.Fatal("dark module missing")
+	}
+
+	// function modules, which hold no data
+	function := make([][]bool, size)
+	for row := range function {
+		function[row] = make([]bool, size)
+	}
+	fill := func(row int, col int, height int, width int) {
+		for r := max(row, 0); r < min(row+height, size); r++ {
+			for c := max(col, 0); c < min(col+width, size); c++ {
+				function[r][c] = true
+			}
+		}
+	}
+	fill(0, 0, 9, 9)
+	fill(0, size-8, 9, 8)
+	fill(size-8, 0, 8, 9)
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	if version >= 2 {
+		step := map[int]int{2: 12, 3: 16, 4: 20, 5: 24, 6: 28, 7: 16, 8: 18, 9: 20, 10: 22}[version]
+		centers := []int{6}
+		for center := size - 7; center > 6; center -= step {
+			centers = append([]int{center}, centers...)
+		}
+		slices.Sort(centers)
+		for _, row := range centers {
+			for _, col := range centers {
+				// except where the finders are
+				if !(row < 9 && col < 9) && !(row < 9 && col > size-9) && !(row > size-9 && col < 9) {
+					fill(row-2, col-2, 5, 5)
+				}
+			}
+		}
+	}
+	if version >= 7 {
+		fill(0, size-11, 6, 3)
+		fill(size-11, 0, 3, 6)
+
+		bits := 0
+		for i := 17; i >= 0; i-- {
+			bits = bits<<1 | dark(i/3, size-11+i%3)
+			if dark(i/3, size-11+i%3) != dark(size-11+i%3, i/3) {
+				t.Fatal("version copies differ")
+			}
+		}
+		if bits>>12 != version {
+			t.Fatalf("version information %018b is not version %d", bits, version)
+		}
+	}
+
+	masks := []func(i int, j int) bool{
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return (i*j)%2+(i*j)%3 == 0 },
+		func(i, j int) bool { return ((i*j)%2+(i*j)%3)%2 == 0 },
+		func(i, j int) bool { return ((i+j)%2+(i*j)%3)%2 == 0 },
+	}
+
+	// codewords in two module wide columns from the bottom right, alternating upwards and
+	// downwards and skipping the vertical timing pattern
+	var bits []bool
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for step := 0; step < size; step++ {
+			row := step
+			if upward {
+				row = size - 1 - step
+			}
+			for _, col := range []int{right, right - 1} {
+				if !function[row][col] {
+					bits = append(bits, modules[row][col] != masks[mask](row, col))
+				}
+			}
+		}
+		upward = !upward
+	}
+
+	reference := qrReferenceBlocks[version-1]
+	var blocks [][]byte
+	for _, group := range reference.blocks {
+		for i := 0; i < group[0]; i++ {
+			blocks = append(blocks, make([]byte, 0, group[1]+reference.ecPerBlock))
+		}
+	}
+	dataLength := func(block int) int { return cap(blocks[block]) - reference.ecPerBlock }
+
+	codeword := func(index int) byte {
+		var value byte
+		for _, bit := range bits[index*8 : index*8+8] {
+			value <<= 1
+			if bit {
+				value |= 1
+			}
+		}
+		return value
+	}
+
+	// data codewords are interleaved across blocks, then the error correction codewords
+	index := 0
+	for column := 0; column < dataLength(len(blocks)-1); column++ {
+		for block := range blocks {
+			if column < dataLength(block) {
+				blocks[block] = append(blocks[block], codeword(index))
+				index++
+			}
+		}
+	}
+	for column := 0; column < reference.ecPerBlock; column++ {
+		for block := range blocks {
+			blocks[block] = append(blocks[block], codeword(index))
+			index++
+		}
+	}
+
+	// a block is a codeword of the Reed-Solomon code when it vanishes at the powers of α
+	var exp [255]int
+	exp[0] = 1
+	for i := 1; i < 255; i++ {
+		exp[i] = exp[i-1] << 1
+		if exp[i] >= 256 {
+			exp[i] ^= 0x11D
+		}
+	}
+	log := map[int]int{}
+	for i, value := range exp {
+		log[value] = i
+	}
+	for number, block := range blocks {
+		for root := 0; root < reference.ecPerBlock; root++ {
+			syndrome := 0
+			for _, value := range block {
+				if syndrome != 0 {
+					syndrome = exp[(log[syndrome]+root)%255]
+				}
+				syndrome ^= int(value)
+			}
+			if syndrome != 0 {
+				t.Fatalf("block %d fails syndrome %d", number, root)
+			}
+		}
+	}
+
+	var data []bool
+	for _, block := range blocks {
+		for _, value := range block[:len(block)-reference.ecPerBlock] {
+			for bit := 7; bit >= 0; bit-- {
+				data = append(data, value>>bit&1 == 1)
+			}
+		}
+	}
+	read := func(length int) int {
+		value := 0
+		for _, bit := range data[:length] {
+			value <<= 1
+			if bit {
+				value |= 1
+			}
+		}
+		data = data[length:]
+		return value
+	}
+
+	if mode := read(4); mode != 0b0100 {
+		t.Fatalf("unexpected mode %04b", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	content := make([]byte, read(countBits))
+	for i := range content {
+		content[i] = byte(read(8))
+	}
+
+	return content
+}
+
+func TestEncodeQRCode_RoundTrip(t *testing.T) {
+	contents := []string{
+		"HELLO WORLD",
+		"otpauth://totp/Shorten%20URL:jane@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Shorten%20URL",
+		strings.Repeat("a", 14),
+		strings.Repeat("b", 60),
+		strings.Repeat("c", 100),
+		strings.Repeat("d", 120),
+		strings.Repeat("e", 150),
+		strings.Repeat("f", 180),
+		strings.Repeat("g", 213),
+	}
+
+	for _, content := range contents {
+		code, err := encodeQRCode([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, content, string(decodeQRCode(t, code.modules)), "version %d", (code.size-17)/4)
+	}
+}